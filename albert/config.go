@@ -0,0 +1,192 @@
+package albert
+
+// albert package implements ALBERT transformer model.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/util"
+)
+
+// AlbertConfig defines the ALBERT model architecture (i.e., number of layers,
+// hidden layer size, label mapping...)
+//
+// Compared to BERT, ALBERT factorizes embeddings (`EmbeddingSize` is smaller than
+// `HiddenSize` and projected to it) and shares parameters across layers:
+// `NumHiddenLayers` layers are computed by `NumHiddenGroups` groups of
+// `InnerGroupNum` layers each.
+type AlbertConfig struct {
+	VocabSize                 int64            `json:"vocab_size"`
+	EmbeddingSize             int64            `json:"embedding_size"`
+	HiddenSize                int64            `json:"hidden_size"`
+	NumHiddenLayers           int64            `json:"num_hidden_layers"`
+	NumHiddenGroups           int64            `json:"num_hidden_groups"`
+	NumAttentionHeads         int64            `json:"num_attention_heads"`
+	IntermediateSize          int64            `json:"intermediate_size"`
+	InnerGroupNum             int64            `json:"inner_group_num"`
+	HiddenAct                 string           `json:"hidden_act"`
+	HiddenDropoutProb         float64          `json:"hidden_dropout_prob"`
+	AttentionProbsDropoutProb float64          `json:"attention_probs_dropout_prob"`
+	ClassifierDropoutProb     float64          `json:"classifier_dropout_prob"`
+	MaxPositionEmbeddings     int64            `json:"max_position_embeddings"`
+	TypeVocabSize             int64            `json:"type_vocab_size"`
+	InitializerRange          float32          `json:"initializer_range"`
+	LayerNormEps              float64          `json:"layer_norm_eps"`
+	PadTokenId                int64            `json:"pad_token_id"`
+	BosTokenId                int64            `json:"bos_token_id"`
+	EosTokenId                int64            `json:"eos_token_id"`
+	OutputAttentions          bool             `json:"output_attentions"`
+	OutputHiddenStates        bool             `json:"output_hidden_states"`
+	Id2Label                  map[int64]string `json:"id2label"`
+	Label2Id                  map[string]int64 `json:"label2id"`
+	NumLabels                 int64            `json:"num_labels"`
+}
+
+// NewConfig initiates AlbertConfig with given input parameters or default values
+// of `albert-base-v2`.
+func NewConfig(customParams map[string]interface{}) *AlbertConfig {
+	defaultValues := map[string]interface{}{
+		"VocabSize":                 int64(30000),
+		"EmbeddingSize":             int64(128),
+		"HiddenSize":                int64(768),
+		"NumHiddenLayers":           int64(12),
+		"NumHiddenGroups":           int64(1),
+		"NumAttentionHeads":         int64(12),
+		"IntermediateSize":          int64(3072),
+		"InnerGroupNum":             int64(1),
+		"HiddenAct":                 "gelu_new",
+		"HiddenDropoutProb":         float64(0),
+		"AttentionProbsDropoutProb": float64(0),
+		"ClassifierDropoutProb":     float64(0.1),
+		"MaxPositionEmbeddings":     int64(512),
+		"TypeVocabSize":             int64(2),
+		"InitializerRange":          float32(0.02),
+		"LayerNormEps":              float64(1e-12),
+		"PadTokenId":                int64(0),
+		"BosTokenId":                int64(2),
+		"EosTokenId":                int64(3),
+	}
+
+	params := defaultValues
+	for k, v := range customParams {
+		if _, ok := params[k]; ok {
+			params[k] = v
+		}
+	}
+
+	config := new(AlbertConfig)
+	config.updateParams(params)
+
+	return config
+}
+
+// ConfigFromFile loads AlbertConfig from a JSON file.
+func ConfigFromFile(filename string) (*AlbertConfig, error) {
+	config := new(AlbertConfig)
+	if err := config.fromFile(filename); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Load loads model configuration from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Config` interface.
+func (c *AlbertConfig) Load(modelNameOrPath string, params map[string]interface{}) error {
+	err := c.fromFile(modelNameOrPath)
+	if err != nil {
+		return err
+	}
+
+	// Update custom parameters
+	c.updateParams(params)
+
+	return nil
+}
+
+// SavePretrained saves configuration to directory `dir` as `config.json` file.
+// This method implements `pretrained.Config` interface.
+func (c *AlbertConfig) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buff, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, util.ConfigName), buff, 0644)
+}
+
+func (c *AlbertConfig) fromFile(filename string) error {
+	filePath, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	buff, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(buff, c)
+	if err != nil {
+		return fmt.Errorf("Could not parse configuration to AlbertConfig: %v: %w", err, util.ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// GetVocabSize returns vocabulary size.
+func (c *AlbertConfig) GetVocabSize() int64 {
+	return c.VocabSize
+}
+
+// numLabels returns number of labels for classification heads. It is taken from
+// `NumLabels` if set, otherwise from the label mapping.
+func (c *AlbertConfig) numLabels() int64 {
+	if c.NumLabels > 0 {
+		return c.NumLabels
+	}
+	return int64(len(c.Id2Label))
+}
+
+// bertConfig returns a BertConfig for layers shared with BERT. Embeddings
+// operate on `EmbeddingSize` and attention on `HiddenSize`.
+func (c *AlbertConfig) bertConfig(hiddenSize int64) *bert.BertConfig {
+	return &bert.BertConfig{
+		VocabSize:                 c.VocabSize,
+		HiddenSize:                hiddenSize,
+		NumAttentionHeads:         c.NumAttentionHeads,
+		HiddenDropoutProb:         c.HiddenDropoutProb,
+		AttentionProbsDropoutProb: c.AttentionProbsDropoutProb,
+		MaxPositionEmbeddings:     c.MaxPositionEmbeddings,
+		TypeVocabSize:             c.TypeVocabSize,
+		OutputAttentions:          c.OutputAttentions,
+		OutputHiddenStates:        c.OutputHiddenStates,
+	}
+}
+
+func (c *AlbertConfig) updateParams(params map[string]interface{}) {
+	for k, v := range params {
+		c.updateField(k, v)
+	}
+}
+
+func (c *AlbertConfig) updateField(field string, value interface{}) {
+	// Check whether field name exists
+	if reflect.ValueOf(c).Elem().FieldByName(field).IsValid() {
+		// Check whether same type
+		if reflect.ValueOf(c).Elem().FieldByName(field).Kind() == reflect.TypeOf(value).Kind() {
+			reflect.ValueOf(c).Elem().FieldByName(field).Set(reflect.ValueOf(value))
+		}
+	}
+}
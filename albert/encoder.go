@@ -0,0 +1,230 @@
+package albert
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/util"
+)
+
+// AlbertAttention:
+// ================
+
+// AlbertAttention is BERT self-attention followed by an output projection
+// (`dense`) and a residual layer norm. Query, key and value projections are
+// `bert.BertSelfAttention` layers.
+type AlbertAttention struct {
+	SelfAttention *bert.BertSelfAttention
+	Dense         *nn.Linear
+	LayerNorm     *nn.LayerNorm
+	Dropout       *util.Dropout
+}
+
+// NewAlbertAttention creates a new AlbertAttention.
+func NewAlbertAttention(p *nn.Path, config *AlbertConfig) (*AlbertAttention, error) {
+	if config.HiddenSize%config.NumAttentionHeads != 0 {
+		return nil, fmt.Errorf("Hidden size (%v) is not a multiple of the number of attention heads (%v): %w", config.HiddenSize, config.NumAttentionHeads, util.ErrShapeMismatch)
+	}
+
+	selfAttention, err := bert.NewBertSelfAttention(p, config.bertConfig(config.HiddenSize))
+	if err != nil {
+		return nil, err
+	}
+	dense := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.HiddenSize, nn.DefaultLinearConfig())
+
+	lnConfig := nn.DefaultLayerNormConfig()
+	lnConfig.Eps = config.LayerNormEps
+	layerNorm := nn.NewLayerNorm(p.Sub("LayerNorm"), []int64{config.HiddenSize}, lnConfig)
+
+	return &AlbertAttention{selfAttention, dense, layerNorm, util.NewDropout(config.HiddenDropoutProb)}, nil
+}
+
+// ForwardT forwards pass through the attention layer. `mask` is an additive
+// attention mask of shape (batch size, 1, 1, sequence length) or `ts.None`.
+func (a *AlbertAttention) ForwardT(hiddenStates, mask *ts.Tensor, train bool) (retVal, retValOpt *ts.Tensor) {
+	context, attentionWeights := a.SelfAttention.ForwardT(hiddenStates, mask, ts.None, ts.None, train)
+
+	projected := context.Apply(a.Dense)
+	context.MustDrop()
+	dropped := projected.ApplyT(a.Dropout, train)
+	projected.MustDrop()
+	residual := dropped.MustAdd(hiddenStates, true)
+	retVal = residual.Apply(a.LayerNorm)
+	residual.MustDrop()
+
+	return retVal, attentionWeights
+}
+
+// AlbertLayer:
+// ============
+
+// AlbertLayer is a single ALBERT layer: attention followed by a feed forward
+// network (`ffn`, `ffn_output`) and a residual layer norm.
+type AlbertLayer struct {
+	Attention          *AlbertAttention
+	Ffn                *nn.Linear
+	FfnOutput          *nn.Linear
+	Activation         util.ActivationFn
+	FullLayerLayerNorm *nn.LayerNorm
+}
+
+// NewAlbertLayer creates a new AlbertLayer.
+func NewAlbertLayer(p *nn.Path, config *AlbertConfig) (*AlbertLayer, error) {
+	attention, err := NewAlbertAttention(p.Sub("attention"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	activation, ok := util.ActivationFnMap[config.HiddenAct]
+	if !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.HiddenAct)
+	}
+
+	ffn := nn.NewLinear(p.Sub("ffn"), config.HiddenSize, config.IntermediateSize, nn.DefaultLinearConfig())
+	ffnOutput := nn.NewLinear(p.Sub("ffn_output"), config.IntermediateSize, config.HiddenSize, nn.DefaultLinearConfig())
+
+	lnConfig := nn.DefaultLayerNormConfig()
+	lnConfig.Eps = config.LayerNormEps
+	fullLayerLayerNorm := nn.NewLayerNorm(p.Sub("full_layer_layer_norm"), []int64{config.HiddenSize}, lnConfig)
+
+	return &AlbertLayer{attention, ffn, ffnOutput, activation, fullLayerLayerNorm}, nil
+}
+
+// ForwardT forwards pass through the layer.
+func (l *AlbertLayer) ForwardT(hiddenStates, mask *ts.Tensor, train bool) (retVal, retValOpt *ts.Tensor) {
+	attentionOutput, attentionWeights := l.Attention.ForwardT(hiddenStates, mask, train)
+
+	x1 := attentionOutput.Apply(l.Ffn)
+	x2 := l.Activation.Fwd(x1)
+	x1.MustDrop()
+	x3 := x2.Apply(l.FfnOutput)
+	x2.MustDrop()
+	residual := x3.MustAdd(attentionOutput, true)
+	attentionOutput.MustDrop()
+	retVal = residual.Apply(l.FullLayerLayerNorm)
+	residual.MustDrop()
+
+	return retVal, attentionWeights
+}
+
+// AlbertLayerGroup:
+// =================
+
+// AlbertLayerGroup is a group of `InnerGroupNum` layers whose parameters are
+// shared by all layers of the encoder mapped to the group.
+type AlbertLayerGroup struct {
+	Layers []*AlbertLayer
+}
+
+// NewAlbertLayerGroup creates a new AlbertLayerGroup.
+func NewAlbertLayerGroup(p *nn.Path, config *AlbertConfig) (*AlbertLayerGroup, error) {
+	path := p.Sub("albert_layers")
+	var layers []*AlbertLayer
+	for i := 0; i < int(config.InnerGroupNum); i++ {
+		layer, err := NewAlbertLayer(path.Sub(fmt.Sprintf("%v", i)), config)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	return &AlbertLayerGroup{layers}, nil
+}
+
+// ForwardT forwards pass through all layers of the group. It returns the last
+// hidden state and attention weights of every layer (nil slice if `outputAttentions` is false).
+func (g *AlbertLayerGroup) ForwardT(hiddenStates, mask *ts.Tensor, outputAttentions, train bool) (retVal *ts.Tensor, retValOpt []ts.Tensor) {
+	var attentions []ts.Tensor
+	hiddenState := hiddenStates
+	for i, layer := range g.Layers {
+		stateTmp, attentionWeights := layer.ForwardT(hiddenState, mask, train)
+		if i > 0 {
+			hiddenState.MustDrop()
+		}
+		hiddenState = stateTmp
+
+		if outputAttentions {
+			attentions = append(attentions, *attentionWeights)
+		} else if attentionWeights.MustDefined() {
+			attentionWeights.MustDrop()
+		}
+	}
+
+	return hiddenState, attentions
+}
+
+// AlbertTransformer:
+// ==================
+
+// AlbertTransformer projects embeddings to hidden size (`embedding_hidden_mapping_in`)
+// then runs `NumHiddenLayers` layers, each mapped to one of `NumHiddenGroups`
+// shared layer groups.
+type AlbertTransformer struct {
+	EmbeddingHiddenMappingIn *nn.Linear
+	LayerGroups              []*AlbertLayerGroup
+	NumHiddenLayers          int64
+	NumHiddenGroups          int64
+	OutputAttentions         bool
+	OutputHiddenStates       bool
+}
+
+// NewAlbertTransformer creates a new AlbertTransformer.
+func NewAlbertTransformer(p *nn.Path, config *AlbertConfig) (*AlbertTransformer, error) {
+	if config.NumHiddenGroups <= 0 || config.NumHiddenLayers%config.NumHiddenGroups != 0 {
+		return nil, fmt.Errorf("Number of hidden layers (%v) must be a multiple of number of hidden groups (%v): %w", config.NumHiddenLayers, config.NumHiddenGroups, util.ErrInvalidConfig)
+	}
+
+	mappingIn := nn.NewLinear(p.Sub("embedding_hidden_mapping_in"), config.EmbeddingSize, config.HiddenSize, nn.DefaultLinearConfig())
+
+	path := p.Sub("albert_layer_groups")
+	var groups []*AlbertLayerGroup
+	for i := 0; i < int(config.NumHiddenGroups); i++ {
+		group, err := NewAlbertLayerGroup(path.Sub(fmt.Sprintf("%v", i)), config)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	return &AlbertTransformer{
+		EmbeddingHiddenMappingIn: mappingIn,
+		LayerGroups:              groups,
+		NumHiddenLayers:          config.NumHiddenLayers,
+		NumHiddenGroups:          config.NumHiddenGroups,
+		OutputAttentions:         config.OutputAttentions,
+		OutputHiddenStates:       config.OutputHiddenStates,
+	}, nil
+}
+
+// ForwardT forwards pass through the transformer.
+//
+// Returns:
+//   - last hidden state of shape (batch size, sequence length, hidden size)
+//   - input hidden state of every layer if `OutputHiddenStates` is set
+//   - attention weights of every inner layer if `OutputAttentions` is set
+func (t *AlbertTransformer) ForwardT(embeddings, mask *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor) {
+	var (
+		allHiddenStates []ts.Tensor
+		allAttentions   []ts.Tensor
+	)
+
+	hiddenState := embeddings.Apply(t.EmbeddingHiddenMappingIn)
+	layersPerGroup := t.NumHiddenLayers / t.NumHiddenGroups
+	for i := int64(0); i < t.NumHiddenLayers; i++ {
+		if t.OutputHiddenStates {
+			allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
+		}
+
+		group := t.LayerGroups[i/layersPerGroup]
+		stateTmp, attentions := group.ForwardT(hiddenState, mask, t.OutputAttentions, train)
+		hiddenState.MustDrop()
+		hiddenState = stateTmp
+
+		allAttentions = append(allAttentions, attentions...)
+	}
+
+	return hiddenState, allHiddenStates, allAttentions
+}
@@ -0,0 +1,608 @@
+package albert
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/util"
+)
+
+// AlbertModel:
+// ============
+
+// AlbertModel defines base architecture for ALBERT models.
+// Task-specific models can be built from this base model.
+//
+// Fields:
+//   - Embeddings: `token`, `position` and `segment` embeddings of size `EmbeddingSize`
+//   - Encoder: projection to `HiddenSize` and shared layer groups
+//   - Pooler: linear layer applied to the first element of the sequence
+type AlbertModel struct {
+	Embeddings *bert.BertEmbeddings
+	Encoder    *AlbertTransformer
+	Pooler     *nn.Linear
+}
+
+// NewAlbertModel builds a new `AlbertModel`.
+//
+// Params:
+//   - `p`: Variable store path for the root of the ALBERT model
+//   - `config`: AlbertConfig configuration for model architecture
+func NewAlbertModel(p *nn.Path, config *AlbertConfig) (*AlbertModel, error) {
+	embeddings := bert.NewBertEmbeddings(p.Sub("embeddings"), config.bertConfig(config.EmbeddingSize), false)
+	encoder, err := NewAlbertTransformer(p.Sub("encoder"), config)
+	if err != nil {
+		return nil, err
+	}
+	pooler := nn.NewLinear(p.Sub("pooler"), config.HiddenSize, config.HiddenSize, nn.DefaultLinearConfig())
+
+	return &AlbertModel{embeddings, encoder, pooler}, nil
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `mask`: optional mask of shape (batch size, sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `tokenTypeIds`: optional segment id of shape (batch size, sequence length).
+//     Convention is value of 0 for the first sentence (incl. [SEP]) and 1 for the second sentence. If None set to 0.
+//   - `positionIds`: optional position ids of shape (batch size, sequence length).
+//     If None, will be incremented from 0.
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, embedding size).
+//     If None, input ids must be provided (see `inputIds`).
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, hidden size)
+//   - `pooledOutput`: tensor of shape (batch size, hidden size)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of tensors of length numHiddenLayers * innerGroupNum with shape (batch size, heads, sequence length, sequence length)
+func (m *AlbertModel) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal1, retVal2 *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	var (
+		inputShape []int64
+		device     gotch.Device
+	)
+
+	switch {
+	case inputIds.MustDefined() && inputEmbeds.MustDefined():
+		err = fmt.Errorf("Only one of input ids or input embeddings may be set: %w", util.ErrInvalidInput)
+		return
+	case inputIds.MustDefined():
+		inputShape = inputIds.MustSize()
+		device = inputIds.MustDevice()
+	case inputEmbeds.MustDefined():
+		size := inputEmbeds.MustSize()
+		inputShape = []int64{size[0], size[1]}
+		device = inputEmbeds.MustDevice()
+	default:
+		err = fmt.Errorf("At least one of input ids or input embeddings must be set: %w", util.ErrInvalidInput)
+		return
+	}
+
+	// Additive mask of shape (batch size, 1, 1, sequence length): 0 to attend, -10000 to mask.
+	var maskTs *ts.Tensor
+	if mask.MustDefined() {
+		maskTs = mask.MustShallowClone()
+	} else {
+		maskTs = ts.MustOnes(inputShape, gotch.Int64, device)
+	}
+	extendedMask := maskTs.MustUnsqueeze(1, true).MustUnsqueeze(2, true).MustTotype(gotch.Float, true)
+	extendedAttnMask := extendedMask.MustOnesLike(false).MustSub(extendedMask, true).MustMulScalar(ts.FloatScalar(-10000.0), true)
+	extendedMask.MustDrop()
+
+	embeddingOutput, err := m.Embeddings.ForwardT(inputIds, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		extendedAttnMask.MustDrop()
+		return
+	}
+
+	hiddenState, allHiddenStates, allAttentions := m.Encoder.ForwardT(embeddingOutput, extendedAttnMask, train)
+	embeddingOutput.MustDrop()
+	extendedAttnMask.MustDrop()
+
+	first := hiddenState.MustSelect(1, 0, false)
+	pooledOutput := first.Apply(m.Pooler).MustTanh(true)
+	first.MustDrop()
+
+	return hiddenState, pooledOutput, allHiddenStates, allAttentions, nil
+}
+
+// AlbertMLMHead:
+// ==============
+
+// AlbertMLMHead predicts masked tokens. Hidden states are projected back to
+// `EmbeddingSize` before decoding to vocabulary.
+type AlbertMLMHead struct {
+	Dense      *nn.Linear
+	Activation util.ActivationFn
+	LayerNorm  *nn.LayerNorm
+	Decoder    *util.LinearNoBias
+	Bias       *ts.Tensor
+}
+
+// NewAlbertMLMHead creates AlbertMLMHead.
+func NewAlbertMLMHead(p *nn.Path, config *AlbertConfig) (*AlbertMLMHead, error) {
+	activation, ok := util.ActivationFnMap[config.HiddenAct]
+	if !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.HiddenAct)
+	}
+
+	dense := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.EmbeddingSize, nn.DefaultLinearConfig())
+
+	lnConfig := nn.DefaultLayerNormConfig()
+	lnConfig.Eps = config.LayerNormEps
+	layerNorm := nn.NewLayerNorm(p.Sub("LayerNorm"), []int64{config.EmbeddingSize}, lnConfig)
+
+	decoder, err := util.NewLinearNoBias(p.Sub("decoder"), config.EmbeddingSize, config.VocabSize, util.DefaultLinearNoBiasConfig())
+	if err != nil {
+		return nil, err
+	}
+	bias, err := p.NewVar("bias", []int64{config.VocabSize}, nn.NewKaimingUniformInit())
+	if err != nil {
+		return nil, err
+	}
+
+	return &AlbertMLMHead{dense, activation, layerNorm, decoder, bias}, nil
+}
+
+// Forward forwards pass through the head.
+func (h *AlbertMLMHead) Forward(hiddenStates *ts.Tensor) *ts.Tensor {
+	x1 := hiddenStates.Apply(h.Dense)
+	x2 := h.Activation.Fwd(x1)
+	x1.MustDrop()
+	x3 := x2.Apply(h.LayerNorm)
+	x2.MustDrop()
+	x4 := x3.Apply(h.Decoder)
+	x3.MustDrop()
+
+	return x4.MustAdd(h.Bias, true)
+}
+
+// AlbertSOPHead:
+// ==============
+
+// AlbertSOPHead predicts whether two segments are in the original order
+// (sentence order prediction). It is applied to the pooled output.
+type AlbertSOPHead struct {
+	Dropout    *util.Dropout
+	Classifier *nn.Linear
+}
+
+// NewAlbertSOPHead creates AlbertSOPHead.
+func NewAlbertSOPHead(p *nn.Path, config *AlbertConfig) *AlbertSOPHead {
+	classifier := nn.NewLinear(p.Sub("classifier"), config.HiddenSize, 2, nn.DefaultLinearConfig())
+	return &AlbertSOPHead{util.NewDropout(config.ClassifierDropoutProb), classifier}
+}
+
+// ForwardT forwards pass through the head.
+func (h *AlbertSOPHead) ForwardT(pooledOutput *ts.Tensor, train bool) *ts.Tensor {
+	x := pooledOutput.ApplyT(h.Dropout, train)
+	retVal := x.Apply(h.Classifier)
+	x.MustDrop()
+
+	return retVal
+}
+
+// AlbertForMaskedLM:
+// ==================
+
+// mlmTiedWeights are masked LM head weights tied to other tensors, which weight files may
+// not store: the decoder shares word embeddings and its bias is the head bias.
+var mlmTiedWeights = map[string]string{
+	"predictions.decoder.weight": "albert.embeddings.word_embeddings.weight",
+	"predictions.bias":           "predictions.decoder.bias",
+}
+
+// AlbertForMaskedLM is ALBERT for masked language model.
+//
+// It is made of the following blocks:
+//   - `albert`: Base AlbertModel
+//   - `predictions`: AlbertMLMHead
+type AlbertForMaskedLM struct {
+	albert      *AlbertModel
+	predictions *AlbertMLMHead
+	config      *AlbertConfig
+	vs          *nn.VarStore
+}
+
+// NewAlbertForMaskedLM creates AlbertForMaskedLM.
+func NewAlbertForMaskedLM(p *nn.Path, config *AlbertConfig) (*AlbertForMaskedLM, error) {
+	albert, err := NewAlbertModel(p.Sub("albert"), config)
+	if err != nil {
+		return nil, err
+	}
+	predictions, err := NewAlbertMLMHead(p.Sub("predictions"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AlbertForMaskedLM{
+		albert:      albert,
+		predictions: predictions,
+		config:      config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (mlm *AlbertForMaskedLM) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	albertConfig, ok := config.(*AlbertConfig)
+	if !ok {
+		return nil, fmt.Errorf("AlbertForMaskedLM.Load() failed: invalid config type %T, want *albert.AlbertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewAlbertForMaskedLM(vs.Root(), albertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*mlm = *model
+	mlm.vs = vs
+
+	opts := util.LoadOptionsFromParams(params, "predictions")
+	opts.Tied = mlmTiedWeights
+
+	return util.LoadVarStore(vs, modelNameOrPath, opts, util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (mlm *AlbertForMaskedLM) SavePretrained(dir string) error {
+	return util.SaveModel(dir, mlm.config, mlm.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (mlm *AlbertForMaskedLM) VarStore() *nn.VarStore {
+	return mlm.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (mlm *AlbertForMaskedLM) SetVarStore(vs *nn.VarStore) {
+	mlm.vs = vs
+}
+
+// Config returns model configuration.
+func (mlm *AlbertForMaskedLM) Config() *AlbertConfig {
+	return mlm.config
+}
+
+// ForwardT forwards pass through the model. See `AlbertModel.ForwardT` for params.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, vocab size)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of attention weights tensors
+func (mlm *AlbertForMaskedLM) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, pooledOutput, allHiddenStates, allAttentions, err := mlm.albert.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pooledOutput.MustDrop()
+
+	retVal = mlm.predictions.Forward(hiddenState)
+	hiddenState.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
+
+// AlbertForPreTraining:
+// =====================
+
+// AlbertForPreTraining is ALBERT with both pre-training heads: masked language
+// model and sentence order prediction (SOP).
+//
+// It is made of the following blocks:
+//   - `albert`: Base AlbertModel
+//   - `predictions`: AlbertMLMHead
+//   - `sop_classifier`: AlbertSOPHead
+type AlbertForPreTraining struct {
+	albert        *AlbertModel
+	predictions   *AlbertMLMHead
+	sopClassifier *AlbertSOPHead
+	config        *AlbertConfig
+	vs            *nn.VarStore
+}
+
+// NewAlbertForPreTraining creates AlbertForPreTraining.
+func NewAlbertForPreTraining(p *nn.Path, config *AlbertConfig) (*AlbertForPreTraining, error) {
+	albert, err := NewAlbertModel(p.Sub("albert"), config)
+	if err != nil {
+		return nil, err
+	}
+	predictions, err := NewAlbertMLMHead(p.Sub("predictions"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AlbertForPreTraining{
+		albert:        albert,
+		predictions:   predictions,
+		sopClassifier: NewAlbertSOPHead(p.Sub("sop_classifier"), config),
+		config:        config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (pt *AlbertForPreTraining) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	albertConfig, ok := config.(*AlbertConfig)
+	if !ok {
+		return nil, fmt.Errorf("AlbertForPreTraining.Load() failed: invalid config type %T, want *albert.AlbertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewAlbertForPreTraining(vs.Root(), albertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*pt = *model
+	pt.vs = vs
+
+	opts := util.LoadOptionsFromParams(params, "predictions", "sop_classifier")
+	opts.Tied = mlmTiedWeights
+
+	return util.LoadVarStore(vs, modelNameOrPath, opts, util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (pt *AlbertForPreTraining) SavePretrained(dir string) error {
+	return util.SaveModel(dir, pt.config, pt.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (pt *AlbertForPreTraining) VarStore() *nn.VarStore {
+	return pt.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (pt *AlbertForPreTraining) SetVarStore(vs *nn.VarStore) {
+	pt.vs = vs
+}
+
+// Config returns model configuration.
+func (pt *AlbertForPreTraining) Config() *AlbertConfig {
+	return pt.config
+}
+
+// ForwardT forwards pass through the model. See `AlbertModel.ForwardT` for params.
+//
+// Returns:
+//   - `predictionLogits`: tensor of shape (batch size, sequence length, vocab size)
+//   - `sopLogits`: tensor of shape (batch size, 2)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of attention weights tensors
+func (pt *AlbertForPreTraining) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal1, retVal2 *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, pooledOutput, allHiddenStates, allAttentions, err := pt.albert.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	predictionLogits := pt.predictions.Forward(hiddenState)
+	hiddenState.MustDrop()
+	sopLogits := pt.sopClassifier.ForwardT(pooledOutput, train)
+	pooledOutput.MustDrop()
+
+	return predictionLogits, sopLogits, allHiddenStates, allAttentions, nil
+}
+
+// AlbertForSequenceClassification:
+// ================================
+
+// AlbertForSequenceClassification is ALBERT for sequence classification.
+//
+// It is made of the following blocks:
+//   - `albert`: Base AlbertModel
+//   - `classifier`: linear layer applied to the pooled output
+type AlbertForSequenceClassification struct {
+	albert     *AlbertModel
+	dropout    *util.Dropout
+	classifier *nn.Linear
+	config     *AlbertConfig
+	vs         *nn.VarStore
+}
+
+// NewAlbertForSequenceClassification creates a new AlbertForSequenceClassification.
+//
+// NOTE. Number of labels is taken from `config.NumLabels` or, if not set, from `config.Id2Label`.
+func NewAlbertForSequenceClassification(p *nn.Path, config *AlbertConfig) (*AlbertForSequenceClassification, error) {
+	albert, err := NewAlbertModel(p.Sub("albert"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	numLabels := config.numLabels()
+	if numLabels == 0 {
+		return nil, fmt.Errorf("Number of labels must be set in config (`num_labels` or `id2label`): %w", util.ErrInvalidConfig)
+	}
+
+	return &AlbertForSequenceClassification{
+		albert:     albert,
+		dropout:    util.NewDropout(config.ClassifierDropoutProb),
+		classifier: nn.NewLinear(p.Sub("classifier"), config.HiddenSize, numLabels, nn.DefaultLinearConfig()),
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (sc *AlbertForSequenceClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	albertConfig, ok := config.(*AlbertConfig)
+	if !ok {
+		return nil, fmt.Errorf("AlbertForSequenceClassification.Load() failed: invalid config type %T, want *albert.AlbertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewAlbertForSequenceClassification(vs.Root(), albertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*sc = *model
+	sc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (sc *AlbertForSequenceClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, sc.config, sc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (sc *AlbertForSequenceClassification) VarStore() *nn.VarStore {
+	return sc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (sc *AlbertForSequenceClassification) SetVarStore(vs *nn.VarStore) {
+	sc.vs = vs
+}
+
+// Config returns model configuration.
+func (sc *AlbertForSequenceClassification) Config() *AlbertConfig {
+	return sc.config
+}
+
+// ForwardT forwards pass through the model. See `AlbertModel.ForwardT` for params.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, num labels)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of attention weights tensors
+func (sc *AlbertForSequenceClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, pooledOutput, allHiddenStates, allAttentions, err := sc.albert.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hiddenState.MustDrop()
+
+	x := pooledOutput.ApplyT(sc.dropout, train)
+	pooledOutput.MustDrop()
+	retVal = x.Apply(sc.classifier)
+	x.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
+
+// AlbertForTokenClassification:
+// =============================
+
+// AlbertForTokenClassification is ALBERT for token classification (e.g., NER, POS).
+//
+// It is made of the following blocks:
+//   - `albert`: Base AlbertModel
+//   - `classifier`: linear layer for token classification
+type AlbertForTokenClassification struct {
+	albert     *AlbertModel
+	dropout    *util.Dropout
+	classifier *nn.Linear
+	config     *AlbertConfig
+	vs         *nn.VarStore
+}
+
+// NewAlbertForTokenClassification creates a new AlbertForTokenClassification.
+//
+// NOTE. Number of labels is taken from `config.NumLabels` or, if not set, from `config.Id2Label`.
+func NewAlbertForTokenClassification(p *nn.Path, config *AlbertConfig) (*AlbertForTokenClassification, error) {
+	albert, err := NewAlbertModel(p.Sub("albert"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	numLabels := config.numLabels()
+	if numLabels == 0 {
+		return nil, fmt.Errorf("Number of labels must be set in config (`num_labels` or `id2label`): %w", util.ErrInvalidConfig)
+	}
+
+	return &AlbertForTokenClassification{
+		albert:     albert,
+		dropout:    util.NewDropout(config.HiddenDropoutProb),
+		classifier: nn.NewLinear(p.Sub("classifier"), config.HiddenSize, numLabels, nn.DefaultLinearConfig()),
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (tc *AlbertForTokenClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	albertConfig, ok := config.(*AlbertConfig)
+	if !ok {
+		return nil, fmt.Errorf("AlbertForTokenClassification.Load() failed: invalid config type %T, want *albert.AlbertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewAlbertForTokenClassification(vs.Root(), albertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*tc = *model
+	tc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (tc *AlbertForTokenClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, tc.config, tc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (tc *AlbertForTokenClassification) VarStore() *nn.VarStore {
+	return tc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (tc *AlbertForTokenClassification) SetVarStore(vs *nn.VarStore) {
+	tc.vs = vs
+}
+
+// Config returns model configuration.
+func (tc *AlbertForTokenClassification) Config() *AlbertConfig {
+	return tc.config
+}
+
+// ForwardT forwards pass through the model. See `AlbertModel.ForwardT` for params.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, num labels)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of attention weights tensors
+func (tc *AlbertForTokenClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, pooledOutput, allHiddenStates, allAttentions, err := tc.albert.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pooledOutput.MustDrop()
+
+	x := hiddenState.ApplyT(tc.dropout, train)
+	hiddenState.MustDrop()
+	retVal = x.Apply(tc.classifier)
+	x.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
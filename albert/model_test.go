@@ -0,0 +1,96 @@
+package albert_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/albert"
+)
+
+// All layers of a group share the same weights, so the number of variables does not
+// depend on the number of hidden layers.
+func TestAlbertModel_SharedLayers(t *testing.T) {
+	newConfig := func(numHiddenLayers int64) *albert.AlbertConfig {
+		return albert.NewConfig(map[string]interface{}{
+			"VocabSize":             int64(50),
+			"EmbeddingSize":         int64(8),
+			"HiddenSize":            int64(16),
+			"NumHiddenLayers":       numHiddenLayers,
+			"NumAttentionHeads":     int64(2),
+			"IntermediateSize":      int64(32),
+			"MaxPositionEmbeddings": int64(32),
+		})
+	}
+
+	vs4 := nn.NewVarStore(gotch.CPU)
+	if _, err := albert.NewAlbertModel(vs4.Root(), newConfig(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	config := newConfig(12)
+	vs12 := nn.NewVarStore(gotch.CPU)
+	if _, err := albert.NewAlbertModel(vs12.Root(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	if vs4.Len() != vs12.Len() {
+		t.Errorf("Want: %v\n", vs4.Len())
+		t.Errorf("Got: %v\n", vs12.Len())
+	}
+
+	config.NumHiddenGroups = 5
+	if _, err := albert.NewAlbertModel(nn.NewVarStore(gotch.CPU).Root(), config); err == nil {
+		t.Errorf("Want error for number of layers not a multiple of number of groups\n")
+	}
+}
+
+func TestAlbertForMaskedLM(t *testing.T) {
+	modelName := "albert-base-v2"
+	config := new(albert.AlbertConfig)
+	if err := transformer.LoadConfig(config, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+	model := new(albert.AlbertForMaskedLM)
+	if _, err := transformer.LoadModel(model, modelName, config, nil, gotch.CPU); err != nil {
+		t.Fatal(err)
+	}
+	tk := albert.NewTokenizer()
+	if err := transformer.LoadTokenizer(tk, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	encoding, err := tk.EncodeSingle("The capital of France is [MASK].", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	var maskPos int
+	for i, id := range encoding.Ids {
+		ids = append(ids, int64(id))
+		if encoding.Tokens[i] == "[MASK]" {
+			maskPos = i
+		}
+	}
+	inputIds := ts.MustOfSlice(ids).MustView([]int64{1, -1}, true)
+
+	var logits *ts.Tensor
+	ts.NoGrad(func() {
+		logits, _, _, err = model.ForwardT(inputIds, ts.None, ts.None, ts.None, ts.None, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := logits.MustGet(0).MustGet(maskPos).MustArgmax([]int64{0}, false, false).Int64Values()[0]
+	got, _ := tk.IdToToken(int(index))
+	want := "▁paris"
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
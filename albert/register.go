@@ -0,0 +1,17 @@
+package albert
+
+import (
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// Registers ALBERT configuration, tokenizer and task models for auto-loading
+// (see `transformer.AutoModelFor`).
+func init() {
+	pretrained.RegisterConfig("albert", func() pretrained.Config { return new(AlbertConfig) })
+	pretrained.RegisterTokenizer("albert", func() pretrained.Tokenizer { return NewTokenizer() })
+
+	pretrained.RegisterModel("albert", pretrained.MaskedLM, "AlbertForMaskedLM", func() pretrained.Model { return new(AlbertForMaskedLM) })
+	pretrained.RegisterModel("albert", pretrained.PreTraining, "AlbertForPreTraining", func() pretrained.Model { return new(AlbertForPreTraining) })
+	pretrained.RegisterModel("albert", pretrained.SequenceClassification, "AlbertForSequenceClassification", func() pretrained.Model { return new(AlbertForSequenceClassification) })
+	pretrained.RegisterModel("albert", pretrained.TokenClassification, "AlbertForTokenClassification", func() pretrained.Model { return new(AlbertForTokenClassification) })
+}
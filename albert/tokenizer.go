@@ -0,0 +1,91 @@
+package albert
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/processor"
+
+	"github.com/sugarme/transformer/sentencepiece"
+	"github.com/sugarme/transformer/util"
+)
+
+// Tokenizer holds data for ALBERT tokenizer.
+type Tokenizer struct {
+	*tokenizer.Tokenizer
+}
+
+// NewTokenizer creates a new ALBERT tokenizer.
+func NewTokenizer() *Tokenizer {
+	tk := tokenizer.NewTokenizer(nil)
+	return &Tokenizer{tk}
+}
+
+// Load loads ALBERT tokenizer from pretrained SentencePiece model file `spiece.model`.
+//
+// Optional params:
+//   - "DoLowerCase" (bool): lowercase input. Default=true
+//   - "KeepAccents" (bool): keep accents instead of stripping them. Default=false
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) Load(modelNameOrPath string, params map[string]interface{}) error {
+	cachedFile, err := util.CachedPathWithProgress(modelNameOrPath, sentencepiece.DefaultModelFile, util.ProgressFromParams(params))
+	if err != nil {
+		return err
+	}
+
+	model, err := sentencepiece.NewUnigramFromFile(cachedFile)
+	if err != nil {
+		return err
+	}
+
+	t.WithModel(model)
+
+	doLowerCase := true
+	if v, ok := params["DoLowerCase"].(bool); ok {
+		doLowerCase = v
+	}
+	keepAccents := false
+	if v, ok := params["KeepAccents"].(bool); ok {
+		keepAccents = v
+	}
+	t.WithNormalizer(sentencepiece.NewNormalizer(doLowerCase, !keepAccents, true))
+	t.WithPreTokenizer(sentencepiece.NewMetaspace())
+	t.WithDecoder(sentencepiece.NewDecoder())
+
+	var specialTokens []tokenizer.AddedToken
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("[CLS]", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("[SEP]", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<pad>", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<unk>", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("[MASK]", true))
+	t.AddSpecialTokens(specialTokens)
+
+	sepId, ok := t.TokenToId("[SEP]")
+	if !ok {
+		return fmt.Errorf("Cannot find ID for [SEP] token: %w", util.ErrMissingToken)
+	}
+	sep := processor.PostToken{Id: sepId, Value: "[SEP]"}
+
+	clsId, ok := t.TokenToId("[CLS]")
+	if !ok {
+		return fmt.Errorf("Cannot find ID for [CLS] token: %w", util.ErrMissingToken)
+	}
+	cls := processor.PostToken{Id: clsId, Value: "[CLS]"}
+
+	t.WithPostProcessor(processor.NewBertProcessing(sep, cls))
+
+	return nil
+}
+
+// SavePretrained saves SentencePiece model file `spiece.model` to directory `dir`.
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return t.GetModel().Save(dir)
+}
@@ -0,0 +1,55 @@
+package albert_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/albert"
+	"github.com/sugarme/transformer/sentencepiece"
+)
+
+func TestTokenizer_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "albert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	proto := sentencepiece.NewModelProto([]sentencepiece.Piece{
+		{Piece: "<pad>", Type: sentencepiece.ControlPiece},
+		{Piece: "<unk>", Type: sentencepiece.UnknownPiece},
+		{Piece: "[CLS]", Type: sentencepiece.ControlPiece},
+		{Piece: "[SEP]", Type: sentencepiece.ControlPiece},
+		{Piece: "[MASK]", Type: sentencepiece.UserDefinedPiece},
+		{Piece: "▁hello", Score: -1, Type: sentencepiece.NormalPiece},
+		{Piece: "▁world", Score: -1, Type: sentencepiece.NormalPiece},
+	})
+	proto.UnkId = 1
+	if err := proto.WriteFile(filepath.Join(dir, sentencepiece.DefaultModelFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	tk := albert.NewTokenizer()
+	if err := tk.Load(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	en, err := tk.EncodeSingle("Hello World", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"[CLS]", "▁hello", "▁world", "[SEP]"}
+	if !reflect.DeepEqual(want, en.Tokens) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", en.Tokens)
+	}
+	wantIds := []int{2, 5, 6, 3}
+	if !reflect.DeepEqual(wantIds, en.Ids) {
+		t.Errorf("Want: %v\n", wantIds)
+		t.Errorf("Got: %v\n", en.Ids)
+	}
+}
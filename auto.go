@@ -0,0 +1,172 @@
+package transformer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/sugarme/gotch"
+
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/util"
+
+	// Model packages register themselves for auto-loading.
+	_ "github.com/sugarme/transformer/albert"
+	_ "github.com/sugarme/transformer/bert"
+	_ "github.com/sugarme/transformer/distilbert"
+	_ "github.com/sugarme/transformer/electra"
+	_ "github.com/sugarme/transformer/gpt2"
+	_ "github.com/sugarme/transformer/marian"
+	_ "github.com/sugarme/transformer/roberta"
+	_ "github.com/sugarme/transformer/t5"
+)
+
+// AutoModel is a model loaded by `AutoModelFor` with its configuration and tokenizer.
+type AutoModel struct {
+	ModelType string // `model_type` of configuration file (e.g. "bert")
+	Task      pretrained.Task
+	Config    pretrained.Config
+	Model     pretrained.Model
+	Tokenizer pretrained.Tokenizer
+
+	LoadReport *pretrained.LoadReport // weights not loaded to `Model`
+}
+
+// ModelInfo holds model type and architectures read from `config.json` file.
+type ModelInfo struct {
+	ModelType     string   `json:"model_type"`
+	Architectures []string `json:"architectures"`
+}
+
+// ReadModelInfo reads model type and architectures from `config.json` of a model name or path.
+// Model type is inferred from architectures when `model_type` field is missing.
+func ReadModelInfo(modelNameOrPath string) (*ModelInfo, error) {
+	configFile, err := util.CachedPath(modelNameOrPath, util.ConfigName)
+	if err != nil {
+		return nil, err
+	}
+	buff, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	info := new(ModelInfo)
+	if err := json.Unmarshal(buff, info); err != nil {
+		return nil, fmt.Errorf("ReadModelInfo() failed: %w", err)
+	}
+
+	if info.ModelType == "" {
+		for _, name := range info.Architectures {
+			if modelType, _, ok := pretrained.LookupArchitecture(name); ok {
+				info.ModelType = modelType
+				break
+			}
+		}
+	}
+	if info.ModelType == "" {
+		return nil, fmt.Errorf("ReadModelInfo() failed: cannot find model type of %q (no `model_type` and unknown architectures %v)", modelNameOrPath, info.Architectures)
+	}
+
+	return info, nil
+}
+
+// AutoConfig loads configuration of a model name or path into the configuration type
+// registered for its `model_type`.
+func AutoConfig(modelNameOrPath string) (pretrained.Config, error) {
+	info, err := ReadModelInfo(modelNameOrPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return autoConfig(info, modelNameOrPath, nil)
+}
+
+func autoConfig(info *ModelInfo, modelNameOrPath string, params map[string]interface{}) (pretrained.Config, error) {
+	config, err := pretrained.NewConfig(info.ModelType)
+	if err != nil {
+		return nil, err
+	}
+	if err := LoadConfig(config, modelNameOrPath, params); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// AutoTokenizer loads tokenizer of a model name or path with the tokenizer type registered
+// for its `model_type`.
+func AutoTokenizer(modelNameOrPath string) (pretrained.Tokenizer, error) {
+	info, err := ReadModelInfo(modelNameOrPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return autoTokenizer(info, modelNameOrPath, nil)
+}
+
+func autoTokenizer(info *ModelInfo, modelNameOrPath string, params map[string]interface{}) (pretrained.Tokenizer, error) {
+	tk, err := pretrained.NewTokenizer(info.ModelType)
+	if err != nil {
+		return nil, err
+	}
+	if err := LoadTokenizer(tk, modelNameOrPath, params); err != nil {
+		return nil, err
+	}
+
+	return tk, nil
+}
+
+// AutoModelFor loads model for `task` of a model name or path, with its configuration and
+// tokenizer, using the types registered for its `model_type`.
+//
+// If `task` is empty, it is inferred from `architectures` of configuration file
+// (e.g. "RobertaForQuestionAnswering").
+//
+// `params` are passed to `LoadConfig`, `LoadModel` and `LoadTokenizer`: configuration fields
+// to override, load options (`util.LoadOptionsParam`, e.g. `util.LoadIgnoreHeads` mode to fine-tune
+// a new head) and download progress reporter (`util.ProgressParam`). It can be nil.
+func AutoModelFor(task pretrained.Task, modelNameOrPath string, params map[string]interface{}, device gotch.Device) (*AutoModel, error) {
+	info, err := ReadModelInfo(modelNameOrPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if task == "" {
+		for _, name := range info.Architectures {
+			if modelType, t, ok := pretrained.LookupArchitecture(name); ok && modelType == info.ModelType {
+				task = t
+				break
+			}
+		}
+		if task == "" {
+			return nil, fmt.Errorf("AutoModelFor() failed: cannot infer task of %q from architectures %v", modelNameOrPath, info.Architectures)
+		}
+	}
+
+	model, err := pretrained.NewModel(info.ModelType, task)
+	if err != nil {
+		return nil, err
+	}
+	config, err := autoConfig(info, modelNameOrPath, params)
+	if err != nil {
+		return nil, err
+	}
+	report, err := LoadModel(model, modelNameOrPath, config, params, device)
+	if err != nil {
+		return nil, err
+	}
+	tk, err := autoTokenizer(info, modelNameOrPath, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AutoModel{
+		ModelType: info.ModelType,
+		Task:      task,
+		Config:    config,
+		Model:     model,
+		Tokenizer: tk,
+
+		LoadReport: report,
+	}, nil
+}
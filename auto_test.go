@@ -0,0 +1,113 @@
+package transformer_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/gpt2"
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// writeConfig writes `config.json` with given content to a new temporary directory.
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestAutoConfig(t *testing.T) {
+	var (
+		gotTypes []string
+		dirs     []string
+	)
+	for _, content := range []string{
+		`{"model_type": "bert", "vocab_size": 30522}`,
+		`{"model_type": "roberta", "vocab_size": 50265}`,
+		`{"architectures": ["GPT2LMHeadModel"], "vocab_size": 50257}`,
+	} {
+		dir := writeConfig(t, content)
+		dirs = append(dirs, dir)
+
+		config, err := transformer.AutoConfig(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotTypes = append(gotTypes, fmt.Sprintf("%T", config))
+	}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	wantTypes := []string{"*bert.BertConfig", "*bert.BertConfig", "*gpt2.GPT2Config"}
+	if !reflect.DeepEqual(wantTypes, gotTypes) {
+		t.Errorf("Want: %v\n", wantTypes)
+		t.Errorf("Got: %v\n", gotTypes)
+	}
+
+	config, err := transformer.AutoConfig(dirs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantVocabSize := int64(50257)
+	gotVocabSize := config.(*gpt2.GPT2Config).VocabSize
+	if !reflect.DeepEqual(wantVocabSize, gotVocabSize) {
+		t.Errorf("Want: %v\n", wantVocabSize)
+		t.Errorf("Got: %v\n", gotVocabSize)
+	}
+}
+
+func TestAutoModelFor_Errors(t *testing.T) {
+	unknown := writeConfig(t, `{"model_type": "unknown"}`)
+	defer os.RemoveAll(unknown)
+	noArchitecture := writeConfig(t, `{"model_type": "bert"}`)
+	defer os.RemoveAll(noArchitecture)
+	gpt := writeConfig(t, `{"model_type": "gpt2"}`)
+	defer os.RemoveAll(gpt)
+
+	// Unregistered model type
+	if _, err := transformer.AutoModelFor(pretrained.MaskedLM, unknown, nil, gotch.CPU); err == nil {
+		t.Errorf("Want: error for unknown model type\n")
+	}
+	// Task cannot be inferred
+	if _, err := transformer.AutoModelFor("", noArchitecture, nil, gotch.CPU); err == nil {
+		t.Errorf("Want: error for missing architectures\n")
+	}
+	// Unregistered task
+	if _, err := transformer.AutoModelFor(pretrained.QuestionAnswering, gpt, nil, gotch.CPU); err == nil {
+		t.Errorf("Want: error for unsupported task\n")
+	}
+}
+
+func TestLookupArchitecture(t *testing.T) {
+	modelType, task, ok := pretrained.LookupArchitecture("RobertaForQuestionAnswering")
+	got := []interface{}{modelType, task, ok}
+	want := []interface{}{"roberta", pretrained.QuestionAnswering, true}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	model, err := pretrained.NewModel("bert", pretrained.SequenceClassification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := model.(*bert.BertForSequenceClassification); !ok {
+		t.Errorf("Want: *bert.BertForSequenceClassification\n")
+		t.Errorf("Got: %T\n", model)
+	}
+}
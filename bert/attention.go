@@ -1,8 +1,7 @@
 package bert
 
 import (
-	// "fmt"
-	"log"
+	"fmt"
 	"math"
 
 	"github.com/sugarme/gotch"
@@ -26,9 +25,9 @@ type BertSelfAttention struct {
 }
 
 // NewBertSelfAttention creates a new `BertSelfAttention`
-func NewBertSelfAttention(p *nn.Path, config *BertConfig) *BertSelfAttention {
+func NewBertSelfAttention(p *nn.Path, config *BertConfig) (*BertSelfAttention, error) {
 	if config.HiddenSize%config.NumAttentionHeads != 0 {
-		log.Fatal("Hidden size is not a multiple of the number of attention heads.")
+		return nil, fmt.Errorf("Hidden size (%v) is not a multiple of the number of attention heads (%v): %w", config.HiddenSize, config.NumAttentionHeads, util.ErrShapeMismatch)
 	}
 
 	lconfig := nn.DefaultLinearConfig()
@@ -48,8 +47,7 @@ func NewBertSelfAttention(p *nn.Path, config *BertConfig) *BertSelfAttention {
 		Query:             query,
 		Key:               key,
 		Value:             value,
-	}
-
+	}, nil
 }
 
 func (bsa *BertSelfAttention) splitHeads(x *ts.Tensor, bs, dimPerHead int64) (retVal *ts.Tensor) {
@@ -97,14 +95,12 @@ func (bsa *BertSelfAttention) ForwardT(hiddenStates, mask, encoderHiddenStates,
 	queryLayer := query.MustDivScalar(ts.FloatScalar(size), true)
 
 	// Calculate score
-	var scores *ts.Tensor
+	keyLayerT := keyLayer.MustTranspose(-1, -2, true)
+	scores := queryLayer.MustMatmul(keyLayerT, true)
+	keyLayerT.MustDrop()
 	if mask.MustDefined() {
-		keyLayerT := keyLayer.MustTranspose(-1, -2, true)
-		keyLayerT.MustAdd_(mask)
-		scores = queryLayer.MustMatmul(keyLayerT, true)
-	} else {
-		keyLayerT := keyLayer.MustTranspose(-1, -2, true)
-		scores = queryLayer.MustMatmul(keyLayerT, true)
+		// mask is additive: 0 for attended positions, large negative otherwise.
+		scores = scores.MustAdd(mask, true)
 	}
 
 	weights := scores.MustSoftmax(-1, gotch.Float, true).ApplyT(bsa.Dropout, train)
@@ -177,15 +173,18 @@ type BertAttention struct {
 	Output *BertSelfOutput
 }
 
-func NewBertAttention(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertAttention {
+func NewBertAttention(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertAttention, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
 	}
-	self := NewBertSelfAttention(p.Sub("self"), config)
+	self, err := NewBertSelfAttention(p.Sub("self"), config)
+	if err != nil {
+		return nil, err
+	}
 	output := NewBertSelfOutput(p.Sub("output"), config, changeName)
 
-	return &BertAttention{self, output}
+	return &BertAttention{self, output}, nil
 }
 
 func (ba *BertAttention) ForwardT(hiddenStates, mask, encoderHiddenStates, encoderMask *ts.Tensor, train bool) (retVal, RetValOpt *ts.Tensor) {
@@ -204,16 +203,16 @@ type BertIntermediate struct {
 	Activation util.ActivationFn // interface
 }
 
-func NewBertIntermediate(p *nn.Path, config *BertConfig) *BertIntermediate {
-	lconfig := nn.DefaultLinearConfig()
-	lin := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.IntermediateSize, lconfig)
-
+func NewBertIntermediate(p *nn.Path, config *BertConfig) (*BertIntermediate, error) {
 	actFn, ok := util.ActivationFnMap[config.HiddenAct]
 	if !ok {
-		log.Fatalf("Unsupported activation function - %v\n", config.HiddenAct)
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.HiddenAct)
 	}
 
-	return &BertIntermediate{lin, actFn}
+	lconfig := nn.DefaultLinearConfig()
+	lin := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.IntermediateSize, lconfig)
+
+	return &BertIntermediate{lin, actFn}, nil
 }
 
 func (bi *BertIntermediate) Forward(hiddenStates *ts.Tensor) (retVal *ts.Tensor) {
@@ -0,0 +1,39 @@
+package bert_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/bert"
+)
+
+// Masked positions must not change the attention output of other positions.
+func TestBertSelfAttention_Mask(t *testing.T) {
+	config := bert.NewConfig(map[string]interface{}{
+		"HiddenSize":        int64(8),
+		"NumAttentionHeads": int64(2),
+	})
+	attention, err := bert.NewBertSelfAttention(nn.NewVarStore(gotch.CPU).Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Additive mask: second position is padding.
+	mask := ts.MustOfSlice([]float32{0, -10000}).MustView([]int64{1, 1, 1, 2}, true)
+	first := []float32{0.1, -0.2, 0.3, 0.5, -0.4, 0.2, 0.7, -0.1}
+	input1 := ts.MustOfSlice(append(append([]float32{}, first...), 1, 2, 3, 4, 5, 6, 7, 8)).MustView([]int64{1, 2, 8}, true)
+	input2 := ts.MustOfSlice(append(append([]float32{}, first...), -8, 7, -6, 5, -4, 3, -2, 1)).MustView([]int64{1, 2, 8}, true)
+
+	output1, _ := attention.ForwardT(input1, mask, ts.None, ts.None, false)
+	output2, _ := attention.ForwardT(input2, mask, ts.None, ts.None, false)
+
+	want := output1.MustNarrow(1, 0, 1, false)
+	got := output2.MustNarrow(1, 0, 1, false)
+	diff := want.MustSub(got, false).MustAbs(true).MustMax(true).Float64Values()[0]
+	if diff > 1e-5 {
+		t.Errorf("Want output of first position independent of masked position, max difference: %v\n", diff)
+	}
+}
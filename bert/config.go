@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+
+	"github.com/sugarme/transformer/util"
 )
 
 // BertConfig defines the BERT model architecture (i.e., number of layers,
@@ -27,8 +28,8 @@ type BertConfig struct {
 	OutputAttentions          bool             `json:"output_attentions"`
 	OutputHiddenStates        bool             `json:"output_hidden_states"`
 	IsDecoder                 bool             `json:"is_decoder"`
-	Id2Label                  map[int64]string `json:"id_2_label"`
-	Label2Id                  map[string]int64 `json:"label_2_id"`
+	Id2Label                  map[int64]string `json:"id2label"`
+	Label2Id                  map[string]int64 `json:"label2id"`
 	NumLabels                 int64            `json:"num_labels"`
 }
 
@@ -84,8 +85,7 @@ func ConfigFromFile(filename string) (*BertConfig, error) {
 	var config BertConfig
 	err = json.Unmarshal(buff, &config)
 	if err != nil {
-		fmt.Println(err)
-		log.Fatalf("Could not parse configuration to BertConfiguration.\n")
+		return nil, fmt.Errorf("Could not parse configuration to BertConfig: %v: %w", err, util.ErrInvalidConfig)
 	}
 	return &config, nil
 }
@@ -105,6 +105,21 @@ func (c *BertConfig) Load(modelNameOrPath string, params map[string]interface{})
 	return nil
 }
 
+// SavePretrained saves configuration to directory `dir` as `config.json` file.
+// This method implements `pretrained.Config` interface.
+func (c *BertConfig) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buff, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, util.ConfigName), buff, 0644)
+}
+
 func (c *BertConfig) fromFile(filename string) error {
 	filePath, err := filepath.Abs(filename)
 	if err != nil {
@@ -124,8 +139,7 @@ func (c *BertConfig) fromFile(filename string) error {
 
 	err = json.Unmarshal(buff, c)
 	if err != nil {
-		fmt.Println(err)
-		log.Fatalf("Could not parse configuration to BertConfiguration.\n")
+		return fmt.Errorf("Could not parse configuration to BertConfig: %v: %w", err, util.ErrInvalidConfig)
 	}
 
 	return nil
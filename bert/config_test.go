@@ -1,10 +1,15 @@
 package bert_test
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/util"
 )
 
 // No custom params
@@ -48,3 +53,25 @@ func TestNewBertConfig_Custom(t *testing.T) {
 		t.Errorf("Got: '%v'\n", gotVocabSize)
 	}
 }
+
+func TestConfigFromFile_Invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configFile, []byte(`{"hidden_size": "large"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bert.ConfigFromFile(configFile); !errors.Is(err, util.ErrInvalidConfig) {
+		t.Errorf("Want: %v\n", util.ErrInvalidConfig)
+		t.Errorf("Got: %v\n", err)
+	}
+	if err := new(bert.BertConfig).Load(configFile, nil); !errors.Is(err, util.ErrInvalidConfig) {
+		t.Errorf("Want: %v\n", util.ErrInvalidConfig)
+		t.Errorf("Got: %v\n", err)
+	}
+}
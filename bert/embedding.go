@@ -66,7 +66,7 @@ func (be *BertEmbeddings) ForwardT(inputIds, tokenTypeIds, positionIds, inputEmb
 
 	if inputIds.MustDefined() {
 		if inputEmbeds.MustDefined() {
-			err = fmt.Errorf("Only one of input Ids or input embeddings may be set.")
+			err = fmt.Errorf("Only one of input Ids or input embeddings may be set: %w", util.ErrInvalidInput)
 			return retVal, err
 		} else {
 			inputEmbeddings = inputIds.ApplyT(be.WordEmbeddings, train)
@@ -78,7 +78,7 @@ func (be *BertEmbeddings) ForwardT(inputIds, tokenTypeIds, positionIds, inputEmb
 			size := inputEmbeds.MustSize()
 			inputShape = []int64{size[0], size[1]}
 		} else {
-			err = fmt.Errorf("Only one of input Ids or input embeddings may be set.")
+			err = fmt.Errorf("Only one of input Ids or input embeddings may be set: %w", util.ErrInvalidInput)
 			return retVal, err
 		}
 	}
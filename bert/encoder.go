@@ -20,13 +20,16 @@ type BertLayer struct {
 }
 
 // NewBertLayer creates a new BertLayer.
-func NewBertLayer(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertLayer {
+func NewBertLayer(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertLayer, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
 	}
 	path := p.Sub("attention")
-	attention := NewBertAttention(path, config, changeName)
+	attention, err := NewBertAttention(path, config, changeName)
+	if err != nil {
+		return nil, err
+	}
 	var (
 		isDecoder      bool = false
 		crossAttention *BertAttention
@@ -35,15 +38,21 @@ func NewBertLayer(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertLa
 	if config.IsDecoder {
 		isDecoder = true
 		attPath := p.Sub("cross_attention")
-		crossAttention = NewBertAttention(attPath, config)
+		crossAttention, err = NewBertAttention(attPath, config)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	intermediatePath := p.Sub("intermediate")
-	intermediate := NewBertIntermediate(intermediatePath, config)
+	intermediate, err := NewBertIntermediate(intermediatePath, config)
+	if err != nil {
+		return nil, err
+	}
 	outputPath := p.Sub("output")
 	output := NewBertOutput(outputPath, config, changeName)
 
-	return &BertLayer{attention, isDecoder, crossAttention, intermediate, output}
+	return &BertLayer{attention, isDecoder, crossAttention, intermediate, output}, nil
 }
 
 // ForwardT forwards pass through the model.
@@ -83,7 +92,7 @@ type BertEncoder struct {
 }
 
 // NewBertEncoder creates a new BertEncoder.
-func NewBertEncoder(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertEncoder {
+func NewBertEncoder(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertEncoder, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
@@ -101,11 +110,14 @@ func NewBertEncoder(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *Bert
 
 	var layers []BertLayer
 	for lIdx := 0; lIdx < int(config.NumHiddenLayers); lIdx++ {
-		layers = append(layers, *NewBertLayer(path.Sub(fmt.Sprintf("%v", lIdx)), config, changeName))
+		layer, err := NewBertLayer(path.Sub(fmt.Sprintf("%v", lIdx)), config, changeName)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, *layer)
 	}
 
-	return &BertEncoder{outputAttentions, outputHiddenStates, layers}
-
+	return &BertEncoder{outputAttentions, outputHiddenStates, layers}, nil
 }
 
 // ForwardT forwards pass through the model.
@@ -125,7 +137,7 @@ func (be *BertEncoder) ForwardT(hiddenStates, mask, encoderHiddenStates, encoder
 
 	for _, layer := range be.Layers {
 		if allHiddenStates != nil {
-			allHiddenStates = append(allHiddenStates, *hiddenState)
+			allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
 		}
 
 		stateTmp, attnWeightsTmp, _ := layer.ForwardT(hiddenState, mask, encoderHiddenStates, encoderMask, train)
@@ -0,0 +1,42 @@
+package bert_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/bert"
+)
+
+// Hidden states must still be readable after the encoder has freed its intermediate tensors.
+func TestBertEncoder_OutputHiddenStates(t *testing.T) {
+	config := bert.NewConfig(map[string]interface{}{
+		"HiddenSize":        int64(8),
+		"NumHiddenLayers":   int64(2),
+		"NumAttentionHeads": int64(2),
+		"IntermediateSize":  int64(16),
+	})
+	config.OutputHiddenStates = true
+	encoder, err := bert.NewBertEncoder(nn.NewVarStore(gotch.CPU).Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := ts.MustRandn([]int64{1, 3, 8}, gotch.Float, gotch.CPU)
+	want := input.Float64Values()
+
+	_, hiddenStates, _ := encoder.ForwardT(input, ts.None, ts.None, ts.None, false)
+	if len(hiddenStates) != 2 {
+		t.Fatalf("Want 2 hidden states, got %v\n", len(hiddenStates))
+	}
+
+	// First hidden state is the encoder input.
+	got := hiddenStates[0].Float64Values()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
@@ -2,11 +2,9 @@ package bert
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/nn"
-	"github.com/sugarme/gotch/pickle"
 	"github.com/sugarme/gotch/ts"
 
 	"github.com/sugarme/transformer/pretrained"
@@ -37,7 +35,7 @@ type BertModel struct {
 // Params:
 //   - `p`: Variable store path for the root of the BERT Model
 //   - `config`: BertConfig onfiguration for model architecture and decoder status
-func NewBertModel(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertModel {
+func NewBertModel(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertModel, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
@@ -48,10 +46,13 @@ func NewBertModel(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertMo
 	}
 
 	embeddings := NewBertEmbeddings(p.Sub("embeddings"), config, changeName)
-	encoder := NewBertEncoder(p.Sub("encoder"), config, changeName)
+	encoder, err := NewBertEncoder(p.Sub("encoder"), config, changeName)
+	if err != nil {
+		return nil, err
+	}
 	pooler := NewBertPooler(p.Sub("pooler"), config)
 
-	return &BertModel{embeddings, encoder, pooler, isDecoder}
+	return &BertModel{embeddings, encoder, pooler, isDecoder}, nil
 }
 
 // ForwardT forwards pass through the model.
@@ -89,7 +90,7 @@ func (b *BertModel) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmb
 
 	if inputIds.MustDefined() {
 		if inputEmbeds.MustDefined() {
-			err = fmt.Errorf("Only one of input ids or input embeddings may be set\n")
+			err = fmt.Errorf("Only one of input ids or input embeddings may be set: %w", util.ErrInvalidInput)
 			return
 		}
 		inputShape = inputIds.MustSize()
@@ -100,7 +101,7 @@ func (b *BertModel) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmb
 			inputShape = []int64{size[0], size[1]}
 			device = inputEmbeds.MustDevice()
 		} else {
-			err = fmt.Errorf("At least one of input ids or input embeddings must be set\n")
+			err = fmt.Errorf("At least one of input ids or input embeddings must be set: %w", util.ErrInvalidInput)
 			return
 		}
 	}
@@ -127,7 +128,8 @@ func (b *BertModel) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmb
 		}
 
 	default:
-		err = fmt.Errorf("Invalid attention mask dimension, must be 2 or 3, got %v\n", maskTs.Dim())
+		err = fmt.Errorf("Invalid attention mask dimension, must be 2 or 3, got %v: %w", maskTs.Dim(), util.ErrInvalidInput)
+		return
 	}
 
 	extendedAttnMask := extendedAttentionMask.MustOnesLike(false).MustSub(extendedAttentionMask, true).MustMulScalar(ts.FloatScalar(-10000.0), true)
@@ -149,7 +151,7 @@ func (b *BertModel) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmb
 		case 3:
 			encoderExtendedAttentionMask = encoderMaskTs.MustUnsqueeze(1, true)
 		default:
-			err = fmt.Errorf("Invalid encoder attention mask dimension, must be 2, or 3 got %v\n", encoderMaskTs.Dim())
+			err = fmt.Errorf("Invalid encoder attention mask dimension, must be 2, or 3 got %v: %w", encoderMaskTs.Dim(), util.ErrInvalidInput)
 			return
 		}
 	} else {
@@ -179,16 +181,16 @@ type BertPredictionHeadTransform struct {
 }
 
 // NewBertPredictionHead creates BertPredictionHeadTransform.
-func NewBertPredictionHeadTransform(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertPredictionHeadTransform {
+func NewBertPredictionHeadTransform(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertPredictionHeadTransform, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
 	}
-	dense := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.HiddenSize, nn.DefaultLinearConfig())
 	activation, ok := util.ActivationFnMap[config.HiddenAct]
 	if !ok {
-		log.Fatalf("Unsupported activation function - %v\n", config.HiddenAct)
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.HiddenAct)
 	}
+	dense := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.HiddenSize, nn.DefaultLinearConfig())
 
 	lnConfig := nn.DefaultLayerNormConfig()
 	if changeName {
@@ -197,7 +199,7 @@ func NewBertPredictionHeadTransform(p *nn.Path, config *BertConfig, changeNameOp
 	}
 	layerNorm := nn.NewLayerNorm(p.Sub("LayerNorm"), []int64{config.HiddenSize}, lnConfig)
 
-	return &BertPredictionHeadTransform{dense, activation, layerNorm}
+	return &BertPredictionHeadTransform{dense, activation, layerNorm}, nil
 }
 
 // Forward forwards through the model.
@@ -224,7 +226,10 @@ type BertLMPredictionHead struct {
 // NewBertLMPredictionHead creates BertLMPredictionHead.
 func NewBertLMPredictionHead(p *nn.Path, config *BertConfig) (*BertLMPredictionHead, error) {
 	path := p.Sub("predictions")
-	transform := NewBertPredictionHeadTransform(path.Sub("transform"), config)
+	transform, err := NewBertPredictionHeadTransform(path.Sub("transform"), config)
+	if err != nil {
+		return nil, err
+	}
 	decoder, err := util.NewLinearNoBias(path.Sub("decoder"), config.HiddenSize, config.VocabSize, util.DefaultLinearNoBiasConfig())
 	if err != nil {
 		return nil, err
@@ -252,8 +257,10 @@ func (ph *BertLMPredictionHead) Forward(hiddenState *ts.Tensor) *ts.Tensor {
 
 // BertForMaskedLM is BERT for masked language model
 type BertForMaskedLM struct {
-	bert *BertModel
-	cls  *BertLMPredictionHead
+	bert   *BertModel
+	cls    *BertLMPredictionHead
+	config *BertConfig
+	vs     *nn.VarStore
 }
 
 // NewBertForMaskedLM creates BertForMaskedLM.
@@ -262,35 +269,71 @@ func NewBertForMaskedLM(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
 	}
-	bert := NewBertModel(p.Sub("bert"), config, changeName)
+	bert, err := NewBertModel(p.Sub("bert"), config, changeName)
+	if err != nil {
+		return nil, err
+	}
 	cls, err := NewBertLMPredictionHead(p.Sub("cls"), config)
 	if err != nil {
 		return nil, err
 	}
 
-	return &BertForMaskedLM{bert, cls}, nil
+	return &BertForMaskedLM{
+		bert:   bert,
+		cls:    cls,
+		config: config,
+	}, nil
 }
 
 // Load loads model from file or model name. It also updates
 // default configuration parameters if provided.
-// This method implements `PretrainedModel` interface.
-func (mlm *BertForMaskedLM) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) error {
+// This method implements `pretrained.Model` interface.
+func (mlm *BertForMaskedLM) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("BertForMaskedLM.Load() failed: invalid config type %T, want *bert.BertConfig", config)
+	}
+
 	vs := nn.NewVarStore(device)
-	p := vs.Root()
-	mlm.bert = NewBertModel(p.Sub("bert"), config.(*BertConfig))
-	var err error
-	mlm.cls, err = NewBertLMPredictionHead(p.Sub("cls"), config.(*BertConfig))
+	model, err := NewBertForMaskedLM(vs.Root(), bertConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	*mlm = *model
+	mlm.vs = vs
 
-	// err = vs.Load(cachedFile)
-	err = pickle.LoadAll(vs, modelNameOrPath)
-	if err != nil {
-		log.Fatalf("Load model weight error: \n%v", err)
+	opts := util.LoadOptionsFromParams(params, "cls")
+	// The decoder shares word embeddings, so safetensors files may not store it.
+	opts.Tied = map[string]string{
+		"cls.predictions.decoder.weight": "bert.embeddings.word_embeddings.weight",
+		"cls.predictions.bias":           "cls.predictions.decoder.bias",
 	}
 
-	return nil
+	return util.LoadVarStore(vs, modelNameOrPath, opts, util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (mlm *BertForMaskedLM) SavePretrained(dir string) error {
+	return util.SaveModel(dir, mlm.config, mlm.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (mlm *BertForMaskedLM) VarStore() *nn.VarStore {
+	return mlm.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (mlm *BertForMaskedLM) SetVarStore(vs *nn.VarStore) {
+	mlm.vs = vs
+}
+
+// Config returns model configuration.
+func (mlm *BertForMaskedLM) Config() *BertConfig {
+	return mlm.config
 }
 
 // ForwardT forwards pass through the model.
@@ -318,16 +361,16 @@ func (mlm *BertForMaskedLM) Load(modelNameOrPath string, config interface{ pretr
 //   - `output`: tensor of shape (batch size, sequence length, hidden size)
 //   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
 //   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
-func (mlm *BertForMaskedLM) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, encoderHiddenStates, encoderMask *ts.Tensor, train bool) (retVal1 *ts.Tensor, optRetVal1, optRetVal2 []ts.Tensor) {
+func (mlm *BertForMaskedLM) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, encoderHiddenStates, encoderMask *ts.Tensor, train bool) (retVal1 *ts.Tensor, optRetVal1, optRetVal2 []ts.Tensor, err error) {
 
 	hiddenState, _, allHiddenStates, allAttentions, err := mlm.bert.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, encoderHiddenStates, encoderMask, train)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, nil, err
 	}
 
 	predictionScores := mlm.cls.Forward(hiddenState)
 
-	return predictionScores, allHiddenStates, allAttentions
+	return predictionScores, allHiddenStates, allAttentions, nil
 }
 
 // BERT for sequence classification:
@@ -343,6 +386,8 @@ type BertForSequenceClassification struct {
 	bert       *BertModel
 	dropout    *util.Dropout
 	classifier *nn.Linear
+	config     *BertConfig
+	vs         *nn.VarStore
 }
 
 // NewBertForSequenceClassification creates a new `BertForSequenceClassification`.
@@ -355,15 +400,18 @@ type BertForSequenceClassification struct {
 //
 //	device := gotch.CPU
 //	vs := nn.NewVarStore(device)
-//	config := bert.ConfigFromFile("path/to/config.json")
+//	config, err := bert.ConfigFromFile("path/to/config.json")
 //	p := vs.Root()
-//	bert := NewBertForSequenceClassification(p.Sub("bert"), config)
-func NewBertForSequenceClassification(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertForSequenceClassification {
+//	bert, err := NewBertForSequenceClassification(p.Sub("bert"), config)
+func NewBertForSequenceClassification(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertForSequenceClassification, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
 	}
-	bert := NewBertModel(p.Sub("bert"), config, changeName)
+	bert, err := NewBertModel(p.Sub("bert"), config, changeName)
+	if err != nil {
+		return nil, err
+	}
 	dropout := util.NewDropout(config.HiddenDropoutProb)
 	numLabels := len(config.Id2Label)
 
@@ -373,7 +421,52 @@ func NewBertForSequenceClassification(p *nn.Path, config *BertConfig, changeName
 		bert:       bert,
 		dropout:    dropout,
 		classifier: classifier,
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (bsc *BertForSequenceClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("BertForSequenceClassification.Load() failed: invalid config type %T, want *bert.BertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewBertForSequenceClassification(vs.Root(), bertConfig)
+	if err != nil {
+		return nil, err
 	}
+	*bsc = *model
+	bsc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (bsc *BertForSequenceClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, bsc.config, bsc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (bsc *BertForSequenceClassification) VarStore() *nn.VarStore {
+	return bsc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (bsc *BertForSequenceClassification) SetVarStore(vs *nn.VarStore) {
+	bsc.vs = vs
+}
+
+// Config returns model configuration.
+func (bsc *BertForSequenceClassification) Config() *BertConfig {
+	return bsc.config
 }
 
 // ForwardT forwards pass through the model.
@@ -402,11 +495,10 @@ func NewBertForSequenceClassification(p *nn.Path, config *BertConfig, changeName
 //   - `pooledOutput`: tensor of shape (batch size, hidden size)
 //   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
 //   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
-func (bsc *BertForSequenceClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor) {
+func (bsc *BertForSequenceClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
 	_, pooledOutput, allHiddenStates, allAttentions, err := bsc.bert.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
-
 	if err != nil {
-		log.Fatalf("call bert.ForwardT error: %v", err)
+		return nil, nil, nil, err
 	}
 
 	dropoutOutput := pooledOutput.ApplyT(bsc.dropout, train)
@@ -414,7 +506,7 @@ func (bsc *BertForSequenceClassification) ForwardT(inputIds, mask, tokenTypeIds,
 	output := dropoutOutput.Apply(bsc.classifier)
 	dropoutOutput.MustDrop()
 
-	return output, allHiddenStates, allAttentions
+	return output, allHiddenStates, allAttentions, nil
 }
 
 // BERT for multiple choices :
@@ -431,6 +523,8 @@ type BertForMultipleChoice struct {
 	bert       *BertModel
 	dropout    *util.Dropout
 	classifier *nn.Linear
+	config     *BertConfig
+	vs         *nn.VarStore
 }
 
 // NewBertForMultipleChoice creates a new `BertForMultipleChoice`.
@@ -438,12 +532,15 @@ type BertForMultipleChoice struct {
 // Params:
 //   - `p`: Variable store path for the root of the BertForMultipleChoice model
 //   - `config`: `BertConfig` object defining the model architecture
-func NewBertForMultipleChoice(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertForMultipleChoice {
+func NewBertForMultipleChoice(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertForMultipleChoice, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
 	}
-	bert := NewBertModel(p.Sub("bert"), config, changeName)
+	bert, err := NewBertModel(p.Sub("bert"), config, changeName)
+	if err != nil {
+		return nil, err
+	}
 	dropout := util.NewDropout(config.HiddenDropoutProb)
 	classifier := nn.NewLinear(p.Sub("classifier"), config.HiddenSize, 1, nn.DefaultLinearConfig())
 
@@ -451,7 +548,52 @@ func NewBertForMultipleChoice(p *nn.Path, config *BertConfig, changeNameOpt ...b
 		bert:       bert,
 		dropout:    dropout,
 		classifier: classifier,
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (mc *BertForMultipleChoice) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("BertForMultipleChoice.Load() failed: invalid config type %T, want *bert.BertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewBertForMultipleChoice(vs.Root(), bertConfig)
+	if err != nil {
+		return nil, err
 	}
+	*mc = *model
+	mc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (mc *BertForMultipleChoice) SavePretrained(dir string) error {
+	return util.SaveModel(dir, mc.config, mc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (mc *BertForMultipleChoice) VarStore() *nn.VarStore {
+	return mc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (mc *BertForMultipleChoice) SetVarStore(vs *nn.VarStore) {
+	mc.vs = vs
+}
+
+// Config returns model configuration.
+func (mc *BertForMultipleChoice) Config() *BertConfig {
+	return mc.config
 }
 
 // ForwardT forwards pass through the model.
@@ -471,9 +613,8 @@ func NewBertForMultipleChoice(p *nn.Path, config *BertConfig, changeNameOpt ...b
 //   - `output`: tensor of shape (batch size, sequence length, hidden size)
 //   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
 //   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
-func (mc *BertForMultipleChoice) ForwardT(inputIds, mask, tokenTypeIds, positionIds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor) {
+func (mc *BertForMultipleChoice) ForwardT(inputIds, mask, tokenTypeIds, positionIds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
 	inputIdsSize := inputIds.MustSize()
-	fmt.Printf("inputIdsSize: %v\n", inputIdsSize)
 	numChoices := inputIdsSize[1]
 	inputIdsView := inputIds.MustView([]int64{-1, inputIdsSize[len(inputIdsSize)-1]}, false)
 
@@ -497,7 +638,7 @@ func (mc *BertForMultipleChoice) ForwardT(inputIds, mask, tokenTypeIds, position
 
 	_, pooledOutput, allHiddenStates, allAttentions, err := mc.bert.ForwardT(inputIdsView, maskView, tokenTypeIdsView, positionIdsView, ts.None, ts.None, ts.None, train)
 	if err != nil {
-		log.Fatalf("Call 'BertForMultipleChoice ForwordT' method error: %v\n", err)
+		return nil, nil, nil, err
 	}
 
 	outputDropout := pooledOutput.ApplyT(mc.dropout, train)
@@ -508,7 +649,7 @@ func (mc *BertForMultipleChoice) ForwardT(inputIds, mask, tokenTypeIds, position
 	outputDropout.MustDrop()
 	outputClassifier.MustDrop()
 
-	return output, allHiddenStates, allAttentions
+	return output, allHiddenStates, allAttentions, nil
 }
 
 // BERT for token classification (e.g., NER, POS):
@@ -524,6 +665,8 @@ type BertForTokenClassification struct {
 	bert       *BertModel
 	dropout    *util.Dropout
 	classifier *nn.Linear
+	config     *BertConfig
+	vs         *nn.VarStore
 }
 
 // NewBertForTokenClassification creates a new `BertForTokenClassification`
@@ -531,12 +674,15 @@ type BertForTokenClassification struct {
 // Params:
 //   - `p`: Variable store path for the root of the BertForTokenClassification model
 //   - `config`: `BertConfig` object defining the model architecture, number of output labels and label mapping
-func NewBertForTokenClassification(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertForTokenClassification {
+func NewBertForTokenClassification(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertForTokenClassification, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
 	}
-	bert := NewBertModel(p.Sub("bert"), config, changeName)
+	bert, err := NewBertModel(p.Sub("bert"), config, changeName)
+	if err != nil {
+		return nil, err
+	}
 	dropout := util.NewDropout(config.HiddenDropoutProb)
 
 	numLabels := len(config.Id2Label)
@@ -546,7 +692,52 @@ func NewBertForTokenClassification(p *nn.Path, config *BertConfig, changeNameOpt
 		bert:       bert,
 		dropout:    dropout,
 		classifier: classifier,
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (tc *BertForTokenClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("BertForTokenClassification.Load() failed: invalid config type %T, want *bert.BertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewBertForTokenClassification(vs.Root(), bertConfig)
+	if err != nil {
+		return nil, err
 	}
+	*tc = *model
+	tc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (tc *BertForTokenClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, tc.config, tc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (tc *BertForTokenClassification) VarStore() *nn.VarStore {
+	return tc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (tc *BertForTokenClassification) SetVarStore(vs *nn.VarStore) {
+	tc.vs = vs
+}
+
+// Config returns model configuration.
+func (tc *BertForTokenClassification) Config() *BertConfig {
+	return tc.config
 }
 
 // ForwordT forwards pass through the model.
@@ -568,11 +759,11 @@ func NewBertForTokenClassification(p *nn.Path, config *BertConfig, changeNameOpt
 //   - `output`: tensor of shape (batch size, sequence length, hidden size)
 //   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
 //   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
-func (tc *BertForTokenClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor) {
+func (tc *BertForTokenClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
 
 	hiddenState, _, allHiddenStates, allAttentions, err := tc.bert.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
 	if err != nil {
-		log.Fatalf("Call 'BertForTokenClassification ForwardT' method error: %v\n", err)
+		return nil, nil, nil, err
 	}
 
 	outputDropout := hiddenState.ApplyT(tc.dropout, train)
@@ -580,7 +771,7 @@ func (tc *BertForTokenClassification) ForwardT(inputIds, mask, tokenTypeIds, pos
 
 	outputDropout.MustDrop()
 
-	return output, allHiddenStates, allAttentions
+	return output, allHiddenStates, allAttentions, nil
 }
 
 // BERT for question answering:
@@ -597,6 +788,8 @@ func (tc *BertForTokenClassification) ForwardT(inputIds, mask, tokenTypeIds, pos
 type BertForQuestionAnswering struct {
 	bert      *BertModel
 	qaOutputs *nn.Linear
+	config    *BertConfig
+	vs        *nn.VarStore
 }
 
 // NewBertForQuestionAnswering creates a new `BertForQuestionAnswering`.
@@ -604,12 +797,15 @@ type BertForQuestionAnswering struct {
 // Params:
 //   - `p`: Variable store path for the root of the BertForQuestionAnswering model
 //   - `config`: `BertConfig` object defining the model architecture
-func NewForBertQuestionAnswering(p *nn.Path, config *BertConfig, changeNameOpt ...bool) *BertForQuestionAnswering {
+func NewForBertQuestionAnswering(p *nn.Path, config *BertConfig, changeNameOpt ...bool) (*BertForQuestionAnswering, error) {
 	changeName := true
 	if len(changeNameOpt) > 0 {
 		changeName = changeNameOpt[0]
 	}
-	bert := NewBertModel(p.Sub("bert"), config, changeName)
+	bert, err := NewBertModel(p.Sub("bert"), config, changeName)
+	if err != nil {
+		return nil, err
+	}
 
 	numLabels := 2
 	qaOutputs := nn.NewLinear(p.Sub("qa_outputs"), config.HiddenSize, int64(numLabels), nn.DefaultLinearConfig())
@@ -617,7 +813,52 @@ func NewForBertQuestionAnswering(p *nn.Path, config *BertConfig, changeNameOpt .
 	return &BertForQuestionAnswering{
 		bert:      bert,
 		qaOutputs: qaOutputs,
+		config:    config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (qa *BertForQuestionAnswering) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("BertForQuestionAnswering.Load() failed: invalid config type %T, want *bert.BertConfig", config)
 	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewForBertQuestionAnswering(vs.Root(), bertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*qa = *model
+	qa.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "qa_outputs"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (qa *BertForQuestionAnswering) SavePretrained(dir string) error {
+	return util.SaveModel(dir, qa.config, qa.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (qa *BertForQuestionAnswering) VarStore() *nn.VarStore {
+	return qa.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (qa *BertForQuestionAnswering) SetVarStore(vs *nn.VarStore) {
+	qa.vs = vs
+}
+
+// Config returns model configuration.
+func (qa *BertForQuestionAnswering) Config() *BertConfig {
+	return qa.config
 }
 
 // ForwardT forwards pass through the model.
@@ -639,11 +880,11 @@ func NewForBertQuestionAnswering(p *nn.Path, config *BertConfig, changeNameOpt .
 //   - `output`: tensor of shape (batch size, sequence length, hidden size)
 //   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
 //   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, sequenceLength, hiddenSize)
-func (qa *BertForQuestionAnswering) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal1, retVal2 *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor) {
+func (qa *BertForQuestionAnswering) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal1, retVal2 *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
 
 	hiddenState, _, allHiddenStates, allAttentions, err := qa.bert.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
 	if err != nil {
-		log.Fatalf("Call 'BertForTokenClassification ForwardT' method error: %v\n", err)
+		return nil, nil, nil, nil, err
 	}
 
 	sequenceOutput := hiddenState.Apply(qa.qaOutputs)
@@ -651,5 +892,5 @@ func (qa *BertForQuestionAnswering) ForwardT(inputIds, mask, tokenTypeIds, posit
 	startLogits := logits[0].MustSqueezeDim(int64(-1), false)
 	endLogits := logits[1].MustSqueezeDim(int64(-1), false)
 
-	return startLogits, endLogits, allHiddenStates, allAttentions
+	return startLogits, endLogits, allHiddenStates, allAttentions, nil
 }
@@ -1,14 +1,17 @@
 package bert_test
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/nn"
-	"github.com/sugarme/gotch/pickle"
 	"github.com/sugarme/gotch/ts"
 	"github.com/sugarme/tokenizer"
 	"github.com/sugarme/tokenizer/model/wordpiece"
@@ -84,7 +87,7 @@ func TestBertForMaskedLM(t *testing.T) {
 		log.Fatal(err)
 	}
 
-	err = pickle.LoadAll(vs, modelFile)
+	_, err = util.LoadWeightFile(vs, modelFile, util.LoadOptions{})
 	if err != nil {
 		t.Error(err)
 	}
@@ -124,7 +127,10 @@ func TestBertForMaskedLM(t *testing.T) {
 
 	var output *ts.Tensor
 	ts.NoGrad(func() {
-		output, _, _ = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, ts.None, ts.None, false)
+		output, _, _, err = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, ts.None, ts.None, false)
+		if err != nil {
+			log.Fatal(err)
+		}
 	})
 
 	index1 := output.MustGet(0).MustGet(4).MustArgmax([]int64{0}, false, false).Int64Values()[0]
@@ -174,7 +180,10 @@ func TestBertForSequenceClassification(t *testing.T) {
 	config.Id2Label = dummyLabelMap
 	config.OutputAttentions = true
 	config.OutputHiddenStates = true
-	model := bert.NewBertForSequenceClassification(vs.Root(), config)
+	model, err := bert.NewBertForSequenceClassification(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	vocabFile, err := util.CachedPath("bert-base-uncased", "vocab.txt")
 	tk := getBertTokenizer(vocabFile)
@@ -216,7 +225,10 @@ func TestBertForSequenceClassification(t *testing.T) {
 	)
 
 	ts.NoGrad(func() {
-		output, allHiddenStates, allAttentions = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, false)
+		output, allHiddenStates, allAttentions, err = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, false)
+		if err != nil {
+			log.Fatal(err)
+		}
 	})
 
 	fmt.Printf("output size: %v\n", output.MustSize())
@@ -255,7 +267,10 @@ func TestBertForMultipleChoice(t *testing.T) {
 
 	config.OutputAttentions = true
 	config.OutputHiddenStates = true
-	model := bert.NewBertForMultipleChoice(vs.Root(), config)
+	model, err := bert.NewBertForMultipleChoice(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	vocabFile, err := util.CachedPath("bert-base-uncased", "vocab.txt")
 	tk := getBertTokenizer(vocabFile)
@@ -297,7 +312,10 @@ func TestBertForMultipleChoice(t *testing.T) {
 	)
 
 	ts.NoGrad(func() {
-		output, allHiddenStates, allAttentions = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, false)
+		output, allHiddenStates, allAttentions, err = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, false)
+		if err != nil {
+			log.Fatal(err)
+		}
 	})
 
 	fmt.Printf("output size: %v\n", output.MustSize())
@@ -343,7 +361,10 @@ func TestBertForTokenClassification(t *testing.T) {
 	config.Id2Label = dummyLabelMap
 	config.OutputAttentions = true
 	config.OutputHiddenStates = true
-	model := bert.NewBertForTokenClassification(vs.Root(), config)
+	model, err := bert.NewBertForTokenClassification(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	vocabFile, err := util.CachedPath("bert-base-uncased", "vocab.txt")
 	tk := getBertTokenizer(vocabFile)
@@ -385,7 +406,10 @@ func TestBertForTokenClassification(t *testing.T) {
 	)
 
 	ts.NoGrad(func() {
-		output, allHiddenStates, allAttentions = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, false)
+		output, allHiddenStates, allAttentions, err = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, false)
+		if err != nil {
+			log.Fatal(err)
+		}
 	})
 
 	fmt.Printf("output size: %v\n", output.MustSize())
@@ -424,7 +448,10 @@ func TestBertForQuestionAnswering(t *testing.T) {
 
 	config.OutputAttentions = true
 	config.OutputHiddenStates = true
-	model := bert.NewForBertQuestionAnswering(vs.Root(), config)
+	model, err := bert.NewForBertQuestionAnswering(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	vocabFile, err := util.CachedPath("bert-base-uncased", "vocab.txt")
 	tk := getBertTokenizer(vocabFile)
@@ -466,7 +493,10 @@ func TestBertForQuestionAnswering(t *testing.T) {
 	)
 
 	ts.NoGrad(func() {
-		startScores, endScores, allHiddenStates, allAttentions = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, false)
+		startScores, endScores, allHiddenStates, allAttentions, err = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, false)
+		if err != nil {
+			log.Fatal(err)
+		}
 	})
 
 	gotStartScoresSize := startScores.MustSize()
@@ -495,3 +525,143 @@ func TestBertForQuestionAnswering(t *testing.T) {
 		t.Errorf("Got num of allAttentions: %v\n", len(allAttentions))
 	}
 }
+
+func TestBertForSequenceClassification_SavePretrained(t *testing.T) {
+	config := bert.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"HiddenSize":            int64(16),
+		"NumHiddenLayers":       int64(1),
+		"NumAttentionHeads":     int64(2),
+		"IntermediateSize":      int64(32),
+		"MaxPositionEmbeddings": int64(32),
+	})
+	config.Id2Label = map[int64]string{0: "NEGATIVE", 1: "POSITIVE"}
+
+	dir, err := ioutil.TempDir("", "bert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vs := nn.NewVarStore(gotch.CPU)
+	trained, err := bert.NewBertForSequenceClassification(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trained.SavePretrained(dir); err == nil {
+		t.Errorf("Want error for model without var store\n")
+	}
+	trained.SetVarStore(vs)
+	if err := trained.SavePretrained(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedConfig := new(bert.BertConfig)
+	configFile, err := util.CachedPath(dir, "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadedConfig.Load(configFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, loadedConfig) {
+		t.Errorf("Want: %+v\n", config)
+		t.Errorf("Got: %+v\n", loadedConfig)
+	}
+
+	model := new(bert.BertForSequenceClassification)
+	if _, err := model.Load(dir, loadedConfig, nil, gotch.CPU); err != nil {
+		t.Fatal(err)
+	}
+
+	want := vs.Variables()
+	got := model.VarStore().Variables()
+	for name, x := range want {
+		y, ok := got[name]
+		if !ok || !util.Equal(&x, &y) {
+			t.Errorf("Want weight %q to be loaded back\n", name)
+		}
+	}
+
+	// A loaded model can be saved again.
+	if err := model.SavePretrained(filepath.Join(dir, "copy")); err != nil {
+		t.Error(err)
+	}
+}
+
+// Safetensors files of masked LM models do not store the decoder tied to word embeddings.
+func TestBertForMaskedLM_LoadTiedDecoder(t *testing.T) {
+	config := bert.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"HiddenSize":            int64(16),
+		"NumHiddenLayers":       int64(1),
+		"NumAttentionHeads":     int64(2),
+		"IntermediateSize":      int64(32),
+		"MaxPositionEmbeddings": int64(32),
+	})
+
+	dir, err := ioutil.TempDir("", "bert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vs := nn.NewVarStore(gotch.CPU)
+	if _, err := bert.NewBertForMaskedLM(vs.Root(), config); err != nil {
+		t.Fatal(err)
+	}
+	if err := vs.Root().Remove("cls.predictions.decoder.weight"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SavePretrained(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.SaveVarStore(vs, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	model := new(bert.BertForMaskedLM)
+	report, err := model.Load(dir, config, nil, gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Complete() {
+		t.Errorf("Want: %v\n", "all weights loaded")
+		t.Errorf("Got: %v\n", report)
+	}
+
+	got := model.VarStore().Variables()
+	embeddings, decoder := got["bert.embeddings.word_embeddings.weight"], got["cls.predictions.decoder.weight"]
+	if !util.Equal(&embeddings, &decoder) {
+		t.Errorf("Want decoder weight equal to word embeddings\n")
+	}
+}
+
+func TestNewBertModel_InvalidConfig(t *testing.T) {
+	newConfig := func() *bert.BertConfig {
+		return bert.NewConfig(map[string]interface{}{
+			"VocabSize":             int64(50),
+			"HiddenSize":            int64(16),
+			"NumHiddenLayers":       int64(1),
+			"NumAttentionHeads":     int64(2),
+			"IntermediateSize":      int64(32),
+			"MaxPositionEmbeddings": int64(32),
+		})
+	}
+
+	config := newConfig()
+	config.HiddenAct = "unknown"
+	_, err := bert.NewBertModel(nn.NewVarStore(gotch.CPU).Root(), config)
+	if !errors.Is(err, util.ErrUnsupportedActivation) {
+		t.Errorf("Want: %v\n", util.ErrUnsupportedActivation)
+		t.Errorf("Got: %v\n", err)
+	}
+
+	config = newConfig()
+	config.NumAttentionHeads = 3
+	_, err = bert.NewBertForSequenceClassification(nn.NewVarStore(gotch.CPU).Root(), config)
+	if !errors.Is(err, util.ErrShapeMismatch) {
+		t.Errorf("Want: %v\n", util.ErrShapeMismatch)
+		t.Errorf("Got: %v\n", err)
+	}
+}
@@ -0,0 +1,18 @@
+package bert
+
+import (
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// Registers BERT configuration, tokenizer and task models for auto-loading
+// (see `transformer.AutoModelFor`).
+func init() {
+	pretrained.RegisterConfig("bert", func() pretrained.Config { return new(BertConfig) })
+	pretrained.RegisterTokenizer("bert", func() pretrained.Tokenizer { return NewTokenizer() })
+
+	pretrained.RegisterModel("bert", pretrained.MaskedLM, "BertForMaskedLM", func() pretrained.Model { return new(BertForMaskedLM) })
+	pretrained.RegisterModel("bert", pretrained.SequenceClassification, "BertForSequenceClassification", func() pretrained.Model { return new(BertForSequenceClassification) })
+	pretrained.RegisterModel("bert", pretrained.MultipleChoice, "BertForMultipleChoice", func() pretrained.Model { return new(BertForMultipleChoice) })
+	pretrained.RegisterModel("bert", pretrained.TokenClassification, "BertForTokenClassification", func() pretrained.Model { return new(BertForTokenClassification) })
+	pretrained.RegisterModel("bert", pretrained.QuestionAnswering, "BertForQuestionAnswering", func() pretrained.Model { return new(BertForQuestionAnswering) })
+}
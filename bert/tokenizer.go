@@ -2,8 +2,10 @@ package bert
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/decoder"
 	"github.com/sugarme/tokenizer/model/wordpiece"
 	"github.com/sugarme/tokenizer/normalizer"
 	"github.com/sugarme/tokenizer/pretokenizer"
@@ -16,11 +18,11 @@ import (
 type BertTokenizerFast = tokenizer.Tokenizer
 
 // BertJapaneseTokenizerFromPretrained initiate BERT tokenizer for Japanese language from pretrained file.
-func BertJapaneseTokenizerFromPretrained(pretrainedModelNameOrPath string, customParams map[string]interface{}) *tokenizer.Tokenizer {
+func BertJapaneseTokenizerFromPretrained(pretrainedModelNameOrPath string, customParams map[string]interface{}) (*tokenizer.Tokenizer, error) {
 
 	// TODO: implement it
 
-	panic("Not implemented yet.")
+	return nil, fmt.Errorf("BertJapaneseTokenizerFromPretrained() failed: %w", util.ErrNotImplemented)
 }
 
 type Tokenizer struct {
@@ -33,7 +35,7 @@ func NewTokenizer() *Tokenizer {
 }
 
 func (bt *Tokenizer) Load(modelNameOrPath string, params map[string]interface{}) error {
-	cachedFile, err := util.CachedPath(modelNameOrPath, "vocab.txt")
+	cachedFile, err := util.CachedPathWithProgress(modelNameOrPath, "vocab.txt", util.ProgressFromParams(params))
 	if err != nil {
 		return err
 	}
@@ -51,6 +53,8 @@ func (bt *Tokenizer) Load(modelNameOrPath string, params map[string]interface{})
 	bertPreTokenizer := pretokenizer.NewBertPreTokenizer()
 	bt.WithPreTokenizer(bertPreTokenizer)
 
+	bt.WithDecoder(decoder.DefaultWordpieceDecoder())
+
 	var specialTokens []tokenizer.AddedToken
 	specialTokens = append(specialTokens, tokenizer.NewAddedToken("[MASK]", true))
 
@@ -58,13 +62,13 @@ func (bt *Tokenizer) Load(modelNameOrPath string, params map[string]interface{})
 
 	sepId, ok := bt.TokenToId("[SEP]")
 	if !ok {
-		return fmt.Errorf("Cannot find ID for [SEP] token.\n")
+		return fmt.Errorf("Cannot find ID for [SEP] token: %w", util.ErrMissingToken)
 	}
 	sep := processor.PostToken{Id: sepId, Value: "[SEP]"}
 
 	clsId, ok := bt.TokenToId("[CLS]")
 	if !ok {
-		return fmt.Errorf("Cannot find ID for [CLS] token.\n")
+		return fmt.Errorf("Cannot find ID for [CLS] token: %w", util.ErrMissingToken)
 	}
 	cls := processor.PostToken{Id: clsId, Value: "[CLS]"}
 
@@ -75,3 +79,13 @@ func (bt *Tokenizer) Load(modelNameOrPath string, params map[string]interface{})
 
 	return nil
 }
+
+// SavePretrained saves tokenizer vocab file `vocab.txt` to directory `dir`.
+// This method implements `pretrained.Tokenizer` interface.
+func (bt *Tokenizer) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return bt.GetModel().Save(dir)
+}
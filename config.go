@@ -19,9 +19,15 @@ import (
 // If `modleNameOrPath` is valid URL, file will be downloaded and cached.
 // Finally, configuration data will be loaded to `config` parameter.
 func LoadConfig(config pretrained.Config, modelNameOrPath string, customParams map[string]interface{}) error {
-	configFile, err := util.CachedPath(modelNameOrPath, "config.json")
+	configFile, err := util.CachedPathWithProgress(modelNameOrPath, "config.json", util.ProgressFromParams(customParams))
 	if err != nil {
 		return err
 	}
 	return config.Load(configFile, customParams)
 }
+
+// SaveConfig saves configuration to local directory `dir` as `config.json` file.
+// Saved configuration can be loaded back with `LoadConfig` by passing `dir` as `modelNameOrPath`.
+func SaveConfig(config pretrained.Config, dir string) error {
+	return config.SavePretrained(dir)
+}
@@ -0,0 +1,125 @@
+package distilbert
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// MultiHeadSelfAttention:
+// =======================
+
+// MultiHeadSelfAttention holds layers of DistilBERT self-attention.
+// Sub-layers are named `q_lin`, `k_lin`, `v_lin` and `out_lin` as in
+// pretrained checkpoints.
+type MultiHeadSelfAttention struct {
+	NHeads           int64
+	DimPerHead       int64
+	Dropout          *util.Dropout
+	OutputAttentions bool
+	QLin             *nn.Linear
+	KLin             *nn.Linear
+	VLin             *nn.Linear
+	OutLin           *nn.Linear
+}
+
+// NewMultiHeadSelfAttention creates a new `MultiHeadSelfAttention`.
+func NewMultiHeadSelfAttention(p *nn.Path, config *DistilBertConfig) (*MultiHeadSelfAttention, error) {
+	if config.Dim%config.NHeads != 0 {
+		return nil, fmt.Errorf("Dim (%v) is not a multiple of the number of attention heads (%v): %w", config.Dim, config.NHeads, util.ErrShapeMismatch)
+	}
+
+	lconfig := nn.DefaultLinearConfig()
+	qLin := nn.NewLinear(p.Sub("q_lin"), config.Dim, config.Dim, lconfig)
+	kLin := nn.NewLinear(p.Sub("k_lin"), config.Dim, config.Dim, lconfig)
+	vLin := nn.NewLinear(p.Sub("v_lin"), config.Dim, config.Dim, lconfig)
+	outLin := nn.NewLinear(p.Sub("out_lin"), config.Dim, config.Dim, lconfig)
+
+	return &MultiHeadSelfAttention{
+		NHeads:           config.NHeads,
+		DimPerHead:       config.Dim / config.NHeads,
+		Dropout:          util.NewDropout(config.AttentionDropout),
+		OutputAttentions: config.OutputAttentions,
+		QLin:             qLin,
+		KLin:             kLin,
+		VLin:             vLin,
+		OutLin:           outLin,
+	}, nil
+}
+
+// shape splits last dimension into heads: (bs, seq, dim) -> (bs, heads, seq, dimPerHead).
+func (a *MultiHeadSelfAttention) shape(x *ts.Tensor, bs int64) *ts.Tensor {
+	xview := x.MustView([]int64{bs, -1, a.NHeads, a.DimPerHead}, false)
+	return xview.MustTranspose(1, 2, true)
+}
+
+// unshape merges heads back: (bs, heads, seq, dimPerHead) -> (bs, seq, dim).
+func (a *MultiHeadSelfAttention) unshape(x *ts.Tensor, bs int64) *ts.Tensor {
+	xT := x.MustTranspose(1, 2, false)
+	xCon := xT.MustContiguous(true)
+	return xCon.MustView([]int64{bs, -1, a.NHeads * a.DimPerHead}, true)
+}
+
+// ForwardT forwards pass through the self-attention layer.
+//
+// Params:
+//   - `hiddenStates`: tensor of shape (batch size, sequence length, dim)
+//   - `mask`: optional mask of shape (batch size, sequence length). Positions with value 0 are
+//     not attended to. `ts.None` can be used to attend to all positions.
+//   - `train`: whether to apply attention dropout.
+//
+// Returns:
+//   - context tensor of shape (batch size, sequence length, dim)
+//   - attention weights of shape (batch size, heads, sequence length, sequence length)
+//     if `OutputAttentions` is set, `ts.None` otherwise.
+func (a *MultiHeadSelfAttention) ForwardT(hiddenStates, mask *ts.Tensor, train bool) (retVal, retValOpt *ts.Tensor) {
+	bs := hiddenStates.MustSize()[0]
+
+	q := hiddenStates.Apply(a.QLin)
+	k := hiddenStates.Apply(a.KLin)
+	v := hiddenStates.Apply(a.VLin)
+
+	query := a.shape(q, bs)
+	q.MustDrop()
+	key := a.shape(k, bs)
+	k.MustDrop()
+	value := a.shape(v, bs)
+	v.MustDrop()
+
+	query = query.MustDivScalar(ts.FloatScalar(math.Sqrt(float64(a.DimPerHead))), true)
+	keyT := key.MustTranspose(-1, -2, true)
+	scores := query.MustMatmul(keyT, true)
+	keyT.MustDrop()
+
+	if mask.MustDefined() {
+		// (bs, seq) -> (bs, 1, 1, seq), true where position must be masked.
+		maskTmp := mask.MustEq(ts.IntScalar(0), false)
+		maskView := maskTmp.MustView([]int64{bs, 1, 1, -1}, true)
+		fillMask := maskView.MustExpandAs(scores, true)
+		scores = scores.MustMaskedFill(fillMask, ts.FloatScalar(-math.MaxFloat32), true)
+		fillMask.MustDrop()
+	}
+
+	weights := scores.MustSoftmax(-1, gotch.Float, true)
+	weightsDropped := weights.ApplyT(a.Dropout, train)
+
+	contextTmp := weightsDropped.MustMatmul(value, true)
+	value.MustDrop()
+	context := a.unshape(contextTmp, bs)
+	contextTmp.MustDrop()
+
+	retVal = context.Apply(a.OutLin)
+	context.MustDrop()
+
+	if !a.OutputAttentions {
+		weights.MustDrop()
+		return retVal, ts.None
+	}
+
+	return retVal, weights
+}
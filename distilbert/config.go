@@ -0,0 +1,159 @@
+package distilbert
+
+// distilbert package implements DistilBERT transformer model.
+// DistilBERT checkpoints share BERT WordPiece vocabulary, hence `bert.Tokenizer` is used to encode inputs.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// DistilBertConfig defines the DistilBERT model architecture (i.e., number of layers,
+// hidden layer size, label mapping...)
+type DistilBertConfig struct {
+	VocabSize             int64            `json:"vocab_size"`
+	Dim                   int64            `json:"dim"`
+	NLayers               int64            `json:"n_layers"`
+	NHeads                int64            `json:"n_heads"`
+	HiddenDim             int64            `json:"hidden_dim"`
+	Activation            string           `json:"activation"`
+	Dropout               float64          `json:"dropout"`
+	AttentionDropout      float64          `json:"attention_dropout"`
+	QaDropout             float64          `json:"qa_dropout"`
+	SeqClassifDropout     float64          `json:"seq_classif_dropout"`
+	MaxPositionEmbeddings int64            `json:"max_position_embeddings"`
+	SinusoidalPosEmbds    bool             `json:"sinusoidal_pos_embds"`
+	InitializerRange      float32          `json:"initializer_range"`
+	PadTokenId            int64            `json:"pad_token_id"`
+	OutputAttentions      bool             `json:"output_attentions"`
+	OutputHiddenStates    bool             `json:"output_hidden_states"`
+	Id2Label              map[int64]string `json:"id2label"`
+	Label2Id              map[string]int64 `json:"label2id"`
+	NumLabels             int64            `json:"num_labels"`
+}
+
+// NewConfig initiates DistilBertConfig with given input parameters or default values
+// of `distilbert-base-uncased`.
+func NewConfig(customParams map[string]interface{}) *DistilBertConfig {
+	defaultValues := map[string]interface{}{
+		"VocabSize":             int64(30522),
+		"Dim":                   int64(768),
+		"NLayers":               int64(6),
+		"NHeads":                int64(12),
+		"HiddenDim":             int64(3072),
+		"Activation":            "gelu",
+		"Dropout":               float64(0.1),
+		"AttentionDropout":      float64(0.1),
+		"QaDropout":             float64(0.1),
+		"SeqClassifDropout":     float64(0.2),
+		"MaxPositionEmbeddings": int64(512),
+		"InitializerRange":      float32(0.02),
+	}
+
+	params := defaultValues
+	for k, v := range customParams {
+		if _, ok := params[k]; ok {
+			params[k] = v
+		}
+	}
+
+	config := new(DistilBertConfig)
+	config.updateParams(params)
+
+	return config
+}
+
+// ConfigFromFile loads DistilBertConfig from a JSON file.
+func ConfigFromFile(filename string) (*DistilBertConfig, error) {
+	config := new(DistilBertConfig)
+	if err := config.fromFile(filename); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Load loads model configuration from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Config` interface.
+func (c *DistilBertConfig) Load(modelNameOrPath string, params map[string]interface{}) error {
+	err := c.fromFile(modelNameOrPath)
+	if err != nil {
+		return err
+	}
+
+	// Update custom parameters
+	c.updateParams(params)
+
+	return nil
+}
+
+// SavePretrained saves configuration to directory `dir` as `config.json` file.
+// This method implements `pretrained.Config` interface.
+func (c *DistilBertConfig) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buff, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, util.ConfigName), buff, 0644)
+}
+
+func (c *DistilBertConfig) fromFile(filename string) error {
+	filePath, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	buff, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(buff, c)
+	if err != nil {
+		return fmt.Errorf("Could not parse configuration to DistilBertConfig: %v: %w", err, util.ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// GetVocabSize returns vocabulary size.
+func (c *DistilBertConfig) GetVocabSize() int64 {
+	return c.VocabSize
+}
+
+// numLabels returns number of labels for classification heads. It is taken from
+// `NumLabels` if set, otherwise from the label mapping.
+func (c *DistilBertConfig) numLabels() int64 {
+	if c.NumLabels > 0 {
+		return c.NumLabels
+	}
+	return int64(len(c.Id2Label))
+}
+
+func (c *DistilBertConfig) updateParams(params map[string]interface{}) {
+	for k, v := range params {
+		c.updateField(k, v)
+	}
+}
+
+func (c *DistilBertConfig) updateField(field string, value interface{}) {
+	// Check whether field name exists
+	if reflect.ValueOf(c).Elem().FieldByName(field).IsValid() {
+		// Check whether same type
+		if reflect.ValueOf(c).Elem().FieldByName(field).Kind() == reflect.TypeOf(value).Kind() {
+			reflect.ValueOf(c).Elem().FieldByName(field).Set(reflect.ValueOf(value))
+		}
+	}
+}
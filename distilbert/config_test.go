@@ -0,0 +1,61 @@
+package distilbert_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/distilbert"
+)
+
+func TestConfigFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "distilbert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Excerpt of `distilbert-base-uncased-finetuned-sst-2-english` config.
+	data := `{
+  "activation": "gelu",
+  "architectures": ["DistilBertForSequenceClassification"],
+  "attention_dropout": 0.1,
+  "dim": 768,
+  "dropout": 0.1,
+  "hidden_dim": 3072,
+  "id2label": {"0": "NEGATIVE", "1": "POSITIVE"},
+  "label2id": {"NEGATIVE": 0, "POSITIVE": 1},
+  "max_position_embeddings": 512,
+  "model_type": "distilbert",
+  "n_heads": 12,
+  "n_layers": 6,
+  "pad_token_id": 0,
+  "qa_dropout": 0.1,
+  "seq_classif_dropout": 0.2,
+  "sinusoidal_pos_embds": false,
+  "vocab_size": 30522
+}`
+	configFile := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := distilbert.ConfigFromFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLabels := map[int64]string{0: "NEGATIVE", 1: "POSITIVE"}
+	if !reflect.DeepEqual(wantLabels, config.Id2Label) {
+		t.Errorf("Want: %v\n", wantLabels)
+		t.Errorf("Got: %v\n", config.Id2Label)
+	}
+
+	wantLayers := int64(6)
+	if !reflect.DeepEqual(wantLayers, config.NLayers) {
+		t.Errorf("Want: %v\n", wantLayers)
+		t.Errorf("Got: %v\n", config.NLayers)
+	}
+}
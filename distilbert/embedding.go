@@ -0,0 +1,86 @@
+package distilbert
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// DistilBertEmbeddings:
+// =====================
+
+// DistilBertEmbeddings holds word and position embeddings. Unlike BERT,
+// DistilBERT has no token type (segment) embeddings.
+type DistilBertEmbeddings struct {
+	WordEmbeddings     *nn.Embedding
+	PositionEmbeddings *nn.Embedding
+	LayerNorm          *nn.LayerNorm
+	Dropout            *util.Dropout
+}
+
+// NewDistilBertEmbeddings builds a new DistilBertEmbeddings.
+//
+// NOTE. When `SinusoidalPosEmbds` is set, pretrained checkpoints still store
+// the (frozen) sinusoidal table as `position_embeddings.weight`, so it is loaded as
+// any other embedding weight.
+func NewDistilBertEmbeddings(p *nn.Path, config *DistilBertConfig) *DistilBertEmbeddings {
+	embeddingConfig := nn.DefaultEmbeddingConfig()
+	embeddingConfig.PaddingIdx = config.PadTokenId
+
+	wordEmbeddings := nn.NewEmbedding(p.Sub("word_embeddings"), config.VocabSize, config.Dim, embeddingConfig)
+	positionEmbeddings := nn.NewEmbedding(p.Sub("position_embeddings"), config.MaxPositionEmbeddings, config.Dim, nn.DefaultEmbeddingConfig())
+
+	layerNormConfig := nn.DefaultLayerNormConfig()
+	layerNormConfig.Eps = 1e-12
+	layerNorm := nn.NewLayerNorm(p.Sub("LayerNorm"), []int64{config.Dim}, layerNormConfig)
+
+	dropout := util.NewDropout(config.Dropout)
+
+	return &DistilBertEmbeddings{wordEmbeddings, positionEmbeddings, layerNorm, dropout}
+}
+
+// ForwardT passes through the embedding layer.
+//
+// Exactly one of `inputIds` (batch size, sequence length) or `inputEmbeds`
+// (batch size, sequence length, dim) must be defined.
+func (e *DistilBertEmbeddings) ForwardT(inputIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, err error) {
+	var inputEmbeddings *ts.Tensor
+	switch {
+	case inputIds.MustDefined() && inputEmbeds.MustDefined():
+		err = fmt.Errorf("Only one of input ids or input embeddings may be set: %w", util.ErrInvalidInput)
+		return retVal, err
+	case inputIds.MustDefined():
+		inputEmbeddings = inputIds.ApplyT(e.WordEmbeddings, train)
+	case inputEmbeds.MustDefined():
+		inputEmbeddings = inputEmbeds.MustShallowClone()
+	default:
+		err = fmt.Errorf("At least one of input ids or input embeddings must be set: %w", util.ErrInvalidInput)
+		return retVal, err
+	}
+
+	size := inputEmbeddings.MustSize()
+	seqLength := size[1]
+	if seqLength > e.PositionEmbeddings.Ws.MustSize()[0] {
+		inputEmbeddings.MustDrop()
+		err = fmt.Errorf("Sequence length (%v) exceeds maximum position embeddings (%v): %w", seqLength, e.PositionEmbeddings.Ws.MustSize()[0], util.ErrInvalidInput)
+		return retVal, err
+	}
+
+	posIds := ts.MustArange(ts.IntScalar(seqLength), gotch.Int64, inputEmbeddings.MustDevice())
+	posEmbeddings := posIds.Apply(e.PositionEmbeddings) // (seq length, dim); broadcast over batch
+	posIds.MustDrop()
+
+	input := inputEmbeddings.MustAdd(posEmbeddings, true)
+	posEmbeddings.MustDrop()
+
+	normed := input.Apply(e.LayerNorm)
+	input.MustDrop()
+	retVal = normed.ApplyT(e.Dropout, train)
+	normed.MustDrop()
+
+	return retVal, nil
+}
@@ -0,0 +1,536 @@
+package distilbert
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/util"
+)
+
+// DistilBertModel:
+// ================
+
+// DistilBertModel defines base architecture for DistilBERT models.
+// Task-specific models can be built from this base model.
+//
+// Fields:
+//   - Embeddings: word and position embeddings (no token type embeddings)
+//   - Transformer: a stack of `NLayers` (6 for pretrained models) transformer blocks
+type DistilBertModel struct {
+	Embeddings  *DistilBertEmbeddings
+	Transformer *Transformer
+}
+
+// NewDistilBertModel builds a new `DistilBertModel`.
+//
+// Params:
+//   - `p`: Variable store path for the root of the DistilBERT model
+//   - `config`: DistilBertConfig configuration for model architecture
+func NewDistilBertModel(p *nn.Path, config *DistilBertConfig) (*DistilBertModel, error) {
+	embeddings := NewDistilBertEmbeddings(p.Sub("embeddings"), config)
+	transformer, err := NewTransformer(p.Sub("transformer"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DistilBertModel{embeddings, transformer}, nil
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `mask`: optional mask of shape (batch size, sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, dim).
+//     If None, input ids must be provided (see `inputIds`).
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, dim)
+//   - `hiddenStates`: slice of tensors of length nLayers with shape (batch size, sequence length, dim)
+//   - `attentions`: slice of tensors of length nLayers with shape (batch size, heads, sequence length, sequence length)
+func (m *DistilBertModel) ForwardT(inputIds, mask, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	embeddingOutput, err := m.Embeddings.ForwardT(inputIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	hiddenState, allHiddenStates, allAttentions := m.Transformer.ForwardT(embeddingOutput, mask, train)
+	if len(m.Transformer.Layers) > 0 {
+		embeddingOutput.MustDrop()
+	}
+
+	return hiddenState, allHiddenStates, allAttentions, nil
+}
+
+// DistilBertForMaskedLM:
+// ======================
+
+// DistilBertForMaskedLM is DistilBERT for masked language model.
+//
+// It is made of the following blocks:
+//   - `distilbert`: Base DistilBertModel
+//   - `vocab_transform`, `vocab_layer_norm`: transform applied to hidden states
+//   - `vocab_projector`: linear layer projecting to vocabulary size
+type DistilBertForMaskedLM struct {
+	distilbert     *DistilBertModel
+	vocabTransform *nn.Linear
+	activation     util.ActivationFn
+	vocabLayerNorm *nn.LayerNorm
+	vocabProjector *nn.Linear
+	config         *DistilBertConfig
+	vs             *nn.VarStore
+}
+
+// NewDistilBertForMaskedLM creates DistilBertForMaskedLM.
+func NewDistilBertForMaskedLM(p *nn.Path, config *DistilBertConfig) (*DistilBertForMaskedLM, error) {
+	distilbert, err := NewDistilBertModel(p.Sub("distilbert"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	activation, ok := util.ActivationFnMap[config.Activation]
+	if !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.Activation)
+	}
+
+	lnConfig := nn.DefaultLayerNormConfig()
+	lnConfig.Eps = 1e-12
+
+	return &DistilBertForMaskedLM{
+		distilbert:     distilbert,
+		vocabTransform: nn.NewLinear(p.Sub("vocab_transform"), config.Dim, config.Dim, nn.DefaultLinearConfig()),
+		activation:     activation,
+		vocabLayerNorm: nn.NewLayerNorm(p.Sub("vocab_layer_norm"), []int64{config.Dim}, lnConfig),
+		vocabProjector: nn.NewLinear(p.Sub("vocab_projector"), config.Dim, config.VocabSize, nn.DefaultLinearConfig()),
+		config:         config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (mlm *DistilBertForMaskedLM) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	distilbertConfig, ok := config.(*DistilBertConfig)
+	if !ok {
+		return nil, fmt.Errorf("DistilBertForMaskedLM.Load() failed: invalid config type %T, want *distilbert.DistilBertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewDistilBertForMaskedLM(vs.Root(), distilbertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*mlm = *model
+	mlm.vs = vs
+
+	opts := util.LoadOptionsFromParams(params, "vocab_transform", "vocab_layer_norm", "vocab_projector")
+	// The vocab projector weight is tied to word embeddings.
+	opts.Tied = map[string]string{
+		"vocab_projector.weight": "distilbert.embeddings.word_embeddings.weight",
+	}
+
+	return util.LoadVarStore(vs, modelNameOrPath, opts, util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (mlm *DistilBertForMaskedLM) SavePretrained(dir string) error {
+	return util.SaveModel(dir, mlm.config, mlm.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (mlm *DistilBertForMaskedLM) VarStore() *nn.VarStore {
+	return mlm.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (mlm *DistilBertForMaskedLM) SetVarStore(vs *nn.VarStore) {
+	mlm.vs = vs
+}
+
+// Config returns model configuration.
+func (mlm *DistilBertForMaskedLM) Config() *DistilBertConfig {
+	return mlm.config
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `mask`: optional mask of shape (batch size, sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, dim).
+//     If None, input ids must be provided (see `inputIds`).
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, vocab size)
+//   - `hiddenStates`: slice of tensors of length nLayers with shape (batch size, sequence length, dim)
+//   - `attentions`: slice of tensors of length nLayers with shape (batch size, heads, sequence length, sequence length)
+func (mlm *DistilBertForMaskedLM) ForwardT(inputIds, mask, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, allHiddenStates, allAttentions, err := mlm.distilbert.ForwardT(inputIds, mask, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	x1 := hiddenState.Apply(mlm.vocabTransform)
+	hiddenState.MustDrop()
+	x2 := mlm.activation.Fwd(x1)
+	x1.MustDrop()
+	x3 := x2.Apply(mlm.vocabLayerNorm)
+	x2.MustDrop()
+	retVal = x3.Apply(mlm.vocabProjector)
+	x3.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
+
+// DistilBertForSequenceClassification:
+// ====================================
+
+// DistilBertForSequenceClassification is DistilBERT for sequence classification.
+//
+// It is made of the following blocks:
+//   - `distilbert`: Base DistilBertModel
+//   - `pre_classifier`: linear layer (followed by ReLU) applied to the first token hidden state
+//   - `classifier`: linear layer for classification
+type DistilBertForSequenceClassification struct {
+	distilbert    *DistilBertModel
+	preClassifier *nn.Linear
+	classifier    *nn.Linear
+	dropout       *util.Dropout
+	config        *DistilBertConfig
+	vs            *nn.VarStore
+}
+
+// NewDistilBertForSequenceClassification creates a new DistilBertForSequenceClassification.
+//
+// NOTE. Number of labels is taken from `config.NumLabels` or, if not set, from `config.Id2Label`.
+func NewDistilBertForSequenceClassification(p *nn.Path, config *DistilBertConfig) (*DistilBertForSequenceClassification, error) {
+	distilbert, err := NewDistilBertModel(p.Sub("distilbert"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	numLabels := config.numLabels()
+	if numLabels == 0 {
+		return nil, fmt.Errorf("Number of labels must be set in config (`num_labels` or `id2label`): %w", util.ErrInvalidConfig)
+	}
+
+	return &DistilBertForSequenceClassification{
+		distilbert:    distilbert,
+		preClassifier: nn.NewLinear(p.Sub("pre_classifier"), config.Dim, config.Dim, nn.DefaultLinearConfig()),
+		classifier:    nn.NewLinear(p.Sub("classifier"), config.Dim, numLabels, nn.DefaultLinearConfig()),
+		dropout:       util.NewDropout(config.SeqClassifDropout),
+		config:        config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (sc *DistilBertForSequenceClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	distilbertConfig, ok := config.(*DistilBertConfig)
+	if !ok {
+		return nil, fmt.Errorf("DistilBertForSequenceClassification.Load() failed: invalid config type %T, want *distilbert.DistilBertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewDistilBertForSequenceClassification(vs.Root(), distilbertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*sc = *model
+	sc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "pre_classifier", "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (sc *DistilBertForSequenceClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, sc.config, sc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (sc *DistilBertForSequenceClassification) VarStore() *nn.VarStore {
+	return sc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (sc *DistilBertForSequenceClassification) SetVarStore(vs *nn.VarStore) {
+	sc.vs = vs
+}
+
+// Config returns model configuration.
+func (sc *DistilBertForSequenceClassification) Config() *DistilBertConfig {
+	return sc.config
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `mask`: optional mask of shape (batch size, sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, dim).
+//     If None, input ids must be provided (see `inputIds`).
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, num labels)
+//   - `hiddenStates`: slice of tensors of length nLayers with shape (batch size, sequence length, dim)
+//   - `attentions`: slice of tensors of length nLayers with shape (batch size, heads, sequence length, sequence length)
+func (sc *DistilBertForSequenceClassification) ForwardT(inputIds, mask, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, allHiddenStates, allAttentions, err := sc.distilbert.ForwardT(inputIds, mask, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	pooled := hiddenState.MustSelect(1, 0, true) // first token: (batch size, dim)
+	x1 := pooled.Apply(sc.preClassifier)
+	pooled.MustDrop()
+	x2 := x1.MustRelu(true)
+	x3 := x2.ApplyT(sc.dropout, train)
+	x2.MustDrop()
+	retVal = x3.Apply(sc.classifier)
+	x3.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
+
+// DistilBertForTokenClassification:
+// =================================
+
+// DistilBertForTokenClassification is DistilBERT for token classification (e.g., NER, POS).
+//
+// It is made of the following blocks:
+//   - `distilbert`: Base DistilBertModel
+//   - `classifier`: linear layer for token classification
+type DistilBertForTokenClassification struct {
+	distilbert *DistilBertModel
+	dropout    *util.Dropout
+	classifier *nn.Linear
+	config     *DistilBertConfig
+	vs         *nn.VarStore
+}
+
+// NewDistilBertForTokenClassification creates a new DistilBertForTokenClassification.
+//
+// NOTE. Number of labels is taken from `config.NumLabels` or, if not set, from `config.Id2Label`.
+func NewDistilBertForTokenClassification(p *nn.Path, config *DistilBertConfig) (*DistilBertForTokenClassification, error) {
+	distilbert, err := NewDistilBertModel(p.Sub("distilbert"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	numLabels := config.numLabels()
+	if numLabels == 0 {
+		return nil, fmt.Errorf("Number of labels must be set in config (`num_labels` or `id2label`): %w", util.ErrInvalidConfig)
+	}
+
+	return &DistilBertForTokenClassification{
+		distilbert: distilbert,
+		dropout:    util.NewDropout(config.Dropout),
+		classifier: nn.NewLinear(p.Sub("classifier"), config.Dim, numLabels, nn.DefaultLinearConfig()),
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (tc *DistilBertForTokenClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	distilbertConfig, ok := config.(*DistilBertConfig)
+	if !ok {
+		return nil, fmt.Errorf("DistilBertForTokenClassification.Load() failed: invalid config type %T, want *distilbert.DistilBertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewDistilBertForTokenClassification(vs.Root(), distilbertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*tc = *model
+	tc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (tc *DistilBertForTokenClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, tc.config, tc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (tc *DistilBertForTokenClassification) VarStore() *nn.VarStore {
+	return tc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (tc *DistilBertForTokenClassification) SetVarStore(vs *nn.VarStore) {
+	tc.vs = vs
+}
+
+// Config returns model configuration.
+func (tc *DistilBertForTokenClassification) Config() *DistilBertConfig {
+	return tc.config
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `mask`: optional mask of shape (batch size, sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, dim).
+//     If None, input ids must be provided (see `inputIds`).
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, num labels)
+//   - `hiddenStates`: slice of tensors of length nLayers with shape (batch size, sequence length, dim)
+//   - `attentions`: slice of tensors of length nLayers with shape (batch size, heads, sequence length, sequence length)
+func (tc *DistilBertForTokenClassification) ForwardT(inputIds, mask, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, allHiddenStates, allAttentions, err := tc.distilbert.ForwardT(inputIds, mask, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	x := hiddenState.ApplyT(tc.dropout, train)
+	hiddenState.MustDrop()
+	retVal = x.Apply(tc.classifier)
+	x.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
+
+// DistilBertForQuestionAnswering:
+// ===============================
+
+// DistilBertForQuestionAnswering is DistilBERT for extractive question answering.
+// It predicts start and end positions of the answer span in a context.
+//
+// It is made of the following blocks:
+//   - `distilbert`: Base DistilBertModel
+//   - `qa_outputs`: linear layer for start and end logits
+type DistilBertForQuestionAnswering struct {
+	distilbert *DistilBertModel
+	dropout    *util.Dropout
+	qaOutputs  *nn.Linear
+	config     *DistilBertConfig
+	vs         *nn.VarStore
+}
+
+// NewDistilBertForQuestionAnswering creates a new DistilBertForQuestionAnswering.
+func NewDistilBertForQuestionAnswering(p *nn.Path, config *DistilBertConfig) (*DistilBertForQuestionAnswering, error) {
+	distilbert, err := NewDistilBertModel(p.Sub("distilbert"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DistilBertForQuestionAnswering{
+		distilbert: distilbert,
+		dropout:    util.NewDropout(config.QaDropout),
+		qaOutputs:  nn.NewLinear(p.Sub("qa_outputs"), config.Dim, 2, nn.DefaultLinearConfig()),
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (qa *DistilBertForQuestionAnswering) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	distilbertConfig, ok := config.(*DistilBertConfig)
+	if !ok {
+		return nil, fmt.Errorf("DistilBertForQuestionAnswering.Load() failed: invalid config type %T, want *distilbert.DistilBertConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewDistilBertForQuestionAnswering(vs.Root(), distilbertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*qa = *model
+	qa.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "qa_outputs"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (qa *DistilBertForQuestionAnswering) SavePretrained(dir string) error {
+	return util.SaveModel(dir, qa.config, qa.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (qa *DistilBertForQuestionAnswering) VarStore() *nn.VarStore {
+	return qa.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (qa *DistilBertForQuestionAnswering) SetVarStore(vs *nn.VarStore) {
+	qa.vs = vs
+}
+
+// Config returns model configuration.
+func (qa *DistilBertForQuestionAnswering) Config() *DistilBertConfig {
+	return qa.config
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `mask`: optional mask of shape (batch size, sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, dim).
+//     If None, input ids must be provided (see `inputIds`).
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `startLogits`: tensor of shape (batch size, sequence length)
+//   - `endLogits`: tensor of shape (batch size, sequence length)
+//   - `hiddenStates`: slice of tensors of length nLayers with shape (batch size, sequence length, dim)
+//   - `attentions`: slice of tensors of length nLayers with shape (batch size, heads, sequence length, sequence length)
+func (qa *DistilBertForQuestionAnswering) ForwardT(inputIds, mask, inputEmbeds *ts.Tensor, train bool) (retVal1, retVal2 *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, allHiddenStates, allAttentions, err := qa.distilbert.ForwardT(inputIds, mask, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	x := hiddenState.ApplyT(qa.dropout, train)
+	hiddenState.MustDrop()
+	logits := x.Apply(qa.qaOutputs)
+	x.MustDrop()
+
+	splits := logits.MustSplit(1, -1, true) // split along last dim
+	startLogits := splits[0].MustSqueezeDim(-1, true)
+	endLogits := splits[1].MustSqueezeDim(-1, true)
+
+	return startLogits, endLogits, allHiddenStates, allAttentions, nil
+}
@@ -0,0 +1,96 @@
+package distilbert_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/distilbert"
+)
+
+// Padding positions must not change outputs of non-padded positions.
+func TestDistilBertModel_Mask(t *testing.T) {
+	config := distilbert.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"Dim":                   int64(16),
+		"NLayers":               int64(2),
+		"NHeads":                int64(2),
+		"HiddenDim":             int64(32),
+		"MaxPositionEmbeddings": int64(32),
+	})
+	vs := nn.NewVarStore(gotch.CPU)
+	model, err := distilbert.NewDistilBertModel(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	short := ts.MustOfSlice([]int64{2, 7, 9}).MustView([]int64{1, 3}, true)
+	padded := ts.MustOfSlice([]int64{2, 7, 9, 0, 0}).MustView([]int64{1, 5}, true)
+	mask := ts.MustOfSlice([]int64{1, 1, 1, 0, 0}).MustView([]int64{1, 5}, true)
+
+	want, _, _, err := model.ForwardT(short, ts.None, ts.None, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _, _, err := model.ForwardT(padded, mask, ts.None, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := out.MustNarrow(1, 0, 3, true)
+
+	diff := want.MustSub(got, false).MustAbs(true).MustMax(true).Float64Values()[0]
+	if diff > 1e-5 {
+		t.Errorf("Want padded outputs equal to unpadded ones, max difference: %v\n", diff)
+	}
+}
+
+func TestDistilBertForMaskedLM(t *testing.T) {
+	modelName := "distilbert-base-uncased"
+	config := new(distilbert.DistilBertConfig)
+	if err := transformer.LoadConfig(config, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+	model := new(distilbert.DistilBertForMaskedLM)
+	if _, err := transformer.LoadModel(model, modelName, config, nil, gotch.CPU); err != nil {
+		t.Fatal(err)
+	}
+	tk := bert.NewTokenizer()
+	if err := transformer.LoadTokenizer(tk, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	encoding, err := tk.EncodeSingle("The capital of France is [MASK].", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	var maskPos int64
+	for i, id := range encoding.Ids {
+		ids = append(ids, int64(id))
+		if encoding.Tokens[i] == "[MASK]" {
+			maskPos = int64(i)
+		}
+	}
+	inputIds := ts.MustOfSlice(ids).MustView([]int64{1, -1}, true)
+
+	var logits *ts.Tensor
+	ts.NoGrad(func() {
+		logits, _, _, err = model.ForwardT(inputIds, ts.None, ts.None, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := logits.MustGet(0).MustGet(int(maskPos)).MustArgmax([]int64{0}, false, false).Int64Values()[0]
+	got, _ := tk.IdToToken(int(index))
+	want := "paris"
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
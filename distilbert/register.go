@@ -0,0 +1,18 @@
+package distilbert
+
+import (
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// Registers DistilBERT configuration, tokenizer (`bert.Tokenizer`) and task models for
+// auto-loading (see `transformer.AutoModelFor`).
+func init() {
+	pretrained.RegisterConfig("distilbert", func() pretrained.Config { return new(DistilBertConfig) })
+	pretrained.RegisterTokenizer("distilbert", func() pretrained.Tokenizer { return bert.NewTokenizer() })
+
+	pretrained.RegisterModel("distilbert", pretrained.MaskedLM, "DistilBertForMaskedLM", func() pretrained.Model { return new(DistilBertForMaskedLM) })
+	pretrained.RegisterModel("distilbert", pretrained.SequenceClassification, "DistilBertForSequenceClassification", func() pretrained.Model { return new(DistilBertForSequenceClassification) })
+	pretrained.RegisterModel("distilbert", pretrained.TokenClassification, "DistilBertForTokenClassification", func() pretrained.Model { return new(DistilBertForTokenClassification) })
+	pretrained.RegisterModel("distilbert", pretrained.QuestionAnswering, "DistilBertForQuestionAnswering", func() pretrained.Model { return new(DistilBertForQuestionAnswering) })
+}
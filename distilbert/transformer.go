@@ -0,0 +1,156 @@
+package distilbert
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// FeedForwardNetwork:
+// ===================
+
+// FeedForwardNetwork holds the position-wise feed forward layers `lin1` and `lin2`.
+type FeedForwardNetwork struct {
+	Lin1       *nn.Linear
+	Lin2       *nn.Linear
+	Activation util.ActivationFn
+	Dropout    *util.Dropout
+}
+
+// NewFeedForwardNetwork creates a new FeedForwardNetwork.
+func NewFeedForwardNetwork(p *nn.Path, config *DistilBertConfig) (*FeedForwardNetwork, error) {
+	activation, ok := util.ActivationFnMap[config.Activation]
+	if !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.Activation)
+	}
+
+	lin1 := nn.NewLinear(p.Sub("lin1"), config.Dim, config.HiddenDim, nn.DefaultLinearConfig())
+	lin2 := nn.NewLinear(p.Sub("lin2"), config.HiddenDim, config.Dim, nn.DefaultLinearConfig())
+
+	return &FeedForwardNetwork{lin1, lin2, activation, util.NewDropout(config.Dropout)}, nil
+}
+
+// ForwardT forwards pass through the feed forward network.
+func (ffn *FeedForwardNetwork) ForwardT(hiddenStates *ts.Tensor, train bool) (retVal *ts.Tensor) {
+	x1 := hiddenStates.Apply(ffn.Lin1)
+	x2 := ffn.Activation.Fwd(x1)
+	x1.MustDrop()
+	x3 := x2.Apply(ffn.Lin2)
+	x2.MustDrop()
+	retVal = x3.ApplyT(ffn.Dropout, train)
+	x3.MustDrop()
+
+	return retVal
+}
+
+// TransformerBlock:
+// =================
+
+// TransformerBlock is a single DistilBERT layer: self-attention and feed forward
+// network, each followed by a residual connection and layer normalization.
+type TransformerBlock struct {
+	Attention       *MultiHeadSelfAttention
+	SaLayerNorm     *nn.LayerNorm
+	Ffn             *FeedForwardNetwork
+	OutputLayerNorm *nn.LayerNorm
+}
+
+// NewTransformerBlock creates a new TransformerBlock.
+func NewTransformerBlock(p *nn.Path, config *DistilBertConfig) (*TransformerBlock, error) {
+	attention, err := NewMultiHeadSelfAttention(p.Sub("attention"), config)
+	if err != nil {
+		return nil, err
+	}
+	ffn, err := NewFeedForwardNetwork(p.Sub("ffn"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	lnConfig := nn.DefaultLayerNormConfig()
+	lnConfig.Eps = 1e-12
+	saLayerNorm := nn.NewLayerNorm(p.Sub("sa_layer_norm"), []int64{config.Dim}, lnConfig)
+	outputLayerNorm := nn.NewLayerNorm(p.Sub("output_layer_norm"), []int64{config.Dim}, lnConfig)
+
+	return &TransformerBlock{attention, saLayerNorm, ffn, outputLayerNorm}, nil
+}
+
+// ForwardT forwards pass through the layer.
+func (tb *TransformerBlock) ForwardT(hiddenStates, mask *ts.Tensor, train bool) (retVal, retValOpt *ts.Tensor) {
+	saOutput, attnWeights := tb.Attention.ForwardT(hiddenStates, mask, train)
+	saResidual := saOutput.MustAdd(hiddenStates, true)
+	saNormed := saResidual.Apply(tb.SaLayerNorm)
+	saResidual.MustDrop()
+
+	ffnOutput := tb.Ffn.ForwardT(saNormed, train)
+	ffnResidual := ffnOutput.MustAdd(saNormed, true)
+	saNormed.MustDrop()
+	retVal = ffnResidual.Apply(tb.OutputLayerNorm)
+	ffnResidual.MustDrop()
+
+	return retVal, attnWeights
+}
+
+// Transformer:
+// ============
+
+// Transformer is a stack of `NLayers` transformer blocks.
+type Transformer struct {
+	OutputAttentions   bool
+	OutputHiddenStates bool
+	Layers             []*TransformerBlock
+}
+
+// NewTransformer creates a new Transformer.
+func NewTransformer(p *nn.Path, config *DistilBertConfig) (*Transformer, error) {
+	path := p.Sub("layer")
+	var layers []*TransformerBlock
+	for lIdx := 0; lIdx < int(config.NLayers); lIdx++ {
+		layer, err := NewTransformerBlock(path.Sub(fmt.Sprintf("%v", lIdx)), config)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	return &Transformer{
+		OutputAttentions:   config.OutputAttentions,
+		OutputHiddenStates: config.OutputHiddenStates,
+		Layers:             layers,
+	}, nil
+}
+
+// ForwardT forwards pass through all layers.
+//
+// Returns:
+//   - last hidden state of shape (batch size, sequence length, dim)
+//   - hidden states of every layer input if `OutputHiddenStates` is set (the last one
+//     excluded as it is returned as first value)
+//   - attention weights of every layer if `OutputAttentions` is set
+func (t *Transformer) ForwardT(hiddenStates, mask *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor) {
+	var (
+		allHiddenStates []ts.Tensor
+		allAttentions   []ts.Tensor
+	)
+
+	hiddenState := hiddenStates
+	for i, layer := range t.Layers {
+		if t.OutputHiddenStates {
+			allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
+		}
+
+		stateTmp, attnWeights := layer.ForwardT(hiddenState, mask, train)
+		if i > 0 {
+			hiddenState.MustDrop()
+		}
+		hiddenState = stateTmp
+
+		if t.OutputAttentions {
+			allAttentions = append(allAttentions, *attnWeights)
+		}
+	}
+
+	return hiddenState, allHiddenStates, allAttentions
+}
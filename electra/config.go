@@ -0,0 +1,185 @@
+package electra
+
+// electra package implements ELECTRA transformer model.
+// ELECTRA checkpoints share BERT WordPiece vocabulary, hence `bert.Tokenizer` is used to encode inputs.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/util"
+)
+
+// ElectraConfig defines the ELECTRA model architecture (i.e., number of layers,
+// hidden layer size, label mapping...)
+//
+// The same configuration type is used for generator and discriminator checkpoints.
+// When `EmbeddingSize` differs from `HiddenSize`, embeddings are projected to
+// `HiddenSize` before the encoder.
+type ElectraConfig struct {
+	VocabSize                 int64            `json:"vocab_size"`
+	EmbeddingSize             int64            `json:"embedding_size"`
+	HiddenSize                int64            `json:"hidden_size"`
+	NumHiddenLayers           int64            `json:"num_hidden_layers"`
+	NumAttentionHeads         int64            `json:"num_attention_heads"`
+	IntermediateSize          int64            `json:"intermediate_size"`
+	HiddenAct                 string           `json:"hidden_act"`
+	HiddenDropoutProb         float64          `json:"hidden_dropout_prob"`
+	AttentionProbsDropoutProb float64          `json:"attention_probs_dropout_prob"`
+	MaxPositionEmbeddings     int64            `json:"max_position_embeddings"`
+	TypeVocabSize             int64            `json:"type_vocab_size"`
+	InitializerRange          float32          `json:"initializer_range"`
+	LayerNormEps              float64          `json:"layer_norm_eps"`
+	PadTokenId                int64            `json:"pad_token_id"`
+	OutputAttentions          bool             `json:"output_attentions"`
+	OutputHiddenStates        bool             `json:"output_hidden_states"`
+	Id2Label                  map[int64]string `json:"id2label"`
+	Label2Id                  map[string]int64 `json:"label2id"`
+	NumLabels                 int64            `json:"num_labels"`
+}
+
+// NewConfig initiates ElectraConfig with given input parameters or default values
+// of `google/electra-small-discriminator`.
+func NewConfig(customParams map[string]interface{}) *ElectraConfig {
+	defaultValues := map[string]interface{}{
+		"VocabSize":                 int64(30522),
+		"EmbeddingSize":             int64(128),
+		"HiddenSize":                int64(256),
+		"NumHiddenLayers":           int64(12),
+		"NumAttentionHeads":         int64(4),
+		"IntermediateSize":          int64(1024),
+		"HiddenAct":                 "gelu",
+		"HiddenDropoutProb":         float64(0.1),
+		"AttentionProbsDropoutProb": float64(0.1),
+		"MaxPositionEmbeddings":     int64(512),
+		"TypeVocabSize":             int64(2),
+		"InitializerRange":          float32(0.02),
+		"LayerNormEps":              float64(1e-12),
+		"PadTokenId":                int64(0),
+	}
+
+	params := defaultValues
+	for k, v := range customParams {
+		if _, ok := params[k]; ok {
+			params[k] = v
+		}
+	}
+
+	config := new(ElectraConfig)
+	config.updateParams(params)
+
+	return config
+}
+
+// ConfigFromFile loads ElectraConfig from a JSON file.
+func ConfigFromFile(filename string) (*ElectraConfig, error) {
+	config := new(ElectraConfig)
+	if err := config.fromFile(filename); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Load loads model configuration from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Config` interface.
+func (c *ElectraConfig) Load(modelNameOrPath string, params map[string]interface{}) error {
+	err := c.fromFile(modelNameOrPath)
+	if err != nil {
+		return err
+	}
+
+	// Update custom parameters
+	c.updateParams(params)
+
+	return nil
+}
+
+// SavePretrained saves configuration to directory `dir` as `config.json` file.
+// This method implements `pretrained.Config` interface.
+func (c *ElectraConfig) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buff, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, util.ConfigName), buff, 0644)
+}
+
+func (c *ElectraConfig) fromFile(filename string) error {
+	filePath, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	buff, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(buff, c)
+	if err != nil {
+		return fmt.Errorf("Could not parse configuration to ElectraConfig: %v: %w", err, util.ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// GetVocabSize returns vocabulary size.
+func (c *ElectraConfig) GetVocabSize() int64 {
+	return c.VocabSize
+}
+
+// numLabels returns number of labels for classification heads. It is taken from
+// `NumLabels` if set, otherwise from the label mapping.
+func (c *ElectraConfig) numLabels() int64 {
+	if c.NumLabels > 0 {
+		return c.NumLabels
+	}
+	return int64(len(c.Id2Label))
+}
+
+// bertConfig returns a BertConfig for layers shared with BERT. Embeddings
+// operate on `EmbeddingSize` and encoder layers on `HiddenSize`.
+func (c *ElectraConfig) bertConfig(hiddenSize int64) *bert.BertConfig {
+	return &bert.BertConfig{
+		HiddenAct:                 c.HiddenAct,
+		AttentionProbsDropoutProb: c.AttentionProbsDropoutProb,
+		HiddenDropoutProb:         c.HiddenDropoutProb,
+		HiddenSize:                hiddenSize,
+		IntermediateSize:          c.IntermediateSize,
+		MaxPositionEmbeddings:     c.MaxPositionEmbeddings,
+		NumAttentionHeads:         c.NumAttentionHeads,
+		NumHiddenLayers:           c.NumHiddenLayers,
+		TypeVocabSize:             c.TypeVocabSize,
+		VocabSize:                 c.VocabSize,
+		OutputAttentions:          c.OutputAttentions,
+		OutputHiddenStates:        c.OutputHiddenStates,
+	}
+}
+
+func (c *ElectraConfig) updateParams(params map[string]interface{}) {
+	for k, v := range params {
+		c.updateField(k, v)
+	}
+}
+
+func (c *ElectraConfig) updateField(field string, value interface{}) {
+	// Check whether field name exists
+	if reflect.ValueOf(c).Elem().FieldByName(field).IsValid() {
+		// Check whether same type
+		if reflect.ValueOf(c).Elem().FieldByName(field).Kind() == reflect.TypeOf(value).Kind() {
+			reflect.ValueOf(c).Elem().FieldByName(field).Set(reflect.ValueOf(value))
+		}
+	}
+}
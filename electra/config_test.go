@@ -0,0 +1,54 @@
+package electra_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/electra"
+)
+
+func TestConfigFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "electra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// `google/electra-small-discriminator` config.
+	data := `{
+  "architectures": ["ElectraForPreTraining"],
+  "attention_probs_dropout_prob": 0.1,
+  "embedding_size": 128,
+  "hidden_act": "gelu",
+  "hidden_dropout_prob": 0.1,
+  "hidden_size": 256,
+  "initializer_range": 0.02,
+  "intermediate_size": 1024,
+  "layer_norm_eps": 1e-12,
+  "max_position_embeddings": 512,
+  "model_type": "electra",
+  "num_attention_heads": 4,
+  "num_hidden_layers": 12,
+  "pad_token_id": 0,
+  "type_vocab_size": 2,
+  "vocab_size": 30522
+}`
+	configFile := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := electra.ConfigFromFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := electra.NewConfig(nil)
+	if !reflect.DeepEqual(want, config) {
+		t.Errorf("Want: %+v\n", want)
+		t.Errorf("Got: %+v\n", config)
+	}
+}
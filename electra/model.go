@@ -0,0 +1,632 @@
+package electra
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/util"
+)
+
+// ElectraModel:
+// =============
+
+// ElectraModel defines base architecture for ELECTRA models. Generator and
+// discriminator share the same base architecture and differ by their heads.
+//
+// Fields:
+//   - Embeddings: `token`, `position` and `segment` embeddings of size `EmbeddingSize`
+//   - EmbeddingsProject: linear projection of embeddings to `HiddenSize`. It is nil
+//     when `EmbeddingSize` equals `HiddenSize`.
+//   - Encoder: BertEncoder made of `NumHiddenLayers` layers
+type ElectraModel struct {
+	Embeddings        *bert.BertEmbeddings
+	EmbeddingsProject *nn.Linear
+	Encoder           *bert.BertEncoder
+}
+
+// NewElectraModel builds a new `ElectraModel`.
+//
+// Params:
+//   - `p`: Variable store path for the root of the ELECTRA model
+//   - `config`: ElectraConfig configuration for model architecture
+func NewElectraModel(p *nn.Path, config *ElectraConfig) (*ElectraModel, error) {
+	if config.HiddenSize%config.NumAttentionHeads != 0 {
+		return nil, fmt.Errorf("Hidden size (%v) is not a multiple of the number of attention heads (%v): %w", config.HiddenSize, config.NumAttentionHeads, util.ErrShapeMismatch)
+	}
+	if _, ok := util.ActivationFnMap[config.HiddenAct]; !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.HiddenAct)
+	}
+
+	embeddings := bert.NewBertEmbeddings(p.Sub("embeddings"), config.bertConfig(config.EmbeddingSize), false)
+
+	var embeddingsProject *nn.Linear
+	if config.EmbeddingSize != config.HiddenSize {
+		embeddingsProject = nn.NewLinear(p.Sub("embeddings_project"), config.EmbeddingSize, config.HiddenSize, nn.DefaultLinearConfig())
+	}
+
+	encoder, err := bert.NewBertEncoder(p.Sub("encoder"), config.bertConfig(config.HiddenSize), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ElectraModel{embeddings, embeddingsProject, encoder}, nil
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `mask`: optional mask of shape (batch size, sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `tokenTypeIds`: optional segment id of shape (batch size, sequence length).
+//     Convention is value of 0 for the first sentence (incl. [SEP]) and 1 for the second sentence. If None set to 0.
+//   - `positionIds`: optional position ids of shape (batch size, sequence length).
+//     If None, will be incremented from 0.
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, embedding size).
+//     If None, input ids must be provided (see `inputIds`).
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, hidden size)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, heads, sequence length, sequence length)
+func (m *ElectraModel) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	var (
+		inputShape []int64
+		device     gotch.Device
+	)
+
+	switch {
+	case inputIds.MustDefined() && inputEmbeds.MustDefined():
+		err = fmt.Errorf("Only one of input ids or input embeddings may be set: %w", util.ErrInvalidInput)
+		return
+	case inputIds.MustDefined():
+		inputShape = inputIds.MustSize()
+		device = inputIds.MustDevice()
+	case inputEmbeds.MustDefined():
+		size := inputEmbeds.MustSize()
+		inputShape = []int64{size[0], size[1]}
+		device = inputEmbeds.MustDevice()
+	default:
+		err = fmt.Errorf("At least one of input ids or input embeddings must be set: %w", util.ErrInvalidInput)
+		return
+	}
+
+	// Additive mask of shape (batch size, 1, 1, sequence length): 0 to attend, -10000 to mask.
+	var maskTs *ts.Tensor
+	if mask.MustDefined() {
+		maskTs = mask.MustShallowClone()
+	} else {
+		maskTs = ts.MustOnes(inputShape, gotch.Int64, device)
+	}
+	extendedMask := maskTs.MustUnsqueeze(1, true).MustUnsqueeze(2, true).MustTotype(gotch.Float, true)
+	extendedAttnMask := extendedMask.MustOnesLike(false).MustSub(extendedMask, true).MustMulScalar(ts.FloatScalar(-10000.0), true)
+	extendedMask.MustDrop()
+
+	hiddenState, err := m.Embeddings.ForwardT(inputIds, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		extendedAttnMask.MustDrop()
+		return
+	}
+	if m.EmbeddingsProject != nil {
+		projected := hiddenState.Apply(m.EmbeddingsProject)
+		hiddenState.MustDrop()
+		hiddenState = projected
+	}
+
+	// NOTE. encoder takes ownership of `hiddenState`.
+	retVal, retValOpt1, retValOpt2 = m.Encoder.ForwardT(hiddenState, extendedAttnMask, ts.None, ts.None, train)
+	extendedAttnMask.MustDrop()
+
+	return retVal, retValOpt1, retValOpt2, nil
+}
+
+// ElectraDiscriminatorHead:
+// =========================
+
+// ElectraDiscriminatorHead predicts for every token whether it was replaced by
+// the generator (replaced token detection).
+type ElectraDiscriminatorHead struct {
+	Dense           *nn.Linear
+	Activation      util.ActivationFn
+	DensePrediction *nn.Linear
+}
+
+// NewElectraDiscriminatorHead creates ElectraDiscriminatorHead.
+func NewElectraDiscriminatorHead(p *nn.Path, config *ElectraConfig) (*ElectraDiscriminatorHead, error) {
+	activation, ok := util.ActivationFnMap[config.HiddenAct]
+	if !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.HiddenAct)
+	}
+
+	dense := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.HiddenSize, nn.DefaultLinearConfig())
+	densePrediction := nn.NewLinear(p.Sub("dense_prediction"), config.HiddenSize, 1, nn.DefaultLinearConfig())
+
+	return &ElectraDiscriminatorHead{dense, activation, densePrediction}, nil
+}
+
+// Forward forwards pass through the head. It returns logits of shape (batch size, sequence length).
+func (h *ElectraDiscriminatorHead) Forward(hiddenStates *ts.Tensor) *ts.Tensor {
+	x1 := hiddenStates.Apply(h.Dense)
+	x2 := h.Activation.Fwd(x1)
+	x1.MustDrop()
+	x3 := x2.Apply(h.DensePrediction)
+	x2.MustDrop()
+
+	return x3.MustSqueezeDim(-1, true)
+}
+
+// ElectraGeneratorHead:
+// =====================
+
+// ElectraGeneratorHead projects hidden states back to `EmbeddingSize` before
+// the language model head of the generator.
+type ElectraGeneratorHead struct {
+	Dense      *nn.Linear
+	Activation util.ActivationFn
+	LayerNorm  *nn.LayerNorm
+}
+
+// NewElectraGeneratorHead creates ElectraGeneratorHead.
+func NewElectraGeneratorHead(p *nn.Path, config *ElectraConfig) *ElectraGeneratorHead {
+	dense := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.EmbeddingSize, nn.DefaultLinearConfig())
+
+	lnConfig := nn.DefaultLayerNormConfig()
+	lnConfig.Eps = config.LayerNormEps
+	layerNorm := nn.NewLayerNorm(p.Sub("LayerNorm"), []int64{config.EmbeddingSize}, lnConfig)
+
+	return &ElectraGeneratorHead{dense, util.ActivationFnMap["gelu"], layerNorm}
+}
+
+// Forward forwards pass through the head.
+func (h *ElectraGeneratorHead) Forward(hiddenStates *ts.Tensor) *ts.Tensor {
+	x1 := hiddenStates.Apply(h.Dense)
+	x2 := h.Activation.Fwd(x1)
+	x1.MustDrop()
+	retVal := x2.Apply(h.LayerNorm)
+	x2.MustDrop()
+
+	return retVal
+}
+
+// ElectraClassificationHead:
+// ==========================
+
+// ElectraClassificationHead is the sequence classification head applied to the
+// first token (`[CLS]`) of the sequence.
+type ElectraClassificationHead struct {
+	Dense      *nn.Linear
+	Activation util.ActivationFn
+	Dropout    *util.Dropout
+	OutProj    *nn.Linear
+}
+
+// NewElectraClassificationHead creates ElectraClassificationHead.
+func NewElectraClassificationHead(p *nn.Path, config *ElectraConfig, numLabels int64) *ElectraClassificationHead {
+	dense := nn.NewLinear(p.Sub("dense"), config.HiddenSize, config.HiddenSize, nn.DefaultLinearConfig())
+	outProj := nn.NewLinear(p.Sub("out_proj"), config.HiddenSize, numLabels, nn.DefaultLinearConfig())
+
+	return &ElectraClassificationHead{dense, util.ActivationFnMap["gelu"], util.NewDropout(config.HiddenDropoutProb), outProj}
+}
+
+// ForwardT forwards pass through the head. It returns logits of shape (batch size, num labels).
+func (h *ElectraClassificationHead) ForwardT(hiddenStates *ts.Tensor, train bool) *ts.Tensor {
+	first := hiddenStates.MustSelect(1, 0, false)
+	x1 := first.ApplyT(h.Dropout, train)
+	first.MustDrop()
+	x2 := x1.Apply(h.Dense)
+	x1.MustDrop()
+	x3 := h.Activation.Fwd(x2)
+	x2.MustDrop()
+	x4 := x3.ApplyT(h.Dropout, train)
+	x3.MustDrop()
+	retVal := x4.Apply(h.OutProj)
+	x4.MustDrop()
+
+	return retVal
+}
+
+// ElectraForMaskedLM:
+// ===================
+
+// ElectraForMaskedLM is the ELECTRA generator, a masked language model.
+//
+// It is made of the following blocks:
+//   - `electra`: Base ElectraModel
+//   - `generator_predictions`: ElectraGeneratorHead
+//   - `generator_lm_head`: linear layer from embedding size to vocab size
+type ElectraForMaskedLM struct {
+	electra              *ElectraModel
+	generatorPredictions *ElectraGeneratorHead
+	lmHead               *nn.Linear
+	config               *ElectraConfig
+	vs                   *nn.VarStore
+}
+
+// NewElectraForMaskedLM creates ElectraForMaskedLM.
+func NewElectraForMaskedLM(p *nn.Path, config *ElectraConfig) (*ElectraForMaskedLM, error) {
+	electra, err := NewElectraModel(p.Sub("electra"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ElectraForMaskedLM{
+		electra:              electra,
+		generatorPredictions: NewElectraGeneratorHead(p.Sub("generator_predictions"), config),
+		lmHead:               nn.NewLinear(p.Sub("generator_lm_head"), config.EmbeddingSize, config.VocabSize, nn.DefaultLinearConfig()),
+		config:               config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (mlm *ElectraForMaskedLM) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	electraConfig, ok := config.(*ElectraConfig)
+	if !ok {
+		return nil, fmt.Errorf("ElectraForMaskedLM.Load() failed: invalid config type %T, want *electra.ElectraConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewElectraForMaskedLM(vs.Root(), electraConfig)
+	if err != nil {
+		return nil, err
+	}
+	*mlm = *model
+	mlm.vs = vs
+
+	opts := util.LoadOptionsFromParams(params, "generator_predictions", "generator_lm_head")
+	// The generator LM head shares word embeddings of the generator.
+	opts.Tied = map[string]string{
+		"generator_lm_head.weight": "electra.embeddings.word_embeddings.weight",
+	}
+
+	return util.LoadVarStore(vs, modelNameOrPath, opts, util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (mlm *ElectraForMaskedLM) SavePretrained(dir string) error {
+	return util.SaveModel(dir, mlm.config, mlm.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (mlm *ElectraForMaskedLM) VarStore() *nn.VarStore {
+	return mlm.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (mlm *ElectraForMaskedLM) SetVarStore(vs *nn.VarStore) {
+	mlm.vs = vs
+}
+
+// Config returns model configuration.
+func (mlm *ElectraForMaskedLM) Config() *ElectraConfig {
+	return mlm.config
+}
+
+// ForwardT forwards pass through the model. See `ElectraModel.ForwardT` for params.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, vocab size)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, heads, sequence length, sequence length)
+func (mlm *ElectraForMaskedLM) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, allHiddenStates, allAttentions, err := mlm.electra.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	x := mlm.generatorPredictions.Forward(hiddenState)
+	hiddenState.MustDrop()
+	retVal = x.Apply(mlm.lmHead)
+	x.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
+
+// ElectraForPreTraining:
+// ======================
+
+// ElectraForPreTraining is the ELECTRA discriminator. It predicts for every token
+// whether it is original or was replaced by the generator.
+//
+// It is made of the following blocks:
+//   - `electra`: Base ElectraModel
+//   - `discriminator_predictions`: ElectraDiscriminatorHead
+type ElectraForPreTraining struct {
+	electra                  *ElectraModel
+	discriminatorPredictions *ElectraDiscriminatorHead
+	config                   *ElectraConfig
+	vs                       *nn.VarStore
+}
+
+// NewElectraForPreTraining creates ElectraForPreTraining.
+func NewElectraForPreTraining(p *nn.Path, config *ElectraConfig) (*ElectraForPreTraining, error) {
+	electra, err := NewElectraModel(p.Sub("electra"), config)
+	if err != nil {
+		return nil, err
+	}
+	discriminatorPredictions, err := NewElectraDiscriminatorHead(p.Sub("discriminator_predictions"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ElectraForPreTraining{
+		electra:                  electra,
+		discriminatorPredictions: discriminatorPredictions,
+		config:                   config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (pt *ElectraForPreTraining) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	electraConfig, ok := config.(*ElectraConfig)
+	if !ok {
+		return nil, fmt.Errorf("ElectraForPreTraining.Load() failed: invalid config type %T, want *electra.ElectraConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewElectraForPreTraining(vs.Root(), electraConfig)
+	if err != nil {
+		return nil, err
+	}
+	*pt = *model
+	pt.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "discriminator_predictions"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (pt *ElectraForPreTraining) SavePretrained(dir string) error {
+	return util.SaveModel(dir, pt.config, pt.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (pt *ElectraForPreTraining) VarStore() *nn.VarStore {
+	return pt.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (pt *ElectraForPreTraining) SetVarStore(vs *nn.VarStore) {
+	pt.vs = vs
+}
+
+// Config returns model configuration.
+func (pt *ElectraForPreTraining) Config() *ElectraConfig {
+	return pt.config
+}
+
+// ForwardT forwards pass through the model. See `ElectraModel.ForwardT` for params.
+//
+// Returns:
+//   - `output`: replaced token detection logits of shape (batch size, sequence length).
+//     Positive values indicate replaced tokens.
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, heads, sequence length, sequence length)
+func (pt *ElectraForPreTraining) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, allHiddenStates, allAttentions, err := pt.electra.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	retVal = pt.discriminatorPredictions.Forward(hiddenState)
+	hiddenState.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
+
+// ElectraForSequenceClassification:
+// =================================
+
+// ElectraForSequenceClassification is ELECTRA for sequence classification.
+//
+// It is made of the following blocks:
+//   - `electra`: Base ElectraModel
+//   - `classifier`: ElectraClassificationHead applied to the first token
+type ElectraForSequenceClassification struct {
+	electra    *ElectraModel
+	classifier *ElectraClassificationHead
+	config     *ElectraConfig
+	vs         *nn.VarStore
+}
+
+// NewElectraForSequenceClassification creates a new ElectraForSequenceClassification.
+//
+// NOTE. Number of labels is taken from `config.NumLabels` or, if not set, from `config.Id2Label`.
+func NewElectraForSequenceClassification(p *nn.Path, config *ElectraConfig) (*ElectraForSequenceClassification, error) {
+	electra, err := NewElectraModel(p.Sub("electra"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	numLabels := config.numLabels()
+	if numLabels == 0 {
+		return nil, fmt.Errorf("Number of labels must be set in config (`num_labels` or `id2label`): %w", util.ErrInvalidConfig)
+	}
+
+	return &ElectraForSequenceClassification{
+		electra:    electra,
+		classifier: NewElectraClassificationHead(p.Sub("classifier"), config, numLabels),
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (sc *ElectraForSequenceClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	electraConfig, ok := config.(*ElectraConfig)
+	if !ok {
+		return nil, fmt.Errorf("ElectraForSequenceClassification.Load() failed: invalid config type %T, want *electra.ElectraConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewElectraForSequenceClassification(vs.Root(), electraConfig)
+	if err != nil {
+		return nil, err
+	}
+	*sc = *model
+	sc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (sc *ElectraForSequenceClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, sc.config, sc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (sc *ElectraForSequenceClassification) VarStore() *nn.VarStore {
+	return sc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (sc *ElectraForSequenceClassification) SetVarStore(vs *nn.VarStore) {
+	sc.vs = vs
+}
+
+// Config returns model configuration.
+func (sc *ElectraForSequenceClassification) Config() *ElectraConfig {
+	return sc.config
+}
+
+// ForwardT forwards pass through the model. See `ElectraModel.ForwardT` for params.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, num labels)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, heads, sequence length, sequence length)
+func (sc *ElectraForSequenceClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, allHiddenStates, allAttentions, err := sc.electra.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	retVal = sc.classifier.ForwardT(hiddenState, train)
+	hiddenState.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
+
+// ElectraForTokenClassification:
+// ==============================
+
+// ElectraForTokenClassification is ELECTRA for token classification (e.g., NER, POS).
+//
+// It is made of the following blocks:
+//   - `electra`: Base ElectraModel
+//   - `classifier`: linear layer for token classification
+type ElectraForTokenClassification struct {
+	electra    *ElectraModel
+	dropout    *util.Dropout
+	classifier *nn.Linear
+	config     *ElectraConfig
+	vs         *nn.VarStore
+}
+
+// NewElectraForTokenClassification creates a new ElectraForTokenClassification.
+//
+// NOTE. Number of labels is taken from `config.NumLabels` or, if not set, from `config.Id2Label`.
+func NewElectraForTokenClassification(p *nn.Path, config *ElectraConfig) (*ElectraForTokenClassification, error) {
+	electra, err := NewElectraModel(p.Sub("electra"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	numLabels := config.numLabels()
+	if numLabels == 0 {
+		return nil, fmt.Errorf("Number of labels must be set in config (`num_labels` or `id2label`): %w", util.ErrInvalidConfig)
+	}
+
+	return &ElectraForTokenClassification{
+		electra:    electra,
+		dropout:    util.NewDropout(config.HiddenDropoutProb),
+		classifier: nn.NewLinear(p.Sub("classifier"), config.HiddenSize, numLabels, nn.DefaultLinearConfig()),
+		config:     config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (tc *ElectraForTokenClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	electraConfig, ok := config.(*ElectraConfig)
+	if !ok {
+		return nil, fmt.Errorf("ElectraForTokenClassification.Load() failed: invalid config type %T, want *electra.ElectraConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewElectraForTokenClassification(vs.Root(), electraConfig)
+	if err != nil {
+		return nil, err
+	}
+	*tc = *model
+	tc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (tc *ElectraForTokenClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, tc.config, tc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (tc *ElectraForTokenClassification) VarStore() *nn.VarStore {
+	return tc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (tc *ElectraForTokenClassification) SetVarStore(vs *nn.VarStore) {
+	tc.vs = vs
+}
+
+// Config returns model configuration.
+func (tc *ElectraForTokenClassification) Config() *ElectraConfig {
+	return tc.config
+}
+
+// ForwardT forwards pass through the model. See `ElectraModel.ForwardT` for params.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, num labels)
+//   - `hiddenStates`: slice of tensors of length numHiddenLayers with shape (batch size, sequence length, hidden size)
+//   - `attentions`: slice of tensors of length numHiddenLayers with shape (batch size, heads, sequence length, sequence length)
+func (tc *ElectraForTokenClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, allHiddenStates, allAttentions, err := tc.electra.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	x := hiddenState.ApplyT(tc.dropout, train)
+	hiddenState.MustDrop()
+	retVal = x.Apply(tc.classifier)
+	x.MustDrop()
+
+	return retVal, allHiddenStates, allAttentions, nil
+}
@@ -0,0 +1,93 @@
+package electra_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/electra"
+)
+
+// Embeddings are only projected when embedding size differs from hidden size.
+func TestElectraModel_EmbeddingsProject(t *testing.T) {
+	config := electra.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"EmbeddingSize":         int64(8),
+		"HiddenSize":            int64(16),
+		"NumHiddenLayers":       int64(2),
+		"NumAttentionHeads":     int64(2),
+		"IntermediateSize":      int64(32),
+		"MaxPositionEmbeddings": int64(32),
+	})
+	vs := nn.NewVarStore(gotch.CPU)
+	if _, err := electra.NewElectraModel(vs.Root(), config); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vs.Variables()["embeddings_project.weight"]; !ok {
+		t.Errorf("Want embeddings projection for embedding size 8 and hidden size 16\n")
+	}
+
+	config.EmbeddingSize = config.HiddenSize
+	vs = nn.NewVarStore(gotch.CPU)
+	if _, err := electra.NewElectraModel(vs.Root(), config); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vs.Variables()["embeddings_project.weight"]; ok {
+		t.Errorf("Want no embeddings projection for equal embedding and hidden sizes\n")
+	}
+}
+
+// The discriminator detects the replaced token "fake" (for "jumps").
+func TestElectraForPreTraining(t *testing.T) {
+	modelName := "google/electra-small-discriminator"
+	config := new(electra.ElectraConfig)
+	if err := transformer.LoadConfig(config, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+	model := new(electra.ElectraForPreTraining)
+	if _, err := transformer.LoadModel(model, modelName, config, nil, gotch.CPU); err != nil {
+		t.Fatal(err)
+	}
+	tk := bert.NewTokenizer()
+	if err := transformer.LoadTokenizer(tk, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	encoding, err := tk.EncodeSingle("The quick brown fox fake over the lazy dog", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, id := range encoding.Ids {
+		ids = append(ids, int64(id))
+	}
+	inputIds := ts.MustOfSlice(ids).MustView([]int64{1, -1}, true)
+
+	var logits *ts.Tensor
+	ts.NoGrad(func() {
+		logits, _, _, err = model.ForwardT(inputIds, ts.None, ts.None, ts.None, ts.None, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Most likely replaced token, special tokens excluded.
+	scores := logits.MustGet(0).Float64Values()
+	best := 1
+	for i := 1; i < len(scores)-1; i++ {
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	want := "fake"
+	got := encoding.Tokens[best]
+	if !reflect.DeepEqual(want, got) || scores[best] <= 0 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v (logit %v)\n", got, scores[best])
+	}
+}
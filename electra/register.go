@@ -0,0 +1,18 @@
+package electra
+
+import (
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// Registers ELECTRA configuration, tokenizer (`bert.Tokenizer`) and task models for
+// auto-loading (see `transformer.AutoModelFor`).
+func init() {
+	pretrained.RegisterConfig("electra", func() pretrained.Config { return new(ElectraConfig) })
+	pretrained.RegisterTokenizer("electra", func() pretrained.Tokenizer { return bert.NewTokenizer() })
+
+	pretrained.RegisterModel("electra", pretrained.MaskedLM, "ElectraForMaskedLM", func() pretrained.Model { return new(ElectraForMaskedLM) })
+	pretrained.RegisterModel("electra", pretrained.PreTraining, "ElectraForPreTraining", func() pretrained.Model { return new(ElectraForPreTraining) })
+	pretrained.RegisterModel("electra", pretrained.SequenceClassification, "ElectraForSequenceClassification", func() pretrained.Model { return new(ElectraForSequenceClassification) })
+	pretrained.RegisterModel("electra", pretrained.TokenClassification, "ElectraForTokenClassification", func() pretrained.Model { return new(ElectraForTokenClassification) })
+}
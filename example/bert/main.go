@@ -6,7 +6,6 @@ import (
 
 	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/nn"
-	"github.com/sugarme/gotch/pickle"
 	"github.com/sugarme/gotch/ts"
 	"github.com/sugarme/tokenizer"
 	"github.com/sugarme/tokenizer/model/wordpiece"
@@ -14,6 +13,7 @@ import (
 	"github.com/sugarme/tokenizer/pretokenizer"
 	"github.com/sugarme/tokenizer/processor"
 	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pipeline"
 	"github.com/sugarme/transformer/util"
 )
 
@@ -66,91 +66,22 @@ func getBert() (retVal *tokenizer.Tokenizer) {
 }
 
 func bertForMaskedLM() {
-	device := gotch.CPU
-	vs := nn.NewVarStore(device)
-
-	configFile, err := util.CachedPath("bert-base-uncased", "config.json")
-	if err != nil {
-		panic(err)
-	}
-	config, err := bert.ConfigFromFile(configFile)
+	model, err := pipeline.NewFillMaskModel("bert-base-uncased", gotch.CPU)
 	if err != nil {
-		panic(err)
-	}
-	// fmt.Printf("Bert Configuration:\n%+v\n", config)
-
-	model, err := bert.NewBertForMaskedLM(vs.Root(), config)
-	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
 
-	modelFile, err := util.CachedPath("bert-base-uncased", "pytorch_model.bin")
-	if err != nil {
-		panic(err)
+	sentences := []string{
+		"Looks like one [MASK] is missing",
+		"It was a very nice and [MASK] day",
 	}
-	err = pickle.LoadAll(vs, modelFile)
-	if err != nil {
-		log.Fatalf("Load model weight error: \n%v", err)
-	}
-
-	// fmt.Printf("Varstore weights have been loaded\n")
-	// fmt.Printf("Num of variables: %v\n", len(vs.Variables()))
-	// fmt.Printf("%v\n", vs.Variables())
-	// fmt.Printf("Bert is Decoder: %v\n", model.bert.IsDecoder)
-
-	tk := getBert()
-	sentence1 := "Looks like one [MASK] is missing"
-	sentence2 := "It was a very nice and [MASK] day"
-
-	var input []tokenizer.EncodeInput
-	input = append(input, tokenizer.NewSingleEncodeInput(tokenizer.NewInputSequence(sentence1)))
-	input = append(input, tokenizer.NewSingleEncodeInput(tokenizer.NewInputSequence(sentence2)))
-
-	encodings, err := tk.EncodeBatch(input, true)
+	predictions, err := model.Predict(sentences, 1)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	// Find max length of token Ids from slice of encodings
-	var maxLen int = 0
-	for _, en := range encodings {
-		if len(en.Ids) > maxLen {
-			maxLen = len(en.Ids)
-		}
-	}
-
-	var tensors []ts.Tensor
-	for _, en := range encodings {
-		var tokInput []int64 = make([]int64, maxLen)
-		for i := 0; i < len(en.Ids); i++ {
-			tokInput[i] = int64(en.Ids[i])
-		}
-
-		tensors = append(tensors, *ts.TensorFrom(tokInput))
-	}
-
-	inputTensor := ts.MustStack(tensors, 0).MustTo(device, true)
-	// inputTensor.Print()
-
-	var output *ts.Tensor
-	ts.NoGrad(func() {
-		output, _, _ = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, ts.None, ts.None, false)
-	})
-
-	index1 := output.MustGet(0).MustGet(4).MustArgmax([]int64{0}, false, false).Int64Values()[0]
-	index2 := output.MustGet(1).MustGet(7).MustArgmax([]int64{0}, false, false).Int64Values()[0]
-
-	word1, ok := tk.IdToToken(int(index1))
-	if !ok {
-		fmt.Printf("Cannot find a corresponding word for the given id (%v) in vocab.\n", index1)
+	for i, prediction := range predictions {
+		fmt.Printf("Input: '%v' \t- Output: '%v'\n", sentences[i], prediction[0].Token)
 	}
-	fmt.Printf("Input: '%v' \t- Output: '%v'\n", sentence1, word1)
-
-	word2, ok := tk.IdToToken(int(index2))
-	if !ok {
-		fmt.Printf("Cannot find a corresponding word for the given id (%v) in vocab.\n", index2)
-	}
-	fmt.Printf("Input: '%v' \t- Output: '%v'\n", sentence2, word2)
 }
 
 func bertForSequenceClassification() {
@@ -174,7 +105,10 @@ func bertForSequenceClassification() {
 	config.Id2Label = dummyLabelMap
 	config.OutputAttentions = true
 	config.OutputHiddenStates = true
-	model := bert.NewBertForSequenceClassification(vs.Root(), config)
+	model, err := bert.NewBertForSequenceClassification(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 	tk := getBert()
 
 	// Define input
@@ -216,7 +150,10 @@ func bertForSequenceClassification() {
 	)
 
 	ts.NoGrad(func() {
-		output, allHiddenStates, allAttentions = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, false)
+		output, allHiddenStates, allAttentions, err = model.ForwardT(inputTensor, ts.None, ts.None, ts.None, ts.None, false)
+		if err != nil {
+			log.Fatal(err)
+		}
 	})
 
 	fmt.Printf("output size: %v\n", output.MustSize())
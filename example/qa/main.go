@@ -1 +1,44 @@
 package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/sugarme/gotch"
+
+	"github.com/sugarme/transformer/pipeline"
+)
+
+func main() {
+	qa, err := pipeline.NewQuestionAnsweringModel("deepset/roberta-base-squad2", gotch.CPU)
+	if err != nil {
+		log.Fatal(err)
+	}
+	qa.AllowNoAnswer = true
+
+	inputs := []pipeline.QAInput{
+		{
+			Question: "Where does Amy live?",
+			Context:  "Amy lives in Amsterdam. She works as a nurse in a hospital near the central station.",
+		},
+		{
+			Question: "What is the capital of Mongolia?",
+			Context:  "Amy lives in Amsterdam. She works as a nurse in a hospital near the central station.",
+		},
+	}
+
+	answers, err := qa.Predict(inputs, 3)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, input := range inputs {
+		fmt.Printf("Question: %v\n", input.Question)
+		for _, answer := range answers[i] {
+			if answer.Text == "" {
+				fmt.Printf("\t(no answer)\tscore: %.4f\n", answer.Score)
+				continue
+			}
+			fmt.Printf("\t%q [%v:%v]\tscore: %.4f\n", answer.Text, answer.Offset.Begin, answer.Offset.End, answer.Score)
+		}
+	}
+}
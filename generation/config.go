@@ -0,0 +1,89 @@
+package generation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Config holds generation parameters.
+type Config struct {
+	MaxLength          int64   // maximum number of generated tokens, excluding prompt
+	MinLength          int64   // stop tokens are banned until this number of tokens is generated
+	NumBeams           int64   // beam search if greater than 1
+	NumReturnSequences int64   // sequences returned per prompt. At most `NumBeams` for beam search.
+	LengthPenalty      float64 // exponent of sequence length dividing beam scores
+	EarlyStopping      bool    // stop beam search as soon as `NumBeams` sequences are finished
+	DoSample           bool    // sample next tokens instead of greedy search. Not supported with beam search.
+	Temperature        float64 // divides log probabilities before sampling
+	TopK               int64   // sample among the `TopK` most probable tokens. 0 means no limit.
+	TopP               float64 // sample among the most probable tokens whose cumulative probability reaches `TopP`
+	RepetitionPenalty  float64 // penalizes tokens already in sequence (CTRL). 1.0 means no penalty.
+	NoRepeatNgramSize  int64   // n-grams of this size can only occur once. 0 means no limit.
+	BadWordsIds        [][]int64
+	StopTokenIds       []int64 // e.g. end of sequence token
+	PadTokenId         int64   // pads prompts of different lengths and finished sequences
+
+	// Rand is the random source of sampling. If nil, `math/rand` default source is used.
+	Rand *rand.Rand
+}
+
+// DefaultConfig returns greedy search configuration generating at most 20 tokens.
+func DefaultConfig() *Config {
+	return &Config{
+		MaxLength:          20,
+		MinLength:          0,
+		NumBeams:           1,
+		NumReturnSequences: 1,
+		LengthPenalty:      1.0,
+		EarlyStopping:      false,
+		DoSample:           false,
+		Temperature:        1.0,
+		TopK:               0,
+		TopP:               1.0,
+		RepetitionPenalty:  1.0,
+		NoRepeatNgramSize:  0,
+	}
+}
+
+func (c *Config) validate() error {
+	switch {
+	case c.MaxLength < 1:
+		return fmt.Errorf("Invalid MaxLength (%v): must be positive", c.MaxLength)
+	case c.MinLength > c.MaxLength:
+		return fmt.Errorf("Invalid MinLength (%v): must not exceed MaxLength (%v)", c.MinLength, c.MaxLength)
+	case c.NumBeams < 1:
+		return fmt.Errorf("Invalid NumBeams (%v): must be positive", c.NumBeams)
+	case c.NumReturnSequences < 1:
+		return fmt.Errorf("Invalid NumReturnSequences (%v): must be positive", c.NumReturnSequences)
+	case c.NumBeams > 1 && c.DoSample:
+		return fmt.Errorf("Sampling with beam search is not supported")
+	case c.NumBeams > 1 && c.NumReturnSequences > c.NumBeams:
+		return fmt.Errorf("Invalid NumReturnSequences (%v): must not exceed NumBeams (%v)", c.NumReturnSequences, c.NumBeams)
+	case c.NumBeams == 1 && !c.DoSample && c.NumReturnSequences > 1:
+		return fmt.Errorf("Invalid NumReturnSequences (%v): greedy search returns a single sequence", c.NumReturnSequences)
+	case c.DoSample && c.Temperature <= 0:
+		return fmt.Errorf("Invalid Temperature (%v): must be positive", c.Temperature)
+	case c.DoSample && (c.TopP <= 0 || c.TopP > 1):
+		return fmt.Errorf("Invalid TopP (%v): must be in (0, 1]", c.TopP)
+	case c.RepetitionPenalty <= 0:
+		return fmt.Errorf("Invalid RepetitionPenalty (%v): must be positive", c.RepetitionPenalty)
+	}
+
+	return nil
+}
+
+// isStop reports whether `token` ends a sequence.
+func (c *Config) isStop(token int64) bool {
+	for _, id := range c.StopTokenIds {
+		if token == id {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize divides beam score by sequence length to the power of `LengthPenalty`.
+func (c *Config) normalize(score float64, length int) float64 {
+	return score / math.Pow(float64(length), c.LengthPenalty)
+}
@@ -0,0 +1,167 @@
+package generation
+
+// generation package generates token sequences with language models using greedy
+// search, beam search or sampling. Models plug in by implementing `CausalLM`.
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// Cache holds model states computed at previous generation steps (e.g. cached keys
+// and values of attention layers).
+type Cache interface {
+	// Reorder returns states of `indices` along batch dimension. It is used to follow
+	// beams and to duplicate prompts. Receiver is left unchanged.
+	Reorder(indices *ts.Tensor) Cache
+	// Drop frees cached tensors.
+	Drop()
+}
+
+// CausalLM is a model predicting next token from previous ones.
+type CausalLM interface {
+	// Step forwards `inputIds` of shape (batch size, length): whole prompts at first
+	// step with nil `cache`, then one new token per sequence with the cache returned by
+	// previous step. `mask` of shape (batch size, cached length + length) has value 0
+	// at padding positions of prompts, 1 elsewhere.
+	//
+	// It returns next token logits of shape (batch size, vocab size) and updated cache.
+	// Input tensors and cache are not modified and are dropped by caller.
+	Step(inputIds, mask *ts.Tensor, cache Cache) (*ts.Tensor, Cache, error)
+}
+
+// Sequence is a generated sequence.
+type Sequence struct {
+	Ids   []int64 // generated tokens without prompt, including stop token if any
+	Score float64 // sum of token log probabilities, normalized by length for beam search
+}
+
+// Generate generates sequences following `prompts`. Prompts of different lengths are
+// left-padded with `PadTokenId`. Model steps run without gradient tracking.
+//
+// It returns at most `NumReturnSequences` sequences per prompt, best first.
+func Generate(model CausalLM, prompts [][]int64, config *Config, device gotch.Device) ([][]Sequence, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	d := &decoder{model: model, device: device}
+	defer d.drop()
+
+	logits, err := d.start(prompts, config.PadTokenId)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.NumBeams > 1 {
+		return beamSearch(d, prompts, logits, config)
+	}
+	return search(d, prompts, logits, config)
+}
+
+// decoder tracks mask and cache of sequences being generated.
+type decoder struct {
+	model  CausalLM
+	device gotch.Device
+	mask   *ts.Tensor
+	cache  Cache
+}
+
+// start forwards prompts and returns their next token logits.
+func (d *decoder) start(prompts [][]int64, padTokenId int64) ([][]float64, error) {
+	var maxLen int
+	for i, prompt := range prompts {
+		if len(prompt) == 0 {
+			return nil, fmt.Errorf("Invalid prompt %v: prompts must not be empty", i)
+		}
+		if len(prompt) > maxLen {
+			maxLen = len(prompt)
+		}
+	}
+	if maxLen == 0 {
+		return nil, fmt.Errorf("No prompt to generate from")
+	}
+
+	var ids, mask []int64
+	for _, prompt := range prompts {
+		for i := len(prompt); i < maxLen; i++ {
+			ids = append(ids, padTokenId)
+			mask = append(mask, 0)
+		}
+		for _, id := range prompt {
+			ids = append(ids, id)
+			mask = append(mask, 1)
+		}
+	}
+
+	size := []int64{int64(len(prompts)), int64(maxLen)}
+	d.mask = ts.MustOfSlice(mask).MustView(size, true).MustTo(d.device, true)
+	inputIds := ts.MustOfSlice(ids).MustView(size, true).MustTo(d.device, true)
+
+	return d.forward(inputIds)
+}
+
+// next implements `stepper` interface.
+func (d *decoder) next(tokens []int64) ([][]float64, error) {
+	size := []int64{int64(len(tokens)), 1}
+	ones := ts.MustOnes(size, gotch.Int64, d.device)
+	mask := ts.MustCat([]ts.Tensor{*d.mask, *ones}, 1)
+	ones.MustDrop()
+	d.mask.MustDrop()
+	d.mask = mask
+
+	inputIds := ts.MustOfSlice(tokens).MustView(size, true).MustTo(d.device, true)
+
+	return d.forward(inputIds)
+}
+
+// reorder implements `stepper` interface.
+func (d *decoder) reorder(rows []int64) {
+	indices := ts.MustOfSlice(rows).MustTo(d.device, true)
+
+	cache := d.cache.Reorder(indices)
+	d.cache.Drop()
+	d.cache = cache
+	d.mask = d.mask.MustIndexSelect(0, indices, true)
+
+	indices.MustDrop()
+}
+
+func (d *decoder) forward(inputIds *ts.Tensor) ([][]float64, error) {
+	var (
+		logits *ts.Tensor
+		cache  Cache
+		err    error
+	)
+	ts.NoGrad(func() {
+		logits, cache, err = d.model.Step(inputIds, d.mask, d.cache)
+	})
+	inputIds.MustDrop()
+	if err != nil {
+		return nil, err
+	}
+	if d.cache != nil {
+		d.cache.Drop()
+	}
+	d.cache = cache
+
+	vocabSize := int(logits.MustSize()[1])
+	values := logits.Float64Values(true)
+	rows := make([][]float64, len(values)/vocabSize)
+	for i := range rows {
+		rows[i] = values[i*vocabSize : (i+1)*vocabSize]
+	}
+
+	return rows, nil
+}
+
+func (d *decoder) drop() {
+	if d.cache != nil {
+		d.cache.Drop()
+	}
+	if d.mask != nil {
+		d.mask.MustDrop()
+	}
+}
@@ -0,0 +1,187 @@
+package generation_test
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/generation"
+)
+
+// bigram is a toy language model: next token log probabilities only depend on last token.
+type bigram struct {
+	table *ts.Tensor
+}
+
+// noCache is a stateless `generation.Cache`.
+type noCache struct{}
+
+func (c noCache) Reorder(indices *ts.Tensor) generation.Cache { return c }
+func (c noCache) Drop()                                       {}
+
+func (m *bigram) Step(inputIds, mask *ts.Tensor, cache generation.Cache) (*ts.Tensor, generation.Cache, error) {
+	length := inputIds.MustSize()[1]
+	last := inputIds.MustSelect(1, length-1, false)
+	logits := m.table.MustIndexSelect(0, last, false)
+	last.MustDrop()
+
+	return logits, noCache{}, nil
+}
+
+// Tokens: 0 is end of sequence, 1 is "A", 2 is "B" and 3 is "C".
+func newBigram() *bigram {
+	probs := [][]float64{
+		{0.97, 0.01, 0.01, 0.01},
+		{0.1, 0.05, 0.45, 0.4},   // after "A": "B" is most probable but "C" is followed by end of sequence
+		{0.34, 0.33, 0.33, 1e-4}, // after "B"
+		{0.9, 0.05, 0.05, 1e-4},  // after "C"
+	}
+	var logProbs []float64
+	for _, row := range probs {
+		for _, p := range row {
+			logProbs = append(logProbs, math.Log(p))
+		}
+	}
+
+	return &bigram{ts.MustOfSlice(logProbs).MustTotype(gotch.Float, true).MustView([]int64{4, 4}, true)}
+}
+
+func ids(sequences [][]generation.Sequence) [][]int64 {
+	var retVal [][]int64
+	for _, s := range sequences {
+		for _, seq := range s {
+			retVal = append(retVal, seq.Ids)
+		}
+	}
+	return retVal
+}
+
+func TestGenerate_Greedy(t *testing.T) {
+	config := generation.DefaultConfig()
+	config.StopTokenIds = []int64{0}
+
+	// Prompts of different lengths are left-padded.
+	got, err := generation.Generate(newBigram(), [][]int64{{1}, {2, 3}}, config, gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]int64{{2, 0}, {0}}
+	if !reflect.DeepEqual(want, ids(got)) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", ids(got))
+	}
+	if math.Abs(got[0][0].Score-math.Log(0.45*0.34)) > 1e-4 {
+		t.Errorf("Want: %v\n", math.Log(0.45*0.34))
+		t.Errorf("Got: %v\n", got[0][0].Score)
+	}
+}
+
+func TestGenerate_BeamSearch(t *testing.T) {
+	config := generation.DefaultConfig()
+	config.StopTokenIds = []int64{0}
+	config.NumBeams = 2
+	config.NumReturnSequences = 2
+
+	got, err := generation.Generate(newBigram(), [][]int64{{1}}, config, gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Beam search finds the more probable sequence missed by greedy search.
+	want := [][]int64{{3, 0}, {2, 0}}
+	if !reflect.DeepEqual(want, ids(got)) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", ids(got))
+	}
+	wantScore := math.Log(0.4*0.9) / 2
+	if math.Abs(got[0][0].Score-wantScore) > 1e-4 {
+		t.Errorf("Want: %v\n", wantScore)
+		t.Errorf("Got: %v\n", got[0][0].Score)
+	}
+}
+
+func TestGenerate_Penalties(t *testing.T) {
+	config := generation.DefaultConfig()
+	config.StopTokenIds = []int64{0}
+	config.MinLength = 3
+	config.NoRepeatNgramSize = 2
+
+	got, err := generation.Generate(newBigram(), [][]int64{{1}}, config, gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "A B" cannot be repeated and end of sequence is banned before 3 tokens.
+	want := [][]int64{{2, 1, 3, 0}}
+	if !reflect.DeepEqual(want, ids(got)) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", ids(got))
+	}
+
+	config = generation.DefaultConfig()
+	config.MaxLength = 3
+	config.BadWordsIds = [][]int64{{0}, {1, 2}}
+
+	got, err = generation.Generate(newBigram(), [][]int64{{1}}, config, gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = [][]int64{{3, 1, 3}}
+	if !reflect.DeepEqual(want, ids(got)) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", ids(got))
+	}
+}
+
+func TestGenerate_Sample(t *testing.T) {
+	config := generation.DefaultConfig()
+	config.StopTokenIds = []int64{0}
+	config.DoSample = true
+	config.NumReturnSequences = 3
+	config.Rand = rand.New(rand.NewSource(1))
+
+	// Sampling among the most probable token is greedy search.
+	config.TopK = 1
+	got, err := generation.Generate(newBigram(), [][]int64{{1}}, config, gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int64{{2, 0}, {2, 0}, {2, 0}}
+	if !reflect.DeepEqual(want, ids(got)) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", ids(got))
+	}
+
+	// After "C", end of sequence alone has cumulative probability 0.9.
+	config.TopK = 0
+	config.TopP = 0.8
+	got, err = generation.Generate(newBigram(), [][]int64{{3}}, config, gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][]int64{{0}, {0}, {0}}
+	if !reflect.DeepEqual(want, ids(got)) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", ids(got))
+	}
+}
+
+func TestGenerate_InvalidConfig(t *testing.T) {
+	config := generation.DefaultConfig()
+	config.NumBeams = 2
+	config.DoSample = true
+	if _, err := generation.Generate(newBigram(), [][]int64{{1}}, config, gotch.CPU); err == nil {
+		t.Errorf("Want error for sampling with beam search\n")
+	}
+
+	config = generation.DefaultConfig()
+	if _, err := generation.Generate(newBigram(), [][]int64{{}}, config, gotch.CPU); err == nil {
+		t.Errorf("Want error for empty prompt\n")
+	}
+}
@@ -0,0 +1,180 @@
+package generation
+
+import (
+	"math"
+	"sort"
+)
+
+// logProbs applies penalties to next token `logits` of a sequence made of `prompt`
+// and `generated` tokens, and returns next token log probabilities.
+func (c *Config) logProbs(logits []float64, prompt, generated []int64) []float64 {
+	scores := append([]float64{}, logits...)
+	sequence := append(append([]int64{}, prompt...), generated...)
+
+	if c.RepetitionPenalty != 1.0 {
+		penalized := make(map[int64]bool)
+		for _, token := range sequence {
+			if penalized[token] || token < 0 || token >= int64(len(scores)) {
+				continue
+			}
+			penalized[token] = true
+			if scores[token] < 0 {
+				scores[token] *= c.RepetitionPenalty
+			} else {
+				scores[token] /= c.RepetitionPenalty
+			}
+		}
+	}
+
+	if c.NoRepeatNgramSize > 0 {
+		for _, token := range bannedNgramTokens(sequence, int(c.NoRepeatNgramSize)) {
+			ban(scores, token)
+		}
+	}
+
+	for _, words := range c.BadWordsIds {
+		if len(words) > 0 && hasSuffix(sequence, words[:len(words)-1]) {
+			ban(scores, words[len(words)-1])
+		}
+	}
+
+	if int64(len(generated)) < c.MinLength {
+		for _, token := range c.StopTokenIds {
+			ban(scores, token)
+		}
+	}
+
+	return logSoftmax(scores)
+}
+
+// sample draws next token from log probabilities `logProbs` scaled by `Temperature`
+// and restricted to `TopK` and `TopP` most probable tokens.
+func (c *Config) sample(logProbs []float64, random func() float64) int64 {
+	ids := make([]int, len(logProbs))
+	for i := range ids {
+		ids[i] = i
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		return logProbs[ids[i]] > logProbs[ids[j]]
+	})
+
+	n := len(ids)
+	if c.TopK > 0 && c.TopK < int64(n) {
+		n = int(c.TopK)
+	}
+
+	best := logProbs[ids[0]] / c.Temperature
+	if math.IsInf(best, -1) {
+		return int64(ids[0])
+	}
+
+	probs := make([]float64, n)
+	var sum float64
+	for i := 0; i < n; i++ {
+		probs[i] = math.Exp(logProbs[ids[i]]/c.Temperature - best)
+		sum += probs[i]
+	}
+
+	if c.TopP < 1 {
+		var cumulative float64
+		for i := 0; i < n; i++ {
+			cumulative += probs[i] / sum
+			if cumulative >= c.TopP {
+				n = i + 1
+				break
+			}
+		}
+		sum = 0
+		for i := 0; i < n; i++ {
+			sum += probs[i]
+		}
+	}
+
+	r := random() * sum
+	for i := 0; i < n; i++ {
+		r -= probs[i]
+		if r < 0 {
+			return int64(ids[i])
+		}
+	}
+
+	return int64(ids[n-1])
+}
+
+// bannedNgramTokens returns tokens which would repeat an n-gram of `sequence`.
+func bannedNgramTokens(sequence []int64, n int) []int64 {
+	if n < 1 || len(sequence)+1 < n {
+		return nil
+	}
+
+	prefix := sequence[len(sequence)-n+1:]
+	var banned []int64
+	for i := 0; i+n <= len(sequence); i++ {
+		if equal(sequence[i:i+n-1], prefix) {
+			banned = append(banned, sequence[i+n-1])
+		}
+	}
+
+	return banned
+}
+
+func ban(scores []float64, token int64) {
+	if token >= 0 && token < int64(len(scores)) {
+		scores[token] = math.Inf(-1)
+	}
+}
+
+func hasSuffix(sequence, suffix []int64) bool {
+	if len(suffix) > len(sequence) {
+		return false
+	}
+	return equal(sequence[len(sequence)-len(suffix):], suffix)
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func logSoftmax(scores []float64) []float64 {
+	max := math.Inf(-1)
+	for _, s := range scores {
+		if s > max {
+			max = s
+		}
+	}
+
+	retVal := make([]float64, len(scores))
+	if math.IsInf(max, -1) {
+		copy(retVal, scores)
+		return retVal
+	}
+
+	var sum float64
+	for _, s := range scores {
+		sum += math.Exp(s - max)
+	}
+	logSum := max + math.Log(sum)
+	for i, s := range scores {
+		retVal[i] = s - logSum
+	}
+
+	return retVal
+}
+
+func argmax(scores []float64) int64 {
+	var retVal int64
+	for i, s := range scores {
+		if s > scores[retVal] {
+			retVal = int64(i)
+		}
+	}
+	return retVal
+}
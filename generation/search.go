@@ -0,0 +1,269 @@
+package generation
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// stepper forwards generated tokens through a model. Rows are sequences being generated.
+type stepper interface {
+	// next forwards one new token per row and returns next token logits of every row.
+	next(tokens []int64) ([][]float64, error)
+	// reorder keeps model states of `rows`, in that order.
+	reorder(rows []int64)
+}
+
+// expand repeats every row of `logits` and model states `n` times.
+func expand(s stepper, logits [][]float64, n int64) [][]float64 {
+	if n == 1 {
+		return logits
+	}
+
+	var (
+		rows     []int64
+		expanded [][]float64
+	)
+	for i := range logits {
+		for j := int64(0); j < n; j++ {
+			rows = append(rows, int64(i))
+			expanded = append(expanded, logits[i])
+		}
+	}
+	s.reorder(rows)
+
+	return expanded
+}
+
+// search generates sequences with greedy search or sampling. `logits` are next token
+// logits of `prompts`.
+func search(s stepper, prompts [][]int64, logits [][]float64, config *Config) ([][]Sequence, error) {
+	n := config.NumReturnSequences
+	logits = expand(s, logits, n)
+
+	random := rand.Float64
+	if config.Rand != nil {
+		random = config.Rand.Float64
+	}
+
+	sequences := make([]Sequence, len(logits))
+	done := make([]bool, len(logits))
+	for {
+		tokens := make([]int64, len(logits))
+		allDone := true
+		for i := range logits {
+			if done[i] {
+				tokens[i] = config.PadTokenId
+				continue
+			}
+
+			logProbs := config.logProbs(logits[i], prompts[int64(i)/n], sequences[i].Ids)
+			var token int64
+			if config.DoSample {
+				token = config.sample(logProbs, random)
+			} else {
+				token = argmax(logProbs)
+			}
+
+			sequences[i].Ids = append(sequences[i].Ids, token)
+			sequences[i].Score += logProbs[token]
+			tokens[i] = token
+
+			done[i] = config.isStop(token) || int64(len(sequences[i].Ids)) >= config.MaxLength
+			allDone = allDone && done[i]
+		}
+
+		if allDone {
+			break
+		}
+
+		var err error
+		logits, err = s.next(tokens)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	retVal := make([][]Sequence, len(prompts))
+	for i := range prompts {
+		retVal[i] = best(sequences[int64(i)*n:int64(i+1)*n], n)
+	}
+
+	return retVal, nil
+}
+
+// beam is a live beam search hypothesis.
+type beam struct {
+	tokens []int64
+	score  float64 // sum of token log probabilities
+}
+
+// candidate is a beam extended with a next token.
+type candidate struct {
+	row   int64
+	token int64
+	score float64
+}
+
+// beamSearch generates sequences with beam search. `logits` are next token logits of `prompts`.
+func beamSearch(s stepper, prompts [][]int64, logits [][]float64, config *Config) ([][]Sequence, error) {
+	numBeams := config.NumBeams
+	logits = expand(s, logits, numBeams)
+
+	// Beams of a prompt start identical, only the first one is extended at first step.
+	beams := make([]beam, len(logits))
+	for i := range beams {
+		if int64(i)%numBeams != 0 {
+			beams[i].score = math.Inf(-1)
+		}
+	}
+
+	finished := make([][]Sequence, len(prompts))
+	done := make([]bool, len(prompts))
+	for length := int64(1); ; length++ {
+		var (
+			nextBeams []beam
+			rows      []int64
+			tokens    []int64
+		)
+		allDone := true
+		for i, prompt := range prompts {
+			first := int64(i) * numBeams
+			if done[i] {
+				for j := int64(0); j < numBeams; j++ {
+					nextBeams = append(nextBeams, beam{score: math.Inf(-1)})
+					rows = append(rows, first)
+					tokens = append(tokens, config.PadTokenId)
+				}
+				continue
+			}
+
+			// Keep 2 * beams candidates so that enough remain after removing finished ones.
+			var candidates []candidate
+			for row := first; row < first+numBeams; row++ {
+				if math.IsInf(beams[row].score, -1) {
+					continue
+				}
+				logProbs := config.logProbs(logits[row], prompt, beams[row].tokens)
+				for token, logProb := range logProbs {
+					if math.IsInf(logProb, -1) {
+						continue
+					}
+					candidates = insert(candidates, candidate{row, int64(token), beams[row].score + logProb}, int(2*numBeams))
+				}
+			}
+
+			var live []beam
+			for rank, c := range candidates {
+				ids := append(append([]int64{}, beams[c.row].tokens...), c.token)
+				if config.isStop(c.token) {
+					// Stop token is only accepted from the top beams candidates.
+					if int64(rank) < numBeams {
+						finished[i] = append(finished[i], Sequence{ids, config.normalize(c.score, len(ids))})
+					}
+					continue
+				}
+				live = append(live, beam{ids, c.score})
+				rows = append(rows, c.row)
+				if int64(len(live)) == numBeams {
+					break
+				}
+			}
+			finished[i] = best(finished[i], numBeams)
+
+			switch {
+			case len(live) == 0 || beamDone(finished[i], live, config):
+				done[i] = true
+			case length >= config.MaxLength:
+				// Live beams at maximum length are finished too.
+				for _, b := range live {
+					finished[i] = append(finished[i], Sequence{b.tokens, config.normalize(b.score, len(b.tokens))})
+				}
+				finished[i] = best(finished[i], numBeams)
+				done[i] = true
+			}
+			allDone = allDone && done[i]
+
+			for _, b := range live {
+				tokens = append(tokens, b.tokens[len(b.tokens)-1])
+			}
+			nextBeams = append(nextBeams, live...)
+			// Fill missing beams with dead ones.
+			for j := int64(len(live)); j < numBeams; j++ {
+				nextBeams = append(nextBeams, beam{score: math.Inf(-1)})
+				rows = append(rows, first)
+				tokens = append(tokens, config.PadTokenId)
+			}
+		}
+
+		if allDone {
+			break
+		}
+
+		beams = nextBeams
+		s.reorder(rows)
+		var err error
+		logits, err = s.next(tokens)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	retVal := make([][]Sequence, len(prompts))
+	for i := range prompts {
+		retVal[i] = best(finished[i], config.NumReturnSequences)
+	}
+
+	return retVal, nil
+}
+
+// beamDone reports whether beam search of a prompt can stop: `NumBeams` sequences are
+// finished and, unless `EarlyStopping` is set, none of the `live` beams beats the
+// worst of them. `finished` must be sorted by `best`.
+func beamDone(finished []Sequence, live []beam, config *Config) bool {
+	if int64(len(finished)) < config.NumBeams {
+		return false
+	}
+	if config.EarlyStopping {
+		return true
+	}
+
+	worst := finished[len(finished)-1].Score
+	for _, b := range live {
+		if config.normalize(b.score, len(b.tokens)) > worst {
+			return false
+		}
+	}
+
+	return true
+}
+
+// insert inserts candidate `c` into `candidates` sorted by decreasing score, keeping at most `n` of them.
+func insert(candidates []candidate, c candidate, n int) []candidate {
+	if len(candidates) == n && c.score <= candidates[n-1].score {
+		return candidates
+	}
+
+	i := sort.Search(len(candidates), func(i int) bool {
+		return candidates[i].score < c.score
+	})
+	if len(candidates) < n {
+		candidates = append(candidates, candidate{})
+	}
+	copy(candidates[i+1:], candidates[i:])
+	candidates[i] = c
+
+	return candidates
+}
+
+// best returns at most `n` sequences with highest scores, best first.
+func best(sequences []Sequence, n int64) []Sequence {
+	sort.SliceStable(sequences, func(i, j int) bool {
+		return sequences[i].Score > sequences[j].Score
+	})
+	if int64(len(sequences)) > n {
+		sequences = sequences[:n]
+	}
+
+	return sequences
+}
@@ -0,0 +1,162 @@
+package gpt2
+
+// gpt2 package implements GPT-2 autoregressive language model. Text is tokenized
+// with the byte-level BPE of `roberta` package (see `Tokenizer`).
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// GPT2Config defines the GPT-2 model architecture (i.e., number of layers,
+// hidden size, context size...)
+type GPT2Config struct {
+	VocabSize          int64   `json:"vocab_size"`
+	NPositions         int64   `json:"n_positions"`
+	NEmbd              int64   `json:"n_embd"`
+	NLayer             int64   `json:"n_layer"`
+	NHead              int64   `json:"n_head"`
+	NInner             int64   `json:"n_inner"`
+	ActivationFunction string  `json:"activation_function"`
+	ResidPdrop         float64 `json:"resid_pdrop"`
+	EmbdPdrop          float64 `json:"embd_pdrop"`
+	AttnPdrop          float64 `json:"attn_pdrop"`
+	LayerNormEpsilon   float64 `json:"layer_norm_epsilon"`
+	InitializerRange   float64 `json:"initializer_range"`
+	BosTokenId         int64   `json:"bos_token_id"`
+	EosTokenId         int64   `json:"eos_token_id"`
+	OutputAttentions   bool    `json:"output_attentions"`
+	OutputHiddenStates bool    `json:"output_hidden_states"`
+}
+
+// NewConfig initiates GPT2Config with given input parameters or default values
+// of `gpt2`.
+//
+// NOTE. `NInner` (inner feed-forward size) defaults to 4 * `NEmbd` when not set.
+func NewConfig(customParams map[string]interface{}) *GPT2Config {
+	defaultValues := map[string]interface{}{
+		"VocabSize":          int64(50257),
+		"NPositions":         int64(1024),
+		"NEmbd":              int64(768),
+		"NLayer":             int64(12),
+		"NHead":              int64(12),
+		"NInner":             int64(0),
+		"ActivationFunction": "gelu_new",
+		"ResidPdrop":         float64(0.1),
+		"EmbdPdrop":          float64(0.1),
+		"AttnPdrop":          float64(0.1),
+		"LayerNormEpsilon":   float64(1e-5),
+		"InitializerRange":   float64(0.02),
+		"BosTokenId":         int64(50256),
+		"EosTokenId":         int64(50256),
+	}
+
+	params := defaultValues
+	for k, v := range customParams {
+		if _, ok := params[k]; ok {
+			params[k] = v
+		}
+	}
+
+	config := new(GPT2Config)
+	config.updateParams(params)
+
+	return config
+}
+
+// ConfigFromFile loads GPT2Config from a JSON file.
+func ConfigFromFile(filename string) (*GPT2Config, error) {
+	config := new(GPT2Config)
+	if err := config.fromFile(filename); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Load loads model configuration from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Config` interface.
+func (c *GPT2Config) Load(modelNameOrPath string, params map[string]interface{}) error {
+	err := c.fromFile(modelNameOrPath)
+	if err != nil {
+		return err
+	}
+
+	// Update custom parameters
+	c.updateParams(params)
+
+	return nil
+}
+
+// SavePretrained saves configuration to directory `dir` as `config.json` file.
+// This method implements `pretrained.Config` interface.
+func (c *GPT2Config) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buff, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, util.ConfigName), buff, 0644)
+}
+
+func (c *GPT2Config) fromFile(filename string) error {
+	filePath, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	buff, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	// Original GPT-2 configs omit `activation_function` and set `n_inner` to null,
+	// hence start from default values.
+	*c = *NewConfig(nil)
+	err = json.Unmarshal(buff, c)
+	if err != nil {
+		return fmt.Errorf("Could not parse configuration to GPT2Config: %v: %w", err, util.ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// GetVocabSize returns vocabulary size.
+func (c *GPT2Config) GetVocabSize() int64 {
+	return c.VocabSize
+}
+
+// innerDim returns size of the feed-forward inner layer.
+func (c *GPT2Config) innerDim() int64 {
+	if c.NInner > 0 {
+		return c.NInner
+	}
+	return 4 * c.NEmbd
+}
+
+func (c *GPT2Config) updateParams(params map[string]interface{}) {
+	for k, v := range params {
+		c.updateField(k, v)
+	}
+}
+
+func (c *GPT2Config) updateField(field string, value interface{}) {
+	// Check whether field name exists
+	if reflect.ValueOf(c).Elem().FieldByName(field).IsValid() {
+		// Check whether same type
+		if reflect.ValueOf(c).Elem().FieldByName(field).Kind() == reflect.TypeOf(value).Kind() {
+			reflect.ValueOf(c).Elem().FieldByName(field).Set(reflect.ValueOf(value))
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package gpt2_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/gpt2"
+)
+
+func TestConfigFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpt2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Excerpt of `distilgpt2` config. It omits `activation_function` and sets `n_inner` to null.
+	data := `{
+  "architectures": ["GPT2LMHeadModel"],
+  "attn_pdrop": 0.1,
+  "bos_token_id": 50256,
+  "embd_pdrop": 0.1,
+  "eos_token_id": 50256,
+  "initializer_range": 0.02,
+  "layer_norm_epsilon": 1e-05,
+  "model_type": "gpt2",
+  "n_ctx": 1024,
+  "n_embd": 768,
+  "n_head": 12,
+  "n_inner": null,
+  "n_layer": 6,
+  "n_positions": 1024,
+  "resid_pdrop": 0.1,
+  "vocab_size": 50257
+}`
+	configFile := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := gpt2.ConfigFromFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := gpt2.NewConfig(map[string]interface{}{"NLayer": int64(6)})
+	if !reflect.DeepEqual(want, config) {
+		t.Errorf("Want: %+v\n", want)
+		t.Errorf("Got: %+v\n", config)
+	}
+}
@@ -0,0 +1,61 @@
+package gpt2
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/generation"
+	"github.com/sugarme/transformer/util"
+)
+
+// Past holds cached keys and values of every block. It implements `generation.Cache` interface.
+type Past []LayerState
+
+// Reorder implements `generation.Cache` interface.
+func (p Past) Reorder(indices *ts.Tensor) generation.Cache {
+	retVal := make(Past, len(p))
+	for i := range p {
+		retVal[i] = p[i].Reorder(indices)
+	}
+	return retVal
+}
+
+// Drop implements `generation.Cache` interface.
+func (p Past) Drop() {
+	for i := range p {
+		p[i].Drop()
+	}
+}
+
+// Step forwards new tokens and returns next token logits. Position ids skip left padding of prompts.
+//
+// This method implements `generation.CausalLM` interface.
+func (lm *GPT2LMHeadModel) Step(inputIds, mask *ts.Tensor, cache generation.Cache) (*ts.Tensor, generation.Cache, error) {
+	var past Past
+	if cache != nil {
+		var ok bool
+		if past, ok = cache.(Past); !ok {
+			return nil, nil, fmt.Errorf("Invalid cache type %T, want gpt2.Past: %w", cache, util.ErrInvalidInput)
+		}
+	}
+
+	qLen := inputIds.MustSize()[1]
+	kLen := mask.MustSize()[1]
+	positionIds := mask.MustCumsum(1, gotch.Int64, false).MustSubScalar(ts.IntScalar(1), true).MustClampMin(ts.IntScalar(0), true).MustNarrow(1, kLen-qLen, qLen, true)
+
+	logits, presents, hiddenStates, attentions, err := lm.ForwardT(inputIds, past, mask, ts.None, positionIds, ts.None, false)
+	positionIds.MustDrop()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range hiddenStates {
+		hiddenStates[i].MustDrop()
+	}
+	for i := range attentions {
+		attentions[i].MustDrop()
+	}
+
+	return logits.MustSelect(1, qLen-1, true), Past(presents), nil
+}
@@ -0,0 +1,226 @@
+package gpt2
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// LayerState holds keys and values of a block cached from previous forward passes
+// (`past_key_values`). Tensors have shape (batch size, heads, past length, head dim).
+type LayerState struct {
+	PrevKey   *ts.Tensor
+	PrevValue *ts.Tensor
+}
+
+// Reorder selects cached keys and values of `indices` along batch dimension.
+// It is used by beam search to follow the beams kept at every step.
+func (s *LayerState) Reorder(indices *ts.Tensor) LayerState {
+	return LayerState{
+		PrevKey:   s.PrevKey.MustIndexSelect(0, indices, false),
+		PrevValue: s.PrevValue.MustIndexSelect(0, indices, false),
+	}
+}
+
+// Drop frees cached tensors.
+func (s *LayerState) Drop() {
+	s.PrevKey.MustDrop()
+	s.PrevValue.MustDrop()
+}
+
+// Attention:
+// ==========
+
+// Attention is GPT-2 causal self-attention with fused query, key and value
+// projection (`c_attn`) and output projection (`c_proj`).
+type Attention struct {
+	CAttn        *util.Conv1D
+	CProj        *util.Conv1D
+	AttnDropout  *util.Dropout
+	ResidDropout *util.Dropout
+	NumHeads     int64
+	HeadDim      int64
+}
+
+// NewAttention creates a new Attention.
+func NewAttention(p *nn.Path, config *GPT2Config) (*Attention, error) {
+	if config.NEmbd%config.NHead != 0 {
+		return nil, fmt.Errorf("Hidden size (%v) is not a multiple of the number of attention heads (%v): %w", config.NEmbd, config.NHead, util.ErrShapeMismatch)
+	}
+
+	cAttn, err := util.NewConv1D(p.Sub("c_attn"), config.NEmbd, 3*config.NEmbd, config.InitializerRange)
+	if err != nil {
+		return nil, err
+	}
+	cProj, err := util.NewConv1D(p.Sub("c_proj"), config.NEmbd, config.NEmbd, config.InitializerRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Attention{
+		CAttn:        cAttn,
+		CProj:        cProj,
+		AttnDropout:  util.NewDropout(config.AttnPdrop),
+		ResidDropout: util.NewDropout(config.ResidPdrop),
+		NumHeads:     config.NHead,
+		HeadDim:      config.NEmbd / config.NHead,
+	}, nil
+}
+
+// splitHeads: (batch size, length, hidden size) -> (batch size, heads, length, head dim).
+func (a *Attention) splitHeads(x *ts.Tensor, bs int64) *ts.Tensor {
+	return x.MustView([]int64{bs, -1, a.NumHeads, a.HeadDim}, false).MustTranspose(1, 2, true)
+}
+
+// ForwardT forwards pass through the attention layer.
+//
+// Params:
+//   - `hiddenStates`: input of shape (batch size, sequence length, hidden size)
+//   - `mask`: additive mask broadcastable to (batch size, heads, sequence length, past length + sequence length)
+//     combining causal and padding masks
+//   - `layerPast`: cached keys and values or nil
+//
+// Returns:
+//   - attention output of shape (batch size, sequence length, hidden size)
+//   - attention weights of shape (batch size, heads, sequence length, past length + sequence length)
+//   - keys and values including current positions to cache
+func (a *Attention) ForwardT(hiddenStates, mask *ts.Tensor, layerPast *LayerState, train bool) (retVal, retValOpt *ts.Tensor, present *LayerState) {
+	size := hiddenStates.MustSize()
+	bs, qLen, hiddenSize := size[0], size[1], size[2]
+
+	qkv := hiddenStates.Apply(a.CAttn)
+	chunks := qkv.MustSplit(hiddenSize, 2, true)
+	q := a.splitHeads(&chunks[0], bs)
+	k := a.splitHeads(&chunks[1], bs)
+	v := a.splitHeads(&chunks[2], bs)
+	for i := range chunks {
+		chunks[i].MustDrop()
+	}
+
+	if layerPast != nil {
+		kTmp := ts.MustCat([]ts.Tensor{*layerPast.PrevKey, *k}, 2)
+		vTmp := ts.MustCat([]ts.Tensor{*layerPast.PrevValue, *v}, 2)
+		k.MustDrop()
+		v.MustDrop()
+		k, v = kTmp, vTmp
+	}
+
+	kT := k.MustTranspose(-1, -2, false)
+	scores := q.MustMatmul(kT, true).MustDivScalar(ts.FloatScalar(math.Sqrt(float64(a.HeadDim))), true)
+	kT.MustDrop()
+	scores = scores.MustAdd(mask, true)
+
+	weights := scores.MustSoftmax(-1, gotch.Float, true)
+	dropped := weights.ApplyT(a.AttnDropout, train)
+	context := dropped.MustMatmul(v, true)
+	merged := context.MustTranspose(1, 2, true).MustContiguous(true).MustView([]int64{bs, qLen, hiddenSize}, true)
+	projected := merged.Apply(a.CProj)
+	merged.MustDrop()
+	retVal = projected.ApplyT(a.ResidDropout, train)
+	projected.MustDrop()
+
+	return retVal, weights, &LayerState{PrevKey: k, PrevValue: v}
+}
+
+// MLP:
+// ====
+
+// MLP is the GPT-2 feed-forward layer.
+type MLP struct {
+	CFc        *util.Conv1D
+	CProj      *util.Conv1D
+	Activation util.ActivationFn
+	Dropout    *util.Dropout
+}
+
+// NewMLP creates a new MLP.
+func NewMLP(p *nn.Path, config *GPT2Config) (*MLP, error) {
+	activation, ok := util.ActivationFnMap[config.ActivationFunction]
+	if !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.ActivationFunction)
+	}
+
+	cFc, err := util.NewConv1D(p.Sub("c_fc"), config.NEmbd, config.innerDim(), config.InitializerRange)
+	if err != nil {
+		return nil, err
+	}
+	cProj, err := util.NewConv1D(p.Sub("c_proj"), config.innerDim(), config.NEmbd, config.InitializerRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MLP{
+		CFc:        cFc,
+		CProj:      cProj,
+		Activation: activation,
+		Dropout:    util.NewDropout(config.ResidPdrop),
+	}, nil
+}
+
+// ForwardT forwards pass through the layer.
+func (m *MLP) ForwardT(x *ts.Tensor, train bool) *ts.Tensor {
+	x1 := x.Apply(m.CFc)
+	x2 := m.Activation.Fwd(x1)
+	x1.MustDrop()
+	x3 := x2.Apply(m.CProj)
+	x2.MustDrop()
+	retVal := x3.ApplyT(m.Dropout, train)
+	x3.MustDrop()
+
+	return retVal
+}
+
+// Block:
+// ======
+
+// Block is a GPT-2 layer: layer norm, self-attention, layer norm and feed-forward
+// sub-layers with residual connections (pre-norm).
+type Block struct {
+	Ln1  *nn.LayerNorm
+	Attn *Attention
+	Ln2  *nn.LayerNorm
+	Mlp  *MLP
+}
+
+// NewBlock creates a new Block.
+func NewBlock(p *nn.Path, config *GPT2Config) (*Block, error) {
+	layerNormConfig := nn.DefaultLayerNormConfig()
+	layerNormConfig.Eps = config.LayerNormEpsilon
+
+	attn, err := NewAttention(p.Sub("attn"), config)
+	if err != nil {
+		return nil, err
+	}
+	mlp, err := NewMLP(p.Sub("mlp"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Block{
+		Ln1:  nn.NewLayerNorm(p.Sub("ln_1"), []int64{config.NEmbd}, layerNormConfig),
+		Attn: attn,
+		Ln2:  nn.NewLayerNorm(p.Sub("ln_2"), []int64{config.NEmbd}, layerNormConfig),
+		Mlp:  mlp,
+	}, nil
+}
+
+// ForwardT forwards pass through the block. See `Attention.ForwardT` for params.
+func (b *Block) ForwardT(hiddenStates, mask *ts.Tensor, layerPast *LayerState, train bool) (retVal, retValOpt *ts.Tensor, present *LayerState) {
+	normed := hiddenStates.Apply(b.Ln1)
+	attnOutput, attnWeights, present := b.Attn.ForwardT(normed, mask, layerPast, train)
+	normed.MustDrop()
+	hiddenState := attnOutput.MustAdd(hiddenStates, true)
+
+	normed = hiddenState.Apply(b.Ln2)
+	mlpOutput := b.Mlp.ForwardT(normed, train)
+	normed.MustDrop()
+	retVal = mlpOutput.MustAdd(hiddenState, true)
+	hiddenState.MustDrop()
+
+	return retVal, attnWeights, present
+}
@@ -0,0 +1,293 @@
+package gpt2
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/util"
+)
+
+// GPT2Model:
+// ==========
+
+// GPT2Model defines base architecture for GPT-2 models: token (`wte`) and position
+// (`wpe`) embeddings, `h.N` blocks and a final layer norm (`ln_f`).
+//
+// Variables are named as in the original `gpt2` checkpoints, i.e. without `transformer.` prefix.
+type GPT2Model struct {
+	Wte                *nn.Embedding
+	Wpe                *nn.Embedding
+	Drop               *util.Dropout
+	H                  []*Block
+	LnF                *nn.LayerNorm
+	NPositions         int64
+	OutputAttentions   bool
+	OutputHiddenStates bool
+}
+
+// NewGPT2Model builds a new `GPT2Model`.
+//
+// Params:
+//   - `p`: Variable store path for the root of the GPT-2 model
+//   - `config`: GPT2Config configuration for model architecture
+func NewGPT2Model(p *nn.Path, config *GPT2Config) (*GPT2Model, error) {
+	wte := nn.NewEmbedding(p.Sub("wte"), config.VocabSize, config.NEmbd, nn.DefaultEmbeddingConfig())
+	wpe := nn.NewEmbedding(p.Sub("wpe"), config.NPositions, config.NEmbd, nn.DefaultEmbeddingConfig())
+
+	path := p.Sub("h")
+	var blocks []*Block
+	for i := 0; i < int(config.NLayer); i++ {
+		block, err := NewBlock(path.Sub(fmt.Sprintf("%v", i)), config)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	layerNormConfig := nn.DefaultLayerNormConfig()
+	layerNormConfig.Eps = config.LayerNormEpsilon
+
+	return &GPT2Model{
+		Wte:                wte,
+		Wpe:                wpe,
+		Drop:               util.NewDropout(config.EmbdPdrop),
+		H:                  blocks,
+		LnF:                nn.NewLayerNorm(p.Sub("ln_f"), []int64{config.NEmbd}, layerNormConfig),
+		NPositions:         config.NPositions,
+		OutputAttentions:   config.OutputAttentions,
+		OutputHiddenStates: config.OutputHiddenStates,
+	}, nil
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `past`: keys and values returned by a previous call for every block (`past_key_values`), or nil.
+//     Then `inputIds` only holds new tokens. Input `past` is not modified and can be dropped by caller.
+//   - `mask`: optional mask of shape (batch size, past length + sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `tokenTypeIds`: optional segment ids of shape (batch size, sequence length), embedded with token embeddings.
+//   - `positionIds`: optional position ids of shape (batch size, sequence length).
+//     If None, set to [past length, past length + sequence length).
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, hidden size).
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, hidden size)
+//   - `presents`: keys and values of every block including current positions, to pass as `past` to next call
+//   - `hiddenStates`: input of every block and final output if `OutputHiddenStates` is set
+//   - `attentions`: attention weights of every block if `OutputAttentions` is set
+func (m *GPT2Model) ForwardT(inputIds *ts.Tensor, past []LayerState, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, presents []LayerState, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	var embeddings *ts.Tensor
+	switch {
+	case inputIds.MustDefined() && inputEmbeds.MustDefined():
+		err = fmt.Errorf("Only one of input ids or input embeddings may be set: %w", util.ErrInvalidInput)
+		return
+	case inputIds.MustDefined():
+		embeddings = inputIds.ApplyT(m.Wte, train)
+	case inputEmbeds.MustDefined():
+		embeddings = inputEmbeds.MustShallowClone()
+	default:
+		err = fmt.Errorf("At least one of input ids or input embeddings must be set: %w", util.ErrInvalidInput)
+		return
+	}
+
+	if past != nil && len(past) != len(m.H) {
+		embeddings.MustDrop()
+		err = fmt.Errorf("Invalid past: got states for %v blocks, want %v: %w", len(past), len(m.H), util.ErrInvalidInput)
+		return
+	}
+
+	size := embeddings.MustSize()
+	bs, qLen := size[0], size[1]
+	device := embeddings.MustDevice()
+
+	var pastLen int64
+	if past != nil {
+		pastLen = past[0].PrevKey.MustSize()[2]
+	}
+	if pastLen+qLen > m.NPositions {
+		embeddings.MustDrop()
+		err = fmt.Errorf("Sequence length (%v) exceeds maximum positions (%v): %w", pastLen+qLen, m.NPositions, util.ErrInvalidInput)
+		return
+	}
+
+	var positions *ts.Tensor
+	if positionIds.MustDefined() {
+		positions = positionIds.ApplyT(m.Wpe, train)
+	} else {
+		ids := ts.MustArangeStart(ts.IntScalar(pastLen), ts.IntScalar(pastLen+qLen), gotch.Int64, device).MustUnsqueeze(0, true)
+		positions = ids.ApplyT(m.Wpe, train)
+		ids.MustDrop()
+	}
+	embeddings = embeddings.MustAdd(positions, true)
+	positions.MustDrop()
+
+	if tokenTypeIds.MustDefined() {
+		tokenTypes := tokenTypeIds.ApplyT(m.Wte, train)
+		embeddings = embeddings.MustAdd(tokenTypes, true)
+		tokenTypes.MustDrop()
+	}
+
+	hiddenState := embeddings.ApplyT(m.Drop, train)
+	embeddings.MustDrop()
+
+	extendedMask := causalMask(mask, bs, qLen, pastLen, device)
+
+	var (
+		allHiddenStates []ts.Tensor
+		allAttentions   []ts.Tensor
+	)
+
+	for i, block := range m.H {
+		if m.OutputHiddenStates {
+			allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
+		}
+
+		var layerPast *LayerState
+		if past != nil {
+			layerPast = &past[i]
+		}
+
+		stateTmp, attnWeights, present := block.ForwardT(hiddenState, extendedMask, layerPast, train)
+		hiddenState.MustDrop()
+		hiddenState = stateTmp
+		presents = append(presents, *present)
+
+		if m.OutputAttentions {
+			allAttentions = append(allAttentions, *attnWeights)
+		} else {
+			attnWeights.MustDrop()
+		}
+	}
+	extendedMask.MustDrop()
+
+	retVal = hiddenState.Apply(m.LnF)
+	hiddenState.MustDrop()
+
+	if m.OutputHiddenStates {
+		allHiddenStates = append(allHiddenStates, *retVal.MustShallowClone())
+	}
+
+	return retVal, presents, allHiddenStates, allAttentions, nil
+}
+
+// causalMask builds the additive self-attention mask of shape (batch size, 1, query length, key length)
+// preventing queries to attend to future and masked positions.
+func causalMask(mask *ts.Tensor, bs, qLen, pastLen int64, device gotch.Device) *ts.Tensor {
+	kLen := pastLen + qLen
+	causal := make([]float32, qLen*kLen)
+	for i := int64(0); i < qLen; i++ {
+		for j := int64(0); j <= i+pastLen; j++ {
+			causal[i*kLen+j] = 1
+		}
+	}
+	causalTs := ts.MustOfSlice(causal).MustView([]int64{1, 1, qLen, kLen}, true).MustTo(device, true)
+
+	var maskTs *ts.Tensor
+	if mask.MustDefined() {
+		maskTs = mask.MustTotype(gotch.Float, false)
+	} else {
+		maskTs = ts.MustOnes([]int64{bs, kLen}, gotch.Float, device)
+	}
+	extended := maskTs.MustUnsqueeze(1, true).MustUnsqueeze(2, true).MustMul(causalTs, true)
+	causalTs.MustDrop()
+
+	inverted := extended.MustOnesLike(false).MustSub(extended, true)
+	extended.MustDrop()
+
+	return inverted.MustMulScalar(ts.FloatScalar(-1e9), true)
+}
+
+// GPT2LMHeadModel:
+// ================
+
+// GPT2LMHeadModel is GPT-2 with a language model head tied to token embeddings.
+type GPT2LMHeadModel struct {
+	transformer *GPT2Model
+	config      *GPT2Config
+	vs          *nn.VarStore
+}
+
+// NewGPT2LMHeadModel creates GPT2LMHeadModel.
+func NewGPT2LMHeadModel(p *nn.Path, config *GPT2Config) (*GPT2LMHeadModel, error) {
+	transformer, err := NewGPT2Model(p, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GPT2LMHeadModel{
+		transformer: transformer,
+		config:      config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (lm *GPT2LMHeadModel) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	gpt2Config, ok := config.(*GPT2Config)
+	if !ok {
+		return nil, fmt.Errorf("GPT2LMHeadModel.Load() failed: invalid config type %T, want *gpt2.GPT2Config", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewGPT2LMHeadModel(vs.Root(), gpt2Config)
+	if err != nil {
+		return nil, err
+	}
+	*lm = *model
+	lm.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (lm *GPT2LMHeadModel) SavePretrained(dir string) error {
+	return util.SaveModel(dir, lm.config, lm.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (lm *GPT2LMHeadModel) VarStore() *nn.VarStore {
+	return lm.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (lm *GPT2LMHeadModel) SetVarStore(vs *nn.VarStore) {
+	lm.vs = vs
+}
+
+// Config returns model configuration.
+func (lm *GPT2LMHeadModel) Config() *GPT2Config {
+	return lm.config
+}
+
+// ForwardT forwards pass through the model. See `GPT2Model.ForwardT` for params.
+//
+// Returns:
+//   - `logits`: tensor of shape (batch size, sequence length, vocab size)
+//   - `presents`: keys and values to pass as `past` to next call
+//   - `hiddenStates`: input of every block and final output if `OutputHiddenStates` is set
+//   - `attentions`: attention weights of every block if `OutputAttentions` is set
+func (lm *GPT2LMHeadModel) ForwardT(inputIds *ts.Tensor, past []LayerState, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (retVal *ts.Tensor, presents []LayerState, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, presents, hiddenStates, attentions, err := lm.transformer.ForwardT(inputIds, past, mask, tokenTypeIds, positionIds, inputEmbeds, train)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	wteT := lm.transformer.Wte.Ws.MustT(false)
+	retVal = hiddenState.MustMatmul(wteT, true)
+	wteT.MustDrop()
+
+	return retVal, presents, hiddenStates, attentions, nil
+}
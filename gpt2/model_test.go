@@ -0,0 +1,99 @@
+package gpt2_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/generation"
+	"github.com/sugarme/transformer/gpt2"
+)
+
+// Generating token by token with `past` keys and values must give the same logits
+// as forwarding the whole sequence at once.
+func TestGPT2LMHeadModel_Past(t *testing.T) {
+	config := gpt2.NewConfig(map[string]interface{}{
+		"VocabSize":  int64(50),
+		"NPositions": int64(16),
+		"NEmbd":      int64(16),
+		"NLayer":     int64(2),
+		"NHead":      int64(4),
+		"ResidPdrop": float64(0),
+		"EmbdPdrop":  float64(0),
+		"AttnPdrop":  float64(0),
+	})
+	inputIds := ts.MustOfSlice([]int64{7, 9, 3, 4, 0, 11, 5, 8}).MustView([]int64{2, 4}, true)
+
+	model, err := gpt2.NewGPT2LMHeadModel(nn.NewVarStore(gotch.CPU).Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, _, _, _, err := model.ForwardT(inputIds, nil, ts.None, ts.None, ts.None, ts.None, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Prompt of 2 tokens then 1 token at a time.
+	var past []gpt2.LayerState
+	for _, step := range [][]int64{{0, 2}, {2, 1}, {3, 1}} {
+		input := inputIds.MustNarrow(1, step[0], step[1], false)
+		logits, presents, _, _, err := model.ForwardT(input, past, ts.None, ts.None, ts.None, ts.None, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		past = presents
+
+		wantStep := want.MustNarrow(1, step[0], step[1], false)
+		diff := wantStep.MustSub(logits, false).MustAbs(true).MustMax(true).Float64Values()[0]
+		if diff > 1e-4 {
+			t.Errorf("Positions %v: want logits with past equal to full forward, max difference: %v\n", step, diff)
+		}
+	}
+}
+
+func TestGPT2LMHeadModel(t *testing.T) {
+	modelName := "gpt2"
+	config := new(gpt2.GPT2Config)
+	if err := transformer.LoadConfig(config, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+	model := new(gpt2.GPT2LMHeadModel)
+	if _, err := transformer.LoadModel(model, modelName, config, nil, gotch.CPU); err != nil {
+		t.Fatal(err)
+	}
+	tk := gpt2.NewTokenizer()
+	if err := transformer.LoadTokenizer(tk, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	encoding, err := tk.EncodeSingle("I enjoy walking with my cute dog", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prompt []int64
+	for _, id := range encoding.Ids {
+		prompt = append(prompt, int64(id))
+	}
+
+	sequences, err := generation.Generate(model, [][]int64{prompt}, generation.DefaultConfig(), gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, id := range sequences[0][0].Ids {
+		ids = append(ids, int(id))
+	}
+
+	// Greedy continuation of the Python implementation
+	want := ", but I'm not sure if I'll ever be able to walk with my dog."
+	got := tk.Decode(ids, false)
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
@@ -0,0 +1,14 @@
+package gpt2
+
+import (
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// Registers GPT-2 configuration, tokenizer and model for auto-loading
+// (see `transformer.AutoModelFor`).
+func init() {
+	pretrained.RegisterConfig("gpt2", func() pretrained.Config { return new(GPT2Config) })
+	pretrained.RegisterTokenizer("gpt2", func() pretrained.Tokenizer { return NewTokenizer() })
+
+	pretrained.RegisterModel("gpt2", pretrained.CausalLM, "GPT2LMHeadModel", func() pretrained.Model { return new(GPT2LMHeadModel) })
+}
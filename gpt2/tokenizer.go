@@ -0,0 +1,59 @@
+package gpt2
+
+import (
+	"os"
+
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+// EndOfText is GPT-2 special token marking document boundaries. It is used as
+// beginning, end of sequence and padding token.
+const EndOfText = "<|endoftext|>"
+
+// Tokenizer holds data for GPT-2 tokenizer.
+type Tokenizer struct {
+	*tokenizer.Tokenizer
+}
+
+// NewTokenizer creates a new GPT-2 tokenizer.
+func NewTokenizer() *Tokenizer {
+	tk := tokenizer.NewTokenizer(nil)
+	return &Tokenizer{tk}
+}
+
+// Load loads GPT-2 tokenizer from pretrain vocab and merges files. Unlike RoBERTa,
+// text is neither normalized nor wrapped with special tokens, and no space is
+// added in front of it.
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) Load(modelNameOrPath string, params map[string]interface{}) error {
+	model, err := roberta.LoadByteLevelBPE(modelNameOrPath, util.ProgressFromParams(params))
+	if err != nil {
+		return err
+	}
+
+	t.WithModel(model)
+
+	byteLevel := roberta.NewByteLevel()
+	byteLevel.SetAddPrefixSpace(false)
+	t.WithPreTokenizer(byteLevel)
+	t.WithDecoder(byteLevel)
+
+	t.AddSpecialTokens([]tokenizer.AddedToken{tokenizer.NewAddedToken(EndOfText, true)})
+
+	return nil
+}
+
+// SavePretrained saves tokenizer vocab files `vocab.json` and `merges.txt` to directory `dir`.
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return t.GetModel().Save(dir)
+}
@@ -0,0 +1,56 @@
+package gpt2_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/gpt2"
+)
+
+func TestTokenizer_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpt2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vocab := `{"h": 0, "i": 1, "Ġ": 2, "hi": 3, "Ġhi": 4, "<|endoftext|>": 5}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "vocab.json"), []byte(vocab), 0644); err != nil {
+		t.Fatal(err)
+	}
+	merges := "#version: 0.2\nh i\nĠ hi\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "merges.txt"), []byte(merges), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tk := gpt2.NewTokenizer()
+	if err := tk.Load(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	en, err := tk.EncodeSingle("hi hi<|endoftext|>", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No space is added in front of text and the special token is kept.
+	want := []string{"hi", "Ġhi", gpt2.EndOfText}
+	if !reflect.DeepEqual(want, en.Tokens) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", en.Tokens)
+	}
+	wantIds := []int{3, 4, 5}
+	if !reflect.DeepEqual(wantIds, en.Ids) {
+		t.Errorf("Want: %v\n", wantIds)
+		t.Errorf("Got: %v\n", en.Ids)
+	}
+
+	got := tk.Decode([]int{3, 4, 5}, true)
+	if got != "hi hi" {
+		t.Errorf("Want: %q\n", "hi hi")
+		t.Errorf("Got: %q\n", got)
+	}
+}
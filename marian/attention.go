@@ -0,0 +1,129 @@
+package marian
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// LayerState holds keys and values of an attention layer cached from previous
+// decoding steps. Tensors have shape (batch size, heads, cached length, head dim).
+type LayerState struct {
+	PrevKey   *ts.Tensor
+	PrevValue *ts.Tensor
+}
+
+// reorder selects cached keys and values of `indices` along batch dimension, e.g.
+// to follow the beams kept at a beam search step.
+func (s *LayerState) reorder(indices *ts.Tensor) *LayerState {
+	return &LayerState{
+		PrevKey:   s.PrevKey.MustIndexSelect(0, indices, false),
+		PrevValue: s.PrevValue.MustIndexSelect(0, indices, false),
+	}
+}
+
+// MarianAttention is multi-head attention with scaled queries and biased projections.
+type MarianAttention struct {
+	QProj    *nn.Linear
+	KProj    *nn.Linear
+	VProj    *nn.Linear
+	OutProj  *nn.Linear
+	Dropout  *util.Dropout
+	NumHeads int64
+	HeadDim  int64
+	Scaling  float64
+	Cached   bool // whether keys and values are returned for caching (decoder layers)
+}
+
+// NewMarianAttention creates a new MarianAttention.
+func NewMarianAttention(p *nn.Path, embedDim, numHeads int64, dropout float64, cached bool) (*MarianAttention, error) {
+	if embedDim%numHeads != 0 {
+		return nil, fmt.Errorf("Model dimension (%v) is not a multiple of the number of attention heads (%v): %w", embedDim, numHeads, util.ErrShapeMismatch)
+	}
+	headDim := embedDim / numHeads
+
+	return &MarianAttention{
+		QProj:    nn.NewLinear(p.Sub("q_proj"), embedDim, embedDim, nn.DefaultLinearConfig()),
+		KProj:    nn.NewLinear(p.Sub("k_proj"), embedDim, embedDim, nn.DefaultLinearConfig()),
+		VProj:    nn.NewLinear(p.Sub("v_proj"), embedDim, embedDim, nn.DefaultLinearConfig()),
+		OutProj:  nn.NewLinear(p.Sub("out_proj"), embedDim, embedDim, nn.DefaultLinearConfig()),
+		Dropout:  util.NewDropout(dropout),
+		NumHeads: numHeads,
+		HeadDim:  headDim,
+		Scaling:  math.Pow(float64(headDim), -0.5),
+		Cached:   cached,
+	}, nil
+}
+
+// shape splits heads: (batch size, length, embed dim) -> (batch size, heads, length, head dim).
+func (a *MarianAttention) shape(x *ts.Tensor, bs int64) *ts.Tensor {
+	return x.MustView([]int64{bs, -1, a.NumHeads, a.HeadDim}, true).MustTranspose(1, 2, true)
+}
+
+// ForwardT forwards pass through the attention layer.
+//
+// Params:
+//   - `hiddenStates`: queries input of shape (batch size, query length, d model)
+//   - `keyValueStates`: encoder hidden states for cross-attention or `ts.None` for self-attention
+//   - `mask`: additive attention mask broadcastable to (batch size, heads, query length, key length) or `ts.None`
+//   - `layerState`: cached keys and values or nil. For self-attention, new keys and values are
+//     appended to cached ones. For cross-attention, cached ones are used as is.
+//
+// Returns:
+//   - attention output of shape (batch size, query length, d model)
+//   - attention weights of shape (batch size, heads, query length, key length)
+//   - keys and values to cache for next decoding step, nil if `Cached` is false
+func (a *MarianAttention) ForwardT(hiddenStates, keyValueStates, mask *ts.Tensor, layerState *LayerState, train bool) (retVal, retValOpt *ts.Tensor, retState *LayerState) {
+	size := hiddenStates.MustSize()
+	bs, qLen := size[0], size[1]
+
+	q := a.shape(hiddenStates.Apply(a.QProj).MustMulScalar(ts.FloatScalar(a.Scaling), true), bs)
+
+	var k, v *ts.Tensor
+	switch {
+	case keyValueStates.MustDefined() && layerState != nil:
+		k = layerState.PrevKey.MustShallowClone()
+		v = layerState.PrevValue.MustShallowClone()
+	case keyValueStates.MustDefined():
+		k = a.shape(keyValueStates.Apply(a.KProj), bs)
+		v = a.shape(keyValueStates.Apply(a.VProj), bs)
+	default:
+		k = a.shape(hiddenStates.Apply(a.KProj), bs)
+		v = a.shape(hiddenStates.Apply(a.VProj), bs)
+		if layerState != nil {
+			kTmp := ts.MustCat([]ts.Tensor{*layerState.PrevKey, *k}, 2)
+			vTmp := ts.MustCat([]ts.Tensor{*layerState.PrevValue, *v}, 2)
+			k.MustDrop()
+			v.MustDrop()
+			k, v = kTmp, vTmp
+		}
+	}
+
+	kT := k.MustTranspose(-1, -2, false)
+	scores := q.MustMatmul(kT, true)
+	kT.MustDrop()
+	if mask.MustDefined() {
+		scores = scores.MustAdd(mask, true)
+	}
+
+	weights := scores.MustSoftmax(-1, gotch.Float, true)
+	dropped := weights.ApplyT(a.Dropout, train)
+	context := dropped.MustMatmul(v, true)
+	merged := context.MustTranspose(1, 2, true).MustContiguous(true).MustView([]int64{bs, qLen, a.NumHeads * a.HeadDim}, true)
+	retVal = merged.Apply(a.OutProj)
+	merged.MustDrop()
+
+	if a.Cached {
+		retState = &LayerState{PrevKey: k, PrevValue: v}
+	} else {
+		k.MustDrop()
+		v.MustDrop()
+	}
+
+	return retVal, weights, retState
+}
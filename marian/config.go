@@ -0,0 +1,172 @@
+package marian
+
+// marian package implements MarianMT translation models: BART-style encoder-decoder
+// transformers with sinusoidal position embeddings trained by the Marian framework.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// MarianConfig defines the MarianMT model architecture (i.e., number of layers,
+// model size, special token ids...) and default generation parameters.
+type MarianConfig struct {
+	VocabSize             int64     `json:"vocab_size"`
+	DModel                int64     `json:"d_model"`
+	EncoderLayers         int64     `json:"encoder_layers"`
+	DecoderLayers         int64     `json:"decoder_layers"`
+	EncoderAttentionHeads int64     `json:"encoder_attention_heads"`
+	DecoderAttentionHeads int64     `json:"decoder_attention_heads"`
+	EncoderFfnDim         int64     `json:"encoder_ffn_dim"`
+	DecoderFfnDim         int64     `json:"decoder_ffn_dim"`
+	ActivationFunction    string    `json:"activation_function"`
+	Dropout               float64   `json:"dropout"`
+	AttentionDropout      float64   `json:"attention_dropout"`
+	ActivationDropout     float64   `json:"activation_dropout"`
+	MaxPositionEmbeddings int64     `json:"max_position_embeddings"`
+	ScaleEmbedding        bool      `json:"scale_embedding"`
+	PadTokenId            int64     `json:"pad_token_id"`
+	EosTokenId            int64     `json:"eos_token_id"`
+	DecoderStartTokenId   int64     `json:"decoder_start_token_id"`
+	NumBeams              int64     `json:"num_beams"`
+	MaxLength             int64     `json:"max_length"`
+	BadWordsIds           [][]int64 `json:"bad_words_ids"`
+	OutputAttentions      bool      `json:"output_attentions"`
+	OutputHiddenStates    bool      `json:"output_hidden_states"`
+}
+
+// NewConfig initiates MarianConfig with given input parameters or default values
+// of `Helsinki-NLP/opus-mt-en-de`.
+func NewConfig(customParams map[string]interface{}) *MarianConfig {
+	defaultValues := map[string]interface{}{
+		"VocabSize":             int64(58101),
+		"DModel":                int64(512),
+		"EncoderLayers":         int64(6),
+		"DecoderLayers":         int64(6),
+		"EncoderAttentionHeads": int64(8),
+		"DecoderAttentionHeads": int64(8),
+		"EncoderFfnDim":         int64(2048),
+		"DecoderFfnDim":         int64(2048),
+		"ActivationFunction":    "swish",
+		"Dropout":               float64(0.1),
+		"AttentionDropout":      float64(0),
+		"ActivationDropout":     float64(0),
+		"MaxPositionEmbeddings": int64(512),
+		"ScaleEmbedding":        true,
+		"PadTokenId":            int64(58100),
+		"EosTokenId":            int64(0),
+		"DecoderStartTokenId":   int64(58100),
+		"NumBeams":              int64(4),
+		"MaxLength":             int64(512),
+	}
+
+	params := defaultValues
+	for k, v := range customParams {
+		if _, ok := params[k]; ok {
+			params[k] = v
+		}
+	}
+
+	config := new(MarianConfig)
+	config.updateParams(params)
+
+	return config
+}
+
+// ConfigFromFile loads MarianConfig from a JSON file.
+func ConfigFromFile(filename string) (*MarianConfig, error) {
+	config := new(MarianConfig)
+	if err := config.fromFile(filename); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Load loads model configuration from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Config` interface.
+func (c *MarianConfig) Load(modelNameOrPath string, params map[string]interface{}) error {
+	err := c.fromFile(modelNameOrPath)
+	if err != nil {
+		return err
+	}
+
+	// Update custom parameters
+	c.updateParams(params)
+
+	return nil
+}
+
+// SavePretrained saves configuration to directory `dir` as `config.json` file.
+// This method implements `pretrained.Config` interface.
+func (c *MarianConfig) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buff, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, util.ConfigName), buff, 0644)
+}
+
+func (c *MarianConfig) fromFile(filename string) error {
+	filePath, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	buff, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	// Older Marian configs omit some fields (e.g. `activation_dropout`), hence
+	// start from default values.
+	*c = *NewConfig(nil)
+	err = json.Unmarshal(buff, c)
+	if err != nil {
+		return fmt.Errorf("Could not parse configuration to MarianConfig: %v: %w", err, util.ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// embedScale returns the factor token embeddings are multiplied by.
+func (c *MarianConfig) embedScale() float64 {
+	if c.ScaleEmbedding {
+		return math.Sqrt(float64(c.DModel))
+	}
+	return 1.0
+}
+
+// GetVocabSize returns vocabulary size.
+func (c *MarianConfig) GetVocabSize() int64 {
+	return c.VocabSize
+}
+
+func (c *MarianConfig) updateParams(params map[string]interface{}) {
+	for k, v := range params {
+		c.updateField(k, v)
+	}
+}
+
+func (c *MarianConfig) updateField(field string, value interface{}) {
+	// Check whether field name exists
+	if reflect.ValueOf(c).Elem().FieldByName(field).IsValid() {
+		// Check whether same type
+		if reflect.ValueOf(c).Elem().FieldByName(field).Kind() == reflect.TypeOf(value).Kind() {
+			reflect.ValueOf(c).Elem().FieldByName(field).Set(reflect.ValueOf(value))
+		}
+	}
+}
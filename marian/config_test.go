@@ -0,0 +1,64 @@
+package marian_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/marian"
+)
+
+func TestConfigFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marian")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Excerpt of `Helsinki-NLP/opus-mt-en-fr` config. It omits `activation_dropout`.
+	data := `{
+  "activation_function": "swish",
+  "architectures": ["MarianMTModel"],
+  "attention_dropout": 0.0,
+  "bad_words_ids": [[59513]],
+  "d_model": 512,
+  "decoder_attention_heads": 8,
+  "decoder_ffn_dim": 2048,
+  "decoder_layers": 6,
+  "decoder_start_token_id": 59513,
+  "dropout": 0.1,
+  "encoder_attention_heads": 8,
+  "encoder_ffn_dim": 2048,
+  "encoder_layers": 6,
+  "eos_token_id": 0,
+  "max_length": 512,
+  "max_position_embeddings": 512,
+  "model_type": "marian",
+  "num_beams": 4,
+  "pad_token_id": 59513,
+  "scale_embedding": true,
+  "vocab_size": 59514
+}`
+	configFile := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := marian.ConfigFromFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := marian.NewConfig(map[string]interface{}{
+		"VocabSize":           int64(59514),
+		"PadTokenId":          int64(59513),
+		"DecoderStartTokenId": int64(59513),
+	})
+	want.BadWordsIds = [][]int64{{59513}}
+	if !reflect.DeepEqual(want, config) {
+		t.Errorf("Want: %+v\n", want)
+		t.Errorf("Got: %+v\n", config)
+	}
+}
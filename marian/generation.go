@@ -0,0 +1,111 @@
+package marian
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/generation"
+	"github.com/sugarme/transformer/util"
+)
+
+// Generator decodes target tokens of encoded source texts with a MarianMT model.
+// It implements `generation.CausalLM` interface: prompts are decoder start tokens.
+type Generator struct {
+	model         *MarianMTModel
+	encoderOutput *ts.Tensor
+	mask          *ts.Tensor
+}
+
+// NewGenerator creates a Generator for source texts encoded by `model.Encode`.
+//
+// Params:
+//   - `encoderOutput`: encoder output of shape (batch size, source length, d model)
+//   - `mask`: optional source mask of shape (batch size, source length), required for padded inputs
+//
+// Input tensors are not modified and must be kept alive while generating.
+func NewGenerator(model *MarianMTModel, encoderOutput, mask *ts.Tensor) *Generator {
+	return &Generator{
+		model:         model,
+		encoderOutput: encoderOutput,
+		mask:          mask,
+	}
+}
+
+// generatorCache holds decoder cache together with encoder output and source mask
+// of every sequence being generated.
+type generatorCache struct {
+	layers        []DecoderLayerState
+	encoderOutput *ts.Tensor
+	mask          *ts.Tensor
+}
+
+// Reorder implements `generation.Cache` interface.
+func (c *generatorCache) Reorder(indices *ts.Tensor) generation.Cache {
+	retVal := &generatorCache{
+		layers:        make([]DecoderLayerState, len(c.layers)),
+		encoderOutput: c.encoderOutput.MustIndexSelect(0, indices, false),
+		mask:          ts.None,
+	}
+	for i := range c.layers {
+		retVal.layers[i] = c.layers[i].Reorder(indices)
+	}
+	if c.mask.MustDefined() {
+		retVal.mask = c.mask.MustIndexSelect(0, indices, false)
+	}
+
+	return retVal
+}
+
+// Drop implements `generation.Cache` interface.
+func (c *generatorCache) Drop() {
+	for i := range c.layers {
+		c.layers[i].Drop()
+	}
+	c.encoderOutput.MustDrop()
+	if c.mask.MustDefined() {
+		c.mask.MustDrop()
+	}
+}
+
+// Step forwards target tokens and returns next token logits.
+//
+// This method implements `generation.CausalLM` interface.
+func (g *Generator) Step(inputIds, mask *ts.Tensor, cache generation.Cache) (*ts.Tensor, generation.Cache, error) {
+	var (
+		layers        []DecoderLayerState
+		encoderOutput = g.encoderOutput
+		sourceMask    = g.mask
+	)
+	if cache != nil {
+		c, ok := cache.(*generatorCache)
+		if !ok {
+			return nil, nil, fmt.Errorf("Invalid cache type %T, want marian generator cache: %w", cache, util.ErrInvalidInput)
+		}
+		layers, encoderOutput, sourceMask = c.layers, c.encoderOutput, c.mask
+	}
+
+	logits, output, err := g.model.ForwardT(ts.None, sourceMask, encoderOutput, inputIds, mask, layers, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	output.DecoderOutput.MustDrop()
+	for _, xs := range [][]ts.Tensor{output.AllDecoderHiddenStates, output.AllDecoderAttentions} {
+		for i := range xs {
+			xs[i].MustDrop()
+		}
+	}
+
+	newCache := &generatorCache{
+		layers:        output.Cache,
+		encoderOutput: output.EncoderHiddenState,
+		mask:          ts.None,
+	}
+	if sourceMask.MustDefined() {
+		newCache.mask = sourceMask.MustShallowClone()
+	}
+
+	length := inputIds.MustSize()[1]
+
+	return logits.MustSelect(1, length-1, true), newCache, nil
+}
@@ -0,0 +1,250 @@
+package marian
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// SinusoidalPositionalEmbedding:
+// ==============================
+
+// SinusoidalPositionalEmbedding holds fixed (non-trainable) position embeddings.
+// For position `pos` and `k < dim/2`, column `k` is sin(pos/10000^(2k/dim)) and
+// column `dim/2 + k` is cos(pos/10000^(2k/dim)).
+//
+// The table is computed at creation and is not part of the var store.
+type SinusoidalPositionalEmbedding struct {
+	Weight       *ts.Tensor // (num positions, dim)
+	NumPositions int64
+}
+
+// NewSinusoidalPositionalEmbedding creates a SinusoidalPositionalEmbedding on the device of `p`.
+func NewSinusoidalPositionalEmbedding(p *nn.Path, numPositions, dim int64) *SinusoidalPositionalEmbedding {
+	sentinel := dim/2 + dim%2
+	table := make([]float32, numPositions*dim)
+	for pos := int64(0); pos < numPositions; pos++ {
+		for j := int64(0); j < dim; j++ {
+			angle := float64(pos) / math.Pow(10000, float64(2*(j/2))/float64(dim))
+			if j%2 == 0 {
+				table[pos*dim+j/2] = float32(math.Sin(angle))
+			} else {
+				table[pos*dim+sentinel+j/2] = float32(math.Cos(angle))
+			}
+		}
+	}
+
+	weight := ts.MustOfSlice(table).MustView([]int64{numPositions, dim}, true).MustTo(p.Device(), true)
+
+	return &SinusoidalPositionalEmbedding{weight, numPositions}
+}
+
+// Forward returns embeddings of shape (sequence length, dim) for positions
+// [pastLen, pastLen + seqLen).
+func (e *SinusoidalPositionalEmbedding) Forward(seqLen, pastLen int64) (*ts.Tensor, error) {
+	if pastLen+seqLen > e.NumPositions {
+		return nil, fmt.Errorf("Sequence length (%v) exceeds maximum position embeddings (%v): %w", pastLen+seqLen, e.NumPositions, util.ErrInvalidInput)
+	}
+
+	return e.Weight.MustNarrow(0, pastLen, seqLen, false), nil
+}
+
+// feedForward applies the position-wise feed-forward network of encoder and decoder
+// layers followed by residual connection and `finalLayerNorm`. It drops input `x`.
+func feedForward(x *ts.Tensor, fc1, fc2 *nn.Linear, activation util.ActivationFn, activationDropout, dropout *util.Dropout, finalLayerNorm *nn.LayerNorm, train bool) *ts.Tensor {
+	x1 := x.Apply(fc1)
+	x2 := activation.Fwd(x1)
+	x1.MustDrop()
+	x3 := x2.ApplyT(activationDropout, train)
+	x2.MustDrop()
+	x4 := x3.Apply(fc2)
+	x3.MustDrop()
+	x5 := x4.ApplyT(dropout, train)
+	x4.MustDrop()
+
+	summed := x5.MustAdd(x, true)
+	x.MustDrop()
+	retVal := summed.Apply(finalLayerNorm)
+	summed.MustDrop()
+
+	return retVal
+}
+
+// MarianEncoderLayer:
+// ===================
+
+// MarianEncoderLayer is an encoder layer: self-attention and feed-forward
+// sub-layers, each followed by residual connection and layer norm.
+type MarianEncoderLayer struct {
+	SelfAttention          *MarianAttention
+	SelfAttentionLayerNorm *nn.LayerNorm
+	Fc1                    *nn.Linear
+	Fc2                    *nn.Linear
+	FinalLayerNorm         *nn.LayerNorm
+	Activation             util.ActivationFn
+	Dropout                *util.Dropout
+	ActivationDropout      *util.Dropout
+}
+
+// NewMarianEncoderLayer creates a new MarianEncoderLayer.
+func NewMarianEncoderLayer(p *nn.Path, config *MarianConfig) (*MarianEncoderLayer, error) {
+	activation, ok := util.ActivationFnMap[config.ActivationFunction]
+	if !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.ActivationFunction)
+	}
+
+	selfAttention, err := NewMarianAttention(p.Sub("self_attn"), config.DModel, config.EncoderAttentionHeads, config.AttentionDropout, false)
+	if err != nil {
+		return nil, err
+	}
+
+	layerNormShape := []int64{config.DModel}
+
+	return &MarianEncoderLayer{
+		SelfAttention:          selfAttention,
+		SelfAttentionLayerNorm: nn.NewLayerNorm(p.Sub("self_attn_layer_norm"), layerNormShape, nn.DefaultLayerNormConfig()),
+		Fc1:                    nn.NewLinear(p.Sub("fc1"), config.DModel, config.EncoderFfnDim, nn.DefaultLinearConfig()),
+		Fc2:                    nn.NewLinear(p.Sub("fc2"), config.EncoderFfnDim, config.DModel, nn.DefaultLinearConfig()),
+		FinalLayerNorm:         nn.NewLayerNorm(p.Sub("final_layer_norm"), layerNormShape, nn.DefaultLayerNormConfig()),
+		Activation:             activation,
+		Dropout:                util.NewDropout(config.Dropout),
+		ActivationDropout:      util.NewDropout(config.ActivationDropout),
+	}, nil
+}
+
+// ForwardT forwards pass through the layer.
+//
+// Returns:
+//   - layer output of shape (batch size, sequence length, d model)
+//   - self-attention weights
+func (l *MarianEncoderLayer) ForwardT(hiddenStates, mask *ts.Tensor, train bool) (retVal, retValOpt *ts.Tensor) {
+	output, attentionWeights, _ := l.SelfAttention.ForwardT(hiddenStates, ts.None, mask, nil, train)
+	dropped := output.ApplyT(l.Dropout, train)
+	output.MustDrop()
+	summed := dropped.MustAdd(hiddenStates, true)
+	hiddenState := summed.Apply(l.SelfAttentionLayerNorm)
+	summed.MustDrop()
+
+	retVal = feedForward(hiddenState, l.Fc1, l.Fc2, l.Activation, l.ActivationDropout, l.Dropout, l.FinalLayerNorm, train)
+
+	return retVal, attentionWeights
+}
+
+// MarianDecoderLayer:
+// ===================
+
+// DecoderLayerState holds cached keys and values of a decoder layer: self-attention
+// keys and values grow with every decoding step, encoder-decoder attention ones
+// are computed once from the encoder output.
+type DecoderLayerState struct {
+	SelfAttention           *LayerState
+	EncoderDecoderAttention *LayerState
+}
+
+// Reorder selects cached keys and values of `indices` along batch dimension.
+// It is used by beam search to follow the beams kept at every step.
+func (s *DecoderLayerState) Reorder(indices *ts.Tensor) DecoderLayerState {
+	var newState DecoderLayerState
+	if s.SelfAttention != nil {
+		newState.SelfAttention = s.SelfAttention.reorder(indices)
+	}
+	if s.EncoderDecoderAttention != nil {
+		newState.EncoderDecoderAttention = s.EncoderDecoderAttention.reorder(indices)
+	}
+
+	return newState
+}
+
+// Drop frees cached tensors.
+func (s *DecoderLayerState) Drop() {
+	for _, state := range []*LayerState{s.SelfAttention, s.EncoderDecoderAttention} {
+		if state != nil {
+			state.PrevKey.MustDrop()
+			state.PrevValue.MustDrop()
+		}
+	}
+}
+
+// MarianDecoderLayer is a decoder layer: self-attention, encoder-decoder attention
+// and feed-forward sub-layers, each followed by residual connection and layer norm.
+type MarianDecoderLayer struct {
+	SelfAttention             *MarianAttention
+	SelfAttentionLayerNorm    *nn.LayerNorm
+	EncoderAttention          *MarianAttention
+	EncoderAttentionLayerNorm *nn.LayerNorm
+	Fc1                       *nn.Linear
+	Fc2                       *nn.Linear
+	FinalLayerNorm            *nn.LayerNorm
+	Activation                util.ActivationFn
+	Dropout                   *util.Dropout
+	ActivationDropout         *util.Dropout
+}
+
+// NewMarianDecoderLayer creates a new MarianDecoderLayer.
+func NewMarianDecoderLayer(p *nn.Path, config *MarianConfig) (*MarianDecoderLayer, error) {
+	activation, ok := util.ActivationFnMap[config.ActivationFunction]
+	if !ok {
+		return nil, fmt.Errorf("%w - %v", util.ErrUnsupportedActivation, config.ActivationFunction)
+	}
+
+	selfAttention, err := NewMarianAttention(p.Sub("self_attn"), config.DModel, config.DecoderAttentionHeads, config.AttentionDropout, true)
+	if err != nil {
+		return nil, err
+	}
+	encoderAttention, err := NewMarianAttention(p.Sub("encoder_attn"), config.DModel, config.DecoderAttentionHeads, config.AttentionDropout, true)
+	if err != nil {
+		return nil, err
+	}
+
+	layerNormShape := []int64{config.DModel}
+
+	return &MarianDecoderLayer{
+		SelfAttention:             selfAttention,
+		SelfAttentionLayerNorm:    nn.NewLayerNorm(p.Sub("self_attn_layer_norm"), layerNormShape, nn.DefaultLayerNormConfig()),
+		EncoderAttention:          encoderAttention,
+		EncoderAttentionLayerNorm: nn.NewLayerNorm(p.Sub("encoder_attn_layer_norm"), layerNormShape, nn.DefaultLayerNormConfig()),
+		Fc1:                       nn.NewLinear(p.Sub("fc1"), config.DModel, config.DecoderFfnDim, nn.DefaultLinearConfig()),
+		Fc2:                       nn.NewLinear(p.Sub("fc2"), config.DecoderFfnDim, config.DModel, nn.DefaultLinearConfig()),
+		FinalLayerNorm:            nn.NewLayerNorm(p.Sub("final_layer_norm"), layerNormShape, nn.DefaultLayerNormConfig()),
+		Activation:                activation,
+		Dropout:                   util.NewDropout(config.Dropout),
+		ActivationDropout:         util.NewDropout(config.ActivationDropout),
+	}, nil
+}
+
+// ForwardT forwards pass through the layer.
+//
+// Returns:
+//   - layer output of shape (batch size, target length, d model)
+//   - self-attention weights
+//   - keys and values to cache for next decoding step
+func (l *MarianDecoderLayer) ForwardT(hiddenStates, encoderHiddenStates, mask, encoderMask *ts.Tensor, state *DecoderLayerState, train bool) (retVal, retValOpt *ts.Tensor, retState *DecoderLayerState) {
+	var selfState, crossState *LayerState
+	if state != nil {
+		selfState, crossState = state.SelfAttention, state.EncoderDecoderAttention
+	}
+
+	output, attentionWeights, newSelfState := l.SelfAttention.ForwardT(hiddenStates, ts.None, mask, selfState, train)
+	dropped := output.ApplyT(l.Dropout, train)
+	output.MustDrop()
+	summed := dropped.MustAdd(hiddenStates, true)
+	hiddenState := summed.Apply(l.SelfAttentionLayerNorm)
+	summed.MustDrop()
+
+	crossOutput, crossWeights, newCrossState := l.EncoderAttention.ForwardT(hiddenState, encoderHiddenStates, encoderMask, crossState, train)
+	crossWeights.MustDrop()
+	dropped = crossOutput.ApplyT(l.Dropout, train)
+	crossOutput.MustDrop()
+	summed = dropped.MustAdd(hiddenState, true)
+	hiddenState.MustDrop()
+	hiddenState = summed.Apply(l.EncoderAttentionLayerNorm)
+	summed.MustDrop()
+
+	retVal = feedForward(hiddenState, l.Fc1, l.Fc2, l.Activation, l.ActivationDropout, l.Dropout, l.FinalLayerNorm, train)
+
+	return retVal, attentionWeights, &DecoderLayerState{newSelfState, newCrossState}
+}
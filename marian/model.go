@@ -0,0 +1,461 @@
+package marian
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/util"
+)
+
+// MarianEncoder:
+// ==============
+
+// MarianEncoder is a stack of `layers.N` encoder layers over scaled token
+// embeddings and sinusoidal position embeddings.
+type MarianEncoder struct {
+	EmbedTokens        *nn.Embedding
+	EmbedPositions     *SinusoidalPositionalEmbedding
+	Layers             []*MarianEncoderLayer
+	Dropout            *util.Dropout
+	EmbedScale         float64
+	OutputAttentions   bool
+	OutputHiddenStates bool
+}
+
+// NewMarianEncoder creates a new MarianEncoder using `embedTokens` as token embeddings.
+func NewMarianEncoder(p *nn.Path, config *MarianConfig, embedTokens *nn.Embedding) (*MarianEncoder, error) {
+	path := p.Sub("layers")
+	var layers []*MarianEncoderLayer
+	for i := 0; i < int(config.EncoderLayers); i++ {
+		layer, err := NewMarianEncoderLayer(path.Sub(fmt.Sprintf("%v", i)), config)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	return &MarianEncoder{
+		EmbedTokens:        embedTokens,
+		EmbedPositions:     NewSinusoidalPositionalEmbedding(p, config.MaxPositionEmbeddings, config.DModel),
+		Layers:             layers,
+		Dropout:            util.NewDropout(config.Dropout),
+		EmbedScale:         config.embedScale(),
+		OutputAttentions:   config.OutputAttentions,
+		OutputHiddenStates: config.OutputHiddenStates,
+	}, nil
+}
+
+// ForwardT forwards pass through the encoder.
+//
+// Params:
+//   - `inputIds`: input tensor of shape (batch size, source length)
+//   - `mask`: optional mask of shape (batch size, source length). Masked position have value 0, non-masked value 1.
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, source length, d model)
+//   - `hiddenStates`: input of every layer and final output if `OutputHiddenStates` is set
+//   - `attentions`: self-attention weights of every layer if `OutputAttentions` is set
+func (e *MarianEncoder) ForwardT(inputIds, mask *ts.Tensor, train bool) (retVal *ts.Tensor, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	hiddenState, err := embed(inputIds, e.EmbedTokens, e.EmbedPositions, e.EmbedScale, 0, e.Dropout, train)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	extendedMask := invertMask(mask)
+
+	var (
+		allHiddenStates []ts.Tensor
+		allAttentions   []ts.Tensor
+	)
+
+	for _, layer := range e.Layers {
+		if e.OutputHiddenStates {
+			allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
+		}
+
+		stateTmp, attnWeights := layer.ForwardT(hiddenState, extendedMask, train)
+		hiddenState.MustDrop()
+		hiddenState = stateTmp
+
+		if e.OutputAttentions {
+			allAttentions = append(allAttentions, *attnWeights)
+		} else {
+			attnWeights.MustDrop()
+		}
+	}
+
+	if extendedMask.MustDefined() {
+		extendedMask.MustDrop()
+	}
+
+	if e.OutputHiddenStates {
+		allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
+	}
+
+	return hiddenState, allHiddenStates, allAttentions, nil
+}
+
+// MarianDecoder:
+// ==============
+
+// MarianDecoder is a stack of `layers.N` decoder layers over scaled token
+// embeddings and sinusoidal position embeddings.
+type MarianDecoder struct {
+	EmbedTokens        *nn.Embedding
+	EmbedPositions     *SinusoidalPositionalEmbedding
+	Layers             []*MarianDecoderLayer
+	Dropout            *util.Dropout
+	EmbedScale         float64
+	OutputAttentions   bool
+	OutputHiddenStates bool
+}
+
+// NewMarianDecoder creates a new MarianDecoder using `embedTokens` as token embeddings.
+func NewMarianDecoder(p *nn.Path, config *MarianConfig, embedTokens *nn.Embedding) (*MarianDecoder, error) {
+	path := p.Sub("layers")
+	var layers []*MarianDecoderLayer
+	for i := 0; i < int(config.DecoderLayers); i++ {
+		layer, err := NewMarianDecoderLayer(path.Sub(fmt.Sprintf("%v", i)), config)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	return &MarianDecoder{
+		EmbedTokens:        embedTokens,
+		EmbedPositions:     NewSinusoidalPositionalEmbedding(p, config.MaxPositionEmbeddings, config.DModel),
+		Layers:             layers,
+		Dropout:            util.NewDropout(config.Dropout),
+		EmbedScale:         config.embedScale(),
+		OutputAttentions:   config.OutputAttentions,
+		OutputHiddenStates: config.OutputHiddenStates,
+	}, nil
+}
+
+// ForwardT forwards pass through the decoder.
+//
+// Params:
+//   - `inputIds`: input tensor of shape (batch size, target length)
+//   - `encoderHiddenStates`: encoder output of shape (batch size, source length, d model)
+//   - `mask`: optional target mask of shape (batch size, cached length + target length)
+//   - `encoderMask`: optional source mask of shape (batch size, source length)
+//   - `cache`: cached keys and values of previous decoding steps for every layer, or nil.
+//     Then `inputIds` only holds new tokens. Input cache is not modified and can be dropped by caller.
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, target length, d model)
+//   - `cache`: updated keys and values for every layer
+//   - `hiddenStates`: input of every layer and final output if `OutputHiddenStates` is set
+//   - `attentions`: self-attention weights of every layer if `OutputAttentions` is set
+func (d *MarianDecoder) ForwardT(inputIds, encoderHiddenStates, mask, encoderMask *ts.Tensor, cache []DecoderLayerState, train bool) (retVal *ts.Tensor, retCache []DecoderLayerState, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	if cache != nil && len(cache) != len(d.Layers) {
+		err = fmt.Errorf("Invalid cache: got states for %v layers, want %v: %w", len(cache), len(d.Layers), util.ErrInvalidInput)
+		return
+	}
+
+	var pastLen int64
+	if cache != nil && cache[0].SelfAttention != nil {
+		pastLen = cache[0].SelfAttention.PrevKey.MustSize()[2]
+	}
+
+	hiddenState, err := embed(inputIds, d.EmbedTokens, d.EmbedPositions, d.EmbedScale, pastLen, d.Dropout, train)
+	if err != nil {
+		return
+	}
+
+	qLen := inputIds.MustSize()[1]
+	extendedMask := causalMask(mask, qLen, pastLen, hiddenState.MustDevice())
+	encoderExtendedMask := invertMask(encoderMask)
+
+	var (
+		allHiddenStates []ts.Tensor
+		allAttentions   []ts.Tensor
+	)
+
+	for i, layer := range d.Layers {
+		if d.OutputHiddenStates {
+			allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
+		}
+
+		var state *DecoderLayerState
+		if cache != nil {
+			state = &cache[i]
+		}
+
+		stateTmp, attnWeights, newState := layer.ForwardT(hiddenState, encoderHiddenStates, extendedMask, encoderExtendedMask, state, train)
+		hiddenState.MustDrop()
+		hiddenState = stateTmp
+		retCache = append(retCache, *newState)
+
+		if d.OutputAttentions {
+			allAttentions = append(allAttentions, *attnWeights)
+		} else {
+			attnWeights.MustDrop()
+		}
+	}
+
+	extendedMask.MustDrop()
+	if encoderExtendedMask.MustDefined() {
+		encoderExtendedMask.MustDrop()
+	}
+
+	if d.OutputHiddenStates {
+		allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
+	}
+
+	return hiddenState, retCache, allHiddenStates, allAttentions, nil
+}
+
+// embed sums scaled token embeddings and position embeddings starting at `pastLen`.
+func embed(inputIds *ts.Tensor, embedTokens *nn.Embedding, embedPositions *SinusoidalPositionalEmbedding, scale float64, pastLen int64, dropout *util.Dropout, train bool) (*ts.Tensor, error) {
+	positions, err := embedPositions.Forward(inputIds.MustSize()[1], pastLen)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := inputIds.ApplyT(embedTokens, train).MustMulScalar(ts.FloatScalar(scale), true)
+	embeddings := tokens.MustAdd(positions, true)
+	positions.MustDrop()
+
+	retVal := embeddings.ApplyT(dropout, train)
+	embeddings.MustDrop()
+
+	return retVal, nil
+}
+
+// invertMask converts a mask of shape (batch size, length) with value 1 for positions
+// to attend to an additive mask of shape (batch size, 1, 1, length).
+// It returns `ts.None` if mask is not set.
+func invertMask(mask *ts.Tensor) *ts.Tensor {
+	if !mask.MustDefined() {
+		return ts.None
+	}
+
+	extended := mask.MustTotype(gotch.Float, false).MustUnsqueeze(1, true).MustUnsqueeze(2, true)
+	inverted := extended.MustOnesLike(false).MustSub(extended, true)
+	extended.MustDrop()
+
+	return inverted.MustMulScalar(ts.FloatScalar(-1e9), true)
+}
+
+// causalMask builds the additive decoder self-attention mask of shape (batch size or 1, 1, query length, key length)
+// preventing queries to attend to future and masked positions.
+func causalMask(mask *ts.Tensor, qLen, pastLen int64, device gotch.Device) *ts.Tensor {
+	kLen := pastLen + qLen
+	causal := make([]float32, qLen*kLen)
+	for i := int64(0); i < qLen; i++ {
+		for j := i + pastLen + 1; j < kLen; j++ {
+			causal[i*kLen+j] = -1e9
+		}
+	}
+	causalTs := ts.MustOfSlice(causal).MustView([]int64{1, 1, qLen, kLen}, true).MustTo(device, true)
+
+	if !mask.MustDefined() {
+		return causalTs
+	}
+
+	padding := invertMask(mask)
+	retVal := causalTs.MustAdd(padding, true)
+	padding.MustDrop()
+
+	return retVal
+}
+
+// MarianModel:
+// ============
+
+// MarianModelOutput holds outputs of Marian encoder-decoder forward pass.
+type MarianModelOutput struct {
+	DecoderOutput          *ts.Tensor          // (batch size, target length, d model)
+	EncoderHiddenState     *ts.Tensor          // (batch size, source length, d model)
+	Cache                  []DecoderLayerState // keys and values to pass to next decoding step
+	AllDecoderHiddenStates []ts.Tensor
+	AllDecoderAttentions   []ts.Tensor
+	AllEncoderHiddenStates []ts.Tensor
+	AllEncoderAttentions   []ts.Tensor
+}
+
+// MarianModel defines base architecture for MarianMT models: an encoder and a decoder
+// sharing token embeddings (`shared`).
+type MarianModel struct {
+	Shared  *nn.Embedding
+	Encoder *MarianEncoder
+	Decoder *MarianDecoder
+}
+
+// NewMarianModel builds a new `MarianModel`.
+//
+// Params:
+//   - `p`: Variable store path for the root of the Marian model
+//   - `config`: MarianConfig configuration for model architecture
+func NewMarianModel(p *nn.Path, config *MarianConfig) (*MarianModel, error) {
+	shared := nn.NewEmbedding(p.Sub("shared"), config.VocabSize, config.DModel, nn.DefaultEmbeddingConfig())
+
+	encoder, err := NewMarianEncoder(p.Sub("encoder"), config, shared)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := NewMarianDecoder(p.Sub("decoder"), config, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MarianModel{shared, encoder, decoder}, nil
+}
+
+// Encode forwards pass through the encoder. Its output can be passed to
+// `ForwardT` as `encoderOutput` to avoid re-encoding source at every decoding step.
+func (m *MarianModel) Encode(inputIds, mask *ts.Tensor, train bool) (*ts.Tensor, error) {
+	hiddenState, _, _, err := m.Encoder.ForwardT(inputIds, mask, train)
+	return hiddenState, err
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional source tokens of shape (batch size, source length).
+//     Not used if `encoderOutput` is provided.
+//   - `mask`: optional source mask of shape (batch size, source length). If None set to 1.
+//     It also masks encoder output in the decoder, hence it is required with `encoderOutput` for padded inputs.
+//   - `encoderOutput`: optional pre-computed encoder output of shape (batch size, source length, d model)
+//   - `decoderInputIds`: target tokens of shape (batch size, target length)
+//   - `decoderMask`: optional target mask of shape (batch size, cached length + target length)
+//   - `cache`: optional keys and values cached at previous decoding step (`MarianModelOutput.Cache`).
+//     When set, `decoderInputIds` only holds new tokens.
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+func (m *MarianModel) ForwardT(inputIds, mask, encoderOutput, decoderInputIds, decoderMask *ts.Tensor, cache []DecoderLayerState, train bool) (*MarianModelOutput, error) {
+	out := new(MarianModelOutput)
+
+	if encoderOutput.MustDefined() {
+		out.EncoderHiddenState = encoderOutput.MustShallowClone()
+	} else {
+		hiddenState, hiddenStates, attentions, err := m.Encoder.ForwardT(inputIds, mask, train)
+		if err != nil {
+			return nil, err
+		}
+		out.EncoderHiddenState = hiddenState
+		out.AllEncoderHiddenStates = hiddenStates
+		out.AllEncoderAttentions = attentions
+	}
+
+	decoderOutput, newCache, hiddenStates, attentions, err := m.Decoder.ForwardT(decoderInputIds, out.EncoderHiddenState, decoderMask, mask, cache, train)
+	if err != nil {
+		return nil, err
+	}
+	out.DecoderOutput = decoderOutput
+	out.Cache = newCache
+	out.AllDecoderHiddenStates = hiddenStates
+	out.AllDecoderAttentions = attentions
+
+	return out, nil
+}
+
+// MarianMTModel:
+// ==============
+
+// MarianMTModel is Marian with a language model head for translation.
+//
+// It is made of the following blocks:
+//   - `model`: Base MarianModel
+//   - `final_logits_bias`: bias of shape (1, vocab size) added to the logits. Logits are
+//     computed with the shared embeddings.
+type MarianMTModel struct {
+	base            *MarianModel
+	finalLogitsBias *ts.Tensor
+	config          *MarianConfig
+	vs              *nn.VarStore
+}
+
+// NewMarianMTModel creates MarianMTModel.
+func NewMarianMTModel(p *nn.Path, config *MarianConfig) (*MarianMTModel, error) {
+	base, err := NewMarianModel(p.Sub("model"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	finalLogitsBias, err := p.NewVar("final_logits_bias", []int64{1, config.VocabSize}, nn.NewConstInit(0.0))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MarianMTModel{
+		base:            base,
+		finalLogitsBias: finalLogitsBias,
+		config:          config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (m *MarianMTModel) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	marianConfig, ok := config.(*MarianConfig)
+	if !ok {
+		return nil, fmt.Errorf("MarianMTModel.Load() failed: invalid config type %T, want *marian.MarianConfig", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewMarianMTModel(vs.Root(), marianConfig)
+	if err != nil {
+		return nil, err
+	}
+	*m = *model
+	m.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "final_logits_bias"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (m *MarianMTModel) SavePretrained(dir string) error {
+	return util.SaveModel(dir, m.config, m.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (m *MarianMTModel) VarStore() *nn.VarStore {
+	return m.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (m *MarianMTModel) SetVarStore(vs *nn.VarStore) {
+	m.vs = vs
+}
+
+// Config returns model configuration.
+func (m *MarianMTModel) Config() *MarianConfig {
+	return m.config
+}
+
+// Encode forwards pass through the encoder. See `MarianModel.Encode`.
+func (m *MarianMTModel) Encode(inputIds, mask *ts.Tensor, train bool) (*ts.Tensor, error) {
+	return m.base.Encode(inputIds, mask, train)
+}
+
+// ForwardT forwards pass through the model. See `MarianModel.ForwardT` for params.
+//
+// Returns:
+//   - `logits`: tensor of shape (batch size, target length, vocab size)
+//   - `output`: base model outputs, including encoder output and cache for next decoding step
+func (m *MarianMTModel) ForwardT(inputIds, mask, encoderOutput, decoderInputIds, decoderMask *ts.Tensor, cache []DecoderLayerState, train bool) (retVal *ts.Tensor, output *MarianModelOutput, err error) {
+	output, err = m.base.ForwardT(inputIds, mask, encoderOutput, decoderInputIds, decoderMask, cache, train)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wsT := m.base.Shared.Ws.MustT(false)
+	logits := output.DecoderOutput.MustMatmul(wsT, false)
+	wsT.MustDrop()
+	retVal = logits.MustAdd(m.finalLogitsBias, true)
+
+	return retVal, output, nil
+}
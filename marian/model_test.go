@@ -0,0 +1,37 @@
+package marian_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer/marian"
+)
+
+func TestSinusoidalPositionalEmbedding(t *testing.T) {
+	emb := marian.NewSinusoidalPositionalEmbedding(nn.NewVarStore(gotch.CPU).Root(), 8, 4)
+	positions, err := emb.Forward(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Columns: sin(pos), sin(pos/100), cos(pos), cos(pos/100) for positions 1 and 2.
+	want := []float64{
+		math.Sin(1), math.Sin(0.01), math.Cos(1), math.Cos(0.01),
+		math.Sin(2), math.Sin(0.02), math.Cos(2), math.Cos(0.02),
+	}
+	got := positions.Float64Values()
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-6 {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			break
+		}
+	}
+
+	if _, err := emb.Forward(8, 1); err == nil {
+		t.Errorf("Want error for positions beyond maximum position embeddings\n")
+	}
+}
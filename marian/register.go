@@ -0,0 +1,14 @@
+package marian
+
+import (
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// Registers MarianMT configuration, tokenizer and model for auto-loading
+// (see `transformer.AutoModelFor`).
+func init() {
+	pretrained.RegisterConfig("marian", func() pretrained.Config { return new(MarianConfig) })
+	pretrained.RegisterTokenizer("marian", func() pretrained.Tokenizer { return NewTokenizer() })
+
+	pretrained.RegisterModel("marian", pretrained.Seq2SeqLM, "MarianMTModel", func() pretrained.Model { return new(MarianMTModel) })
+}
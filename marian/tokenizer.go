@@ -0,0 +1,265 @@
+package marian
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/processor"
+
+	"github.com/sugarme/transformer/sentencepiece"
+	"github.com/sugarme/transformer/util"
+)
+
+// Marian tokenizer files.
+const (
+	SourceSpmFile = "source.spm"
+	TargetSpmFile = "target.spm"
+	VocabFile     = "vocab.json"
+)
+
+// Model:
+// ======
+
+// Model segments text with a SentencePiece model and maps pieces to ids of the
+// Marian vocabulary (`vocab.json`) shared by source and target languages.
+// Pieces missing from the vocabulary are mapped to `<unk>`.
+//
+// It implements `tokenizer.Model` interface.
+type Model struct {
+	spm   *sentencepiece.Unigram
+	vocab map[string]int
+	ids   map[int]string
+	unkId int
+}
+
+// NewModel creates a Model from a SentencePiece model and a vocabulary.
+func NewModel(spm *sentencepiece.Unigram, vocab map[string]int) (*Model, error) {
+	unkId, ok := vocab["<unk>"]
+	if !ok {
+		return nil, fmt.Errorf("NewModel() failed: vocabulary has no <unk> token")
+	}
+
+	ids := make(map[int]string, len(vocab))
+	for tok, id := range vocab {
+		ids[id] = tok
+	}
+
+	return &Model{spm, vocab, ids, unkId}, nil
+}
+
+// NewModelFromFiles loads a Model from a SentencePiece model file and a JSON vocabulary file.
+func NewModelFromFiles(spmFile, vocabFile string) (*Model, error) {
+	spm, err := sentencepiece.NewUnigramFromFile(spmFile)
+	if err != nil {
+		return nil, err
+	}
+
+	buff, err := ioutil.ReadFile(vocabFile)
+	if err != nil {
+		return nil, err
+	}
+	var vocab map[string]int
+	if err := json.Unmarshal(buff, &vocab); err != nil {
+		return nil, fmt.Errorf("Could not parse vocabulary file %q: %w", vocabFile, err)
+	}
+
+	return NewModel(spm, vocab)
+}
+
+// Spm returns the underlying SentencePiece model.
+func (m *Model) Spm() *sentencepiece.Unigram {
+	return m.spm
+}
+
+// Tokenize implements `tokenizer.Model` interface.
+func (m *Model) Tokenize(sequence string) ([]tokenizer.Token, error) {
+	tokens, err := m.spm.Tokenize(sequence)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tokens {
+		id, ok := m.vocab[tokens[i].Value]
+		if !ok {
+			id = m.unkId
+		}
+		tokens[i].Id = id
+	}
+
+	return tokens, nil
+}
+
+// TokenToId implements `tokenizer.Model` interface.
+func (m *Model) TokenToId(token string) (int, bool) {
+	id, ok := m.vocab[token]
+	return id, ok
+}
+
+// IdToToken implements `tokenizer.Model` interface.
+func (m *Model) IdToToken(id int) (string, bool) {
+	tok, ok := m.ids[id]
+	return tok, ok
+}
+
+// GetVocab implements `tokenizer.Model` interface.
+func (m *Model) GetVocab() map[string]int {
+	vocab := make(map[string]int, len(m.vocab))
+	for tok, id := range m.vocab {
+		vocab[tok] = id
+	}
+	return vocab
+}
+
+// GetVocabSize implements `tokenizer.Model` interface.
+func (m *Model) GetVocabSize() int {
+	return len(m.vocab)
+}
+
+// Save implements `tokenizer.Model` interface. It writes the vocabulary to `dir`
+// as `vocab.json` or, if `prefixOpt` is given, as `<prefix>-vocab.json`.
+// The SentencePiece model is saved by `Tokenizer.SavePretrained`.
+func (m *Model) Save(dir string, prefixOpt ...string) error {
+	name := VocabFile
+	if len(prefixOpt) > 0 {
+		name = fmt.Sprintf("%v-%v", prefixOpt[0], VocabFile)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buff, err := json.Marshal(m.vocab)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, name), buff, 0644)
+}
+
+// Tokenizer:
+// ==========
+
+// Tokenizer holds data for Marian tokenizer. The embedded tokenizer encodes
+// source texts with `source.spm`, `Target` encodes and decodes target texts
+// with `target.spm`. Both share ids of `vocab.json`.
+type Tokenizer struct {
+	*tokenizer.Tokenizer
+	Target *tokenizer.Tokenizer
+}
+
+// NewTokenizer creates a new Marian tokenizer.
+func NewTokenizer() *Tokenizer {
+	return &Tokenizer{
+		Tokenizer: tokenizer.NewTokenizer(nil),
+		Target:    tokenizer.NewTokenizer(nil),
+	}
+}
+
+// Load loads Marian tokenizer from pretrained files `source.spm`, `target.spm`
+// and `vocab.json`. An end of sequence token `</s>` is appended to every encoded
+// sequence. Target language codes of multilingual models (e.g., `>>fra<<`) are
+// added as special tokens to the source tokenizer and must prefix source texts.
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) Load(modelNameOrPath string, params map[string]interface{}) error {
+	vocabFile, err := util.CachedPathWithProgress(modelNameOrPath, VocabFile, util.ProgressFromParams(params))
+	if err != nil {
+		return err
+	}
+
+	for spmName, tk := range t.spmFiles() {
+		spmFile, err := util.CachedPathWithProgress(modelNameOrPath, spmName, util.ProgressFromParams(params))
+		if err != nil {
+			return err
+		}
+
+		model, err := NewModelFromFiles(spmFile, vocabFile)
+		if err != nil {
+			return err
+		}
+
+		if err := setup(tk, model); err != nil {
+			return err
+		}
+	}
+
+	var langCodes []tokenizer.AddedToken
+	for _, code := range t.LanguageCodes() {
+		langCodes = append(langCodes, tokenizer.NewAddedToken(code, true))
+	}
+	t.AddSpecialTokens(langCodes)
+
+	return nil
+}
+
+// spmFiles maps SentencePiece model file names to source and target tokenizers.
+func (t *Tokenizer) spmFiles() map[string]*tokenizer.Tokenizer {
+	return map[string]*tokenizer.Tokenizer{
+		SourceSpmFile: t.Tokenizer,
+		TargetSpmFile: t.Target,
+	}
+}
+
+// setup configures `tk` to use `model` with Marian normalization, special tokens
+// and post-processing.
+func setup(tk *tokenizer.Tokenizer, model *Model) error {
+	tk.WithModel(model)
+	tk.WithNormalizer(sentencepiece.NewNormalizer(false, false, false))
+	tk.WithPreTokenizer(sentencepiece.NewMetaspace())
+	tk.WithDecoder(sentencepiece.NewDecoder())
+
+	var specialTokens []tokenizer.AddedToken
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("</s>", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<unk>", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<pad>", true))
+	tk.AddSpecialTokens(specialTokens)
+
+	eosId, ok := tk.TokenToId("</s>")
+	if !ok {
+		return fmt.Errorf("Cannot find ID for </s> token: %w", util.ErrMissingToken)
+	}
+	tk.WithPostProcessor(sentencepiece.NewEosProcessing(processor.PostToken{Id: eosId, Value: "</s>"}))
+
+	return nil
+}
+
+// LanguageCodes returns sorted target language codes (e.g., `>>fra<<`) of the vocabulary.
+// It is empty for bilingual models.
+func (t *Tokenizer) LanguageCodes() []string {
+	var codes []string
+	for tok := range t.GetModel().GetVocab() {
+		if strings.HasPrefix(tok, ">>") && strings.HasSuffix(tok, "<<") {
+			codes = append(codes, tok)
+		}
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// SavePretrained saves `source.spm`, `target.spm` and `vocab.json` to directory `dir`.
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for spmName, tk := range t.spmFiles() {
+		model, ok := tk.GetModel().(*Model)
+		if !ok {
+			return fmt.Errorf("Tokenizer.SavePretrained() failed: invalid model type %T, want *marian.Model", tk.GetModel())
+		}
+		if err := model.Spm().Proto().WriteFile(filepath.Join(dir, spmName)); err != nil {
+			return err
+		}
+	}
+
+	return t.GetModel().Save(dir)
+}
@@ -0,0 +1,110 @@
+package marian_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/marian"
+	"github.com/sugarme/transformer/sentencepiece"
+)
+
+func TestTokenizer_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marian")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	proto := sentencepiece.NewModelProto([]sentencepiece.Piece{
+		{Piece: "<unk>", Type: sentencepiece.UnknownPiece},
+		{Piece: "<s>", Type: sentencepiece.ControlPiece},
+		{Piece: "</s>", Type: sentencepiece.ControlPiece},
+		{Piece: "▁Hello", Score: -1, Type: sentencepiece.NormalPiece},
+		{Piece: "▁World", Score: -1, Type: sentencepiece.NormalPiece},
+		{Piece: "▁Bye", Score: -1, Type: sentencepiece.NormalPiece},
+	})
+	proto.UnkId, proto.BosId, proto.EosId, proto.PadId = 0, 1, 2, -1
+	for _, file := range []string{marian.SourceSpmFile, marian.TargetSpmFile} {
+		if err := proto.WriteFile(filepath.Join(dir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Ids come from the vocabulary, not the SentencePiece model. "▁Bye" is out of vocabulary.
+	vocab := `{"</s>": 0, "<unk>": 1, ">>fra<<": 2, "▁Hello": 3, "▁World": 4, "<pad>": 5}`
+	if err := ioutil.WriteFile(filepath.Join(dir, marian.VocabFile), []byte(vocab), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tk := marian.NewTokenizer()
+	if err := tk.Load(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	en, err := tk.EncodeSingle(">>fra<< Hello World Bye", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{">>fra<<", "▁Hello", "▁World", "▁Bye", "</s>"}
+	if !reflect.DeepEqual(want, en.Tokens) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", en.Tokens)
+	}
+	wantIds := []int{2, 3, 4, 1, 0}
+	if !reflect.DeepEqual(wantIds, en.Ids) {
+		t.Errorf("Want: %v\n", wantIds)
+		t.Errorf("Got: %v\n", en.Ids)
+	}
+
+	got := tk.Target.Decode([]int{3, 4, 0, 5}, true)
+	if got != "Hello World" {
+		t.Errorf("Want: %q\n", "Hello World")
+		t.Errorf("Got: %q\n", got)
+	}
+}
+
+func TestTokenizer_SavePretrained(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marian")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	proto := sentencepiece.NewModelProto([]sentencepiece.Piece{
+		{Piece: "<unk>", Type: sentencepiece.UnknownPiece},
+		{Piece: "</s>", Type: sentencepiece.ControlPiece},
+		{Piece: "▁Hallo", Score: -1, Type: sentencepiece.NormalPiece},
+	})
+	proto.UnkId, proto.EosId, proto.BosId, proto.PadId = 0, 1, -1, -1
+	for _, file := range []string{marian.SourceSpmFile, marian.TargetSpmFile} {
+		if err := proto.WriteFile(filepath.Join(dir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vocab := `{"</s>": 0, "<unk>": 1, "▁Hallo": 2, "<pad>": 3}`
+	if err := ioutil.WriteFile(filepath.Join(dir, marian.VocabFile), []byte(vocab), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tk := marian.NewTokenizer()
+	if err := tk.Load(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	saveDir := filepath.Join(dir, "saved")
+	if err := tk.SavePretrained(saveDir); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := marian.NewTokenizer()
+	if err := loaded.Load(saveDir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tk.GetVocab(true), loaded.GetVocab(true)) {
+		t.Errorf("Want: %v\n", tk.GetVocab(true))
+		t.Errorf("Got: %v\n", loaded.GetVocab(true))
+	}
+}
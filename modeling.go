@@ -17,6 +17,15 @@ import (
 // environment if existing, otherwise it will be cached in `$HOME/.cache/transformers/` directory.
 // If `modleNameOrPath` is valid URL, file will be downloaded and cached.
 // Finally, model weights will be loaded to `varstore`.
-func LoadModel(model pretrained.Model, modelNameOrPath string, config pretrained.Config, customParams map[string]interface{}, device gotch.Device) error {
+//
+// It returns a report of missing, unexpected and mismatched weights. How strictly weights
+// are matched is set with `util.LoadOptions` at `util.LoadOptionsParam` key of `customParams`.
+func LoadModel(model pretrained.Model, modelNameOrPath string, config pretrained.Config, customParams map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
 	return model.Load(modelNameOrPath, config, customParams, device)
 }
+
+// SaveModel saves model configuration and weights to local directory `dir`.
+// Saved model can be loaded back with `LoadModel` by passing `dir` as `modelNameOrPath`.
+func SaveModel(model pretrained.Model, dir string) error {
+	return model.SavePretrained(dir)
+}
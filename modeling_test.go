@@ -1,11 +1,25 @@
 package transformer_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
 	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/albert"
 	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/distilbert"
+	"github.com/sugarme/transformer/electra"
+	"github.com/sugarme/transformer/gpt2"
+	"github.com/sugarme/transformer/marian"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/t5"
+	"github.com/sugarme/transformer/util"
 )
 
 // With model name
@@ -26,6 +40,143 @@ func TestModelFromPretrained_ModelName(t *testing.T) {
 	}
 }
 
+// Models with random weights saved by `SaveModel` must load back unchanged.
+func TestSaveModel_RoundTrip(t *testing.T) {
+	labels := map[int64]string{0: "O", 1: "B-PER", 2: "I-PER"}
+
+	distilbertConfig := distilbert.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"Dim":                   int64(16),
+		"NLayers":               int64(2),
+		"NHeads":                int64(2),
+		"HiddenDim":             int64(32),
+		"MaxPositionEmbeddings": int64(32),
+	})
+	distilbertConfig.Id2Label = labels
+
+	albertConfig := albert.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"EmbeddingSize":         int64(8),
+		"HiddenSize":            int64(16),
+		"NumHiddenLayers":       int64(4),
+		"NumAttentionHeads":     int64(2),
+		"IntermediateSize":      int64(32),
+		"MaxPositionEmbeddings": int64(32),
+	})
+
+	electraConfig := electra.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"EmbeddingSize":         int64(8),
+		"HiddenSize":            int64(16),
+		"NumHiddenLayers":       int64(2),
+		"NumAttentionHeads":     int64(2),
+		"IntermediateSize":      int64(32),
+		"MaxPositionEmbeddings": int64(32),
+	})
+	electraConfig.Id2Label = labels
+
+	t5Config := t5.NewConfig(map[string]interface{}{
+		"VocabSize": int64(50),
+		"DModel":    int64(16),
+		"DKv":       int64(4),
+		"DFf":       int64(32),
+		"NumLayers": int64(2),
+		"NumHeads":  int64(4),
+	})
+
+	marianConfig := marian.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"DModel":                int64(16),
+		"EncoderLayers":         int64(2),
+		"DecoderLayers":         int64(2),
+		"EncoderAttentionHeads": int64(4),
+		"DecoderAttentionHeads": int64(4),
+		"EncoderFfnDim":         int64(32),
+		"DecoderFfnDim":         int64(32),
+		"MaxPositionEmbeddings": int64(32),
+		"PadTokenId":            int64(49),
+		"DecoderStartTokenId":   int64(49),
+	})
+
+	gpt2Config := gpt2.NewConfig(map[string]interface{}{
+		"VocabSize":  int64(50),
+		"NPositions": int64(16),
+		"NEmbd":      int64(16),
+		"NLayer":     int64(2),
+		"NHead":      int64(4),
+	})
+
+	tests := []struct {
+		name   string
+		config pretrained.Config
+		model  func() pretrained.Model
+	}{
+		{"distilbert", distilbertConfig, func() pretrained.Model { return new(distilbert.DistilBertForSequenceClassification) }},
+		{"albert", albertConfig, func() pretrained.Model { return new(albert.AlbertForMaskedLM) }},
+		{"electra", electraConfig, func() pretrained.Model { return new(electra.ElectraForSequenceClassification) }},
+		{"t5", t5Config, func() pretrained.Model { return new(t5.T5ForConditionalGeneration) }},
+		{"marian", marianConfig, func() pretrained.Model { return new(marian.MarianMTModel) }},
+		{"gpt2", gpt2Config, func() pretrained.Model { return new(gpt2.GPT2LMHeadModel) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			// Loading from an empty weight file leaves weights at their random initial values.
+			initDir := filepath.Join(dir, "init")
+			if err := tt.config.SavePretrained(initDir); err != nil {
+				t.Fatal(err)
+			}
+			if err := util.WriteSafetensors(filepath.Join(initDir, util.SafetensorsName), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			params := map[string]interface{}{util.LoadOptionsParam: util.LoadOptions{Mode: util.LoadLenient}}
+			model := tt.model()
+			if _, err := transformer.LoadModel(model, initDir, tt.config, params, gotch.CPU); err != nil {
+				t.Fatal(err)
+			}
+
+			savedDir := filepath.Join(dir, "saved")
+			if err := transformer.SaveModel(model, savedDir); err != nil {
+				t.Fatal(err)
+			}
+
+			loadedConfig := reflect.New(reflect.TypeOf(tt.config).Elem()).Interface().(pretrained.Config)
+			if err := transformer.LoadConfig(loadedConfig, savedDir, nil); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.config, loadedConfig) {
+				t.Errorf("Want: %+v\n", tt.config)
+				t.Errorf("Got: %+v\n", loadedConfig)
+			}
+
+			loaded := tt.model()
+			report, err := transformer.LoadModel(loaded, savedDir, loadedConfig, nil, gotch.CPU)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.Complete() || len(report.UnexpectedKeys) > 0 {
+				t.Errorf("Want: %v\n", "all weights loaded")
+				t.Errorf("Got: %v\n", report)
+			}
+
+			want := model.(interface{ VarStore() *nn.VarStore }).VarStore().Variables()
+			got := loaded.(interface{ VarStore() *nn.VarStore }).VarStore().Variables()
+			for name, x := range want {
+				y, ok := got[name]
+				if !ok || !util.Equal(&x, &y) {
+					t.Errorf("Want weight %q to be loaded back\n", name)
+				}
+			}
+		})
+	}
+}
+
 // With local file
 
 /*
@@ -1,8 +1,7 @@
 package pipeline
 
 import (
-	"log"
-	"reflect"
+	"fmt"
 
 	"github.com/sugarme/tokenizer"
 	"github.com/sugarme/tokenizer/model/wordpiece"
@@ -10,6 +9,8 @@ import (
 	"github.com/sugarme/tokenizer/pretokenizer"
 	"github.com/sugarme/tokenizer/processor"
 	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
 )
 
 // Common blocks for generic pipelines (e.g. token classification or sequence classification)
@@ -51,6 +52,14 @@ func NewBertConfigOption(config bert.BertConfig) *ConfigOption {
 	}
 }
 
+// NewRobertaConfigOption creates ConfigOption for Roberta models, which use BertConfig.
+func NewRobertaConfigOption(config bert.BertConfig) *ConfigOption {
+	return &ConfigOption{
+		model:  Roberta,
+		config: config,
+	}
+}
+
 type TokenizerType int
 
 const (
@@ -72,70 +81,88 @@ type TokenizerOption struct {
 // =====================
 
 // ConfigOptionFromFile loads configuration for corresponding model type from file.
-func ConfigOptionFromFile(modelType ModelType, path string) *ConfigOption {
+func ConfigOptionFromFile(modelType ModelType, path string) (*ConfigOption, error) {
 
 	var configOpt *ConfigOption
 
-	switch reflect.TypeOf(modelType).Kind().String() {
-	case "Bert":
-		config := bert.ConfigFromFile(path)
+	switch modelType {
+	case Bert, Roberta:
+		config, err := bert.ConfigFromFile(path)
+		if err != nil {
+			return nil, err
+		}
 		configOpt = &ConfigOption{
-			model:  Bert,
-			config: config,
+			model:  modelType,
+			config: *config,
 		}
 
 	// TODO: implement others
-	// case "DistilBert":
+	// case DistilBert:
 	default:
-		log.Fatalf("Invalid modelType: '%v'\n", reflect.TypeOf(modelType).Kind().String())
+		return nil, fmt.Errorf("Invalid modelType: '%v'", modelType)
 	}
 
-	return configOpt
+	return configOpt, nil
 }
 
 // GetLabelMap returns label mapping for corresponding model type.
-func (co *ConfigOption) GetLabelMapping() map[int64]string {
+func (co *ConfigOption) GetLabelMapping() (map[int64]string, error) {
 
 	var labelMap map[int64]string = make(map[int64]string)
 
-	modelTypeStr := reflect.TypeOf(co.model).Kind().String()
-	switch modelTypeStr {
-	case "Bert":
+	switch co.model {
+	case Bert, Roberta:
 		labelMap = co.config.(bert.BertConfig).Id2Label
 
 	// TODO: implement others
 	default:
-		log.Fatalf("ConfigOption GetLabelMapping error: invalid model type ('%v')\n", modelTypeStr)
+		return nil, fmt.Errorf("ConfigOption GetLabelMapping error: invalid model type ('%v')", co.model)
 	}
 
-	return labelMap
+	return labelMap, nil
 }
 
 // TOkenizerOptionFromFile loads TokenizerOption from file corresponding to model type.
-func TokenizerOptionFromFile(modelType ModelType, path string) *TokenizerOption {
-	modelTypeStr := reflect.TypeOf(modelType).Kind().String()
-
-	var tk *TokenizerOption
-	switch modelTypeStr {
-	case "Bert":
-		tk = &TokenizerOption{
-			model:     modelType,
-			tokenizer: getBert(path),
-		}
+// Path is the vocab file for Bert, model name or directory with `vocab.json` and `merges.txt` files for Roberta.
+func TokenizerOptionFromFile(modelType ModelType, path string) (*TokenizerOption, error) {
+	var (
+		tk  *tokenizer.Tokenizer
+		err error
+	)
+	switch modelType {
+	case Bert:
+		tk, err = getBert(path)
+
+	case Roberta:
+		tk, err = getRoberta(path)
 
 	// TODO: implement others
 
 	default:
-		log.Fatalf("Unsupported model type: '%v'", modelTypeStr)
+		err = fmt.Errorf("Unsupported model type: '%v'", modelType)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return tk
+	return &TokenizerOption{
+		model:     modelType,
+		tokenizer: tk,
+	}, nil
 }
 
-func getBert(path string) (retVal *tokenizer.Tokenizer) {
+// NewTokenizerOption creates TokenizerOption from a loaded tokenizer.
+func NewTokenizerOption(modelType ModelType, tk *tokenizer.Tokenizer) *TokenizerOption {
+	return &TokenizerOption{
+		model:     modelType,
+		tokenizer: tk,
+	}
+}
+
+func getBert(path string) (*tokenizer.Tokenizer, error) {
 	model, err := wordpiece.NewWordPieceFromFile(path, "[UNK]")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	tk := tokenizer.NewTokenizer(model)
@@ -153,20 +180,29 @@ func getBert(path string) (retVal *tokenizer.Tokenizer) {
 
 	sepId, ok := tk.TokenToId("[SEP]")
 	if !ok {
-		log.Fatalf("Cannot find ID for [SEP] token.\n")
+		return nil, fmt.Errorf("Cannot find ID for [SEP] token: %w", util.ErrMissingToken)
 	}
 	sep := processor.PostToken{Id: sepId, Value: "[SEP]"}
 
 	clsId, ok := tk.TokenToId("[CLS]")
 	if !ok {
-		log.Fatalf("Cannot find ID for [CLS] token.\n")
+		return nil, fmt.Errorf("Cannot find ID for [CLS] token: %w", util.ErrMissingToken)
 	}
 	cls := processor.PostToken{Id: clsId, Value: "[CLS]"}
 
 	postProcess := processor.NewBertProcessing(sep, cls)
 	tk.WithPostProcessor(postProcess)
 
-	return tk
+	return tk, nil
+}
+
+func getRoberta(path string) (*tokenizer.Tokenizer, error) {
+	tk := roberta.NewTokenizer()
+	if err := tk.Load(path, nil); err != nil {
+		return nil, err
+	}
+
+	return tk.Tokenizer, nil
 }
 
 // ModelType returns chosen model type
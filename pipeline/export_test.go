@@ -0,0 +1,14 @@
+package pipeline
+
+// Unexported functions used by `pipeline_test` tests.
+
+var (
+	CharOffset   = charOffset
+	SplitLabel   = splitLabel
+	Truncate     = truncate
+	TruncatePair = truncatePair
+)
+
+func (nm *NERModel) Merge(input []string, tokens []Token) []Entity {
+	return nm.merge(input, tokens)
+}
@@ -0,0 +1,433 @@
+package pipeline
+
+// Feature extraction pipeline
+// Computes token embeddings or sentence embeddings (e.g. for semantic search) with BERT or RoBERTa
+// encoders. Sentence-transformers checkpoints (e.g. "sentence-transformers/all-MiniLM-L6-v2") are
+// loaded with their pooling and normalization modules.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+// Pooling is a strategy to compute a sentence embedding from token embeddings.
+type Pooling int
+
+const (
+	// MeanPooling averages token embeddings, ignoring padding.
+	MeanPooling Pooling = iota
+	// CLSPooling uses embedding of the first token ([CLS] or <s>).
+	CLSPooling
+	// MaxPooling takes maximum of every dimension over tokens, ignoring padding.
+	MaxPooling
+	// WeightedLayerPooling averages token embeddings of a weighted sum of the last
+	// hidden layers (see `LayerWeights`).
+	WeightedLayerPooling
+)
+
+// FeatureExtractionModel computes embeddings with a BERT or RoBERTa encoder.
+type FeatureExtractionModel struct {
+	model     *bert.BertModel
+	tokenizer *tokenizer.Tokenizer
+	padId     int64
+	device    gotch.Device
+
+	// Pooling computes sentence embeddings. Default is `MeanPooling`.
+	Pooling Pooling
+	// LayerWeights are weights of the last hidden layers, output layer last, for
+	// `WeightedLayerPooling`. Default is equal weights of the last 4 layers.
+	LayerWeights []float64
+	// Normalize scales sentence embeddings to unit L2 norm.
+	Normalize bool
+	// MaxSeqLength truncates inputs to at most this number of tokens if greater than 0.
+	MaxSeqLength int
+	// RobertaPositions creates RoBERTa position ids, starting after padding index (see
+	// `roberta.PositionIds`), instead of numbering positions from 0. It is set by
+	// `NewFeatureExtractionModel` for RoBERTa models.
+	RobertaPositions bool
+}
+
+// stModule is an entry of sentence-transformers `modules.json` file.
+type stModule struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// stPoolingConfig is a sentence-transformers pooling module configuration.
+type stPoolingConfig struct {
+	ClsToken          bool `json:"pooling_mode_cls_token"`
+	MeanTokens        bool `json:"pooling_mode_mean_tokens"`
+	MaxTokens         bool `json:"pooling_mode_max_tokens"`
+	MeanSqrtLenTokens bool `json:"pooling_mode_mean_sqrt_len_tokens"`
+	WeightedMean      bool `json:"pooling_mode_weightedmean_tokens"`
+	LastToken         bool `json:"pooling_mode_lasttoken"`
+}
+
+// NewFeatureExtractionModel loads an encoder and its tokenizer from model name or path.
+// Model type is read from configuration file (see `transformer.ReadModelInfo`). BERT and
+// RoBERTa encoders are supported.
+//
+// Sentence-transformers checkpoints are detected by their `modules.json` file: pooling
+// strategy, normalization and maximum sequence length are then read from their modules.
+func NewFeatureExtractionModel(modelNameOrPath string, device gotch.Device) (*FeatureExtractionModel, error) {
+	info, err := transformer.ReadModelInfo(modelNameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.ModelType != "bert" && info.ModelType != "roberta" {
+		return nil, fmt.Errorf("Unsupported encoder model type %q", info.ModelType)
+	}
+
+	config := new(bert.BertConfig)
+	if err := transformer.LoadConfig(config, modelNameOrPath, nil); err != nil {
+		return nil, err
+	}
+
+	// Sentence-transformers save encoders without task model prefix.
+	modules, isSentenceTransformer := readModules(modelNameOrPath)
+	prefix := "bert"
+	if info.ModelType == "roberta" {
+		prefix = "roberta"
+	}
+
+	vs := nn.NewVarStore(device)
+	p := vs.Root()
+	if !isSentenceTransformer {
+		p = p.Sub(prefix)
+	}
+	model, err := bert.NewBertModel(p, config, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := util.LoadVarStore(vs, modelNameOrPath, util.LoadOptions{}, nil); err != nil {
+		return nil, err
+	}
+
+	autoTk, err := transformer.AutoTokenizer(modelNameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	tk, err := baseTokenizer(autoTk)
+	if err != nil {
+		return nil, err
+	}
+
+	fe := NewFeatureExtractionModelFrom(model, tk, device)
+	fe.MaxSeqLength = int(config.MaxPositionEmbeddings)
+	if info.ModelType == "roberta" {
+		fe.RobertaPositions = true
+		// RoBERTa position ids start after padding index.
+		fe.MaxSeqLength -= 2
+	}
+
+	if isSentenceTransformer {
+		if err := fe.loadModules(modelNameOrPath, modules); err != nil {
+			return nil, err
+		}
+	}
+
+	return fe, nil
+}
+
+// NewFeatureExtractionModelFrom creates a FeatureExtractionModel from a loaded encoder and tokenizer.
+func NewFeatureExtractionModelFrom(model *bert.BertModel, tk *tokenizer.Tokenizer, device gotch.Device) *FeatureExtractionModel {
+	padId, ok := tokenId(tk, "[PAD]", "<pad>")
+	if !ok {
+		padId = 0
+	}
+
+	return &FeatureExtractionModel{
+		model:     model,
+		tokenizer: tk,
+		padId:     int64(padId),
+		device:    device,
+		Pooling:   MeanPooling,
+	}
+}
+
+// readModules reads sentence-transformers `modules.json` file. It reports false if there is none.
+func readModules(modelNameOrPath string) ([]stModule, bool) {
+	file, err := util.CachedPath(modelNameOrPath, "modules.json")
+	if err != nil {
+		return nil, false
+	}
+	buff, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+
+	var modules []stModule
+	if err := json.Unmarshal(buff, &modules); err != nil {
+		return nil, false
+	}
+
+	return modules, true
+}
+
+// loadModules configures pooling and normalization from sentence-transformers modules.
+func (fe *FeatureExtractionModel) loadModules(modelNameOrPath string, modules []stModule) error {
+	for _, module := range modules {
+		switch path.Ext(module.Type) {
+		case ".Pooling":
+			file, err := util.CachedPath(modelNameOrPath, path.Join(module.Path, util.ConfigName))
+			if err != nil {
+				return err
+			}
+			buff, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			var config stPoolingConfig
+			if err := json.Unmarshal(buff, &config); err != nil {
+				return err
+			}
+
+			switch {
+			case config.MeanSqrtLenTokens || config.WeightedMean || config.LastToken:
+				return fmt.Errorf("Unsupported sentence-transformers pooling mode in %q", file)
+			case config.ClsToken && !config.MeanTokens && !config.MaxTokens:
+				fe.Pooling = CLSPooling
+			case config.MaxTokens && !config.ClsToken && !config.MeanTokens:
+				fe.Pooling = MaxPooling
+			case config.MeanTokens && !config.ClsToken && !config.MaxTokens:
+				fe.Pooling = MeanPooling
+			default:
+				return fmt.Errorf("Unsupported combination of sentence-transformers pooling modes in %q", file)
+			}
+
+		case ".Normalize":
+			fe.Normalize = true
+		}
+	}
+
+	// Optional maximum sequence length
+	file, err := util.CachedPath(modelNameOrPath, "sentence_bert_config.json")
+	if err != nil {
+		return nil
+	}
+	buff, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var config struct {
+		MaxSeqLength int `json:"max_seq_length"`
+	}
+	if err := json.Unmarshal(buff, &config); err != nil {
+		return err
+	}
+	if config.MaxSeqLength > 0 {
+		fe.MaxSeqLength = config.MaxSeqLength
+	}
+
+	return nil
+}
+
+// Predict returns sentence embeddings of input texts computed with `Pooling` strategy.
+func (fe *FeatureExtractionModel) Predict(texts []string) ([][]float64, error) {
+	outputs, err := fe.forward(texts, fe.Pooling == WeightedLayerPooling)
+	if err != nil {
+		return nil, err
+	}
+
+	var embeddings [][]float64
+	for _, layers := range outputs {
+		var embedding []float64
+		switch fe.Pooling {
+		case CLSPooling:
+			embedding = append(embedding, layers[len(layers)-1][0]...)
+		case MaxPooling:
+			embedding = maxPool(layers[len(layers)-1])
+		case WeightedLayerPooling:
+			embedding = meanPool(weightLayers(layers, fe.LayerWeights))
+		default:
+			embedding = meanPool(layers[len(layers)-1])
+		}
+
+		if fe.Normalize {
+			embedding = l2Normalize(embedding)
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	return embeddings, nil
+}
+
+// TokenFeatures returns embeddings of every token of input texts, including special tokens.
+func (fe *FeatureExtractionModel) TokenFeatures(texts []string) ([][][]float64, error) {
+	outputs, err := fe.forward(texts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var features [][][]float64
+	for _, layers := range outputs {
+		features = append(features, layers[len(layers)-1])
+	}
+
+	return features, nil
+}
+
+// forward returns token embeddings of every text as (layer, token, hidden size) values,
+// without padding. Only the output layer is returned unless `allLayers` is set.
+func (fe *FeatureExtractionModel) forward(texts []string, allLayers bool) ([][][][]float64, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	var (
+		encodings [][]int
+		maxLen    int
+	)
+	for _, text := range texts {
+		encoding, err := fe.tokenizer.EncodeSingle(text, true)
+		if err != nil {
+			return nil, err
+		}
+		ids := truncate(*encoding, fe.MaxSeqLength).Ids
+		encodings = append(encodings, ids)
+		if len(ids) > maxLen {
+			maxLen = len(ids)
+		}
+	}
+
+	var inputIds, mask []int64
+	for _, ids := range encodings {
+		for j := 0; j < maxLen; j++ {
+			if j < len(ids) {
+				inputIds = append(inputIds, int64(ids[j]))
+				mask = append(mask, 1)
+			} else {
+				inputIds = append(inputIds, fe.padId)
+				mask = append(mask, 0)
+			}
+		}
+	}
+
+	size := []int64{int64(len(texts)), int64(maxLen)}
+	inputTs := ts.MustOfSlice(inputIds).MustView(size, true).MustTo(fe.device, true)
+	maskTs := ts.MustOfSlice(mask).MustView(size, true).MustTo(fe.device, true)
+
+	// Position ids are dropped by the model.
+	positionTs := ts.None
+	if fe.RobertaPositions {
+		positionTs = roberta.PositionIds(inputTs, fe.padId)
+	}
+
+	outputHiddenStates := fe.model.Encoder.OutputHiddenStates
+	fe.model.Encoder.OutputHiddenStates = allLayers
+
+	var (
+		output, pooled          *ts.Tensor
+		hiddenStates, attention []ts.Tensor
+		err                     error
+	)
+	ts.NoGrad(func() {
+		output, pooled, hiddenStates, attention, err = fe.model.ForwardT(inputTs, maskTs, ts.None, positionTs, ts.None, ts.None, ts.None, false)
+	})
+	fe.model.Encoder.OutputHiddenStates = outputHiddenStates
+	inputTs.MustDrop()
+	maskTs.MustDrop()
+	if err != nil {
+		return nil, err
+	}
+	pooled.MustDrop()
+	dropAll(attention)
+
+	// Hidden states are inputs of every encoder layer, output is last.
+	layers := append(hiddenStates, *output)
+	hiddenSize := int(output.MustSize()[2])
+
+	retVal := make([][][][]float64, len(texts))
+	for _, layer := range layers {
+		values := layer.Float64Values(true)
+		for i, ids := range encodings {
+			tokens := make([][]float64, len(ids))
+			for j := range ids {
+				start := (i*maxLen + j) * hiddenSize
+				tokens[j] = values[start : start+hiddenSize]
+			}
+			retVal[i] = append(retVal[i], tokens)
+		}
+	}
+
+	return retVal, nil
+}
+
+// weightLayers returns weighted sum of the last `len(weights)` layers of token embeddings,
+// normalized by the sum of weights. `layers` are of shape (layer, token, hidden size).
+func weightLayers(layers [][][]float64, weights []float64) [][]float64 {
+	if len(weights) == 0 {
+		weights = []float64{1, 1, 1, 1}
+	}
+	if len(weights) > len(layers) {
+		weights = weights[len(weights)-len(layers):]
+	}
+	layers = layers[len(layers)-len(weights):]
+
+	var sum float64
+	for _, w := range weights {
+		sum += w
+	}
+
+	tokens := make([][]float64, len(layers[0]))
+	for j := range tokens {
+		tokens[j] = make([]float64, len(layers[0][j]))
+		for l, layer := range layers {
+			for k, v := range layer[j] {
+				tokens[j][k] += weights[l] * v / sum
+			}
+		}
+	}
+
+	return tokens
+}
+
+func meanPool(tokens [][]float64) []float64 {
+	embedding := make([]float64, len(tokens[0]))
+	for _, token := range tokens {
+		for k, v := range token {
+			embedding[k] += v / float64(len(tokens))
+		}
+	}
+
+	return embedding
+}
+
+func maxPool(tokens [][]float64) []float64 {
+	embedding := append([]float64{}, tokens[0]...)
+	for _, token := range tokens[1:] {
+		for k, v := range token {
+			embedding[k] = math.Max(embedding[k], v)
+		}
+	}
+
+	return embedding
+}
+
+func l2Normalize(x []float64) []float64 {
+	var norm float64
+	for _, v := range x {
+		norm += v * v
+	}
+	norm = math.Max(math.Sqrt(norm), 1e-12)
+
+	normalized := make([]float64, len(x))
+	for i, v := range x {
+		normalized[i] = v / norm
+	}
+
+	return normalized
+}
@@ -0,0 +1,78 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pipeline"
+	"github.com/sugarme/transformer/util"
+)
+
+func TestFeatureExtractionModel(t *testing.T) {
+	device := gotch.CPU
+	vs := nn.NewVarStore(device)
+
+	configFile, err := util.CachedPath("bert-base-uncased", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := bert.ConfigFromFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := bert.NewBertModel(vs.Root(), config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk := bert.NewTokenizer()
+	err = tk.Load("bert-base-uncased", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fe := pipeline.NewFeatureExtractionModelFrom(model, tk.Tokenizer, device)
+	fe.Normalize = true
+
+	texts := []string{"Looks like one thing is missing", "It's like comparing oranges to apples"}
+
+	var gotSizes []int
+	for _, pooling := range []pipeline.Pooling{pipeline.MeanPooling, pipeline.CLSPooling, pipeline.MaxPooling, pipeline.WeightedLayerPooling} {
+		fe.Pooling = pooling
+		embeddings, err := fe.Predict(texts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotSizes = append(gotSizes, len(embeddings), len(embeddings[0]))
+
+		var norm float64
+		for _, v := range embeddings[0] {
+			norm += v * v
+		}
+		if norm < 0.999 || norm > 1.001 {
+			t.Errorf("Want: unit norm embeddings\n")
+			t.Errorf("Got: squared norm %v\n", norm)
+		}
+	}
+
+	wantSizes := []int{2, 768, 2, 768, 2, 768, 2, 768}
+	if !reflect.DeepEqual(wantSizes, gotSizes) {
+		t.Errorf("Want: %v\n", wantSizes)
+		t.Errorf("Got: %v\n", gotSizes)
+	}
+
+	// [CLS] looks like one thing is missing [SEP]
+	features, err := fe.TokenFeatures(texts[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTokens := 8
+	if len(features[0]) != wantTokens {
+		t.Errorf("Want: %v\n", wantTokens)
+		t.Errorf("Got: %v\n", len(features[0]))
+	}
+}
@@ -0,0 +1,303 @@
+package pipeline
+
+// Fill-mask pipeline
+// Predicts masked tokens of input texts with BERT or RoBERTa masked language models.
+// The mask token is detected from the tokenizer: "[MASK]" for BERT (e.g. "Paris is the [MASK] of France.")
+// and "<mask>" for RoBERTa (e.g. "Paris is the <mask> of France.").
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+// MaskPrediction is a predicted token for a masked input.
+type MaskPrediction struct {
+	Token    string  // predicted token
+	Score    float64 // probability of predicted token
+	Sentence string  // input text with mask replaced by predicted token
+}
+
+// FillMaskModel predicts masked tokens with a masked language model.
+type FillMaskModel struct {
+	forward   func(inputIds, mask *ts.Tensor) (*ts.Tensor, error)
+	tokenizer *tokenizer.Tokenizer
+	maskId    int
+	padId     int
+	device    gotch.Device
+
+	// MaxSeqLength truncates inputs to at most this number of tokens, keeping the closing
+	// special token. Default is the maximum input length of the model.
+	MaxSeqLength int
+}
+
+// NewFillMaskModel loads a masked language model and its tokenizer from model name or path.
+// Model type is read from configuration file (see `transformer.ReadModelInfo`). BERT and
+// RoBERTa models are supported.
+func NewFillMaskModel(modelNameOrPath string, device gotch.Device) (*FillMaskModel, error) {
+	model, tk, err := autoModel(pretrained.MaskedLM, modelNameOrPath, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFillMaskModelFrom(model, tk, device)
+}
+
+// NewFillMaskModelFrom creates a FillMaskModel from a loaded model and tokenizer.
+// Supported models are `*bert.BertForMaskedLM` and `*roberta.RobertaForMaskedLM`.
+func NewFillMaskModelFrom(model pretrained.Model, tk *tokenizer.Tokenizer, device gotch.Device) (*FillMaskModel, error) {
+	var (
+		forward      func(inputIds, mask *ts.Tensor) (*ts.Tensor, error)
+		maxSeqLength int
+	)
+	switch m := model.(type) {
+	case *bert.BertForMaskedLM:
+		maxSeqLength = maxInputLength(m.Config(), false)
+		forward = func(inputIds, mask *ts.Tensor) (*ts.Tensor, error) {
+			output, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, ts.None, ts.None, ts.None, ts.None, ts.None, false)
+			if err != nil {
+				return nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return output, nil
+		}
+	case *roberta.RobertaForMaskedLM:
+		maxSeqLength = maxInputLength(m.Config(), true)
+		forward = func(inputIds, mask *ts.Tensor) (*ts.Tensor, error) {
+			output, hiddenStates, attentions, err := m.Forward(inputIds, mask, ts.None, ts.None, ts.None, ts.None, ts.None, false)
+			if err != nil {
+				return nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return output, nil
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported masked language model type %T", model)
+	}
+
+	maskId, ok := tokenId(tk, "[MASK]", "<mask>")
+	if !ok {
+		return nil, fmt.Errorf("Cannot find mask token ([MASK] or <mask>) in tokenizer vocab")
+	}
+	padId, ok := tokenId(tk, "[PAD]", "<pad>")
+	if !ok {
+		padId = 0
+	}
+
+	return &FillMaskModel{
+		forward:      forward,
+		tokenizer:    tk,
+		maskId:       maskId,
+		padId:        padId,
+		device:       device,
+		MaxSeqLength: maxSeqLength,
+	}, nil
+}
+
+// Predict returns `topK` most probable tokens for the mask of every input, best first.
+// Each input must contain exactly one mask token within the first `MaxSeqLength` tokens,
+// otherwise it returns an error wrapping `util.ErrInvalidInput`.
+func (fm *FillMaskModel) Predict(inputs []string, topK int) ([][]MaskPrediction, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	var (
+		encodings     []tokenizer.Encoding
+		maskPositions []int
+		maxLen        int
+	)
+	for i, input := range inputs {
+		encoding, err := fm.tokenizer.EncodeSingle(input, true)
+		if err != nil {
+			return nil, err
+		}
+
+		position, count := -1, 0
+		for j, id := range encoding.Ids {
+			if id == fm.maskId {
+				position = j
+				count++
+			}
+		}
+		if count != 1 {
+			return nil, fmt.Errorf("Input %v must contain exactly one mask token, got %v: %w", i, count, util.ErrInvalidInput)
+		}
+		if fm.MaxSeqLength > 0 && position >= fm.MaxSeqLength-1 {
+			return nil, fmt.Errorf("Mask token of input %v is beyond maximum sequence length %v: %w", i, fm.MaxSeqLength, util.ErrInvalidInput)
+		}
+
+		*encoding = truncate(*encoding, fm.MaxSeqLength)
+		encodings = append(encodings, *encoding)
+		maskPositions = append(maskPositions, position)
+		if len(encoding.Ids) > maxLen {
+			maxLen = len(encoding.Ids)
+		}
+	}
+
+	var inputIds, mask []int64
+	for _, encoding := range encodings {
+		for j := 0; j < maxLen; j++ {
+			if j < len(encoding.Ids) {
+				inputIds = append(inputIds, int64(encoding.Ids[j]))
+				mask = append(mask, 1)
+			} else {
+				inputIds = append(inputIds, int64(fm.padId))
+				mask = append(mask, 0)
+			}
+		}
+	}
+
+	size := []int64{int64(len(inputs)), int64(maxLen)}
+	inputTs := ts.MustOfSlice(inputIds).MustView(size, true).MustTo(fm.device, true)
+	maskTs := ts.MustOfSlice(mask).MustView(size, true).MustTo(fm.device, true)
+
+	var (
+		logits *ts.Tensor
+		err    error
+	)
+	ts.NoGrad(func() {
+		logits, err = fm.forward(inputTs, maskTs)
+	})
+	inputTs.MustDrop()
+	maskTs.MustDrop()
+	if err != nil {
+		return nil, err
+	}
+	defer logits.MustDrop()
+
+	vocabSize := int(logits.MustSize()[2])
+	if topK < 1 {
+		topK = 1
+	}
+	if topK > vocabSize {
+		topK = vocabSize
+	}
+
+	var predictions [][]MaskPrediction
+	for i, encoding := range encodings {
+		probs := logits.MustSelect(0, int64(i), false).MustSelect(0, int64(maskPositions[i]), true).MustSoftmax(-1, gotch.Double, true)
+		scoresTs, idsTs := probs.MustTopk(int64(topK), -1, true, true, true)
+		scores := scoresTs.Float64Values(true)
+		ids := idsTs.Int64Values(true)
+
+		var inputPredictions []MaskPrediction
+		for j, id := range ids {
+			inputPredictions = append(inputPredictions, MaskPrediction{
+				Token:    strings.TrimSpace(fm.tokenizer.Decode([]int{int(id)}, false)),
+				Score:    scores[j],
+				Sentence: fm.fill(encoding, maskPositions[i], int(id)),
+			})
+		}
+		predictions = append(predictions, inputPredictions)
+	}
+
+	return predictions, nil
+}
+
+// fill decodes `encoding` with token at `position` replaced by `id`, skipping special tokens.
+func (fm *FillMaskModel) fill(encoding tokenizer.Encoding, position int, id int) string {
+	var ids []int
+	for i, tokenId := range encoding.Ids {
+		switch {
+		case i == position:
+			ids = append(ids, id)
+		case encoding.SpecialTokenMask[i] == 0:
+			ids = append(ids, tokenId)
+		}
+	}
+
+	return strings.TrimSpace(fm.tokenizer.Decode(ids, true))
+}
+
+// tokenId returns id of the first of `tokens` found in tokenizer vocab.
+func tokenId(tk *tokenizer.Tokenizer, tokens ...string) (int, bool) {
+	for _, token := range tokens {
+		if id, ok := tk.TokenToId(token); ok {
+			return id, true
+		}
+	}
+	return -1, false
+}
+
+// maxInputLength returns the maximum number of input tokens of a BERT or RoBERTa model.
+func maxInputLength(config *bert.BertConfig, isRoberta bool) int {
+	if isRoberta {
+		// RoBERTa position ids start after padding index (see `roberta.PositionIds`).
+		return int(config.MaxPositionEmbeddings) - 2
+	}
+	return int(config.MaxPositionEmbeddings)
+}
+
+// truncate truncates `encoding` to at most `maxLen` tokens, keeping the closing special
+// token (e.g. [SEP]). It returns `encoding` unchanged if `maxLen` is not greater than 0.
+func truncate(encoding tokenizer.Encoding, maxLen int) tokenizer.Encoding {
+	last := len(encoding.Ids) - 1
+	if maxLen <= 0 || last < maxLen {
+		return encoding
+	}
+
+	ints := func(xs []int) []int {
+		if len(xs) <= last {
+			return xs
+		}
+		return append(append([]int{}, xs[:maxLen-1]...), xs[last])
+	}
+	encoding.Ids = ints(encoding.Ids)
+	encoding.TypeIds = ints(encoding.TypeIds)
+	encoding.SpecialTokenMask = ints(encoding.SpecialTokenMask)
+	encoding.AttentionMask = ints(encoding.AttentionMask)
+	encoding.Words = ints(encoding.Words)
+	if len(encoding.Tokens) > last {
+		encoding.Tokens = append(append([]string{}, encoding.Tokens[:maxLen-1]...), encoding.Tokens[last])
+	}
+	if len(encoding.Offsets) > last {
+		encoding.Offsets = append(append([][]int{}, encoding.Offsets[:maxLen-1]...), encoding.Offsets[last])
+	}
+
+	return encoding
+}
+
+// autoModel loads the model for `task` of a model name or path and its tokenizer with
+// `transformer.AutoModelFor`.
+func autoModel(task pretrained.Task, modelNameOrPath string, device gotch.Device) (pretrained.Model, *tokenizer.Tokenizer, error) {
+	auto, err := transformer.AutoModelFor(task, modelNameOrPath, nil, device)
+	if err != nil {
+		return nil, nil, err
+	}
+	tk, err := baseTokenizer(auto.Tokenizer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return auto.Model, tk, nil
+}
+
+// baseTokenizer returns the tokenizer wrapped by BERT or RoBERTa tokenizer.
+func baseTokenizer(tk pretrained.Tokenizer) (*tokenizer.Tokenizer, error) {
+	switch t := tk.(type) {
+	case *bert.Tokenizer:
+		return t.Tokenizer, nil
+	case *roberta.Tokenizer:
+		return t.Tokenizer, nil
+	default:
+		return nil, fmt.Errorf("Unsupported tokenizer type %T", tk)
+	}
+}
+
+func dropAll(xs []ts.Tensor) {
+	for i := range xs {
+		xs[i].MustDrop()
+	}
+}
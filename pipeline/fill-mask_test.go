@@ -0,0 +1,53 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer/pipeline"
+)
+
+func TestTruncate(t *testing.T) {
+	// [CLS] paris is the capital [SEP]
+	encoding := tokenizer.Encoding{
+		Ids:              []int{101, 3000, 2003, 1996, 3007, 102},
+		TypeIds:          []int{0, 0, 0, 0, 0, 0},
+		Tokens:           []string{"[CLS]", "paris", "is", "the", "capital", "[SEP]"},
+		Offsets:          [][]int{{0, 0}, {0, 5}, {6, 8}, {9, 12}, {13, 20}, {0, 0}},
+		SpecialTokenMask: []int{1, 0, 0, 0, 0, 1},
+		AttentionMask:    []int{1, 1, 1, 1, 1, 1},
+		Words:            []int{-1, 0, 1, 2, 3, -1},
+	}
+
+	want := tokenizer.Encoding{
+		Ids:              []int{101, 3000, 2003, 102},
+		TypeIds:          []int{0, 0, 0, 0},
+		Tokens:           []string{"[CLS]", "paris", "is", "[SEP]"},
+		Offsets:          [][]int{{0, 0}, {0, 5}, {6, 8}, {0, 0}},
+		SpecialTokenMask: []int{1, 0, 0, 1},
+		AttentionMask:    []int{1, 1, 1, 1},
+		Words:            []int{-1, 0, 1, -1},
+	}
+	got := pipeline.Truncate(encoding, 4)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Input is not modified.
+	if len(encoding.Ids) != 6 || encoding.Ids[3] != 1996 {
+		t.Errorf("Want: %v\n", []int{101, 3000, 2003, 1996, 3007, 102})
+		t.Errorf("Got: %v\n", encoding.Ids)
+	}
+
+	// No truncation
+	for _, maxLen := range []int{0, 6, 10} {
+		got := pipeline.Truncate(encoding, maxLen)
+		if !reflect.DeepEqual(encoding, got) {
+			t.Errorf("Want: %v\n", encoding)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package pipeline
+
+// Multiple choice pipeline
+// Ranks choices (e.g. answers, sentence endings) following a context with BERT or RoBERTa
+// multiple choice models (e.g. trained on SWAG).
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/roberta"
+)
+
+// MultipleChoiceModel ranks choices with a multiple choice model.
+type MultipleChoiceModel struct {
+	forward   func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, error)
+	tokenizer *tokenizer.Tokenizer
+	padId     int64
+	device    gotch.Device
+
+	// MaxSeqLength truncates inputs to at most this number of tokens by removing tokens at
+	// the end of the context, keeping choices. Default is the maximum input length of the model.
+	MaxSeqLength int
+}
+
+// NewMultipleChoiceModel loads a multiple choice model and its tokenizer from model name or path.
+// Model type is read from configuration file (see `transformer.ReadModelInfo`). BERT and
+// RoBERTa models are supported.
+func NewMultipleChoiceModel(modelNameOrPath string, device gotch.Device) (*MultipleChoiceModel, error) {
+	model, tk, err := autoModel(pretrained.MultipleChoice, modelNameOrPath, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMultipleChoiceModelFrom(model, tk, device)
+}
+
+// NewMultipleChoiceModelFrom creates a MultipleChoiceModel from a loaded model and tokenizer.
+// Supported models are `*bert.BertForMultipleChoice` and `*roberta.RobertaForMultipleChoice`.
+func NewMultipleChoiceModelFrom(model pretrained.Model, tk *tokenizer.Tokenizer, device gotch.Device) (*MultipleChoiceModel, error) {
+	var (
+		forward      func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, error)
+		maxSeqLength int
+	)
+	switch m := model.(type) {
+	case *bert.BertForMultipleChoice:
+		maxSeqLength = maxInputLength(m.Config(), false)
+		forward = func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, error) {
+			output, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, tokenTypeIds, ts.None, false)
+			if err != nil {
+				return nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return output, nil
+		}
+	case *roberta.RobertaForMultipleChoice:
+		maxSeqLength = maxInputLength(m.Config(), true)
+		forward = func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, error) {
+			output, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, ts.None, ts.None, false)
+			if err != nil {
+				return nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return output, nil
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported multiple choice model type %T", model)
+	}
+
+	padId, ok := tokenId(tk, "[PAD]", "<pad>")
+	if !ok {
+		padId = 0
+	}
+
+	return &MultipleChoiceModel{
+		forward:      forward,
+		tokenizer:    tk,
+		padId:        int64(padId),
+		device:       device,
+		MaxSeqLength: maxSeqLength,
+	}, nil
+}
+
+// Predict ranks `choices` following `context` and returns them best first. Label `Id` is
+// the index of choice in `choices` and `Score` its probability.
+func (mc *MultipleChoiceModel) Predict(context string, choices []string) ([]Label, error) {
+	if len(choices) == 0 {
+		return nil, nil
+	}
+
+	var (
+		encodings []tokenizer.Encoding
+		maxLen    int
+	)
+	for _, choice := range choices {
+		encoding, err := mc.tokenizer.EncodePair(context, choice, true)
+		if err != nil {
+			return nil, err
+		}
+		*encoding = truncatePair(*encoding, mc.MaxSeqLength)
+		encodings = append(encodings, *encoding)
+		if len(encoding.Ids) > maxLen {
+			maxLen = len(encoding.Ids)
+		}
+	}
+
+	var inputIds, mask, tokenTypeIds []int64
+	for _, encoding := range encodings {
+		for j := 0; j < maxLen; j++ {
+			if j < len(encoding.Ids) {
+				inputIds = append(inputIds, int64(encoding.Ids[j]))
+				mask = append(mask, 1)
+				tokenTypeIds = append(tokenTypeIds, int64(encoding.TypeIds[j]))
+			} else {
+				inputIds = append(inputIds, mc.padId)
+				mask = append(mask, 0)
+				tokenTypeIds = append(tokenTypeIds, 0)
+			}
+		}
+	}
+
+	// (batch size, number of choices, sequence length)
+	size := []int64{1, int64(len(choices)), int64(maxLen)}
+	inputTs := ts.MustOfSlice(inputIds).MustView(size, true).MustTo(mc.device, true)
+	maskTs := ts.MustOfSlice(mask).MustView(size, true).MustTo(mc.device, true)
+	typeTs := ts.MustOfSlice(tokenTypeIds).MustView(size, true).MustTo(mc.device, true)
+
+	var (
+		logits *ts.Tensor
+		err    error
+	)
+	ts.NoGrad(func() {
+		logits, err = mc.forward(inputTs, maskTs, typeTs)
+	})
+	inputTs.MustDrop()
+	maskTs.MustDrop()
+	typeTs.MustDrop()
+	if err != nil {
+		return nil, err
+	}
+
+	probs := softmax(logits.Float64Values(true))
+
+	labels := make([]Label, len(choices))
+	for i, choice := range choices {
+		labels[i] = Label{Text: choice, Id: int64(i), Score: probs[i]}
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Score > labels[j].Score
+	})
+
+	return labels, nil
+}
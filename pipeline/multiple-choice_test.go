@@ -0,0 +1,71 @@
+package pipeline_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pipeline"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+func TestMultipleChoiceModel(t *testing.T) {
+	// Config
+	config := new(bert.BertConfig)
+	configFile, err := util.CachedPath("roberta-base", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = config.Load(configFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Model
+	device := gotch.CPU
+	vs := nn.NewVarStore(device)
+	model, err := roberta.NewRobertaForMultipleChoice(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roberta tokenizer
+	tk := roberta.NewTokenizer()
+	err = tk.Load("roberta-base", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mc, err := pipeline.NewMultipleChoiceModelFrom(model, tk.Tokenizer, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Choices of different lengths are padded.
+	choices := []string{"eats an apple.", "goes to the theatre with friends.", "sleeps."}
+	labels, err := mc.Predict("The girl is hungry, so she", choices)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		gotIds []int64
+		sum    float64
+	)
+	for _, label := range labels {
+		gotIds = append(gotIds, label.Id)
+		sum += label.Score
+		if label.Text != choices[label.Id] {
+			t.Errorf("Want: %v\n", choices[label.Id])
+			t.Errorf("Got: %v\n", label.Text)
+		}
+	}
+
+	if len(gotIds) != 3 || sum < 0.999 || sum > 1.001 {
+		t.Errorf("Want: 3 choices with probabilities summing to 1\n")
+		t.Errorf("Got: %v\n", labels)
+	}
+}
@@ -10,42 +10,162 @@ package pipeline
 //
 // The default NER mode is an English BERT cased large model finetuned on CoNNL03, contributed by the [MDZ Digital Library team at the Bavarian State Library](https://github.com/dbmdz)
 
+import (
+	"strings"
+)
+
 // Entity holds entity data generated by NERModel
 type Entity struct {
 	// String representation of the Entity
 	Word string
-	// Confidence score
+	// Confidence score, averaged over words of the entity
 	Score float64
-	// Entity label (e.g. ORG, LOC...)
+	// Entity label without tagging prefix (e.g. ORG, LOC...)
 	Label string
+	// Character offsets of the entity in input sentence
+	Offset Offset
+	// Index of input sentence
+	Sentence int
 }
 
+// TaggingScheme is a scheme of entity label prefixes.
+type TaggingScheme int
+
+const (
+	// BIO: "B-" begins an entity, "I-" continues it.
+	BIO TaggingScheme = iota
+	// BIOES: "B-" begins, "I-" continues, "E-" ends an entity of several words and
+	// "S-" is a single word entity.
+	BIOES
+	// IOB1: "I-" is inside an entity, "B-" only begins an entity following another
+	// one of the same type.
+	IOB1
+)
+
 // NERModel is a model to extract entities
 type NERModel struct {
-	tokenClassificationModel TokenClassificationModel
+	tokenClassificationModel *TokenClassificationModel
+
+	// Scheme is the tagging scheme of model labels. Default is `BIO`.
+	Scheme TaggingScheme
+	// IgnoreLabels are labels of words outside entities. Default is ["O"].
+	IgnoreLabels []string
 }
 
-// NewNERModel creates a NERModel from input config
-func NewNERModel(config TokenClassificationModel) *NERModel {
+// NewNERModel creates a NERModel from a token classification model.
+func NewNERModel(model *TokenClassificationModel) *NERModel {
 	return &NERModel{
-		tokenClassificationModel: config,
+		tokenClassificationModel: model,
+		Scheme:                   BIO,
+		IgnoreLabels:             []string{"O"},
+	}
+}
+
+// Predict extracts entities from input text and returns slice of entities with score.
+// Consecutive words of an entity are merged following `Scheme`.
+func (nm *NERModel) Predict(input []string) ([]Entity, error) {
+	tokens, err := nm.tokenClassificationModel.Predict(input, true, false)
+	if err != nil {
+		return nil, err
 	}
+
+	return nm.merge(input, tokens), nil
 }
 
-// Predict extracts entities from input text and returns slice of entities with score
-func (nm *NERModel) Predict(input []string) []Entity {
-	tokens := nm.tokenClassificationModel.Predict(input, true, false)
+// merge groups labeled words into entity spans.
+func (nm *NERModel) merge(input []string, tokens []Token) []Entity {
+	var (
+		entities []Entity
+		span     []Token // words of current entity
+		spanType string
+	)
+
+	flush := func() {
+		if len(span) == 0 {
+			return
+		}
+		first, last := span[0], span[len(span)-1]
+		offset := Offset{first.Offset.Begin, last.Offset.End}
+		var score float64
+		for _, tok := range span {
+			score += tok.Score
+		}
+		entities = append(entities, Entity{
+			Word:     substring(input[first.Sentence], offset),
+			Score:    score / float64(len(span)),
+			Label:    spanType,
+			Offset:   offset,
+			Sentence: first.Sentence,
+		})
+		span = nil
+		spanType = ""
+	}
 
-	var entities []Entity
 	for _, tok := range tokens {
-		if tok.Label != "0" {
-			entities = append(entities, Entity{
-				Word:  tok.Text,
-				Score: tok.Score,
-				Label: tok.Label,
-			})
+		if len(span) > 0 && tok.Sentence != span[0].Sentence {
+			flush()
+		}
+		if nm.ignore(tok.Label) {
+			flush()
+			continue
+		}
+
+		prefix, entityType := splitLabel(tok.Label)
+		continues := len(span) > 0 && entityType == spanType
+		switch prefix {
+		case "B":
+			flush()
+			span = append(span, tok)
+			spanType = entityType
+		case "E":
+			if !continues {
+				flush()
+				spanType = entityType
+			}
+			span = append(span, tok)
+			if nm.Scheme == BIOES {
+				flush()
+			}
+		case "S":
+			flush()
+			span = append(span, tok)
+			spanType = entityType
+			if nm.Scheme == BIOES {
+				flush()
+			}
+		default:
+			// "I" or label without prefix continues an entity of the same type.
+			if !continues {
+				flush()
+				spanType = entityType
+			}
+			span = append(span, tok)
 		}
 	}
+	flush()
 
 	return entities
 }
+
+// ignore reports whether `label` is outside entities.
+func (nm *NERModel) ignore(label string) bool {
+	for _, l := range nm.IgnoreLabels {
+		if label == l {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLabel splits a label into tagging prefix and entity type (e.g. "B-LOC" into "B" and "LOC").
+// Prefix is empty for labels without one.
+func splitLabel(label string) (string, string) {
+	if len(label) > 2 && (label[1] == '-' || label[1] == '_') {
+		prefix := strings.ToUpper(label[:1])
+		switch prefix {
+		case "B", "I", "E", "S":
+			return prefix, label[2:]
+		}
+	}
+	return "", label
+}
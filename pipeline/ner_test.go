@@ -0,0 +1,178 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sugarme/transformer/pipeline"
+)
+
+func TestSplitLabel(t *testing.T) {
+	tests := []struct {
+		label      string
+		wantPrefix string
+		wantType   string
+	}{
+		{"B-LOC", "B", "LOC"},
+		{"I-PER", "I", "PER"},
+		{"E-ORG", "E", "ORG"},
+		{"S-MISC", "S", "MISC"},
+		{"b_loc", "B", "loc"},
+		{"O", "", "O"},
+		{"LOC", "", "LOC"},
+		{"X-LOC", "", "X-LOC"},
+		{"B-", "", "B-"},
+	}
+
+	for _, tt := range tests {
+		prefix, entityType := pipeline.SplitLabel(tt.label)
+		if prefix != tt.wantPrefix || entityType != tt.wantType {
+			t.Errorf("Want: %q %q\n", tt.wantPrefix, tt.wantType)
+			t.Errorf("Got: %q %q\n", prefix, entityType)
+		}
+	}
+}
+
+// labelWords labels space separated words of sentences with `labels` and `scores`.
+func labelWords(sentences []string, labels [][]string, scores [][]float64) []pipeline.Token {
+	var tokens []pipeline.Token
+	for i, sentence := range sentences {
+		begin := 0
+		for j, word := range strings.Split(sentence, " ") {
+			tokens = append(tokens, pipeline.Token{
+				Text:      word,
+				Score:     scores[i][j],
+				Label:     labels[i][j],
+				Sentence:  i,
+				Index:     j + 1,
+				WordIndex: j,
+				Offset:    pipeline.Offset{Begin: begin, End: begin + len(word)},
+			})
+			begin += len(word) + 1
+		}
+	}
+	return tokens
+}
+
+func ones(n int) []float64 {
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	return scores
+}
+
+func TestNERModel_Merge(t *testing.T) {
+	sentence := "John Smith lives in New York City"
+
+	tests := []struct {
+		name         string
+		scheme       pipeline.TaggingScheme
+		ignoreLabels []string
+		sentences    []string
+		labels       [][]string
+		scores       [][]float64
+		want         []pipeline.Entity
+	}{
+		{
+			name:      "BIO",
+			scheme:    pipeline.BIO,
+			sentences: []string{sentence},
+			labels:    [][]string{{"B-PER", "I-PER", "O", "O", "B-LOC", "I-LOC", "I-LOC"}},
+			scores:    [][]float64{{0.5, 1, 1, 1, 1, 1, 1}},
+			want: []pipeline.Entity{
+				{Word: "John Smith", Score: 0.75, Label: "PER", Offset: pipeline.Offset{Begin: 0, End: 10}},
+				{Word: "New York City", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 20, End: 33}},
+			},
+		},
+		{
+			// "E-" and "S-" do not end entities under BIO.
+			name:      "E- and S- under BIO",
+			scheme:    pipeline.BIO,
+			sentences: []string{sentence},
+			labels:    [][]string{{"S-PER", "I-PER", "O", "O", "B-LOC", "E-LOC", "I-LOC"}},
+			scores:    [][]float64{ones(7)},
+			want: []pipeline.Entity{
+				{Word: "John Smith", Score: 1, Label: "PER", Offset: pipeline.Offset{Begin: 0, End: 10}},
+				{Word: "New York City", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 20, End: 33}},
+			},
+		},
+		{
+			name:      "E- and S- under BIOES",
+			scheme:    pipeline.BIOES,
+			sentences: []string{sentence},
+			labels:    [][]string{{"S-PER", "I-PER", "O", "O", "B-LOC", "E-LOC", "I-LOC"}},
+			scores:    [][]float64{ones(7)},
+			want: []pipeline.Entity{
+				{Word: "John", Score: 1, Label: "PER", Offset: pipeline.Offset{Begin: 0, End: 4}},
+				{Word: "Smith", Score: 1, Label: "PER", Offset: pipeline.Offset{Begin: 5, End: 10}},
+				{Word: "New York", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 20, End: 28}},
+				{Word: "City", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 29, End: 33}},
+			},
+		},
+		{
+			name:      "IOB1",
+			scheme:    pipeline.IOB1,
+			sentences: []string{sentence},
+			labels:    [][]string{{"I-PER", "B-PER", "O", "O", "I-LOC", "I-LOC", "I-LOC"}},
+			scores:    [][]float64{ones(7)},
+			want: []pipeline.Entity{
+				{Word: "John", Score: 1, Label: "PER", Offset: pipeline.Offset{Begin: 0, End: 4}},
+				{Word: "Smith", Score: 1, Label: "PER", Offset: pipeline.Offset{Begin: 5, End: 10}},
+				{Word: "New York City", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 20, End: 33}},
+			},
+		},
+		{
+			name:      "type change inside span",
+			scheme:    pipeline.BIO,
+			sentences: []string{sentence},
+			labels:    [][]string{{"B-PER", "I-PER", "O", "O", "B-ORG", "I-LOC", "I-LOC"}},
+			scores:    [][]float64{ones(7)},
+			want: []pipeline.Entity{
+				{Word: "John Smith", Score: 1, Label: "PER", Offset: pipeline.Offset{Begin: 0, End: 10}},
+				{Word: "New", Score: 1, Label: "ORG", Offset: pipeline.Offset{Begin: 20, End: 23}},
+				{Word: "York City", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 24, End: 33}},
+			},
+		},
+		{
+			name:      "sentence boundary",
+			scheme:    pipeline.BIO,
+			sentences: []string{"She moved to Paris", "London is rainy"},
+			labels:    [][]string{{"O", "O", "O", "B-LOC"}, {"I-LOC", "O", "O"}},
+			scores:    [][]float64{ones(4), ones(3)},
+			want: []pipeline.Entity{
+				{Word: "Paris", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 13, End: 18}, Sentence: 0},
+				{Word: "London", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 0, End: 6}, Sentence: 1},
+			},
+		},
+		{
+			name:         "custom IgnoreLabels",
+			scheme:       pipeline.BIO,
+			ignoreLabels: []string{"NONE", "B-MISC"},
+			sentences:    []string{sentence},
+			labels:       [][]string{{"PER", "PER", "NONE", "B-MISC", "LOC", "LOC", "O"}},
+			scores:       [][]float64{ones(7)},
+			want: []pipeline.Entity{
+				{Word: "John Smith", Score: 1, Label: "PER", Offset: pipeline.Offset{Begin: 0, End: 10}},
+				{Word: "New York", Score: 1, Label: "LOC", Offset: pipeline.Offset{Begin: 20, End: 28}},
+				{Word: "City", Score: 1, Label: "O", Offset: pipeline.Offset{Begin: 29, End: 33}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		nm := pipeline.NewNERModel(nil)
+		nm.Scheme = tt.scheme
+		if tt.ignoreLabels != nil {
+			nm.IgnoreLabels = tt.ignoreLabels
+		}
+
+		got := nm.Merge(tt.sentences, labelWords(tt.sentences, tt.labels, tt.scores))
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%v\n", tt.name)
+			t.Errorf("Want: %v\n", tt.want)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+}
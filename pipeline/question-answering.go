@@ -0,0 +1,376 @@
+package pipeline
+
+// Extractive question answering pipeline
+// Extracts answers to questions from their contexts with BERT or RoBERTa question answering models
+// (e.g. "deepset/roberta-base-squad2"). Long contexts are split into overlapping windows.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+// QAInput is a question with the context holding its answer.
+type QAInput struct {
+	Question string
+	Context  string
+}
+
+// Answer is an answer extracted from a context.
+type Answer struct {
+	// Answer text, empty for "no answer"
+	Text string
+	// Probability of answer span
+	Score float64
+	// Character offsets of answer in context, zero for "no answer"
+	Offset Offset
+}
+
+// QuestionAnsweringModel extracts answers to questions with a question answering model.
+type QuestionAnsweringModel struct {
+	forward   func(inputIds, mask, tokenTypeIds *ts.Tensor) (startLogits, endLogits *ts.Tensor, err error)
+	tokenizer *tokenizer.Tokenizer
+	isRoberta bool
+	clsId     int64
+	sepId     int64
+	padId     int64
+	maxLength int64 // maximum sequence length supported by model
+	device    gotch.Device
+
+	// MaxSeqLength is the maximum length of (question, context window) sequences. Default is 384.
+	MaxSeqLength int64
+	// DocStride is the number of tokens shared by consecutive context windows. Default is 128.
+	DocStride int64
+	// MaxQuestionLength is the maximum number of question tokens. Default is 64.
+	MaxQuestionLength int64
+	// MaxAnswerLength is the maximum number of answer tokens. Default is 15.
+	MaxAnswerLength int64
+	// AllowNoAnswer enables SQuAD2 style "no answer" predictions.
+	AllowNoAnswer bool
+	// NoAnswerThreshold: "no answer" is predicted when its score exceeds best answer
+	// score by more than threshold. Default is 0.
+	NoAnswerThreshold float64
+}
+
+// NewQuestionAnsweringModel loads a question answering model and its tokenizer from model name or path.
+// Model type is read from configuration file (see `transformer.ReadModelInfo`). BERT and
+// RoBERTa models are supported.
+func NewQuestionAnsweringModel(modelNameOrPath string, device gotch.Device) (*QuestionAnsweringModel, error) {
+	model, tk, err := autoModel(pretrained.QuestionAnswering, modelNameOrPath, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewQuestionAnsweringModelFrom(model, tk, device)
+}
+
+// NewQuestionAnsweringModelFrom creates a QuestionAnsweringModel from a loaded model and tokenizer.
+// Supported models are `*bert.BertForQuestionAnswering` and `*roberta.RobertaForQuestionAnswering`.
+func NewQuestionAnsweringModelFrom(model pretrained.Model, tk *tokenizer.Tokenizer, device gotch.Device) (*QuestionAnsweringModel, error) {
+	qa := &QuestionAnsweringModel{
+		tokenizer:         tk,
+		device:            device,
+		MaxSeqLength:      384,
+		DocStride:         128,
+		MaxQuestionLength: 64,
+		MaxAnswerLength:   15,
+	}
+
+	switch m := model.(type) {
+	case *bert.BertForQuestionAnswering:
+		qa.forward = func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, *ts.Tensor, error) {
+			startLogits, endLogits, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, tokenTypeIds, ts.None, ts.None, false)
+			if err != nil {
+				return nil, nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return startLogits, endLogits, nil
+		}
+		qa.maxLength = m.Config().MaxPositionEmbeddings
+	case *roberta.RobertaForQuestionAnswering:
+		qa.forward = func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, *ts.Tensor, error) {
+			startLogits, endLogits, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, ts.None, ts.None, ts.None, false)
+			if err != nil {
+				return nil, nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return startLogits, endLogits, nil
+		}
+		qa.isRoberta = true
+		// RoBERTa position ids start after padding index (see `roberta.PositionIds`).
+		qa.maxLength = m.Config().MaxPositionEmbeddings - 2
+	default:
+		return nil, fmt.Errorf("Unsupported question answering model type %T", model)
+	}
+
+	ids := make([]int64, 3)
+	for i, tokens := range [][]string{{"[CLS]", "<s>"}, {"[SEP]", "</s>"}, {"[PAD]", "<pad>"}} {
+		id, ok := tokenId(tk, tokens...)
+		if !ok {
+			return nil, fmt.Errorf("Cannot find token %v in tokenizer vocab", tokens)
+		}
+		ids[i] = int64(id)
+	}
+	qa.clsId, qa.sepId, qa.padId = ids[0], ids[1], ids[2]
+
+	return qa, nil
+}
+
+// window is a (question, context window) sequence.
+type window struct {
+	ids          []int64
+	tokenTypeIds []int64
+	contextStart int // position of first context token in sequence
+	tokenStart   int // index of first context token in context encoding
+	length       int // number of context tokens
+}
+
+// Predict returns at most `topK` answers for every input, best first. It returns an error
+// wrapping `util.ErrInvalidInput` if a question leaves no room for context in sequences of
+// `MaxSeqLength` tokens.
+func (qa *QuestionAnsweringModel) Predict(inputs []QAInput, topK int) ([][]Answer, error) {
+	if topK < 1 {
+		topK = 1
+	}
+
+	var answers [][]Answer
+	for _, input := range inputs {
+		question, err := qa.tokenizer.EncodeSingle(input.Question, false)
+		if err != nil {
+			return nil, err
+		}
+		context, err := qa.tokenizer.EncodeSingle(input.Context, false)
+		if err != nil {
+			return nil, err
+		}
+
+		windows, err := qa.windows(question.Ids, context.Ids)
+		if err != nil {
+			return nil, err
+		}
+		startLogits, endLogits, err := qa.logits(windows)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			candidates []Answer
+			noAnswer   = math.Inf(1)
+		)
+		for i, w := range windows {
+			start, end, null := qa.probs(w, startLogits[i], endLogits[i])
+			noAnswer = math.Min(noAnswer, null)
+			for _, span := range bestSpans(start, end, w.length, int(qa.MaxAnswerLength), topK) {
+				offset := Offset{
+					charOffset(input.Context, context.Offsets[w.tokenStart+span.start]).Begin,
+					charOffset(input.Context, context.Offsets[w.tokenStart+span.end]).End,
+				}
+				candidates = addAnswer(candidates, Answer{
+					Text:   substring(input.Context, offset),
+					Score:  span.score,
+					Offset: offset,
+				})
+			}
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Score > candidates[j].Score
+		})
+		if qa.AllowNoAnswer && (len(candidates) == 0 || noAnswer-candidates[0].Score > qa.NoAnswerThreshold) {
+			candidates = append([]Answer{{Score: noAnswer}}, candidates...)
+		}
+		if len(candidates) > topK {
+			candidates = candidates[:topK]
+		}
+		answers = append(answers, candidates)
+	}
+
+	return answers, nil
+}
+
+// windows splits context into overlapping windows, each following the question.
+func (qa *QuestionAnsweringModel) windows(questionIds, contextIds []int) ([]window, error) {
+	if int64(len(questionIds)) > qa.MaxQuestionLength {
+		questionIds = questionIds[:qa.MaxQuestionLength]
+	}
+
+	maxSeqLength := qa.MaxSeqLength
+	if maxSeqLength > qa.maxLength {
+		maxSeqLength = qa.maxLength
+	}
+
+	// [CLS] question [SEP] context [SEP] for BERT, <s> question </s></s> context </s> for RoBERTa
+	var prefix, typeIds []int64
+	prefix = append(prefix, qa.clsId)
+	for _, id := range questionIds {
+		prefix = append(prefix, int64(id))
+	}
+	prefix = append(prefix, qa.sepId)
+	if qa.isRoberta {
+		prefix = append(prefix, qa.sepId)
+	}
+	for range prefix {
+		typeIds = append(typeIds, 0)
+	}
+
+	maxContext := int(maxSeqLength) - len(prefix) - 1
+	if maxContext < 1 {
+		return nil, fmt.Errorf("Question of %v tokens leaves no room for context in sequences of %v tokens: %w", len(questionIds), maxSeqLength, util.ErrInvalidInput)
+	}
+	stride := maxContext - int(qa.DocStride)
+	if stride < 1 {
+		stride = 1
+	}
+
+	var windows []window
+	for start := 0; ; start += stride {
+		end := start + maxContext
+		if end > len(contextIds) {
+			end = len(contextIds)
+		}
+
+		w := window{
+			ids:          append([]int64{}, prefix...),
+			tokenTypeIds: append([]int64{}, typeIds...),
+			contextStart: len(prefix),
+			tokenStart:   start,
+			length:       end - start,
+		}
+		for _, id := range contextIds[start:end] {
+			w.ids = append(w.ids, int64(id))
+			w.tokenTypeIds = append(w.tokenTypeIds, 1)
+		}
+		w.ids = append(w.ids, qa.sepId)
+		w.tokenTypeIds = append(w.tokenTypeIds, 1)
+		windows = append(windows, w)
+
+		if end == len(contextIds) {
+			break
+		}
+	}
+
+	return windows, nil
+}
+
+// logits forwards windows and returns their start and end logits.
+func (qa *QuestionAnsweringModel) logits(windows []window) ([][]float64, [][]float64, error) {
+	var maxLen int
+	for _, w := range windows {
+		if len(w.ids) > maxLen {
+			maxLen = len(w.ids)
+		}
+	}
+
+	var inputIds, mask, tokenTypeIds []int64
+	for _, w := range windows {
+		for j := 0; j < maxLen; j++ {
+			if j < len(w.ids) {
+				inputIds = append(inputIds, w.ids[j])
+				mask = append(mask, 1)
+				tokenTypeIds = append(tokenTypeIds, w.tokenTypeIds[j])
+			} else {
+				inputIds = append(inputIds, qa.padId)
+				mask = append(mask, 0)
+				tokenTypeIds = append(tokenTypeIds, 0)
+			}
+		}
+	}
+
+	size := []int64{int64(len(windows)), int64(maxLen)}
+	inputTs := ts.MustOfSlice(inputIds).MustView(size, true).MustTo(qa.device, true)
+	maskTs := ts.MustOfSlice(mask).MustView(size, true).MustTo(qa.device, true)
+	typeTs := ts.MustOfSlice(tokenTypeIds).MustView(size, true).MustTo(qa.device, true)
+
+	var (
+		startTs, endTs *ts.Tensor
+		err            error
+	)
+	ts.NoGrad(func() {
+		startTs, endTs, err = qa.forward(inputTs, maskTs, typeTs)
+	})
+	inputTs.MustDrop()
+	maskTs.MustDrop()
+	typeTs.MustDrop()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	split := func(x *ts.Tensor) [][]float64 {
+		values := x.Float64Values(true)
+		rows := make([][]float64, len(windows))
+		for i := range rows {
+			rows[i] = values[i*maxLen : (i+1)*maxLen]
+		}
+		return rows
+	}
+
+	return split(startTs), split(endTs), nil
+}
+
+// probs returns start and end probabilities of context tokens of window `w`, and
+// probability of "no answer" span ([CLS], [CLS]). Probabilities are normalized over
+// context tokens and [CLS].
+func (qa *QuestionAnsweringModel) probs(w window, startLogits, endLogits []float64) (start, end []float64, null float64) {
+	normalize := func(logits []float64) ([]float64, float64) {
+		values := append([]float64{logits[0]}, logits[w.contextStart:w.contextStart+w.length]...)
+		probs := softmax(values)
+		return probs[1:], probs[0]
+	}
+
+	start, startNull := normalize(startLogits)
+	end, endNull := normalize(endLogits)
+
+	return start, end, startNull * endNull
+}
+
+// answerSpan is a candidate answer of context tokens [start, end] of a window.
+type answerSpan struct {
+	start, end int
+	score      float64
+}
+
+// bestSpans returns at most `n` spans of at most `maxLength` tokens with highest
+// `start[i] * end[j]` scores, best first.
+func bestSpans(start, end []float64, length, maxLength, n int) []answerSpan {
+	var spans []answerSpan
+	for i := 0; i < length; i++ {
+		for j := i; j < length && j < i+maxLength; j++ {
+			spans = append(spans, answerSpan{i, j, start[i] * end[j]})
+		}
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].score > spans[j].score
+	})
+	if len(spans) > n {
+		spans = spans[:n]
+	}
+
+	return spans
+}
+
+// addAnswer adds `answer` to `answers`, keeping the best score of answers found in
+// several windows.
+func addAnswer(answers []Answer, answer Answer) []Answer {
+	for i := range answers {
+		if answers[i].Offset == answer.Offset {
+			if answer.Score > answers[i].Score {
+				answers[i].Score = answer.Score
+			}
+			return answers
+		}
+	}
+
+	return append(answers, answer)
+}
@@ -0,0 +1,81 @@
+package pipeline_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pipeline"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+func TestQuestionAnsweringModel(t *testing.T) {
+	// Config
+	config := new(bert.BertConfig)
+	configFile, err := util.CachedPath("roberta-base", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = config.Load(configFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Model
+	device := gotch.CPU
+	vs := nn.NewVarStore(device)
+	model, err := roberta.NewRobertaForQuestionAnswering(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roberta tokenizer
+	tk := roberta.NewTokenizer()
+	err = tk.Load("roberta-base", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qa, err := pipeline.NewQuestionAnsweringModelFrom(model, tk.Tokenizer, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Small windows to split context.
+	qa.MaxSeqLength = 16
+	qa.DocStride = 4
+	qa.MaxAnswerLength = 3
+
+	context := "Amy lives in Amsterdam. She works as a nurse in a hospital near the central station."
+	input := []pipeline.QAInput{{Question: "Where does Amy live?", Context: context}}
+	answers, err := qa.Predict(input, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(answers) != 1 || len(answers[0]) != 5 {
+		t.Errorf("Want: 1 input with 5 answers\n")
+		t.Errorf("Got: %v\n", answers)
+	}
+
+	for i, answer := range answers[0] {
+		if answer.Text != context[answer.Offset.Begin:answer.Offset.End] {
+			t.Errorf("Want: %q\n", context[answer.Offset.Begin:answer.Offset.End])
+			t.Errorf("Got: %q\n", answer.Text)
+		}
+		if i > 0 && answer.Score > answers[0][i-1].Score {
+			t.Errorf("Want: answers sorted by score\n")
+			t.Errorf("Got: %v\n", answers[0])
+		}
+	}
+
+	// Question leaves no room for context.
+	qa.MaxSeqLength = 6
+	if _, err := qa.Predict(input, 5); !errors.Is(err, util.ErrInvalidInput) {
+		t.Errorf("Want: %v\n", util.ErrInvalidInput)
+		t.Errorf("Got: %v\n", err)
+	}
+}
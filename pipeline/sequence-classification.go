@@ -0,0 +1,362 @@
+package pipeline
+
+// Sequence classification pipeline
+// Classifies texts or text pairs (e.g. sentiment analysis, natural language inference) with BERT or
+// RoBERTa sequence classification models. Models trained for multi-label classification use
+// sigmoid scores with per-label thresholds.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/roberta"
+)
+
+// Label is a predicted label of a text.
+type Label struct {
+	Text  string  // label (e.g. POSITIVE)
+	Id    int64   // label index
+	Score float64 // label probability
+}
+
+// TextPair is a pair of texts classified together (e.g. premise and hypothesis).
+type TextPair struct {
+	Text string
+	Pair string
+}
+
+// SequenceClassificationModel classifies texts with a sequence classification model.
+type SequenceClassificationModel struct {
+	forward      func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, error)
+	tokenizer    *tokenizer.Tokenizer
+	labelMapping map[int64]string
+	padId        int64
+	device       gotch.Device
+
+	// MultiLabel scores labels independently with sigmoid instead of softmax.
+	MultiLabel bool
+	// Threshold is the minimum score of labels returned in multi-label mode. Default is 0.5.
+	Threshold float64
+	// Thresholds overrides `Threshold` for some labels in multi-label mode.
+	Thresholds map[string]float64
+	// MaxSeqLength truncates inputs to at most this number of tokens, keeping the closing
+	// special token. Text pairs are truncated by removing tokens at the end of the first text
+	// only. Default is the maximum input length of the model.
+	MaxSeqLength int
+}
+
+// NewSequenceClassificationModel loads a sequence classification model and its tokenizer from model name or path.
+// Model type is read from configuration file (see `transformer.ReadModelInfo`). BERT and
+// RoBERTa models are supported.
+func NewSequenceClassificationModel(modelNameOrPath string, device gotch.Device) (*SequenceClassificationModel, error) {
+	model, tk, err := autoModel(pretrained.SequenceClassification, modelNameOrPath, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSequenceClassificationModelFrom(model, tk, device)
+}
+
+// NewSequenceClassificationModelFrom creates a SequenceClassificationModel from a loaded model and tokenizer.
+// Supported models are `*bert.BertForSequenceClassification` and `*roberta.RobertaForSequenceClassification`.
+// Labels are taken from `Id2Label` of model configuration.
+func NewSequenceClassificationModelFrom(model pretrained.Model, tk *tokenizer.Tokenizer, device gotch.Device) (*SequenceClassificationModel, error) {
+	sc := &SequenceClassificationModel{
+		tokenizer: tk,
+		device:    device,
+		Threshold: 0.5,
+	}
+
+	switch m := model.(type) {
+	case *bert.BertForSequenceClassification:
+		sc.forward = func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, error) {
+			output, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, tokenTypeIds, ts.None, ts.None, false)
+			if err != nil {
+				return nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return output, nil
+		}
+		sc.labelMapping = m.Config().Id2Label
+		sc.MaxSeqLength = maxInputLength(m.Config(), false)
+	case *roberta.RobertaForSequenceClassification:
+		sc.forward = func(inputIds, mask, tokenTypeIds *ts.Tensor) (*ts.Tensor, error) {
+			output, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, ts.None, ts.None, ts.None, false)
+			if err != nil {
+				return nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return output, nil
+		}
+		sc.labelMapping = m.Config().Id2Label
+		sc.MaxSeqLength = maxInputLength(m.Config(), true)
+	default:
+		return nil, fmt.Errorf("Unsupported sequence classification model type %T", model)
+	}
+
+	padId, ok := tokenId(tk, "[PAD]", "<pad>")
+	if !ok {
+		padId = 0
+	}
+	sc.padId = int64(padId)
+
+	return sc, nil
+}
+
+// Predict classifies input texts.
+//
+// It returns the best label of every text, or in multi-label mode all labels scoring
+// above their threshold, best first.
+func (sc *SequenceClassificationModel) Predict(texts []string) ([][]Label, error) {
+	encodings, err := sc.encode(texts)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.classify(encodings)
+}
+
+// PredictPairs classifies pairs of texts. Pairs are encoded as a single sequence, with
+// token type ids distinguishing texts for BERT models.
+func (sc *SequenceClassificationModel) PredictPairs(pairs []TextPair) ([][]Label, error) {
+	encodings, err := sc.encodePairs(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.classify(encodings)
+}
+
+// Scores returns scores of all labels of input texts, in label index order.
+func (sc *SequenceClassificationModel) Scores(texts []string) ([][]Label, error) {
+	encodings, err := sc.encode(texts)
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.scores(encodings)
+}
+
+func (sc *SequenceClassificationModel) encode(texts []string) ([]tokenizer.Encoding, error) {
+	var encodings []tokenizer.Encoding
+	for _, text := range texts {
+		encoding, err := sc.tokenizer.EncodeSingle(text, true)
+		if err != nil {
+			return nil, err
+		}
+		encodings = append(encodings, truncate(*encoding, sc.MaxSeqLength))
+	}
+
+	return encodings, nil
+}
+
+func (sc *SequenceClassificationModel) encodePairs(pairs []TextPair) ([]tokenizer.Encoding, error) {
+	var encodings []tokenizer.Encoding
+	for _, pair := range pairs {
+		encoding, err := sc.tokenizer.EncodePair(pair.Text, pair.Pair, true)
+		if err != nil {
+			return nil, err
+		}
+		encodings = append(encodings, truncatePair(*encoding, sc.MaxSeqLength))
+	}
+
+	return encodings, nil
+}
+
+// truncatePair truncates pair `encoding` to at most `maxLen` tokens by removing tokens at the
+// end of the first sequence, keeping the second sequence and special tokens ("only first"
+// truncation). If the first sequence is too short, it falls back to `truncate`.
+func truncatePair(encoding tokenizer.Encoding, maxLen int) tokenizer.Encoding {
+	n := len(encoding.Ids)
+	if maxLen <= 0 || n <= maxLen {
+		return encoding
+	}
+
+	// First sequence ends at the first special token after the opening one (e.g. [SEP]).
+	end := 1
+	for end < len(encoding.SpecialTokenMask) && encoding.SpecialTokenMask[end] == 0 {
+		end++
+	}
+	start := end - (n - maxLen)
+	if start <= 1 {
+		return truncate(encoding, maxLen)
+	}
+
+	ints := func(xs []int) []int {
+		if len(xs) < end {
+			return xs
+		}
+		return append(append([]int{}, xs[:start]...), xs[end:]...)
+	}
+	encoding.Ids = ints(encoding.Ids)
+	encoding.TypeIds = ints(encoding.TypeIds)
+	encoding.SpecialTokenMask = ints(encoding.SpecialTokenMask)
+	encoding.AttentionMask = ints(encoding.AttentionMask)
+	encoding.Words = ints(encoding.Words)
+	if len(encoding.Tokens) >= end {
+		encoding.Tokens = append(append([]string{}, encoding.Tokens[:start]...), encoding.Tokens[end:]...)
+	}
+	if len(encoding.Offsets) >= end {
+		encoding.Offsets = append(append([][]int{}, encoding.Offsets[:start]...), encoding.Offsets[end:]...)
+	}
+
+	return encoding
+}
+
+func (sc *SequenceClassificationModel) classify(encodings []tokenizer.Encoding) ([][]Label, error) {
+	scores, err := sc.scores(encodings)
+	if err != nil {
+		return nil, err
+	}
+
+	var retVal [][]Label
+	for _, labels := range scores {
+		sort.SliceStable(labels, func(i, j int) bool {
+			return labels[i].Score > labels[j].Score
+		})
+
+		if !sc.MultiLabel {
+			retVal = append(retVal, labels[:1])
+			continue
+		}
+
+		var selected []Label
+		for _, label := range labels {
+			threshold, ok := sc.Thresholds[label.Text]
+			if !ok {
+				threshold = sc.Threshold
+			}
+			if label.Score >= threshold {
+				selected = append(selected, label)
+			}
+		}
+		retVal = append(retVal, selected)
+	}
+
+	return retVal, nil
+}
+
+// scores forwards encodings and returns scores of all labels, in label index order.
+func (sc *SequenceClassificationModel) scores(encodings []tokenizer.Encoding) ([][]Label, error) {
+	allLogits, err := sc.logits(encodings)
+	if err != nil {
+		return nil, err
+	}
+
+	var retVal [][]Label
+	for _, logits := range allLogits {
+		var probs []float64
+		if sc.MultiLabel {
+			probs = sigmoid(logits)
+		} else {
+			probs = softmax(logits)
+		}
+
+		var labels []Label
+		for id, score := range probs {
+			text, ok := sc.labelMapping[int64(id)]
+			if !ok {
+				text = fmt.Sprintf("LABEL_%v", id)
+			}
+			labels = append(labels, Label{Text: text, Id: int64(id), Score: score})
+		}
+		retVal = append(retVal, labels)
+	}
+
+	return retVal, nil
+}
+
+// logits forwards encodings and returns their label logits.
+func (sc *SequenceClassificationModel) logits(encodings []tokenizer.Encoding) ([][]float64, error) {
+	if len(encodings) == 0 {
+		return nil, nil
+	}
+
+	var maxLen int
+	for _, encoding := range encodings {
+		if len(encoding.Ids) > maxLen {
+			maxLen = len(encoding.Ids)
+		}
+	}
+
+	var inputIds, mask, tokenTypeIds []int64
+	for _, encoding := range encodings {
+		for j := 0; j < maxLen; j++ {
+			if j < len(encoding.Ids) {
+				inputIds = append(inputIds, int64(encoding.Ids[j]))
+				mask = append(mask, 1)
+				tokenTypeIds = append(tokenTypeIds, int64(encoding.TypeIds[j]))
+			} else {
+				inputIds = append(inputIds, sc.padId)
+				mask = append(mask, 0)
+				tokenTypeIds = append(tokenTypeIds, 0)
+			}
+		}
+	}
+
+	size := []int64{int64(len(encodings)), int64(maxLen)}
+	inputTs := ts.MustOfSlice(inputIds).MustView(size, true).MustTo(sc.device, true)
+	maskTs := ts.MustOfSlice(mask).MustView(size, true).MustTo(sc.device, true)
+	typeTs := ts.MustOfSlice(tokenTypeIds).MustView(size, true).MustTo(sc.device, true)
+
+	var (
+		logits *ts.Tensor
+		err    error
+	)
+	ts.NoGrad(func() {
+		logits, err = sc.forward(inputTs, maskTs, typeTs)
+	})
+	inputTs.MustDrop()
+	maskTs.MustDrop()
+	typeTs.MustDrop()
+	if err != nil {
+		return nil, err
+	}
+
+	numLabels := int(logits.MustSize()[1])
+	values := logits.Float64Values(true)
+
+	retVal := make([][]float64, len(encodings))
+	for i := range retVal {
+		retVal[i] = values[i*numLabels : (i+1)*numLabels]
+	}
+
+	return retVal, nil
+}
+
+func softmax(logits []float64) []float64 {
+	max := math.Inf(-1)
+	for _, v := range logits {
+		max = math.Max(max, v)
+	}
+
+	var sum float64
+	probs := make([]float64, len(logits))
+	for i, v := range logits {
+		probs[i] = math.Exp(v - max)
+		sum += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= sum
+	}
+
+	return probs
+}
+
+func sigmoid(logits []float64) []float64 {
+	probs := make([]float64, len(logits))
+	for i, v := range logits {
+		probs[i] = 1 / (1 + math.Exp(-v))
+	}
+
+	return probs
+}
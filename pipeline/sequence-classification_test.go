@@ -0,0 +1,157 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pipeline"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+func TestTruncatePair(t *testing.T) {
+	// [CLS] a long premise about paris [SEP] paris is big [SEP]
+	encoding := tokenizer.Encoding{
+		Ids:              []int{101, 1037, 2146, 18458, 2055, 3000, 102, 3000, 2003, 2502, 102},
+		TypeIds:          []int{0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1},
+		Tokens:           []string{"[CLS]", "a", "long", "premise", "about", "paris", "[SEP]", "paris", "is", "big", "[SEP]"},
+		Offsets:          [][]int{{0, 0}, {0, 1}, {2, 6}, {7, 14}, {15, 20}, {21, 26}, {0, 0}, {0, 5}, {6, 8}, {9, 12}, {0, 0}},
+		SpecialTokenMask: []int{1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1},
+		AttentionMask:    []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		Words:            []int{-1, 0, 1, 2, 3, 4, -1, 0, 1, 2, -1},
+	}
+
+	// Premise is longer than maximum length: only the premise is truncated.
+	want := tokenizer.Encoding{
+		Ids:              []int{101, 1037, 2146, 102, 3000, 2003, 2502, 102},
+		TypeIds:          []int{0, 0, 0, 0, 1, 1, 1, 1},
+		Tokens:           []string{"[CLS]", "a", "long", "[SEP]", "paris", "is", "big", "[SEP]"},
+		Offsets:          [][]int{{0, 0}, {0, 1}, {2, 6}, {0, 0}, {0, 5}, {6, 8}, {9, 12}, {0, 0}},
+		SpecialTokenMask: []int{1, 0, 0, 1, 0, 0, 0, 1},
+		AttentionMask:    []int{1, 1, 1, 1, 1, 1, 1, 1},
+		Words:            []int{-1, 0, 1, -1, 0, 1, 2, -1},
+	}
+	got := pipeline.TruncatePair(encoding, 8)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Input is not modified.
+	if len(encoding.Ids) != 11 || encoding.Ids[3] != 18458 {
+		t.Errorf("Want: %v\n", []int{101, 1037, 2146, 18458, 2055, 3000, 102, 3000, 2003, 2502, 102})
+		t.Errorf("Got: %v\n", encoding.Ids)
+	}
+
+	// Premise too short: falls back to keeping the closing special token.
+	want = pipeline.Truncate(encoding, 4)
+	got = pipeline.TruncatePair(encoding, 4)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// No truncation
+	for _, maxLen := range []int{0, 11, 20} {
+		got := pipeline.TruncatePair(encoding, maxLen)
+		if !reflect.DeepEqual(encoding, got) {
+			t.Errorf("Want: %v\n", encoding)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+}
+
+func TestSequenceClassificationModel(t *testing.T) {
+	// Config
+	config := new(bert.BertConfig)
+	configFile, err := util.CachedPath("roberta-base", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = config.Load(configFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dummyLabelMap map[int64]string = make(map[int64]string)
+	dummyLabelMap[0] = "Positive"
+	dummyLabelMap[1] = "Negative"
+	dummyLabelMap[2] = "Neutral"
+	config.Id2Label = dummyLabelMap
+
+	// Model
+	device := gotch.CPU
+	vs := nn.NewVarStore(device)
+	model, err := roberta.NewRobertaForSequenceClassification(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roberta tokenizer
+	tk := roberta.NewTokenizer()
+	err = tk.Load("roberta-base", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc, err := pipeline.NewSequenceClassificationModelFrom(model, tk.Tokenizer, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	texts := []string{"Looks like one thing is missing", "It's like comparing oranges to apples"}
+
+	predict := func(texts []string) [][]pipeline.Label {
+		labels, err := sc.Predict(texts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return labels
+	}
+
+	// Single label
+	var gotLabels []int
+	for _, labels := range predict(texts) {
+		gotLabels = append(gotLabels, len(labels))
+	}
+	pairLabels, err := sc.PredictPairs([]pipeline.TextPair{{Text: texts[0], Pair: texts[1]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, labels := range pairLabels {
+		gotLabels = append(gotLabels, len(labels))
+	}
+
+	// Multi-label: all labels pass zero thresholds.
+	sc.MultiLabel = true
+	sc.Threshold = 0
+	for _, labels := range predict(texts) {
+		gotLabels = append(gotLabels, len(labels))
+	}
+	// Only "Neutral" passes.
+	sc.Threshold = 1.1
+	sc.Thresholds = map[string]float64{"Neutral": 0}
+	var gotTexts []string
+	for _, labels := range predict(texts) {
+		for _, label := range labels {
+			gotTexts = append(gotTexts, label.Text)
+		}
+	}
+
+	wantLabels := []int{1, 1, 1, 3, 3}
+	if !reflect.DeepEqual(wantLabels, gotLabels) {
+		t.Errorf("Want: %v\n", wantLabels)
+		t.Errorf("Got: %v\n", gotLabels)
+	}
+
+	wantTexts := []string{"Neutral", "Neutral"}
+	if !reflect.DeepEqual(wantTexts, gotTexts) {
+		t.Errorf("Want: %v\n", wantTexts)
+		t.Errorf("Got: %v\n", gotTexts)
+	}
+}
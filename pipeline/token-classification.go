@@ -4,8 +4,341 @@ package pipeline
 // More generic token classification pipeline, works with multiple models (Bert, Roberta).
 
 import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
 
-// "github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/roberta"
 )
 
-type TokenClassificationModel struct{}
+// LabelAggregation is a strategy to label a word from the labels of its sub-tokens.
+type LabelAggregation int
+
+const (
+	// First uses label of the first sub-token.
+	First LabelAggregation = iota
+	// Average uses label with highest probability averaged over sub-tokens.
+	Average
+	// Max uses label of the sub-token with highest score.
+	Max
+)
+
+// Offset is a span of characters [Begin, End) of input text.
+type Offset struct {
+	Begin int
+	End   int
+}
+
+// Token holds label of a token, or of a word when sub-tokens are consolidated.
+type Token struct {
+	// Text of token in input, or token string for special tokens
+	Text string
+	// Probability of label
+	Score float64
+	// Label (e.g. B-LOC) and its index
+	Label      string
+	LabelIndex int64
+	// Index of input sentence
+	Sentence int
+	// Index of (first) token in sentence encoding
+	Index int
+	// Index of word in sentence, -1 for special tokens
+	WordIndex int
+	// Character offsets in input sentence. Zero for special tokens.
+	Offset Offset
+	// Whether token is a special token (e.g. [CLS])
+	Special bool
+}
+
+// TokenClassificationModel labels tokens of input texts with a token classification model.
+type TokenClassificationModel struct {
+	forward      func(inputIds, mask *ts.Tensor) (*ts.Tensor, error)
+	tokenizer    *TokenizerOption
+	labelMapping map[int64]string
+	padId        int64
+	device       gotch.Device
+
+	// Aggregation labels words made of several sub-tokens. Default is `First`.
+	Aggregation LabelAggregation
+	// MaxSeqLength truncates inputs to at most this number of tokens, keeping the closing
+	// special token. Tokens beyond are not labeled. Default is the maximum input length of the model.
+	MaxSeqLength int
+}
+
+// NewTokenClassificationModel creates a TokenClassificationModel from a loaded model, its
+// configuration (holding label mapping) and tokenizer. Supported models are
+// `*bert.BertForTokenClassification` and `*roberta.RobertaForTokenClassification`.
+func NewTokenClassificationModel(model pretrained.Model, config *ConfigOption, tk *TokenizerOption, device gotch.Device) (*TokenClassificationModel, error) {
+	var (
+		forward      func(inputIds, mask *ts.Tensor) (*ts.Tensor, error)
+		maxSeqLength int
+	)
+	switch m := model.(type) {
+	case *bert.BertForTokenClassification:
+		maxSeqLength = maxInputLength(m.Config(), false)
+		forward = func(inputIds, mask *ts.Tensor) (*ts.Tensor, error) {
+			output, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, ts.None, ts.None, ts.None, false)
+			if err != nil {
+				return nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return output, nil
+		}
+	case *roberta.RobertaForTokenClassification:
+		maxSeqLength = maxInputLength(m.Config(), true)
+		forward = func(inputIds, mask *ts.Tensor) (*ts.Tensor, error) {
+			output, hiddenStates, attentions, err := m.ForwardT(inputIds, mask, ts.None, ts.None, ts.None, false)
+			if err != nil {
+				return nil, err
+			}
+			dropAll(hiddenStates)
+			dropAll(attentions)
+			return output, nil
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported token classification model type %T", model)
+	}
+
+	padId, ok := tokenId(tk.tokenizer, "[PAD]", "<pad>")
+	if !ok {
+		padId = 0
+	}
+
+	labelMapping, err := config.GetLabelMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenClassificationModel{
+		forward:      forward,
+		tokenizer:    tk,
+		labelMapping: labelMapping,
+		padId:        int64(padId),
+		device:       device,
+		Aggregation:  First,
+		MaxSeqLength: maxSeqLength,
+	}, nil
+}
+
+// Predict labels tokens of input sentences.
+//
+// Params:
+//   - `input`: input sentences
+//   - `consolidateSubTokens`: whether to label words instead of sub-tokens, using `Aggregation` strategy
+//   - `returnSpecial`: whether to return special tokens (e.g. [CLS], [SEP])
+func (tm *TokenClassificationModel) Predict(input []string, consolidateSubTokens bool, returnSpecial bool) ([]Token, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+
+	encodings, err := tm.tokenizer.EncodeList(input)
+	if err != nil {
+		return nil, err
+	}
+	for i := range encodings {
+		encodings[i] = truncate(encodings[i], tm.MaxSeqLength)
+	}
+
+	var maxLen int
+	for _, encoding := range encodings {
+		if len(encoding.Ids) > maxLen {
+			maxLen = len(encoding.Ids)
+		}
+	}
+
+	var inputIds, mask []int64
+	for _, encoding := range encodings {
+		for j := 0; j < maxLen; j++ {
+			switch {
+			case j >= len(encoding.Ids):
+				inputIds = append(inputIds, tm.padId)
+				mask = append(mask, 0)
+			case len(encoding.AttentionMask) > j:
+				inputIds = append(inputIds, int64(encoding.Ids[j]))
+				mask = append(mask, int64(encoding.AttentionMask[j]))
+			default:
+				inputIds = append(inputIds, int64(encoding.Ids[j]))
+				mask = append(mask, 1)
+			}
+		}
+	}
+
+	size := []int64{int64(len(input)), int64(maxLen)}
+	inputTs := ts.MustOfSlice(inputIds).MustView(size, true).MustTo(tm.device, true)
+	maskTs := ts.MustOfSlice(mask).MustView(size, true).MustTo(tm.device, true)
+
+	var logits *ts.Tensor
+	ts.NoGrad(func() {
+		logits, err = tm.forward(inputTs, maskTs)
+	})
+	inputTs.MustDrop()
+	maskTs.MustDrop()
+	if err != nil {
+		return nil, err
+	}
+
+	numLabels := int(logits.MustSize()[2])
+	probs := logits.MustSoftmax(-1, gotch.Double, true).Float64Values(true)
+
+	var tokens []Token
+	for i, encoding := range encodings {
+		var (
+			sentenceTokens []Token
+			tokenProbs     [][]float64
+		)
+		for j := range encoding.Ids {
+			if mask[i*maxLen+j] == 0 {
+				continue
+			}
+			special := encoding.SpecialTokenMask[j] == 1
+			if special && !returnSpecial {
+				continue
+			}
+
+			token := Token{
+				Sentence:  i,
+				Index:     j,
+				WordIndex: -1,
+				Special:   special,
+			}
+			if special {
+				token.Text = encoding.Tokens[j]
+			} else {
+				token.Offset = charOffset(input[i], encoding.Offsets[j])
+				token.Text = substring(input[i], token.Offset)
+				token.WordIndex = j
+				if len(encoding.Words) > j {
+					token.WordIndex = encoding.Words[j]
+				}
+			}
+
+			start := (i*maxLen + j) * numLabels
+			sentenceTokens = append(sentenceTokens, token)
+			tokenProbs = append(tokenProbs, probs[start:start+numLabels])
+		}
+
+		if consolidateSubTokens {
+			sentenceTokens = tm.consolidate(input[i], sentenceTokens, tokenProbs)
+		} else {
+			for k := range sentenceTokens {
+				tm.label(&sentenceTokens[k], tokenProbs[k:k+1], First)
+			}
+		}
+
+		tokens = append(tokens, sentenceTokens...)
+	}
+
+	return tokens, nil
+}
+
+// consolidate merges consecutive sub-tokens of a word into a single token labeled with
+// `Aggregation` strategy. `probs` are label probabilities of every token.
+func (tm *TokenClassificationModel) consolidate(sentence string, tokens []Token, probs [][]float64) []Token {
+	var words []Token
+	for start := 0; start < len(tokens); {
+		end := start + 1
+		if !tokens[start].Special {
+			for end < len(tokens) && !tokens[end].Special && tokens[end].WordIndex == tokens[start].WordIndex {
+				end++
+			}
+		}
+
+		word := tokens[start]
+		if end-start > 1 {
+			word.Offset = Offset{tokens[start].Offset.Begin, tokens[end-1].Offset.End}
+			word.Text = substring(sentence, word.Offset)
+		}
+		tm.label(&word, probs[start:end], tm.Aggregation)
+		words = append(words, word)
+
+		start = end
+	}
+
+	return words
+}
+
+// label sets label of `token` from label probabilities of its sub-tokens.
+func (tm *TokenClassificationModel) label(token *Token, probs [][]float64, aggregation LabelAggregation) {
+	var labelIndex int64
+	var score float64
+	switch aggregation {
+	case Average:
+		average := make([]float64, len(probs[0]))
+		for _, p := range probs {
+			for k := range p {
+				average[k] += p[k] / float64(len(probs))
+			}
+		}
+		labelIndex, score = argmax(average)
+	case Max:
+		score = -1
+		for _, p := range probs {
+			if idx, s := argmax(p); s > score {
+				labelIndex, score = idx, s
+			}
+		}
+	default:
+		labelIndex, score = argmax(probs[0])
+	}
+
+	token.LabelIndex = labelIndex
+	token.Score = score
+	label, ok := tm.labelMapping[labelIndex]
+	if !ok {
+		label = fmt.Sprintf("LABEL_%v", labelIndex)
+	}
+	token.Label = label
+}
+
+func argmax(values []float64) (int64, float64) {
+	var idx int64
+	for i, v := range values {
+		if v > values[idx] {
+			idx = int64(i)
+		}
+	}
+	return idx, values[idx]
+}
+
+// charOffset converts byte offsets of a token in `text` (as returned by `tokenizer.Encode`)
+// to character offsets, trimming whitespace that byte-level tokens (e.g. "Ġlike") carry.
+func charOffset(text string, offsets []int) Offset {
+	toChar := func(b int) int {
+		if b > len(text) {
+			b = len(text)
+		}
+		if b < 0 {
+			b = 0
+		}
+		return utf8.RuneCountInString(text[:b])
+	}
+
+	runes := []rune(text)
+	offset := Offset{toChar(offsets[0]), toChar(offsets[1])}
+	for offset.Begin < offset.End && unicode.IsSpace(runes[offset.Begin]) {
+		offset.Begin++
+	}
+	for offset.End > offset.Begin && unicode.IsSpace(runes[offset.End-1]) {
+		offset.End--
+	}
+
+	return offset
+}
+
+// substring returns characters of `text` in `offset`.
+func substring(text string, offset Offset) string {
+	runes := []rune(text)
+	if offset.End > len(runes) {
+		offset.End = len(runes)
+	}
+	if offset.Begin > offset.End {
+		return ""
+	}
+	return string(runes[offset.Begin:offset.End])
+}
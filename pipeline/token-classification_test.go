@@ -0,0 +1,103 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pipeline"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+func TestTokenClassificationModel(t *testing.T) {
+	// Config
+	config := new(bert.BertConfig)
+	configFile, err := util.CachedPath("roberta-base", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = config.Load(configFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dummyLabelMap map[int64]string = make(map[int64]string)
+	dummyLabelMap[0] = "O"
+	dummyLabelMap[1] = "LOC"
+	dummyLabelMap[2] = "PER"
+	dummyLabelMap[3] = "ORG"
+	config.Id2Label = dummyLabelMap
+
+	// Model
+	device := gotch.CPU
+	vs := nn.NewVarStore(device)
+	model, err := roberta.NewRobertaForTokenClassification(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roberta tokenizer
+	tk := roberta.NewTokenizer()
+	err = tk.Load("roberta-base", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcModel, err := pipeline.NewTokenClassificationModel(model, pipeline.NewRobertaConfigOption(*config), pipeline.NewTokenizerOption(pipeline.Roberta, tk.Tokenizer), device)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentence := "Looks like one thing is missing"
+	tokens, err := tcModel.Predict([]string{sentence}, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		gotTexts   []string
+		gotOffsets []bool
+		wantTexts  []string = []string{"Looks", "like", "one", "thing", "is", "missing"}
+	)
+	for _, tok := range tokens {
+		gotTexts = append(gotTexts, tok.Text)
+		_, ok := dummyLabelMap[tok.LabelIndex]
+		gotOffsets = append(gotOffsets, ok && sentence[tok.Offset.Begin:tok.Offset.End] == tok.Text)
+	}
+
+	if !reflect.DeepEqual(wantTexts, gotTexts) {
+		t.Errorf("Want: %v\n", wantTexts)
+		t.Errorf("Got: %v\n", gotTexts)
+	}
+
+	wantOffsets := []bool{true, true, true, true, true, true}
+	if !reflect.DeepEqual(wantOffsets, gotOffsets) {
+		t.Errorf("Want: %v\n", wantOffsets)
+		t.Errorf("Got: %v\n", gotOffsets)
+	}
+}
+
+func TestCharOffset(t *testing.T) {
+	text := "Zoë lives in Zürich"
+	tests := []struct {
+		offsets []int
+		want    pipeline.Offset
+	}{
+		{[]int{0, 4}, pipeline.Offset{Begin: 0, End: 3}},     // "Zoë"
+		{[]int{4, 10}, pipeline.Offset{Begin: 4, End: 9}},    // " lives"
+		{[]int{13, 21}, pipeline.Offset{Begin: 13, End: 19}}, // " Zürich"
+		{[]int{15, 17}, pipeline.Offset{Begin: 14, End: 15}}, // "ü"
+	}
+
+	for _, tt := range tests {
+		got := pipeline.CharOffset(text, tt.offsets)
+		if got != tt.want {
+			t.Errorf("Want: %v\n", tt.want)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+}
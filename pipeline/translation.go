@@ -0,0 +1,158 @@
+package pipeline
+
+// Translation pipeline
+// Translates texts with MarianMT models (e.g. `Helsinki-NLP/opus-mt-en-de`) using beam search
+// of `generation` package. Multilingual models expect source texts to be prefixed with a target
+// language code (e.g. ">>fra<< Hello world").
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/generation"
+	"github.com/sugarme/transformer/marian"
+	"github.com/sugarme/transformer/util"
+)
+
+// TranslationModel translates texts with a MarianMT model.
+type TranslationModel struct {
+	model     *marian.MarianMTModel
+	tokenizer *marian.Tokenizer
+	device    gotch.Device
+
+	// Generation parameters. Defaults are taken from model configuration: beam search,
+	// pad token and bad words banned, end of sequence as stop token.
+	GenerationConfig *generation.Config
+}
+
+// NewTranslationModel loads a MarianMT model and its tokenizer from model name or path.
+func NewTranslationModel(modelNameOrPath string, device gotch.Device) (*TranslationModel, error) {
+	config := new(marian.MarianConfig)
+	if err := transformer.LoadConfig(config, modelNameOrPath, nil); err != nil {
+		return nil, err
+	}
+
+	model := new(marian.MarianMTModel)
+	if _, err := transformer.LoadModel(model, modelNameOrPath, config, nil, device); err != nil {
+		return nil, err
+	}
+
+	tk := marian.NewTokenizer()
+	if err := transformer.LoadTokenizer(tk, modelNameOrPath, nil); err != nil {
+		return nil, err
+	}
+
+	return NewTranslationModelFrom(model, tk, device), nil
+}
+
+// NewTranslationModelFrom creates a TranslationModel from a loaded model and tokenizer.
+func NewTranslationModelFrom(model *marian.MarianMTModel, tk *marian.Tokenizer, device gotch.Device) *TranslationModel {
+	config := model.Config()
+
+	generationConfig := generation.DefaultConfig()
+	// Target length includes decoder start token.
+	maxLength := config.MaxLength
+	if maxLength > config.MaxPositionEmbeddings {
+		maxLength = config.MaxPositionEmbeddings
+	}
+	generationConfig.MaxLength = maxLength - 1
+	if config.NumBeams > 1 {
+		generationConfig.NumBeams = config.NumBeams
+	}
+	generationConfig.StopTokenIds = []int64{config.EosTokenId}
+	generationConfig.PadTokenId = config.PadTokenId
+	generationConfig.BadWordsIds = append([][]int64{{config.PadTokenId}}, config.BadWordsIds...)
+
+	return &TranslationModel{
+		model:            model,
+		tokenizer:        tk,
+		device:           device,
+		GenerationConfig: generationConfig,
+	}
+}
+
+// Translate translates input texts. It returns an error wrapping `util.ErrInvalidInput`
+// if a text is longer than the maximum input length of the model.
+func (tm *TranslationModel) Translate(texts []string) ([]string, error) {
+	sequences, err := tm.generate(texts)
+	if err != nil {
+		return nil, err
+	}
+
+	var translations []string
+	for _, sequence := range sequences {
+		var ids []int
+		for _, id := range sequence[0].Ids {
+			ids = append(ids, int(id))
+		}
+		translations = append(translations, tm.tokenizer.Target.Decode(ids, true))
+	}
+
+	return translations, nil
+}
+
+// generate encodes texts and generates their translations.
+func (tm *TranslationModel) generate(texts []string) ([][]generation.Sequence, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	config := tm.model.Config()
+
+	var encodings [][]int64
+	var maxLen int
+	for _, text := range texts {
+		encoding, err := tm.tokenizer.EncodeSingle(text, true)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(encoding.Ids)) > config.MaxPositionEmbeddings {
+			return nil, fmt.Errorf("Input text has %v tokens, maximum is %v: %w", len(encoding.Ids), config.MaxPositionEmbeddings, util.ErrInvalidInput)
+		}
+
+		var ids []int64
+		for _, id := range encoding.Ids {
+			ids = append(ids, int64(id))
+		}
+		encodings = append(encodings, ids)
+		if len(ids) > maxLen {
+			maxLen = len(ids)
+		}
+	}
+
+	var inputIds, mask []int64
+	var prompts [][]int64
+	for _, ids := range encodings {
+		for i := 0; i < maxLen; i++ {
+			if i < len(ids) {
+				inputIds = append(inputIds, ids[i])
+				mask = append(mask, 1)
+			} else {
+				inputIds = append(inputIds, config.PadTokenId)
+				mask = append(mask, 0)
+			}
+		}
+		prompts = append(prompts, []int64{config.DecoderStartTokenId})
+	}
+
+	size := []int64{int64(len(texts)), int64(maxLen)}
+	inputTs := ts.MustOfSlice(inputIds).MustView(size, true).MustTo(tm.device, true)
+	maskTs := ts.MustOfSlice(mask).MustView(size, true).MustTo(tm.device, true)
+	defer maskTs.MustDrop()
+
+	var encoderOutput *ts.Tensor
+	var err error
+	ts.NoGrad(func() {
+		encoderOutput, err = tm.model.Encode(inputTs, maskTs, false)
+	})
+	inputTs.MustDrop()
+	if err != nil {
+		return nil, err
+	}
+	defer encoderOutput.MustDrop()
+
+	return generation.Generate(marian.NewGenerator(tm.model, encoderOutput, maskTs), prompts, tm.GenerationConfig, tm.device)
+}
@@ -0,0 +1,28 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+
+	"github.com/sugarme/transformer/pipeline"
+)
+
+func TestTranslationModel(t *testing.T) {
+	tm, err := pipeline.NewTranslationModel("Helsinki-NLP/opus-mt-en-de", gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tm.Translate([]string{"My name is Wolfgang and I live in Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Mein Name ist Wolfgang und ich lebe in Berlin."}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+// Zero-shot classification pipeline
+// Classifies texts into candidate labels given at prediction time with a natural language inference
+// (NLI) model (e.g. "roberta-large-mnli"). Every text is paired as premise with a hypothesis built
+// from each candidate label, and labels are scored with the entailment logits.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/tokenizer"
+
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// ZeroShotClassificationModel classifies texts into arbitrary candidate labels with a NLI model.
+type ZeroShotClassificationModel struct {
+	model         *SequenceClassificationModel
+	entailment    int64
+	contradiction int64 // -1 if model has no contradiction label
+
+	// HypothesisTemplate builds hypotheses from candidate labels, replacing "{}" with the label.
+	// Default is "This example is {}.".
+	HypothesisTemplate string
+}
+
+// NewZeroShotClassificationModel loads a NLI model and its tokenizer from model name or path.
+func NewZeroShotClassificationModel(modelNameOrPath string, device gotch.Device) (*ZeroShotClassificationModel, error) {
+	model, err := NewSequenceClassificationModel(modelNameOrPath, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return newZeroShotClassificationModel(model)
+}
+
+// NewZeroShotClassificationModelFrom creates a ZeroShotClassificationModel from a loaded NLI model and tokenizer.
+// Supported models are `*bert.BertForSequenceClassification` and `*roberta.RobertaForSequenceClassification`
+// whose labels include "entailment" and, preferably, "contradiction".
+func NewZeroShotClassificationModelFrom(model pretrained.Model, tk *tokenizer.Tokenizer, device gotch.Device) (*ZeroShotClassificationModel, error) {
+	sc, err := NewSequenceClassificationModelFrom(model, tk, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return newZeroShotClassificationModel(sc)
+}
+
+func newZeroShotClassificationModel(model *SequenceClassificationModel) (*ZeroShotClassificationModel, error) {
+	zs := &ZeroShotClassificationModel{
+		model:              model,
+		entailment:         -1,
+		contradiction:      -1,
+		HypothesisTemplate: "This example is {}.",
+	}
+
+	for id, label := range model.labelMapping {
+		label = strings.ToLower(label)
+		switch {
+		case strings.HasPrefix(label, "entail"):
+			zs.entailment = id
+		case strings.HasPrefix(label, "contradict"):
+			zs.contradiction = id
+		}
+	}
+	if zs.entailment == -1 {
+		return nil, fmt.Errorf("Cannot find entailment label in model labels %v", model.labelMapping)
+	}
+
+	return zs, nil
+}
+
+// Predict scores `candidateLabels` of every input text and returns them best first.
+//
+// In single-label mode, scores are a softmax of entailment logits over candidate labels
+// and sum to 1. In multi-label mode, every label is scored independently with a softmax
+// of its entailment and contradiction logits.
+func (zs *ZeroShotClassificationModel) Predict(texts []string, candidateLabels []string, multiLabel bool) ([][]Label, error) {
+	if len(candidateLabels) == 0 {
+		return make([][]Label, len(texts)), nil
+	}
+
+	var pairs []TextPair
+	for _, text := range texts {
+		for _, label := range candidateLabels {
+			pairs = append(pairs, TextPair{
+				Text: text,
+				Pair: strings.Replace(zs.HypothesisTemplate, "{}", label, 1),
+			})
+		}
+	}
+	encodings, err := zs.model.encodePairs(pairs)
+	if err != nil {
+		return nil, err
+	}
+	logits, err := zs.model.logits(encodings)
+	if err != nil {
+		return nil, err
+	}
+
+	var retVal [][]Label
+	for i := range texts {
+		var entailment []float64
+		scores := make([]float64, len(candidateLabels))
+		for j := range candidateLabels {
+			nli := logits[i*len(candidateLabels)+j]
+			entailment = append(entailment, nli[zs.entailment])
+			if !multiLabel {
+				continue
+			}
+			if zs.contradiction == -1 {
+				scores[j] = softmax(nli)[zs.entailment]
+			} else {
+				scores[j] = softmax([]float64{nli[zs.contradiction], nli[zs.entailment]})[1]
+			}
+		}
+		if !multiLabel {
+			scores = softmax(entailment)
+		}
+
+		labels := make([]Label, len(candidateLabels))
+		for j, label := range candidateLabels {
+			labels[j] = Label{Text: label, Id: int64(j), Score: scores[j]}
+		}
+		sort.SliceStable(labels, func(i, j int) bool {
+			return labels[i].Score > labels[j].Score
+		})
+		retVal = append(retVal, labels)
+	}
+
+	return retVal, nil
+}
@@ -0,0 +1,88 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pipeline"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+func TestZeroShotClassificationModel(t *testing.T) {
+	// Config
+	config := new(bert.BertConfig)
+	configFile, err := util.CachedPath("roberta-base", "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = config.Load(configFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dummyLabelMap map[int64]string = make(map[int64]string)
+	dummyLabelMap[0] = "CONTRADICTION"
+	dummyLabelMap[1] = "NEUTRAL"
+	dummyLabelMap[2] = "ENTAILMENT"
+	config.Id2Label = dummyLabelMap
+
+	// Model
+	device := gotch.CPU
+	vs := nn.NewVarStore(device)
+	model, err := roberta.NewRobertaForSequenceClassification(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roberta tokenizer
+	tk := roberta.NewTokenizer()
+	err = tk.Load("roberta-base", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zs, err := pipeline.NewZeroShotClassificationModelFrom(model, tk.Tokenizer, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	texts := []string{"Who are you voting for in 2020?"}
+	candidateLabels := []string{"politics", "public health", "economics"}
+
+	var (
+		gotLengths []int
+		gotSums    []bool
+	)
+	for _, multiLabel := range []bool{false, true} {
+		predictions, err := zs.Predict(texts, candidateLabels, multiLabel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		labels := predictions[0]
+		gotLengths = append(gotLengths, len(labels))
+
+		var sum float64
+		for _, label := range labels {
+			sum += label.Score
+		}
+		gotSums = append(gotSums, sum > 0.999 && sum < 1.001)
+	}
+
+	wantLengths := []int{3, 3}
+	if !reflect.DeepEqual(wantLengths, gotLengths) {
+		t.Errorf("Want: %v\n", wantLengths)
+		t.Errorf("Got: %v\n", gotLengths)
+	}
+
+	// Only single-label scores sum to 1.
+	wantSums := []bool{true, false}
+	if !reflect.DeepEqual(wantSums, gotSums) {
+		t.Errorf("Want: %v\n", wantSums)
+		t.Errorf("Got: %v\n", gotSums)
+	}
+}
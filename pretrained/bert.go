@@ -1,6 +1,9 @@
 package pretrained
 
 // BertConfigs is a map of pretrained Bert configuration names to corresponding URLs.
+//
+// Deprecated: these legacy URLs are not used to resolve files. Use model names of
+// Hugging Face hub, or list models in a `util.Manifest` for `util.ManifestResolver`.
 var BertConfigs map[string]string = map[string]string{
 	"bert-base-uncased": "https://s3.amazonaws.com/models.huggingface.co/bert/bert-base-uncased-config.json",
 	"bert-ner":          "https://cdn.huggingface.co/dbmdz/bert-large-cased-finetuned-conll03-english/config.json",
@@ -16,6 +19,8 @@ var BertConfigs map[string]string = map[string]string{
 }
 
 // BertModels is a map of pretrained Bert model names to corresponding URLs.
+//
+// Deprecated: unused legacy URLs, see `BertConfigs`.
 var BertModels map[string]string = map[string]string{
 	"bert-base-uncased": "https://cdn.huggingface.co/bert-base-uncased-rust_model.ot",
 	"bert-ner":          "https://cdn.huggingface.co/dbmdz/bert-large-cased-finetuned-conll03-english/rust_model.ot",
@@ -23,6 +28,8 @@ var BertModels map[string]string = map[string]string{
 }
 
 // BertVocabs is a map of BERT model vocab name to corresponding URLs.
+//
+// Deprecated: unused legacy URLs, see `BertConfigs`.
 var BertVocabs map[string]string = map[string]string{
 	"bert-base-uncased": "https://s3.amazonaws.com/models.huggingface.co/bert/bert-base-uncased-vocab.txt",
 	"bert-ner":          "https://cdn.huggingface.co/dbmdz/bert-large-cased-finetuned-conll03-english/vocab.txt",
@@ -1,8 +1,10 @@
 package pretrained
 
 // Config is an interface for pretrained model configuration.
-// It has only one method `Load(string) error` to load configuration
-// from local or remote file.
+// It has method `Load(string) error` to load configuration
+// from local or remote file and method `SavePretrained(string) error`
+// to save configuration to a local directory.
 type Config interface {
 	Load(modelNamOrPath string, params map[string]interface{}) error
+	SavePretrained(dir string) error
 }
@@ -5,8 +5,11 @@ import (
 )
 
 // Model is an interface for pretrained model.
-// It has only one method `Load(string) error` to load model
-// from local or remote file.
+// It has method `Load` to load model from local or remote file,
+// reporting missing, unexpected and mismatched weights, and method
+// `SavePretrained(string) error` to save model configuration and weights
+// to a local directory.
 type Model interface {
-	Load(modelNamOrPath string, config interface{ Config }, params map[string]interface{}, device gotch.Device) error
+	Load(modelNamOrPath string, config interface{ Config }, params map[string]interface{}, device gotch.Device) (*LoadReport, error)
+	SavePretrained(dir string) error
 }
@@ -0,0 +1,153 @@
+package pretrained
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Task is a model head (e.g. masked language modeling, sequence classification).
+type Task string
+
+const (
+	MaskedLM               Task = "masked-lm"
+	PreTraining            Task = "pretraining"
+	SequenceClassification Task = "sequence-classification"
+	TokenClassification    Task = "token-classification"
+	QuestionAnswering      Task = "question-answering"
+	MultipleChoice         Task = "multiple-choice"
+	CausalLM               Task = "causal-lm"
+	Seq2SeqLM              Task = "seq2seq-lm"
+)
+
+// registry maps model types (`model_type` field of `config.json`) to constructors of
+// their configuration, tokenizer and task models. Model packages register themselves
+// in their `init` function.
+type registry struct {
+	sync.RWMutex
+	configs       map[string]func() Config
+	tokenizers    map[string]func() Tokenizer
+	models        map[string]map[Task]func() Model
+	architectures map[string]architecture
+}
+
+// architecture is a model class name found in `architectures` field of `config.json` (e.g. "BertForMaskedLM").
+type architecture struct {
+	modelType string
+	task      Task
+}
+
+var defaultRegistry = &registry{
+	configs:       make(map[string]func() Config),
+	tokenizers:    make(map[string]func() Tokenizer),
+	models:        make(map[string]map[Task]func() Model),
+	architectures: make(map[string]architecture),
+}
+
+// RegisterConfig registers configuration constructor of `modelType`.
+func RegisterConfig(modelType string, newConfig func() Config) {
+	defaultRegistry.Lock()
+	defer defaultRegistry.Unlock()
+
+	defaultRegistry.configs[modelType] = newConfig
+}
+
+// RegisterTokenizer registers tokenizer constructor of `modelType`.
+func RegisterTokenizer(modelType string, newTokenizer func() Tokenizer) {
+	defaultRegistry.Lock()
+	defer defaultRegistry.Unlock()
+
+	defaultRegistry.tokenizers[modelType] = newTokenizer
+}
+
+// RegisterModel registers constructor of `modelType` model for `task`. `architectureName`
+// is the model class name used in `architectures` field of configuration files
+// (e.g. "BertForMaskedLM"). Constructed models are loaded with their `Load` method.
+func RegisterModel(modelType string, task Task, architectureName string, newModel func() Model) {
+	defaultRegistry.Lock()
+	defer defaultRegistry.Unlock()
+
+	if _, ok := defaultRegistry.models[modelType]; !ok {
+		defaultRegistry.models[modelType] = make(map[Task]func() Model)
+	}
+	defaultRegistry.models[modelType][task] = newModel
+	if architectureName != "" {
+		defaultRegistry.architectures[architectureName] = architecture{modelType, task}
+	}
+}
+
+// NewConfig creates an empty configuration of `modelType`.
+func NewConfig(modelType string) (Config, error) {
+	defaultRegistry.RLock()
+	defer defaultRegistry.RUnlock()
+
+	newConfig, ok := defaultRegistry.configs[modelType]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported model type %q: no registered configuration (registered model types: %v)", modelType, modelTypes())
+	}
+
+	return newConfig(), nil
+}
+
+// NewTokenizer creates an empty tokenizer of `modelType`.
+func NewTokenizer(modelType string) (Tokenizer, error) {
+	defaultRegistry.RLock()
+	defer defaultRegistry.RUnlock()
+
+	newTokenizer, ok := defaultRegistry.tokenizers[modelType]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported model type %q: no registered tokenizer", modelType)
+	}
+
+	return newTokenizer(), nil
+}
+
+// NewModel creates an empty `modelType` model for `task`.
+func NewModel(modelType string, task Task) (Model, error) {
+	defaultRegistry.RLock()
+	defer defaultRegistry.RUnlock()
+
+	models, ok := defaultRegistry.models[modelType]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported model type %q: no registered models (registered model types: %v)", modelType, modelTypes())
+	}
+	newModel, ok := models[task]
+	if !ok {
+		var tasks []string
+		for t := range models {
+			tasks = append(tasks, string(t))
+		}
+		sort.Strings(tasks)
+		return nil, fmt.Errorf("Unsupported task %q for model type %q (supported tasks: %v)", task, modelType, tasks)
+	}
+
+	return newModel(), nil
+}
+
+// LookupArchitecture returns model type and task of a registered architecture name
+// (e.g. "roberta" and `MaskedLM` for "RobertaForMaskedLM").
+func LookupArchitecture(name string) (modelType string, task Task, ok bool) {
+	defaultRegistry.RLock()
+	defer defaultRegistry.RUnlock()
+
+	a, ok := defaultRegistry.architectures[name]
+	return a.modelType, a.task, ok
+}
+
+// ModelTypes returns registered model types, sorted.
+func ModelTypes() []string {
+	defaultRegistry.RLock()
+	defer defaultRegistry.RUnlock()
+
+	return modelTypes()
+}
+
+func modelTypes() []string {
+	var types []string
+	for t := range defaultRegistry.configs {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return types
+}
@@ -0,0 +1,59 @@
+package pretrained
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LoadReport reports how tensors of a weight file matched variables of a model
+// when loading it. It is returned by `Model.Load`.
+type LoadReport struct {
+	// MissingKeys are model variables not found in the weight file.
+	// They are left at their initial (random) values.
+	MissingKeys []string
+
+	// UnexpectedKeys are tensors of the weight file not matching any model variable.
+	// They are not loaded. Pretrained checkpoints often hold weights of other heads
+	// (e.g. pre-training heads) which are unexpected for task models.
+	UnexpectedKeys []string
+
+	// MismatchedKeys are model variables found in the weight file with a different shape,
+	// e.g. a classifier with a different number of labels. They are left at their initial values.
+	MismatchedKeys []ShapeMismatch
+}
+
+// ShapeMismatch is a model variable whose shape differs from the shape of its tensor in a weight file.
+type ShapeMismatch struct {
+	Key        string
+	ModelShape []int64
+	FileShape  []int64
+}
+
+// Complete returns whether all model variables were loaded, i.e. none is missing or mismatched.
+func (r *LoadReport) Complete() bool {
+	return len(r.MissingKeys) == 0 && len(r.MismatchedKeys) == 0
+}
+
+// String implements fmt.Stringer interface. It lists missing, unexpected and mismatched keys.
+func (r *LoadReport) String() string {
+	if r.Complete() && len(r.UnexpectedKeys) == 0 {
+		return "all weights loaded"
+	}
+
+	var lines []string
+	if len(r.MissingKeys) > 0 {
+		lines = append(lines, fmt.Sprintf("missing keys (%v): %v", len(r.MissingKeys), strings.Join(r.MissingKeys, ", ")))
+	}
+	if len(r.UnexpectedKeys) > 0 {
+		lines = append(lines, fmt.Sprintf("unexpected keys (%v): %v", len(r.UnexpectedKeys), strings.Join(r.UnexpectedKeys, ", ")))
+	}
+	if len(r.MismatchedKeys) > 0 {
+		var keys []string
+		for _, m := range r.MismatchedKeys {
+			keys = append(keys, fmt.Sprintf("%v (model: %v, file: %v)", m.Key, m.ModelShape, m.FileShape))
+		}
+		lines = append(lines, fmt.Sprintf("mismatched keys (%v): %v", len(r.MismatchedKeys), strings.Join(keys, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -1,6 +1,8 @@
 package pretrained
 
 // RobertaConfigs is a map of pretrained Roberta configuration names to corresponding URLs.
+//
+// Deprecated: unused legacy URLs, see `BertConfigs`.
 var RobertaConfigs map[string]string = map[string]string{
 	"roberta-base":       "https://cdn.huggingface.co/roberta-base-config.json",
 	"roberta-qa":         "https://s3.amazonaws.com/models.huggingface.co/bert/deepset/roberta-base-squad2/config.json",
@@ -11,6 +13,8 @@ var RobertaConfigs map[string]string = map[string]string{
 }
 
 // RobertaModels is a map of pretrained Roberta model names to corresponding URLs.
+//
+// Deprecated: unused legacy URLs, see `BertConfigs`.
 var RobertaModels map[string]string = map[string]string{
 	"roberta-base":       "https://cdn.huggingface.co/roberta-base-rust_model.ot",
 	"roberta-qa":         "https://cdn.huggingface.co/deepset/roberta-base-squad2/rust_model.ot",
@@ -21,6 +25,8 @@ var RobertaModels map[string]string = map[string]string{
 }
 
 // RobertaVocabs is a map of pretrained Roberta vocab name to corresponding URLs.
+//
+// Deprecated: unused legacy URLs, see `BertConfigs`.
 var RobertaVocabs map[string]string = map[string]string{
 	"roberta-base":       "https://cdn.huggingface.co/roberta-base-vocab.json",
 	"roberta-qa":         "https://cdn.huggingface.co/deepset/roberta-base-squad2/vocab.json",
@@ -31,6 +37,8 @@ var RobertaVocabs map[string]string = map[string]string{
 }
 
 // RobertaMerges is a map of pretrained Roberta vocab merges name to corresponding URLs.
+//
+// Deprecated: unused legacy URLs, see `BertConfigs`.
 var RobertaMerges map[string]string = map[string]string{
 	"roberta-base": "https://cdn.huggingface.co/roberta-base-merges.txt",
 	"roberta-qa":   "https://cdn.huggingface.co/deepset/roberta-base-squad2/merges.txt",
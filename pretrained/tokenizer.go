@@ -1,5 +1,10 @@
 package pretrained
 
+// Tokenizer is an interface for pretrained tokenizer.
+// It has method `Load(string) error` to load vocab files
+// from local or remote file and method `SavePretrained(string) error`
+// to save vocab files to a local directory.
 type Tokenizer interface {
 	Load(modelNamOrPath string, params map[string]interface{}) error
+	SavePretrained(dir string) error
 }
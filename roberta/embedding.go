@@ -22,16 +22,26 @@ type RobertaEmbeddings struct {
 	paddingIndex        int64
 }
 
-func (re *RobertaEmbeddings) createPositionIdsFromInputIds(x *ts.Tensor) *ts.Tensor {
-	mask := x.MustNe(ts.IntScalar(re.paddingIndex), false).MustTotype(gotch.Int64, true)
+// PaddingIdx is the index of `<pad>` token in RoBERTa vocab. Position ids start after it.
+const PaddingIdx int64 = 1
+
+// PositionIds creates RoBERTa position ids from input ids of shape (batch size, sequence length).
+// Padding tokens get position `paddingIdx` and other tokens are numbered from `paddingIdx + 1`.
+func PositionIds(inputIds *ts.Tensor, paddingIdx int64) *ts.Tensor {
+	mask := inputIds.MustNe(ts.IntScalar(paddingIdx), false).MustTotype(gotch.Int64, true)
 	cumSum := mask.MustCumsum(1, gotch.Int64, false)
 	mul := cumSum.MustMul(mask, true)
-	retVal := mul.MustAddScalar(ts.IntScalar(re.paddingIndex), false)
+	retVal := mul.MustAddScalar(ts.IntScalar(paddingIdx), false)
 	mul.MustDrop()
+	mask.MustDrop()
 
 	return retVal
 }
 
+func (re *RobertaEmbeddings) createPositionIdsFromInputIds(x *ts.Tensor) *ts.Tensor {
+	return PositionIds(x, re.paddingIndex)
+}
+
 func (re *RobertaEmbeddings) createPositionIdsFromEmbeddings(x *ts.Tensor) *ts.Tensor {
 	shape := x.MustSize()
 	var inputShape []int64 = []int64{shape[0], shape[1]}
@@ -50,7 +60,7 @@ func (re *RobertaEmbeddings) createPositionIdsFromEmbeddings(x *ts.Tensor) *ts.T
 func NewRobertaEmbeddings(p nn.Path, config *bert.BertConfig) *RobertaEmbeddings {
 
 	embeddingConfig := nn.DefaultEmbeddingConfig()
-	embeddingConfig.PaddingIdx = 1
+	embeddingConfig.PaddingIdx = PaddingIdx
 
 	wordEmbeddings := nn.NewEmbedding(p.Sub("word_embeddings"), config.VocabSize, config.HiddenSize, embeddingConfig)
 	positionEmbeddings := nn.NewEmbedding(p.Sub("position_embeddings"), config.MaxPositionEmbeddings, config.HiddenSize, nn.DefaultEmbeddingConfig())
@@ -67,6 +77,7 @@ func NewRobertaEmbeddings(p nn.Path, config *bert.BertConfig) *RobertaEmbeddings
 		tokenTypeEmbeddings: tokenTypeEmbeddings,
 		layerNorm:           layerNorm,
 		dropout:             dropout,
+		paddingIndex:        PaddingIdx,
 	}
 }
 
@@ -100,13 +111,13 @@ func (re *RobertaEmbeddings) ForwardT(inputIds, tokenTypeIds, positionIds, input
 			inputEmbedsShape := inputEmbeds.MustSize()
 			inputShape = []int64{inputEmbedsShape[0], inputEmbedsShape[1]}
 		} else {
-			err := fmt.Errorf("Only one of input Ids or input embeddings may be set.")
+			err := fmt.Errorf("Only one of input Ids or input embeddings may be set: %w", util.ErrInvalidInput)
 			return ts.None, err
 		}
 	} else {
 		// if inputIds == inputEmbeds
 		if util.Equal(inputIds, inputEmbeds) {
-			err := fmt.Errorf("Only one of input Ids or input embeddings may be set.")
+			err := fmt.Errorf("Only one of input Ids or input embeddings may be set: %w", util.ErrInvalidInput)
 			return ts.None, err
 		} else {
 			inputEmbeddings = inputIds.ApplyT(re.wordEmbeddings, train)
@@ -0,0 +1,29 @@
+package roberta_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/roberta"
+)
+
+func TestPositionIds(t *testing.T) {
+	// <s> ... </s> followed by padding
+	inputIds := ts.MustOfSlice([]int64{
+		0, 31414, 232, 2, 1, 1,
+		0, 232, 2, 1, 1, 1,
+	}).MustView([]int64{2, 6}, true)
+
+	// Positions start after the padding index, padding keeps it.
+	want := []int64{
+		2, 3, 4, 5, 1, 1,
+		2, 3, 4, 1, 1, 1,
+	}
+	got := roberta.PositionIds(inputIds, roberta.PaddingIdx).Int64Values()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
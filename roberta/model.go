@@ -3,11 +3,10 @@ package roberta
 // roberta package implements Roberta transformer model.
 
 import (
-	// "fmt"
+	"fmt"
 
 	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/nn"
-	"github.com/sugarme/gotch/pickle"
 	"github.com/sugarme/gotch/ts"
 
 	"github.com/sugarme/transformer/bert"
@@ -15,6 +14,17 @@ import (
 	"github.com/sugarme/transformer/util"
 )
 
+// forwardBase forwards pass through base model. If `positionIds` is None, RoBERTa position ids
+// are created from `inputIds`, as base model numbers positions from 0. They are dropped by
+// base model embeddings.
+func forwardBase(model *bert.BertModel, inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, encoderHiddenStates, encoderMask *ts.Tensor, train bool) (*ts.Tensor, *ts.Tensor, []ts.Tensor, []ts.Tensor, error) {
+	if !positionIds.MustDefined() && inputIds.MustDefined() {
+		positionIds = PositionIds(inputIds, PaddingIdx)
+	}
+
+	return model.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, encoderHiddenStates, encoderMask, train)
+}
+
 // RobertaLMHead holds data of Roberta LM head.
 type RobertaLMHead struct {
 	dense     *nn.Linear
@@ -72,11 +82,16 @@ func (rh *RobertaLMHead) Forward(hiddenStates *ts.Tensor) *ts.Tensor {
 type RobertaForMaskedLM struct {
 	roberta *bert.BertModel
 	lmHead  *RobertaLMHead
+	config  *bert.BertConfig
+	vs      *nn.VarStore
 }
 
 // NewRobertaForMaskedLM builds a new RobertaForMaskedLM.
 func NewRobertaForMaskedLM(p *nn.Path, config *bert.BertConfig) (*RobertaForMaskedLM, error) {
-	roberta := bert.NewBertModel(p.Sub("roberta"), config, false)
+	roberta, err := bert.NewBertModel(p.Sub("roberta"), config, false)
+	if err != nil {
+		return nil, err
+	}
 	lmHead, err := NewRobertaLMHead(p.Sub("lm_head"), config)
 	if err != nil {
 		return nil, err
@@ -85,40 +100,59 @@ func NewRobertaForMaskedLM(p *nn.Path, config *bert.BertConfig) (*RobertaForMask
 	return &RobertaForMaskedLM{
 		roberta: roberta,
 		lmHead:  lmHead,
+		config:  config,
 	}, nil
 }
 
-// Load loads model from file or model name. It also updates
-// default configuration parameters if provided.
-// This method implements `PretrainedModel` interface.
-func (mlm *RobertaForMaskedLM) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) error {
-	// var urlOrFilename string
-	// // If modelName, infer to default configuration filename:
-	// if modelFile, ok := pretrained.RobertaModels[modelNameOrPath]; ok {
-	// urlOrFilename = modelFile
-	// } else {
-	// // Otherwise, just take the input
-	// urlOrFilename = modelNameOrPath
-	// }
-
-	cachedFile, err := util.CachedPath(modelNameOrPath, "pytorch_model.bin")
-	if err != nil {
-		return err
+// Load loads model from file or model name. It also updates default configuration parameters if provided.
+//
+// This method implements `pretrained.Model` interface.
+func (mlm *RobertaForMaskedLM) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*bert.BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("RobertaForMaskedLM.Load() failed: invalid config type %T, want *bert.BertConfig", config)
 	}
 
 	vs := nn.NewVarStore(device)
-	p := vs.Root()
-
-	mlm.roberta = bert.NewBertModel(p.Sub("roberta"), config.(*bert.BertConfig), false)
-	mlm.lmHead, err = NewRobertaLMHead(p.Sub("lm_head"), config.(*bert.BertConfig))
-
-	// err = vs.Load(cachedFile)
-	err = pickle.LoadAll(vs, cachedFile)
+	model, err := NewRobertaForMaskedLM(vs.Root(), bertConfig)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	*mlm = *model
+	mlm.vs = vs
+
+	opts := util.LoadOptionsFromParams(params, "lm_head")
+	// Safetensors checkpoints store the decoder once, as word embeddings.
+	opts.Tied = map[string]string{
+		"lm_head.decoder.weight": "roberta.embeddings.word_embeddings.weight",
+		"lm_head.bias":           "lm_head.decoder.bias",
 	}
 
-	return nil
+	return util.LoadVarStore(vs, modelNameOrPath, opts, util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+//
+// This method implements `pretrained.Model` interface.
+func (mlm *RobertaForMaskedLM) SavePretrained(dir string) error {
+	return util.SaveModel(dir, mlm.config, mlm.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (mlm *RobertaForMaskedLM) VarStore() *nn.VarStore {
+	return mlm.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (mlm *RobertaForMaskedLM) SetVarStore(vs *nn.VarStore) {
+	mlm.vs = vs
+}
+
+// Config returns model configuration.
+func (mlm *RobertaForMaskedLM) Config() *bert.BertConfig {
+	return mlm.config
 }
 
 // Forwad forwads pass through the model.
@@ -132,7 +166,7 @@ func (mlm *RobertaForMaskedLM) Load(modelNameOrPath string, config interface{ pr
 //     Convention is value of 0 for the first sentence (incl. </s>) and 1 for the
 //     second sentence. If None set to 0.
 //   - `positionIds`: Optional position ids of shape (batch size, sequence length).
-//     If None, will be incremented from 0.
+//     If None, created from input ids with `PositionIds`.
 //   - `inputEmbeds`: Optional pre-computed input embeddings of shape (batch size,
 //     sequence length, hidden size). If None, input ids must be provided (see inputIds).
 //   - `encoderHiddenStates`: Optional encoder hidden state of shape (batch size,
@@ -154,7 +188,7 @@ func (mlm *RobertaForMaskedLM) Load(modelNameOrPath string, config interface{ pr
 //   - `err`: error
 func (mlm *RobertaForMaskedLM) Forward(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, encoderHiddenStates, encoderMask *ts.Tensor, train bool) (output *ts.Tensor, hiddenStates, attentions []ts.Tensor, err error) {
 
-	hiddenState, _, allHiddenStates, allAttentions, err := mlm.roberta.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, encoderHiddenStates, encoderMask, train)
+	hiddenState, _, allHiddenStates, allAttentions, err := forwardBase(mlm.roberta, inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, encoderHiddenStates, encoderMask, train)
 
 	if err != nil {
 		return ts.None, nil, nil, err
@@ -207,56 +241,73 @@ func (ch *RobertaClassificationHead) ForwardT(hiddenStates *ts.Tensor, train boo
 type RobertaForSequenceClassification struct {
 	roberta    *bert.BertModel
 	classifier *RobertaClassificationHead
+	config     *bert.BertConfig
+	vs         *nn.VarStore
 }
 
 // NewRobertaForSequenceClassification creates a new RobertaForSequenceClassification model.
-func NewRobertaForSequenceClassification(p *nn.Path, config *bert.BertConfig) *RobertaForSequenceClassification {
-	roberta := bert.NewBertModel(p.Sub("roberta"), config, false)
+func NewRobertaForSequenceClassification(p *nn.Path, config *bert.BertConfig) (*RobertaForSequenceClassification, error) {
+	roberta, err := bert.NewBertModel(p.Sub("roberta"), config, false)
+	if err != nil {
+		return nil, err
+	}
 	classifier := NewRobertaClassificationHead(p.Sub("classifier"), config)
 
 	return &RobertaForSequenceClassification{
 		roberta:    roberta,
 		classifier: classifier,
-	}
+		config:     config,
+	}, nil
 }
 
 // Load loads model from file or model name. It also updates default configuration parameters if provided.
 //
-// This method implements `PretrainedModel` interface.
-func (sc *RobertaForSequenceClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) error {
-	// var urlOrFilename string
-	// // If modelName, infer to default configuration filename:
-	// if modelFile, ok := pretrained.RobertaModels[modelNameOrPath]; ok {
-	// urlOrFilename = modelFile
-	// } else {
-	// // Otherwise, just take the input
-	// urlOrFilename = modelNameOrPath
-	// }
-
-	cachedFile, err := util.CachedPath(modelNameOrPath, "pytorch_model.bin")
-	if err != nil {
-		return err
+// This method implements `pretrained.Model` interface.
+func (sc *RobertaForSequenceClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*bert.BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("RobertaForSequenceClassification.Load() failed: invalid config type %T, want *bert.BertConfig", config)
 	}
 
 	vs := nn.NewVarStore(device)
-	p := vs.Root()
-
-	sc.roberta = bert.NewBertModel(p.Sub("roberta"), config.(*bert.BertConfig), false)
-	sc.classifier = NewRobertaClassificationHead(p.Sub("classifier"), config.(*bert.BertConfig))
-
-	// err = vs.Load(cachedFile)
-	err = pickle.LoadAll(vs, cachedFile)
+	model, err := NewRobertaForSequenceClassification(vs.Root(), bertConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	*sc = *model
+	sc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
 
-	return nil
+// SavePretrained saves model configuration and weights to directory `dir`.
+//
+// This method implements `pretrained.Model` interface.
+func (sc *RobertaForSequenceClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, sc.config, sc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (sc *RobertaForSequenceClassification) VarStore() *nn.VarStore {
+	return sc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (sc *RobertaForSequenceClassification) SetVarStore(vs *nn.VarStore) {
+	sc.vs = vs
+}
+
+// Config returns model configuration.
+func (sc *RobertaForSequenceClassification) Config() *bert.BertConfig {
+	return sc.config
 }
 
 // Forward forwards pass through the model.
 func (sc *RobertaForSequenceClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (labels *ts.Tensor, hiddenStates, attentions []ts.Tensor, err error) {
 
-	hiddenState, _, hiddenStates, attentions, err := sc.roberta.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
+	hiddenState, _, hiddenStates, attentions, err := forwardBase(sc.roberta, inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
 	if err != nil {
 		return ts.None, nil, nil, err
 	}
@@ -276,11 +327,16 @@ type RobertaForMultipleChoice struct {
 	roberta    *bert.BertModel
 	dropout    *util.Dropout
 	classifier *nn.Linear
+	config     *bert.BertConfig
+	vs         *nn.VarStore
 }
 
 // NewRobertaForMultipleChoice creates a new RobertaForMultipleChoice model.
-func NewRobertaForMultipleChoice(p *nn.Path, config *bert.BertConfig) *RobertaForMultipleChoice {
-	roberta := bert.NewBertModel(p.Sub("roberta"), config, false)
+func NewRobertaForMultipleChoice(p *nn.Path, config *bert.BertConfig) (*RobertaForMultipleChoice, error) {
+	roberta, err := bert.NewBertModel(p.Sub("roberta"), config, false)
+	if err != nil {
+		return nil, err
+	}
 	dropout := util.NewDropout(config.HiddenDropoutProb)
 	classifier := nn.NewLinear(p.Sub("classifier"), config.HiddenSize, 1, nn.DefaultLinearConfig())
 
@@ -288,41 +344,52 @@ func NewRobertaForMultipleChoice(p *nn.Path, config *bert.BertConfig) *RobertaFo
 		roberta:    roberta,
 		dropout:    dropout,
 		classifier: classifier,
-	}
+		config:     config,
+	}, nil
 }
 
 // Load loads model from file or model name. It also updates default configuration parameters if provided.
 //
-// This method implements `PretrainedModel` interface.
-func (mc *RobertaForMultipleChoice) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) error {
-	// var urlOrFilename string
-	// // If modelName, infer to default configuration filename:
-	// if modelFile, ok := pretrained.RobertaModels[modelNameOrPath]; ok {
-	// urlOrFilename = modelFile
-	// } else {
-	// // Otherwise, just take the input
-	// urlOrFilename = modelNameOrPath
-	// }
-
-	cachedFile, err := util.CachedPath(modelNameOrPath, "pytorch_model.bin")
-	if err != nil {
-		return err
+// This method implements `pretrained.Model` interface.
+func (mc *RobertaForMultipleChoice) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*bert.BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("RobertaForMultipleChoice.Load() failed: invalid config type %T, want *bert.BertConfig", config)
 	}
 
 	vs := nn.NewVarStore(device)
-	p := vs.Root()
-
-	mc.roberta = bert.NewBertModel(p.Sub("roberta"), config.(*bert.BertConfig), false)
-	mc.dropout = util.NewDropout(config.(*bert.BertConfig).HiddenDropoutProb)
-	classifier := nn.NewLinear(p.Sub("classifier"), config.(*bert.BertConfig).HiddenSize, 1, nn.DefaultLinearConfig())
-	mc.classifier = classifier
-
-	err = vs.Load(cachedFile)
+	model, err := NewRobertaForMultipleChoice(vs.Root(), bertConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	*mc = *model
+	mc.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+//
+// This method implements `pretrained.Model` interface.
+func (mc *RobertaForMultipleChoice) SavePretrained(dir string) error {
+	return util.SaveModel(dir, mc.config, mc.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (mc *RobertaForMultipleChoice) VarStore() *nn.VarStore {
+	return mc.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (mc *RobertaForMultipleChoice) SetVarStore(vs *nn.VarStore) {
+	mc.vs = vs
+}
 
-	return nil
+// Config returns model configuration.
+func (mc *RobertaForMultipleChoice) Config() *bert.BertConfig {
+	return mc.config
 }
 
 // ForwardT forwards pass through the model.
@@ -347,12 +414,12 @@ func (mc *RobertaForMultipleChoice) ForwardT(inputIds, mask, tokenTypeIds, posit
 
 	flatMask := ts.None
 	if mask.MustDefined() {
-		flatMaskSize := flatMask.MustSize()
+		flatMaskSize := mask.MustSize()
 		flatMask = mask.MustView([]int64{-1, flatMaskSize[len(flatMaskSize)-1]}, false)
 	}
 
 	var pooledOutput *ts.Tensor
-	_, pooledOutput, hiddenStates, attentions, err = mc.roberta.ForwardT(flatInputIds, flatMask, flatTokenTypeIds, flatPositionIds, ts.None, ts.None, ts.None, train)
+	_, pooledOutput, hiddenStates, attentions, err = forwardBase(mc.roberta, flatInputIds, flatMask, flatTokenTypeIds, flatPositionIds, ts.None, ts.None, ts.None, train)
 	if err != nil {
 		return ts.None, nil, nil, err
 	}
@@ -371,11 +438,16 @@ type RobertaForTokenClassification struct {
 	roberta    *bert.BertModel
 	dropout    *util.Dropout
 	classifier *nn.Linear
+	config     *bert.BertConfig
+	vs         *nn.VarStore
 }
 
 // NewRobertaForTokenClassification creates a new RobertaForTokenClassification model.
-func NewRobertaForTokenClassification(p *nn.Path, config *bert.BertConfig) *RobertaForTokenClassification {
-	roberta := bert.NewBertModel(p.Sub("roberta"), config, false)
+func NewRobertaForTokenClassification(p *nn.Path, config *bert.BertConfig) (*RobertaForTokenClassification, error) {
+	roberta, err := bert.NewBertModel(p.Sub("roberta"), config, false)
+	if err != nil {
+		return nil, err
+	}
 	dropout := util.NewDropout(config.HiddenDropoutProb)
 	numLabels := int64(len(config.Id2Label))
 	classifier := nn.NewLinear(p.Sub("classifier"), config.HiddenSize, numLabels, nn.DefaultLinearConfig())
@@ -384,50 +456,57 @@ func NewRobertaForTokenClassification(p *nn.Path, config *bert.BertConfig) *Robe
 		roberta:    roberta,
 		dropout:    dropout,
 		classifier: classifier,
-	}
+		config:     config,
+	}, nil
 }
 
 // Load loads model from file or model name. It also updates default configuration parameters if provided.
 //
-// This method implements `PretrainedModel` interface.
-func (tc *RobertaForTokenClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) error {
-	// var urlOrFilename string
-	// // If modelName, infer to default configuration filename:
-	// if modelFile, ok := pretrained.RobertaModels[modelNameOrPath]; ok {
-	// urlOrFilename = modelFile
-	// } else {
-	// // Otherwise, just take the input
-	// urlOrFilename = modelNameOrPath
-	// }
-
-	cachedFile, err := util.CachedPath(modelNameOrPath, "pytorch_model.bin")
-	if err != nil {
-		return err
+// This method implements `pretrained.Model` interface.
+func (tc *RobertaForTokenClassification) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*bert.BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("RobertaForTokenClassification.Load() failed: invalid config type %T, want *bert.BertConfig", config)
 	}
 
 	vs := nn.NewVarStore(device)
-	p := vs.Root()
+	model, err := NewRobertaForTokenClassification(vs.Root(), bertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*tc = *model
+	tc.vs = vs
 
-	roberta := bert.NewBertModel(p.Sub("roberta"), config.(*bert.BertConfig), false)
-	dropout := util.NewDropout(config.(*bert.BertConfig).HiddenDropoutProb)
-	numLabels := int64(len(config.(*bert.BertConfig).Id2Label))
-	classifier := nn.NewLinear(p.Sub("classifier"), config.(*bert.BertConfig).HiddenSize, numLabels, nn.DefaultLinearConfig())
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "classifier"), util.ProgressFromParams(params))
+}
 
-	tc.roberta = roberta
-	tc.dropout = dropout
-	tc.classifier = classifier
+// SavePretrained saves model configuration and weights to directory `dir`.
+//
+// This method implements `pretrained.Model` interface.
+func (tc *RobertaForTokenClassification) SavePretrained(dir string) error {
+	return util.SaveModel(dir, tc.config, tc.vs)
+}
 
-	err = vs.Load(cachedFile)
-	if err != nil {
-		return err
-	}
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (tc *RobertaForTokenClassification) VarStore() *nn.VarStore {
+	return tc.vs
+}
 
-	return nil
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (tc *RobertaForTokenClassification) SetVarStore(vs *nn.VarStore) {
+	tc.vs = vs
+}
+
+// Config returns model configuration.
+func (tc *RobertaForTokenClassification) Config() *bert.BertConfig {
+	return tc.config
 }
 
 // ForwardT forwards pass through the model.
 func (tc *RobertaForTokenClassification) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (output *ts.Tensor, hiddenStates, attentions []ts.Tensor, err error) {
-	hiddenState, _, hiddenStates, attentions, err := tc.roberta.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
+	hiddenState, _, hiddenStates, attentions, err := forwardBase(tc.roberta, inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
 	if err != nil {
 		return ts.None, nil, nil, err
 	}
@@ -444,59 +523,73 @@ func (tc *RobertaForTokenClassification) ForwardT(inputIds, mask, tokenTypeIds,
 type RobertaForQuestionAnswering struct {
 	roberta   *bert.BertModel
 	qaOutputs *nn.Linear
+	config    *bert.BertConfig
+	vs        *nn.VarStore
 }
 
 // NewRobertaQuestionAnswering creates a new RobertaForQuestionAnswering model.
-func NewRobertaForQuestionAnswering(p *nn.Path, config *bert.BertConfig) *RobertaForQuestionAnswering {
-	roberta := bert.NewBertModel(p.Sub("roberta"), config, false)
+func NewRobertaForQuestionAnswering(p *nn.Path, config *bert.BertConfig) (*RobertaForQuestionAnswering, error) {
+	roberta, err := bert.NewBertModel(p.Sub("roberta"), config, false)
+	if err != nil {
+		return nil, err
+	}
 	numLabels := int64(2)
 	qaOutputs := nn.NewLinear(p.Sub("qa_outputs"), config.HiddenSize, numLabels, nn.DefaultLinearConfig())
 
 	return &RobertaForQuestionAnswering{
 		roberta:   roberta,
 		qaOutputs: qaOutputs,
-	}
+		config:    config,
+	}, nil
 }
 
 // Load loads model from file or model name. It also updates default configuration parameters if provided.
 //
-// This method implements `PretrainedModel` interface.
-func (qa *RobertaForQuestionAnswering) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) error {
-	// var urlOrFilename string
-	// // If modelName, infer to default configuration filename:
-	// if modelFile, ok := pretrained.RobertaModels[modelNameOrPath]; ok {
-	// urlOrFilename = modelFile
-	// } else {
-	// // Otherwise, just take the input
-	// urlOrFilename = modelNameOrPath
-	// }
-
-	cachedFile, err := util.CachedPath(modelNameOrPath, "pytorch_model.bin")
-	if err != nil {
-		return err
+// This method implements `pretrained.Model` interface.
+func (qa *RobertaForQuestionAnswering) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	bertConfig, ok := config.(*bert.BertConfig)
+	if !ok {
+		return nil, fmt.Errorf("RobertaForQuestionAnswering.Load() failed: invalid config type %T, want *bert.BertConfig", config)
 	}
 
 	vs := nn.NewVarStore(device)
-	p := vs.Root()
+	model, err := NewRobertaForQuestionAnswering(vs.Root(), bertConfig)
+	if err != nil {
+		return nil, err
+	}
+	*qa = *model
+	qa.vs = vs
 
-	roberta := bert.NewBertModel(p.Sub("roberta"), config.(*bert.BertConfig), false)
-	numLabels := int64(2)
-	qaOutputs := nn.NewLinear(p.Sub("qa_outputs"), config.(*bert.BertConfig).HiddenSize, numLabels, nn.DefaultLinearConfig())
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "qa_outputs"), util.ProgressFromParams(params))
+}
 
-	qa.roberta = roberta
-	qa.qaOutputs = qaOutputs
+// SavePretrained saves model configuration and weights to directory `dir`.
+//
+// This method implements `pretrained.Model` interface.
+func (qa *RobertaForQuestionAnswering) SavePretrained(dir string) error {
+	return util.SaveModel(dir, qa.config, qa.vs)
+}
 
-	err = vs.Load(cachedFile)
-	if err != nil {
-		return err
-	}
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (qa *RobertaForQuestionAnswering) VarStore() *nn.VarStore {
+	return qa.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (qa *RobertaForQuestionAnswering) SetVarStore(vs *nn.VarStore) {
+	qa.vs = vs
+}
 
-	return nil
+// Config returns model configuration.
+func (qa *RobertaForQuestionAnswering) Config() *bert.BertConfig {
+	return qa.config
 }
 
 // ForwadT forwards pass through the model.
 func (qa *RobertaForQuestionAnswering) ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds *ts.Tensor, train bool) (startScores, endScores *ts.Tensor, hiddenStates, attentions []ts.Tensor, err error) {
-	hiddenState, _, hiddenStates, attentions, err := qa.roberta.ForwardT(inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
+	hiddenState, _, hiddenStates, attentions, err := forwardBase(qa.roberta, inputIds, mask, tokenTypeIds, positionIds, inputEmbeds, ts.None, ts.None, train)
 	if err != nil {
 		return ts.None, ts.None, nil, nil, err
 	}
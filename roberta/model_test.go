@@ -8,12 +8,10 @@ import (
 
 	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/nn"
-	"github.com/sugarme/gotch/pickle"
 	"github.com/sugarme/gotch/ts"
 	"github.com/sugarme/tokenizer"
 	"github.com/sugarme/tokenizer/model/bpe"
 	"github.com/sugarme/tokenizer/normalizer"
-	"github.com/sugarme/tokenizer/pretokenizer"
 	"github.com/sugarme/tokenizer/processor"
 	"github.com/sugarme/transformer/bert"
 	"github.com/sugarme/transformer/roberta"
@@ -32,7 +30,7 @@ func getRobertaTokenizer(vocabFile string, mergeFile string) (retVal *tokenizer.
 	bertNormalizer := normalizer.NewBertNormalizer(true, true, true, true)
 	tk.WithNormalizer(bertNormalizer)
 
-	blPreTokenizer := pretokenizer.NewByteLevel()
+	blPreTokenizer := roberta.NewByteLevel()
 	// blPreTokenizer.SetAddPrefixSpace(false)
 	tk.WithPreTokenizer(blPreTokenizer)
 
@@ -41,7 +39,7 @@ func getRobertaTokenizer(vocabFile string, mergeFile string) (retVal *tokenizer.
 	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<pad>", true))
 	specialTokens = append(specialTokens, tokenizer.NewAddedToken("</s>", true))
 	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<unk>", true))
-	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<mask>", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<mask>", true, tokenizer.WithLStrip(true)))
 	tk.AddSpecialTokens(specialTokens)
 
 	postProcess := processor.DefaultRobertaProcessing()
@@ -77,7 +75,7 @@ func TestRobertaForMaskedLM(t *testing.T) {
 		log.Fatal(err)
 	}
 	// err = vs.Load("../data/roberta/roberta-base-model.gt")
-	err = pickle.LoadAll(vs, modelFile)
+	_, err = util.LoadWeightFile(vs, modelFile, util.LoadOptions{})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -192,7 +190,10 @@ func TestRobertaForSequenceClassification(t *testing.T) {
 	config.Id2Label = dummyLabelMap
 	config.OutputAttentions = true
 	config.OutputHiddenStates = true
-	model := roberta.NewRobertaForSequenceClassification(vs.Root(), config)
+	model, err := roberta.NewRobertaForSequenceClassification(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Roberta tokenizer
 	vocabFile, err := util.CachedPath("roberta-base", "vocab.json")
@@ -294,7 +295,10 @@ func TestRobertaForMultipleChoice(t *testing.T) {
 	device := gotch.CPU
 	vs := nn.NewVarStore(device)
 
-	model := roberta.NewRobertaForMultipleChoice(vs.Root(), config)
+	model, err := roberta.NewRobertaForMultipleChoice(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Roberta tokenizer
 	vocabFile, err := util.CachedPath("roberta-base", "vocab.json")
@@ -403,7 +407,10 @@ func TestRobertaForTokenClassification(t *testing.T) {
 	config.Id2Label = dummyLabelMap
 	config.OutputAttentions = true
 	config.OutputHiddenStates = true
-	model := roberta.NewRobertaForTokenClassification(vs.Root(), config)
+	model, err := roberta.NewRobertaForTokenClassification(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Roberta tokenizer
 	vocabFile, err := util.CachedPath("roberta-base", "vocab.json")
@@ -513,7 +520,10 @@ func TestRobertaForQuestionAnswering(t *testing.T) {
 	config.Id2Label = dummyLabelMap
 	config.OutputAttentions = true
 	config.OutputHiddenStates = true
-	model := roberta.NewRobertaForQuestionAnswering(vs.Root(), config)
+	model, err := roberta.NewRobertaForQuestionAnswering(vs.Root(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Roberta tokenizer
 	vocabFile, err := util.CachedPath("roberta-base", "vocab.json")
@@ -603,11 +613,3 @@ func TestRobertaForQuestionAnswering(t *testing.T) {
 		t.Errorf("want %v - got %v\n", wantAttentions, gotAttentions)
 	}
 }
-
-func TestRobertaQA(t *testing.T) {
-	// TODO: implement via pipelines
-}
-
-func TestRobertaNER(t *testing.T) {
-	// TODO: implement via pipelines
-}
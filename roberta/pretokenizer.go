@@ -0,0 +1,66 @@
+package roberta
+
+import (
+	"strings"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/normalizer"
+	"github.com/sugarme/tokenizer/pretokenizer"
+)
+
+// splitRegStr is the GPT-2 word splitting pattern used by `pretokenizer.ByteLevel`.
+const splitRegStr = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+`
+
+// ByteLevel is a byte-level pre-tokenizer keeping added tokens (e.g., `<mask>`) found
+// in input text.
+//
+// `pretokenizer.ByteLevel` maps words to byte-level characters with
+// `PreTokenizedString.Normalize`, which drops splits already matched as added tokens.
+// ByteLevel does the same mapping while splitting words instead. Decoding and
+// post-processing are inherited.
+type ByteLevel struct {
+	*pretokenizer.ByteLevel
+}
+
+// NewByteLevel creates a ByteLevel pre-tokenizer adding a space in front of input text.
+func NewByteLevel() *ByteLevel {
+	return &ByteLevel{pretokenizer.NewByteLevel()}
+}
+
+// PreTokenize implements `tokenizer.PreTokenizer` interface.
+func (bl *ByteLevel) PreTokenize(pretokenized *tokenizer.PreTokenizedString) (*tokenizer.PreTokenizedString, error) {
+	splitPattern := normalizer.NewRegexpPattern(splitRegStr)
+
+	pretok := pretokenized.Split(func(noop int, normalized *normalizer.NormalizedString) []tokenizer.SplitIdx {
+		newNormalized := normalized
+		if bl.AddPrefixSpace && !strings.HasPrefix(normalized.GetNormalized(), " ") {
+			newNormalized = normalized.Prepend(" ")
+		}
+
+		var splitIdx []tokenizer.SplitIdx
+		for _, s := range newNormalized.Split(splitPattern, normalizer.IsolatediBehavior) {
+			split := s
+			splitIdx = append(splitIdx, tokenizer.SplitIdx{Normalized: toByteLevel(&split), Tokens: nil})
+		}
+
+		return splitIdx
+	})
+
+	return pretok, nil
+}
+
+// toByteLevel maps every byte of `normalized` to its byte-level character.
+func toByteLevel(normalized *normalizer.NormalizedString) *normalizer.NormalizedString {
+	var changeMap []normalizer.ChangeMap
+	for _, r := range normalized.GetNormalized() {
+		for i, b := range []byte(string(r)) {
+			change := 0
+			if i > 0 {
+				change = 1
+			}
+			changeMap = append(changeMap, normalizer.ChangeMap{RuneVal: pretokenizer.BytesChar[b], Changes: change})
+		}
+	}
+
+	return normalized.Transform(changeMap, 0)
+}
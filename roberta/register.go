@@ -0,0 +1,19 @@
+package roberta
+
+import (
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// Registers RoBERTa configuration (`bert.BertConfig`), tokenizer and task models for
+// auto-loading (see `transformer.AutoModelFor`).
+func init() {
+	pretrained.RegisterConfig("roberta", func() pretrained.Config { return new(bert.BertConfig) })
+	pretrained.RegisterTokenizer("roberta", func() pretrained.Tokenizer { return NewTokenizer() })
+
+	pretrained.RegisterModel("roberta", pretrained.MaskedLM, "RobertaForMaskedLM", func() pretrained.Model { return new(RobertaForMaskedLM) })
+	pretrained.RegisterModel("roberta", pretrained.SequenceClassification, "RobertaForSequenceClassification", func() pretrained.Model { return new(RobertaForSequenceClassification) })
+	pretrained.RegisterModel("roberta", pretrained.MultipleChoice, "RobertaForMultipleChoice", func() pretrained.Model { return new(RobertaForMultipleChoice) })
+	pretrained.RegisterModel("roberta", pretrained.TokenClassification, "RobertaForTokenClassification", func() pretrained.Model { return new(RobertaForTokenClassification) })
+	pretrained.RegisterModel("roberta", pretrained.QuestionAnswering, "RobertaForQuestionAnswering", func() pretrained.Model { return new(RobertaForQuestionAnswering) })
+}
@@ -1,12 +1,11 @@
 package roberta
 
 import (
-	// "fmt"
+	"os"
 
 	"github.com/sugarme/tokenizer"
 	"github.com/sugarme/tokenizer/model/bpe"
 	"github.com/sugarme/tokenizer/normalizer"
-	"github.com/sugarme/tokenizer/pretokenizer"
 	"github.com/sugarme/tokenizer/processor"
 
 	"github.com/sugarme/transformer/util"
@@ -25,16 +24,7 @@ func NewTokenizer() *Tokenizer {
 
 // Load loads Roberta tokenizer from pretrain vocab and merges files.
 func (t *Tokenizer) Load(modelNameOrPath string, params map[string]interface{}) error {
-	vocabFile, err := util.CachedPath("roberta-base", "vocab.json")
-	if err != nil {
-		return err
-	}
-	mergesFile, err := util.CachedPath("roberta-base", "merges.txt")
-	if err != nil {
-		return err
-	}
-
-	model, err := bpe.NewBpeFromFiles(vocabFile, mergesFile)
+	model, err := LoadByteLevelBPE(modelNameOrPath, util.ProgressFromParams(params))
 	if err != nil {
 		return err
 	}
@@ -44,16 +34,17 @@ func (t *Tokenizer) Load(modelNameOrPath string, params map[string]interface{})
 	bertNormalizer := normalizer.NewBertNormalizer(true, true, true, true)
 	t.WithNormalizer(bertNormalizer)
 
-	blPreTokenizer := pretokenizer.NewByteLevel()
+	blPreTokenizer := NewByteLevel()
 	// blPreTokenizer.SetAddPrefixSpace(false)
 	t.WithPreTokenizer(blPreTokenizer)
+	t.WithDecoder(blPreTokenizer)
 
 	var specialTokens []tokenizer.AddedToken
 	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<s>", true))
 	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<pad>", true))
 	specialTokens = append(specialTokens, tokenizer.NewAddedToken("</s>", true))
 	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<unk>", true))
-	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<mask>", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<mask>", true, tokenizer.WithLStrip(true)))
 	t.AddSpecialTokens(specialTokens)
 
 	postProcess := processor.DefaultRobertaProcessing()
@@ -61,3 +52,30 @@ func (t *Tokenizer) Load(modelNameOrPath string, params map[string]interface{})
 
 	return nil
 }
+
+// LoadByteLevelBPE loads byte-level BPE model from pretrain vocab and merges files
+// (`vocab.json` and `merges.txt`). It is shared with GPT-2 tokenizer. Download progress
+// is reported to `progress`, or `util.DefaultProgress` if nil.
+func LoadByteLevelBPE(modelNameOrPath string, progress util.ProgressReporter) (*bpe.BPE, error) {
+	vocabFile, err := util.CachedPathWithProgress(modelNameOrPath, "vocab.json", progress)
+	if err != nil {
+		return nil, err
+	}
+	mergesFile, err := util.CachedPathWithProgress(modelNameOrPath, "merges.txt", progress)
+	if err != nil {
+		return nil, err
+	}
+
+	return bpe.NewBpeFromFiles(vocabFile, mergesFile)
+}
+
+// SavePretrained saves tokenizer vocab files `vocab.json` and `merges.txt` to directory `dir`.
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return t.GetModel().Save(dir)
+}
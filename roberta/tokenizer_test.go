@@ -0,0 +1,33 @@
+package roberta_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/roberta"
+)
+
+func TestTokenizer_Mask(t *testing.T) {
+	tk := roberta.NewTokenizer()
+	if err := tk.Load("roberta-base", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	encoding, err := tk.EncodeSingle("Paris is the <mask> of France.", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// `<mask>` is kept as a single token and absorbs the space before it.
+	var got []string
+	for i, token := range encoding.Tokens {
+		if token == "<mask>" {
+			got = append(got, encoding.Tokens[i-1], token, encoding.Tokens[i+1])
+		}
+	}
+	want := []string{"Ġthe", "<mask>", "Ġof"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
@@ -0,0 +1,296 @@
+package sentencepiece
+
+// sentencepiece package reads SentencePiece model files (`spiece.model`, `*.spm`)
+// and provides a unigram tokenizer model compatible with `github.com/sugarme/tokenizer`.
+//
+// Only the fields of the SentencePiece `ModelProto` needed for encoding and decoding
+// are decoded. Original file content is kept so that a model can be saved unchanged.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+// PieceType is type of a vocabulary piece.
+type PieceType int32
+
+const (
+	NormalPiece      PieceType = 1
+	UnknownPiece     PieceType = 2
+	ControlPiece     PieceType = 3
+	UserDefinedPiece PieceType = 4
+	UnusedPiece      PieceType = 5
+	BytePiece        PieceType = 6
+)
+
+// ModelType is type of a SentencePiece model.
+type ModelType int32
+
+const (
+	UnigramModel ModelType = 1
+	BpeModel     ModelType = 2
+	WordModel    ModelType = 3
+	CharModel    ModelType = 4
+)
+
+// Piece is a vocabulary entry. Its id is its index in `ModelProto.Pieces`.
+type Piece struct {
+	Piece string
+	Score float32
+	Type  PieceType
+}
+
+// ModelProto holds the content of a SentencePiece model file.
+type ModelProto struct {
+	Pieces []Piece
+
+	// Trainer spec
+	ModelType ModelType
+	UnkId     int
+	BosId     int
+	EosId     int
+	PadId     int
+
+	// Normalizer spec
+	AddDummyPrefix         bool
+	RemoveExtraWhitespaces bool
+
+	raw []byte // original file content if loaded from file
+}
+
+// NewModelProto creates a unigram ModelProto with SentencePiece default special ids
+// (`<unk>`=0, `<s>`=1, `</s>`=2, no padding).
+func NewModelProto(pieces []Piece) *ModelProto {
+	return &ModelProto{
+		Pieces:                 pieces,
+		ModelType:              UnigramModel,
+		UnkId:                  0,
+		BosId:                  1,
+		EosId:                  2,
+		PadId:                  -1,
+		AddDummyPrefix:         true,
+		RemoveExtraWhitespaces: true,
+	}
+}
+
+// LoadModelProto reads a SentencePiece model file.
+func LoadModelProto(file string) (*ModelProto, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ParseModelProto(data)
+	if err != nil {
+		return nil, fmt.Errorf("LoadModelProto() failed to parse %q: %w", file, err)
+	}
+
+	return m, nil
+}
+
+// ParseModelProto decodes a serialized SentencePiece `ModelProto`.
+func ParseModelProto(data []byte) (*ModelProto, error) {
+	m := NewModelProto(nil)
+
+	err := walkFields(data, func(num int, wire int, v uint64, b []byte) error {
+		switch num {
+		case 1: // pieces
+			p, err := parsePiece(b)
+			if err != nil {
+				return err
+			}
+			m.Pieces = append(m.Pieces, p)
+		case 2: // trainer_spec
+			return walkFields(b, func(num int, wire int, v uint64, b []byte) error {
+				switch num {
+				case 3:
+					m.ModelType = ModelType(v)
+				case 40:
+					m.UnkId = int(int32(v))
+				case 41:
+					m.BosId = int(int32(v))
+				case 42:
+					m.EosId = int(int32(v))
+				case 43:
+					m.PadId = int(int32(v))
+				}
+				return nil
+			})
+		case 3: // normalizer_spec
+			return walkFields(b, func(num int, wire int, v uint64, b []byte) error {
+				switch num {
+				case 3:
+					m.AddDummyPrefix = v != 0
+				case 4:
+					m.RemoveExtraWhitespaces = v != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Pieces) == 0 {
+		return nil, fmt.Errorf("model has no pieces")
+	}
+	m.raw = data
+
+	return m, nil
+}
+
+func parsePiece(data []byte) (Piece, error) {
+	p := Piece{Type: NormalPiece}
+	err := walkFields(data, func(num int, wire int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			p.Piece = string(b)
+		case 2:
+			p.Score = math.Float32frombits(uint32(v))
+		case 3:
+			p.Type = PieceType(v)
+		}
+		return nil
+	})
+
+	return p, err
+}
+
+// Marshal serializes the model. A model loaded from file is returned unchanged,
+// otherwise only fields of `ModelProto` are encoded.
+func (m *ModelProto) Marshal() []byte {
+	if m.raw != nil {
+		return m.raw
+	}
+
+	var buf []byte
+	for _, p := range m.Pieces {
+		var pb []byte
+		pb = appendBytes(pb, 1, []byte(p.Piece))
+		pb = appendFixed32(pb, 2, math.Float32bits(p.Score))
+		pb = appendVarint(pb, 3, uint64(p.Type))
+		buf = appendBytes(buf, 1, pb)
+	}
+
+	var tb []byte
+	tb = appendVarint(tb, 3, uint64(m.ModelType))
+	tb = appendVarint(tb, 40, uint64(int64(m.UnkId)))
+	tb = appendVarint(tb, 41, uint64(int64(m.BosId)))
+	tb = appendVarint(tb, 42, uint64(int64(m.EosId)))
+	tb = appendVarint(tb, 43, uint64(int64(m.PadId)))
+	buf = appendBytes(buf, 2, tb)
+
+	var nb []byte
+	nb = appendVarint(nb, 3, boolToUint(m.AddDummyPrefix))
+	nb = appendVarint(nb, 4, boolToUint(m.RemoveExtraWhitespaces))
+	buf = appendBytes(buf, 3, nb)
+
+	return buf
+}
+
+// WriteFile writes the serialized model to `file`.
+func (m *ModelProto) WriteFile(file string) error {
+	return ioutil.WriteFile(file, m.Marshal(), 0644)
+}
+
+// Protobuf wire format helpers:
+// =============================
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// walkFields calls `fn` for each field of a protobuf message. For varint and fixed
+// fields, value is passed in `v`, for length-delimited fields in `b`.
+func walkFields(data []byte, fn func(num int, wire int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("invalid field key")
+		}
+		data = data[n:]
+
+		num, wire := int(key>>3), int(key&7)
+		var (
+			v uint64
+			b []byte
+		)
+		switch wire {
+		case wireVarint:
+			v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("invalid varint in field %v", num)
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return fmt.Errorf("truncated fixed64 field %v", num)
+			}
+			v = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return fmt.Errorf("truncated length-delimited field %v", num)
+			}
+			b = data[n : n+int(size)]
+			data = data[n+int(size):]
+		case wireFixed32:
+			if len(data) < 4 {
+				return fmt.Errorf("truncated fixed32 field %v", num)
+			}
+			v = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %v in field %v", wire, num)
+		}
+
+		if err := fn(num, wire, v, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func appendKey(buf []byte, num, wire int) []byte {
+	return appendUvarint(buf, uint64(num)<<3|uint64(wire))
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarint(buf []byte, num int, v uint64) []byte {
+	buf = appendKey(buf, num, wireVarint)
+	return appendUvarint(buf, v)
+}
+
+func appendFixed32(buf []byte, num int, v uint32) []byte {
+	buf = appendKey(buf, num, wireFixed32)
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
+
+func appendBytes(buf []byte, num int, b []byte) []byte {
+	buf = appendKey(buf, num, wireBytes)
+	buf = appendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func boolToUint(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
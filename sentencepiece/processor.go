@@ -0,0 +1,72 @@
+package sentencepiece
+
+import (
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/processor"
+)
+
+// EosProcessing appends an end of sequence token to every sequence, i.e.
+// `A </s>` for a single sequence and `A </s> B </s>` for a pair. It is used by
+// encoder-decoder models such as T5 and Marian.
+//
+// It implements `tokenizer.PostProcessor` interface.
+type EosProcessing struct {
+	eos processor.PostToken
+}
+
+// NewEosProcessing creates EosProcessing appending `eos` token.
+func NewEosProcessing(eos processor.PostToken) *EosProcessing {
+	return &EosProcessing{eos}
+}
+
+// AddedTokens implements `tokenizer.PostProcessor` interface.
+func (ep *EosProcessing) AddedTokens(isPair bool) int {
+	if isPair {
+		return 2
+	}
+	return 1
+}
+
+// Process implements `tokenizer.PostProcessor` interface.
+func (ep *EosProcessing) Process(encoding, pairEncoding *tokenizer.Encoding, addSpecialTokens bool) *tokenizer.Encoding {
+	if !addSpecialTokens {
+		return tokenizer.DefaultProcess(encoding, pairEncoding, addSpecialTokens)
+	}
+
+	newEncoding := ep.addEos(encoding, 0)
+	for _, en := range encoding.Overflowing {
+		newEncoding.Overflowing = append(newEncoding.Overflowing, *ep.addEos(&en, 0))
+	}
+
+	if pairEncoding != nil {
+		newPairEncoding := ep.addEos(pairEncoding, 1)
+		for _, en := range pairEncoding.Overflowing {
+			newPairEncoding.Overflowing = append(newPairEncoding.Overflowing, *ep.addEos(&en, 1))
+		}
+
+		newEncoding.MergeWith(newPairEncoding, false)
+	}
+
+	return newEncoding
+}
+
+// addEos appends end of sequence token to input encoding. It ignores `Overflowing` field.
+func (ep *EosProcessing) addEos(encoding *tokenizer.Encoding, typeId int) *tokenizer.Encoding {
+	n := len(encoding.Ids)
+
+	ids := append(append(make([]int, 0, n+1), encoding.GetIds()...), ep.eos.Id)
+	typeIds := append(append(make([]int, 0, n+1), encoding.GetTypeIds()...), typeId)
+	tokens := append(append(make([]string, 0, n+1), encoding.GetTokens()...), ep.eos.Value)
+	words := append(append(make([]int, 0, n+1), encoding.GetWords()...), -1)
+	offsets := append(append(make([][]int, 0, n+1), encoding.GetOffsets()...), []int{0, 0})
+
+	specialTokens := make([]int, n+1)
+	specialTokens[n] = 1
+
+	attentionMask := make([]int, n+1)
+	for i := range attentionMask {
+		attentionMask[i] = 1
+	}
+
+	return tokenizer.NewEncoding(ids, typeIds, tokens, offsets, specialTokens, attentionMask, []tokenizer.Encoding{}, words)
+}
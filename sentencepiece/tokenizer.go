@@ -0,0 +1,113 @@
+package sentencepiece
+
+import (
+	"strings"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/normalizer"
+)
+
+// WhitespaceSymbol is the meta symbol SentencePiece uses to represent a space.
+const WhitespaceSymbol = "▁"
+
+// Normalizer:
+// ===========
+
+// Normalizer applies SentencePiece-style text normalization: NFKC, optional
+// lowercasing and accent stripping, and replacement of quote pairs
+// (two backticks and two single quotes) with a double quote as done by ALBERT/XLNet preprocessing.
+//
+// It implements `normalizer.Normalizer` interface.
+type Normalizer struct {
+	Lowercase     bool
+	StripAccents  bool
+	ReplaceQuotes bool
+}
+
+// NewNormalizer creates a Normalizer.
+func NewNormalizer(lowercase, stripAccents, replaceQuotes bool) *Normalizer {
+	return &Normalizer{lowercase, stripAccents, replaceQuotes}
+}
+
+// Normalize implements `normalizer.Normalizer` interface.
+func (n *Normalizer) Normalize(normalized *normalizer.NormalizedString) (*normalizer.NormalizedString, error) {
+	if n.ReplaceQuotes {
+		normalized = normalized.Replace(normalizer.NewStringPattern("``"), `"`)
+		normalized = normalized.Replace(normalizer.NewStringPattern("''"), `"`)
+	}
+
+	if n.StripAccents {
+		normalized = applyForm(normalized, normalized.NFKD).RemoveAccents()
+	}
+	normalized = applyForm(normalized, normalized.NFKC)
+
+	if n.Lowercase {
+		normalized = normalized.Lowercase()
+	}
+
+	return normalized, nil
+}
+
+// applyForm applies a unicode normalization form. `NormalizedString.NFKD` returns nil
+// when the string is already normalized, in which case input is returned unchanged.
+func applyForm(n *normalizer.NormalizedString, form func() *normalizer.NormalizedString) *normalizer.NormalizedString {
+	if out := form(); out != nil {
+		return out
+	}
+	return n
+}
+
+// Metaspace:
+// ==========
+
+// Metaspace splits input on whitespace and prefixes every word with `WhitespaceSymbol`
+// so that word boundaries are kept in pieces (e.g., "▁hello").
+//
+// It implements `tokenizer.PreTokenizer` interface.
+type Metaspace struct{}
+
+// NewMetaspace creates a Metaspace pre-tokenizer.
+func NewMetaspace() *Metaspace {
+	return &Metaspace{}
+}
+
+// PreTokenize implements `tokenizer.PreTokenizer` interface.
+func (m *Metaspace) PreTokenize(pretokenized *tokenizer.PreTokenizedString) (*tokenizer.PreTokenizedString, error) {
+	pretok := pretokenized.Split(func(noop int, sub *normalizer.NormalizedString) []tokenizer.SplitIdx {
+		whitespace := normalizer.NewRegexpPattern(`\s+`)
+		words := sub.Split(whitespace, normalizer.RemovedBehavior)
+
+		var splitIdxs []tokenizer.SplitIdx
+		for _, w := range words {
+			word := w
+			if word.GetNormalized() == "" {
+				continue
+			}
+			splitIdxs = append(splitIdxs, tokenizer.SplitIdx{Normalized: word.Prepend(WhitespaceSymbol), Tokens: nil})
+		}
+
+		return splitIdxs
+	})
+
+	return pretok, nil
+}
+
+// Decoder:
+// ========
+
+// Decoder joins pieces and converts `WhitespaceSymbol` back to spaces.
+//
+// It implements `tokenizer.Decoder` interface.
+type Decoder struct{}
+
+// NewDecoder creates a Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{}
+}
+
+// Decode implements `tokenizer.Decoder` interface.
+func (d *Decoder) Decode(tokens []string) string {
+	s := strings.Join(tokens, "")
+	s = strings.ReplaceAll(s, WhitespaceSymbol, " ")
+	return strings.TrimLeft(s, " ")
+}
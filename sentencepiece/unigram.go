@@ -0,0 +1,212 @@
+package sentencepiece
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/sugarme/tokenizer"
+)
+
+// DefaultModelFile is the default file name of a SentencePiece model.
+const DefaultModelFile = "spiece.model"
+
+// Unigram is a SentencePiece unigram language model tokenizer.
+// It implements `tokenizer.Model` interface.
+//
+// Input is segmented into pieces maximizing the sum of piece log probabilities
+// (Viterbi). Characters not covered by the vocabulary are mapped to the unknown
+// piece; consecutive unknown characters are merged into a single token.
+type Unigram struct {
+	proto    *ModelProto
+	vocab    map[string]int
+	maxLen   int     // longest piece in runes
+	unkScore float64 // score of unknown piece
+}
+
+// NewUnigram creates a Unigram tokenizer model from a SentencePiece model.
+func NewUnigram(proto *ModelProto) (*Unigram, error) {
+	if proto.ModelType != UnigramModel {
+		return nil, fmt.Errorf("NewUnigram() failed: unsupported SentencePiece model type %v, want unigram (1)", proto.ModelType)
+	}
+	if proto.UnkId < 0 || proto.UnkId >= len(proto.Pieces) {
+		return nil, fmt.Errorf("NewUnigram() failed: invalid unknown piece id %v", proto.UnkId)
+	}
+
+	u := &Unigram{
+		proto: proto,
+		vocab: make(map[string]int, len(proto.Pieces)),
+	}
+
+	minScore := math.MaxFloat64
+	for id, p := range proto.Pieces {
+		if _, ok := u.vocab[p.Piece]; !ok {
+			u.vocab[p.Piece] = id
+		}
+		if n := utf8.RuneCountInString(p.Piece); n > u.maxLen {
+			u.maxLen = n
+		}
+		if p.Type == NormalPiece && float64(p.Score) < minScore {
+			minScore = float64(p.Score)
+		}
+	}
+	if minScore == math.MaxFloat64 {
+		minScore = 0
+	}
+	u.unkScore = minScore - 10
+
+	return u, nil
+}
+
+// NewUnigramFromFile loads a Unigram tokenizer model from a SentencePiece model file.
+func NewUnigramFromFile(file string) (*Unigram, error) {
+	proto, err := LoadModelProto(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUnigram(proto)
+}
+
+// Proto returns the underlying SentencePiece model.
+func (u *Unigram) Proto() *ModelProto {
+	return u.proto
+}
+
+// UnkToken returns the unknown piece.
+func (u *Unigram) UnkToken() string {
+	return u.proto.Pieces[u.proto.UnkId].Piece
+}
+
+// matchable reports whether piece `id` can be produced by segmentation.
+func (u *Unigram) matchable(id int) bool {
+	switch u.proto.Pieces[id].Type {
+	case NormalPiece, UserDefinedPiece:
+		return true
+	default:
+		return false
+	}
+}
+
+// Tokenize implements `tokenizer.Model` interface. Token offsets are byte offsets in `sequence`.
+func (u *Unigram) Tokenize(sequence string) ([]tokenizer.Token, error) {
+	if sequence == "" {
+		return nil, nil
+	}
+
+	// byte position of each rune, with end position appended.
+	var pos []int
+	for i := range sequence {
+		pos = append(pos, i)
+	}
+	pos = append(pos, len(sequence))
+	n := len(pos) - 1
+
+	type node struct {
+		score float64
+		start int // rune index the best path to this node comes from
+		id    int // piece id; -1 for unknown
+		ok    bool
+	}
+	lattice := make([]node, n+1)
+	lattice[0].ok = true
+
+	for i := 0; i < n; i++ {
+		if !lattice[i].ok {
+			continue
+		}
+		matched := false
+		for l := 1; l <= u.maxLen && i+l <= n; l++ {
+			id, ok := u.vocab[sequence[pos[i]:pos[i+l]]]
+			if !ok || !u.matchable(id) {
+				continue
+			}
+			score := lattice[i].score + float64(u.proto.Pieces[id].Score)
+			if u.proto.Pieces[id].Type == UserDefinedPiece {
+				score = lattice[i].score // user defined pieces are always preferred
+			}
+			if l == 1 {
+				matched = true
+			}
+			if next := &lattice[i+l]; !next.ok || score > next.score {
+				*next = node{score: score, start: i, id: id, ok: true}
+			}
+		}
+		if !matched {
+			score := lattice[i].score + u.unkScore
+			if next := &lattice[i+1]; !next.ok || score > next.score {
+				*next = node{score: score, start: i, id: -1, ok: true}
+			}
+		}
+	}
+
+	// Backtrack
+	type span struct{ start, end, id int }
+	var path []span
+	for i := n; i > 0; i = lattice[i].start {
+		path = append(path, span{lattice[i].start, i, lattice[i].id})
+	}
+
+	var tokens []tokenizer.Token
+	for k := len(path) - 1; k >= 0; k-- {
+		sp := path[k]
+		start, end := pos[sp.start], pos[sp.end]
+		if sp.id < 0 {
+			// Merge consecutive unknown characters.
+			if last := len(tokens) - 1; last >= 0 && tokens[last].Id == u.proto.UnkId && tokens[last].Offsets[1] == start {
+				tokens[last].Offsets[1] = end
+				continue
+			}
+			tokens = append(tokens, tokenizer.Token{Id: u.proto.UnkId, Value: u.UnkToken(), Offsets: []int{start, end}})
+			continue
+		}
+		tokens = append(tokens, tokenizer.Token{Id: sp.id, Value: u.proto.Pieces[sp.id].Piece, Offsets: []int{start, end}})
+	}
+
+	return tokens, nil
+}
+
+// TokenToId implements `tokenizer.Model` interface.
+func (u *Unigram) TokenToId(token string) (int, bool) {
+	id, ok := u.vocab[token]
+	return id, ok
+}
+
+// IdToToken implements `tokenizer.Model` interface.
+func (u *Unigram) IdToToken(id int) (string, bool) {
+	if id < 0 || id >= len(u.proto.Pieces) {
+		return "", false
+	}
+	return u.proto.Pieces[id].Piece, true
+}
+
+// GetVocab implements `tokenizer.Model` interface.
+func (u *Unigram) GetVocab() map[string]int {
+	vocab := make(map[string]int, len(u.vocab))
+	for k, v := range u.vocab {
+		vocab[k] = v
+	}
+	return vocab
+}
+
+// GetVocabSize implements `tokenizer.Model` interface.
+func (u *Unigram) GetVocabSize() int {
+	return len(u.proto.Pieces)
+}
+
+// Save implements `tokenizer.Model` interface. It writes the SentencePiece model
+// to `dir` as `spiece.model` or, if `prefixOpt` is given, as `<prefix>-spiece.model`.
+func (u *Unigram) Save(dir string, prefixOpt ...string) error {
+	name := DefaultModelFile
+	if len(prefixOpt) > 0 {
+		name = fmt.Sprintf("%v-%v", prefixOpt[0], DefaultModelFile)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return u.proto.WriteFile(filepath.Join(dir, name))
+}
@@ -0,0 +1,158 @@
+package sentencepiece_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/processor"
+
+	"github.com/sugarme/transformer/sentencepiece"
+)
+
+func toyProto() *sentencepiece.ModelProto {
+	return sentencepiece.NewModelProto([]sentencepiece.Piece{
+		{Piece: "<unk>", Type: sentencepiece.UnknownPiece},
+		{Piece: "<s>", Type: sentencepiece.ControlPiece},
+		{Piece: "</s>", Type: sentencepiece.ControlPiece},
+		{Piece: "▁hello", Score: -1, Type: sentencepiece.NormalPiece},
+		{Piece: "▁he", Score: -2, Type: sentencepiece.NormalPiece},
+		{Piece: "llo", Score: -2, Type: sentencepiece.NormalPiece},
+		{Piece: "▁world", Score: -1.5, Type: sentencepiece.NormalPiece},
+		{Piece: "▁", Score: -3, Type: sentencepiece.NormalPiece},
+		{Piece: "w", Score: -4, Type: sentencepiece.NormalPiece},
+		{Piece: "s", Score: -4, Type: sentencepiece.NormalPiece},
+	})
+}
+
+func TestModelProto_RoundTrip(t *testing.T) {
+	want := toyProto()
+
+	got, err := sentencepiece.ParseModelProto(want.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want.Pieces, got.Pieces) {
+		t.Errorf("Want: %v\n", want.Pieces)
+		t.Errorf("Got: %v\n", got.Pieces)
+	}
+	if got.PadId != -1 || got.EosId != 2 {
+		t.Errorf("Want pad id -1 and eos id 2, got %v and %v\n", got.PadId, got.EosId)
+	}
+}
+
+func TestUnigram_Tokenize(t *testing.T) {
+	model, err := sentencepiece.NewUnigram(toyProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toks, err := model.Tokenize("▁hellos▁wx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, tok := range toks {
+		got = append(got, tok.Value)
+	}
+	// "x" is not in vocab and becomes unknown.
+	want := []string{"▁hello", "s", "▁", "w", "<unk>"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	wantOffsets := []int{len("▁hellos▁w"), len("▁hellos▁wx")}
+	if !reflect.DeepEqual(wantOffsets, toks[4].Offsets) {
+		t.Errorf("Want: %v\n", wantOffsets)
+		t.Errorf("Got: %v\n", toks[4].Offsets)
+	}
+}
+
+func TestUnigram_Tokenizer(t *testing.T) {
+	model, err := sentencepiece.NewUnigram(toyProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk := tokenizer.NewTokenizer(model)
+	tk.WithNormalizer(sentencepiece.NewNormalizer(true, true, false))
+	tk.WithPreTokenizer(sentencepiece.NewMetaspace())
+	tk.WithDecoder(sentencepiece.NewDecoder())
+
+	en, err := tk.EncodeSingle("Hello  World")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"▁hello", "▁world"}
+	if !reflect.DeepEqual(want, en.Tokens) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", en.Tokens)
+	}
+
+	wantText := "hello world"
+	gotText := tk.Decode(en.Ids, true)
+	if wantText != gotText {
+		t.Errorf("Want: %q\n", wantText)
+		t.Errorf("Got: %q\n", gotText)
+	}
+}
+
+func TestUnigram_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sentencepiece")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	model, err := sentencepiece.NewUnigram(toyProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := sentencepiece.NewUnigramFromFile(filepath.Join(dir, sentencepiece.DefaultModelFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(model.GetVocab(), loaded.GetVocab()) {
+		t.Errorf("Want: %v\n", model.GetVocab())
+		t.Errorf("Got: %v\n", loaded.GetVocab())
+	}
+}
+
+func TestEosProcessing(t *testing.T) {
+	model, err := sentencepiece.NewUnigram(toyProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk := tokenizer.NewTokenizer(model)
+	tk.WithNormalizer(sentencepiece.NewNormalizer(false, false, false))
+	tk.WithPreTokenizer(sentencepiece.NewMetaspace())
+	tk.WithPostProcessor(sentencepiece.NewEosProcessing(processor.PostToken{Id: 2, Value: "</s>"}))
+
+	en, err := tk.EncodePair("hello", "world", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"▁hello", "</s>", "▁world", "</s>"}
+	if !reflect.DeepEqual(want, en.Tokens) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", en.Tokens)
+	}
+	wantIds := []int{3, 2, 6, 2}
+	if !reflect.DeepEqual(wantIds, en.Ids) {
+		t.Errorf("Want: %v\n", wantIds)
+		t.Errorf("Got: %v\n", en.Ids)
+	}
+}
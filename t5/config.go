@@ -0,0 +1,186 @@
+package t5
+
+// t5 package implements T5 encoder-decoder transformer model.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// T5Config defines the T5 model architecture (i.e., number of layers,
+// model size, feed-forward projection...)
+type T5Config struct {
+	VocabSize                    int64   `json:"vocab_size"`
+	DModel                       int64   `json:"d_model"`
+	DKv                          int64   `json:"d_kv"`
+	DFf                          int64   `json:"d_ff"`
+	NumLayers                    int64   `json:"num_layers"`
+	NumDecoderLayers             int64   `json:"num_decoder_layers"`
+	NumHeads                     int64   `json:"num_heads"`
+	RelativeAttentionNumBuckets  int64   `json:"relative_attention_num_buckets"`
+	RelativeAttentionMaxDistance int64   `json:"relative_attention_max_distance"`
+	DropoutRate                  float64 `json:"dropout_rate"`
+	LayerNormEpsilon             float64 `json:"layer_norm_epsilon"`
+	InitializerFactor            float64 `json:"initializer_factor"`
+	FeedForwardProj              string  `json:"feed_forward_proj"`
+	IsEncoderDecoder             bool    `json:"is_encoder_decoder"`
+	TieWordEmbeddings            bool    `json:"tie_word_embeddings"`
+	PadTokenId                   int64   `json:"pad_token_id"`
+	EosTokenId                   int64   `json:"eos_token_id"`
+	DecoderStartTokenId          int64   `json:"decoder_start_token_id"`
+	OutputAttentions             bool    `json:"output_attentions"`
+	OutputHiddenStates           bool    `json:"output_hidden_states"`
+}
+
+// NewConfig initiates T5Config with given input parameters or default values
+// of `t5-small`.
+//
+// NOTE. `NumDecoderLayers` defaults to `NumLayers` when not set.
+func NewConfig(customParams map[string]interface{}) *T5Config {
+	defaultValues := map[string]interface{}{
+		"VocabSize":                    int64(32128),
+		"DModel":                       int64(512),
+		"DKv":                          int64(64),
+		"DFf":                          int64(2048),
+		"NumLayers":                    int64(6),
+		"NumDecoderLayers":             int64(0),
+		"NumHeads":                     int64(8),
+		"RelativeAttentionNumBuckets":  int64(32),
+		"RelativeAttentionMaxDistance": int64(128),
+		"DropoutRate":                  float64(0.1),
+		"LayerNormEpsilon":             float64(1e-6),
+		"InitializerFactor":            float64(1.0),
+		"FeedForwardProj":              "relu",
+		"IsEncoderDecoder":             true,
+		"TieWordEmbeddings":            true,
+		"PadTokenId":                   int64(0),
+		"EosTokenId":                   int64(1),
+		"DecoderStartTokenId":          int64(0),
+	}
+
+	params := defaultValues
+	for k, v := range customParams {
+		if _, ok := params[k]; ok {
+			params[k] = v
+		}
+	}
+
+	config := new(T5Config)
+	config.updateParams(params)
+
+	return config
+}
+
+// ConfigFromFile loads T5Config from a JSON file.
+func ConfigFromFile(filename string) (*T5Config, error) {
+	config := new(T5Config)
+	if err := config.fromFile(filename); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Load loads model configuration from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Config` interface.
+func (c *T5Config) Load(modelNameOrPath string, params map[string]interface{}) error {
+	err := c.fromFile(modelNameOrPath)
+	if err != nil {
+		return err
+	}
+
+	// Update custom parameters
+	c.updateParams(params)
+
+	return nil
+}
+
+// SavePretrained saves configuration to directory `dir` as `config.json` file.
+// This method implements `pretrained.Config` interface.
+func (c *T5Config) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	buff, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, util.ConfigName), buff, 0644)
+}
+
+func (c *T5Config) fromFile(filename string) error {
+	filePath, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	buff, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	// Configs of original T5 checkpoints omit `feed_forward_proj`, `tie_word_embeddings`
+	// and `relative_attention_max_distance`, hence start from default values.
+	*c = *NewConfig(nil)
+	err = json.Unmarshal(buff, c)
+	if err != nil {
+		return fmt.Errorf("Could not parse configuration to T5Config: %v: %w", err, util.ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// GetVocabSize returns vocabulary size.
+func (c *T5Config) GetVocabSize() int64 {
+	return c.VocabSize
+}
+
+// decoderLayers returns number of decoder layers.
+func (c *T5Config) decoderLayers() int64 {
+	if c.NumDecoderLayers > 0 {
+		return c.NumDecoderLayers
+	}
+	return c.NumLayers
+}
+
+// feedForward parses `FeedForwardProj` (e.g., "relu" or "gated-gelu") into the
+// activation name and whether the feed-forward layer is gated.
+func (c *T5Config) feedForward() (activation string, gated bool) {
+	activation = c.FeedForwardProj
+	if strings.HasPrefix(activation, "gated-") {
+		gated = true
+		activation = strings.TrimPrefix(activation, "gated-")
+	}
+	// Gated-GELU checkpoints (T5 v1.1) use the tanh approximation.
+	if gated && activation == "gelu" {
+		activation = "gelu_new"
+	}
+
+	return activation, gated
+}
+
+func (c *T5Config) updateParams(params map[string]interface{}) {
+	for k, v := range params {
+		c.updateField(k, v)
+	}
+}
+
+func (c *T5Config) updateField(field string, value interface{}) {
+	// Check whether field name exists
+	if reflect.ValueOf(c).Elem().FieldByName(field).IsValid() {
+		// Check whether same type
+		if reflect.ValueOf(c).Elem().FieldByName(field).Kind() == reflect.TypeOf(value).Kind() {
+			reflect.ValueOf(c).Elem().FieldByName(field).Set(reflect.ValueOf(value))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package t5_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/t5"
+)
+
+func TestConfigFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "t5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Excerpt of `t5-base` config. It omits `feed_forward_proj` and `tie_word_embeddings`.
+	data := `{
+  "architectures": ["T5WithLMHeadModel"],
+  "d_ff": 3072,
+  "d_kv": 64,
+  "d_model": 768,
+  "decoder_start_token_id": 0,
+  "dropout_rate": 0.1,
+  "eos_token_id": 1,
+  "initializer_factor": 1.0,
+  "is_encoder_decoder": true,
+  "layer_norm_epsilon": 1e-06,
+  "model_type": "t5",
+  "n_positions": 512,
+  "num_heads": 12,
+  "num_layers": 12,
+  "pad_token_id": 0,
+  "relative_attention_num_buckets": 32,
+  "vocab_size": 32128
+}`
+	configFile := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := t5.ConfigFromFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := t5.NewConfig(map[string]interface{}{
+		"DModel":    int64(768),
+		"DFf":       int64(3072),
+		"NumHeads":  int64(12),
+		"NumLayers": int64(12),
+	})
+	if !reflect.DeepEqual(want, config) {
+		t.Errorf("Want: %+v\n", want)
+		t.Errorf("Got: %+v\n", config)
+	}
+}
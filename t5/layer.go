@@ -0,0 +1,429 @@
+package t5
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// T5LayerNorm:
+// ============
+
+// T5LayerNorm is a root mean square layer norm: hidden states are scaled by
+// their root mean square without centering and there is no bias.
+type T5LayerNorm struct {
+	Weight *ts.Tensor
+	Eps    float64
+}
+
+// NewT5LayerNorm creates a new T5LayerNorm.
+func NewT5LayerNorm(p *nn.Path, hiddenSize int64, eps float64) (*T5LayerNorm, error) {
+	weight, err := p.NewVar("weight", []int64{hiddenSize}, nn.NewConstInit(1.0))
+	if err != nil {
+		return nil, err
+	}
+
+	return &T5LayerNorm{weight, eps}, nil
+}
+
+// Forward implements ts.Module interface.
+func (ln *T5LayerNorm) Forward(hiddenStates *ts.Tensor) *ts.Tensor {
+	variance := hiddenStates.MustPowTensorScalar(ts.FloatScalar(2), false).MustMeanDim([]int64{-1}, true, gotch.Float, true)
+	scale := variance.MustAddScalar(ts.FloatScalar(ln.Eps), true).MustRsqrt(true)
+	normalized := hiddenStates.MustMul(scale, false)
+	scale.MustDrop()
+
+	return normalized.MustMul(ln.Weight, true)
+}
+
+// RelativePositionBucket maps a relative position (memory position - query position)
+// to a bucket index. Half of the buckets are for exact increments in positions and the
+// other half are for logarithmically bigger bins up to `maxDistance`; farther positions
+// share the last bucket. Bidirectional attention uses separate buckets for positive
+// and negative positions, unidirectional (decoder) attention ignores future positions.
+func RelativePositionBucket(relativePosition int64, bidirectional bool, numBuckets, maxDistance int64) int64 {
+	var bucket int64
+	n := relativePosition
+	if bidirectional {
+		numBuckets /= 2
+		if n > 0 {
+			bucket += numBuckets
+		}
+		if n < 0 {
+			n = -n
+		}
+	} else {
+		if n > 0 {
+			n = 0
+		}
+		n = -n
+	}
+
+	maxExact := numBuckets / 2
+	if n < maxExact {
+		return bucket + n
+	}
+
+	// NOTE. computed in float32 to match buckets of pretrained checkpoints.
+	logRatio := float32(math.Log(float64(float32(n) / float32(maxExact))))
+	logMax := float32(math.Log(float64(maxDistance) / float64(maxExact)))
+	large := maxExact + int64(logRatio/logMax*float32(numBuckets-maxExact))
+	if large > numBuckets-1 {
+		large = numBuckets - 1
+	}
+
+	return bucket + large
+}
+
+// T5Attention:
+// ============
+
+// LayerState holds keys and values of an attention layer cached from previous
+// decoding steps. Tensors have shape (batch size, heads, cached length, dKv).
+type LayerState struct {
+	PrevKey   *ts.Tensor
+	PrevValue *ts.Tensor
+}
+
+// T5Attention is T5 multi-head attention. Attention scores are not scaled and a
+// relative position bias is added to them. Only the first layer of a stack holds
+// the relative attention bias embedding; other layers reuse its position bias.
+type T5Attention struct {
+	Q                     *util.LinearNoBias
+	K                     *util.LinearNoBias
+	V                     *util.LinearNoBias
+	O                     *util.LinearNoBias
+	RelativeAttentionBias *nn.Embedding // nil if layer has no relative attention bias
+	Dropout               *util.Dropout
+	NumHeads              int64
+	DKv                   int64
+	NumBuckets            int64
+	MaxDistance           int64
+	IsDecoder             bool
+}
+
+// NewT5Attention creates a new T5Attention.
+func NewT5Attention(p *nn.Path, config *T5Config, isDecoder, hasRelativeAttentionBias bool) (*T5Attention, error) {
+	innerDim := config.NumHeads * config.DKv
+
+	var linears []*util.LinearNoBias
+	for _, name := range []string{"q", "k", "v"} {
+		lin, err := util.NewLinearNoBias(p.Sub(name), config.DModel, innerDim, util.DefaultLinearNoBiasConfig())
+		if err != nil {
+			return nil, err
+		}
+		linears = append(linears, lin)
+	}
+	o, err := util.NewLinearNoBias(p.Sub("o"), innerDim, config.DModel, util.DefaultLinearNoBiasConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	var relativeAttentionBias *nn.Embedding
+	if hasRelativeAttentionBias {
+		relativeAttentionBias = nn.NewEmbedding(p.Sub("relative_attention_bias"), config.RelativeAttentionNumBuckets, config.NumHeads, nn.DefaultEmbeddingConfig())
+	}
+
+	return &T5Attention{
+		Q:                     linears[0],
+		K:                     linears[1],
+		V:                     linears[2],
+		O:                     o,
+		RelativeAttentionBias: relativeAttentionBias,
+		Dropout:               util.NewDropout(config.DropoutRate),
+		NumHeads:              config.NumHeads,
+		DKv:                   config.DKv,
+		NumBuckets:            config.RelativeAttentionNumBuckets,
+		MaxDistance:           config.RelativeAttentionMaxDistance,
+		IsDecoder:             isDecoder,
+	}, nil
+}
+
+// shape splits heads: (batch size, length, inner dim) -> (batch size, heads, length, dKv).
+func (a *T5Attention) shape(x *ts.Tensor, bs int64) *ts.Tensor {
+	return x.MustView([]int64{bs, -1, a.NumHeads, a.DKv}, true).MustTranspose(1, 2, true)
+}
+
+// unshape merges heads: (batch size, heads, length, dKv) -> (batch size, length, inner dim).
+func (a *T5Attention) unshape(x *ts.Tensor, bs int64) *ts.Tensor {
+	return x.MustTranspose(1, 2, true).MustContiguous(true).MustView([]int64{bs, -1, a.NumHeads * a.DKv}, true)
+}
+
+// computeBias computes relative position bias of shape (1, heads, query length, key length).
+// Queries are the last `qLen` positions of the `kLen` keys.
+func (a *T5Attention) computeBias(qLen, kLen int64, device gotch.Device) *ts.Tensor {
+	offset := kLen - qLen
+	buckets := make([]int64, qLen*kLen)
+	for i := int64(0); i < qLen; i++ {
+		for j := int64(0); j < kLen; j++ {
+			buckets[i*kLen+j] = RelativePositionBucket(j-(i+offset), !a.IsDecoder, a.NumBuckets, a.MaxDistance)
+		}
+	}
+
+	bucketTs := ts.MustOfSlice(buckets).MustView([]int64{qLen, kLen}, true).MustTo(device, true)
+	values := bucketTs.Apply(a.RelativeAttentionBias)
+	bucketTs.MustDrop()
+
+	return values.MustPermute([]int64{2, 0, 1}, true).MustUnsqueeze(0, true)
+}
+
+// ForwardT forwards pass through the attention layer.
+//
+// Params:
+//   - `hiddenStates`: queries input of shape (batch size, query length, d model)
+//   - `keyValueStates`: encoder hidden states for cross-attention or `ts.None` for self-attention
+//   - `positionBias`: position bias (mask included) computed by the first layer of the stack or
+//     `ts.None` to compute it
+//   - `mask`: additive attention mask broadcastable to (batch size, heads, query length, key length) or `ts.None`
+//   - `layerState`: cached keys and values or nil. For self-attention, new keys and values are
+//     appended to cached ones. For cross-attention, cached ones are used as is.
+//
+// Returns:
+//   - attention output of shape (batch size, query length, d model)
+//   - position bias (mask included) to be reused by next layers
+//   - attention weights of shape (batch size, heads, query length, key length)
+//   - keys and values to cache for next decoding step. It is nil for encoder layers.
+func (a *T5Attention) ForwardT(hiddenStates, keyValueStates, positionBias, mask *ts.Tensor, layerState *LayerState, train bool) (retVal, retValOpt1, retValOpt2 *ts.Tensor, retState *LayerState) {
+	size := hiddenStates.MustSize()
+	bs, qLen := size[0], size[1]
+
+	q := a.shape(hiddenStates.Apply(a.Q), bs)
+
+	var k, v *ts.Tensor
+	switch {
+	case keyValueStates.MustDefined() && layerState != nil:
+		k = layerState.PrevKey.MustShallowClone()
+		v = layerState.PrevValue.MustShallowClone()
+	case keyValueStates.MustDefined():
+		k = a.shape(keyValueStates.Apply(a.K), bs)
+		v = a.shape(keyValueStates.Apply(a.V), bs)
+	default:
+		k = a.shape(hiddenStates.Apply(a.K), bs)
+		v = a.shape(hiddenStates.Apply(a.V), bs)
+		if layerState != nil {
+			kTmp := ts.MustCat([]ts.Tensor{*layerState.PrevKey, *k}, 2)
+			vTmp := ts.MustCat([]ts.Tensor{*layerState.PrevValue, *v}, 2)
+			k.MustDrop()
+			v.MustDrop()
+			k, v = kTmp, vTmp
+		}
+	}
+	kLen := k.MustSize()[2]
+
+	kT := k.MustTranspose(-1, -2, false)
+	scores := q.MustMatmul(kT, true)
+	kT.MustDrop()
+
+	if !positionBias.MustDefined() {
+		if a.RelativeAttentionBias != nil {
+			positionBias = a.computeBias(qLen, kLen, hiddenStates.MustDevice())
+		} else {
+			positionBias = ts.MustZeros([]int64{1, a.NumHeads, qLen, kLen}, gotch.Float, hiddenStates.MustDevice())
+		}
+		if mask.MustDefined() {
+			positionBias = positionBias.MustAdd(mask, true)
+		}
+	}
+	scores = scores.MustAdd(positionBias, true)
+
+	weights := scores.MustSoftmax(-1, gotch.Float, true)
+	dropped := weights.ApplyT(a.Dropout, train)
+	context := dropped.MustMatmul(v, true)
+	merged := a.unshape(context, bs)
+	retVal = merged.Apply(a.O)
+	merged.MustDrop()
+
+	if a.IsDecoder {
+		retState = &LayerState{PrevKey: k, PrevValue: v}
+	} else {
+		k.MustDrop()
+		v.MustDrop()
+	}
+
+	return retVal, positionBias, weights, retState
+}
+
+// Feed-forward:
+// =============
+
+// T5FeedForward is the feed-forward network of T5 layers.
+type T5FeedForward interface {
+	ForwardT(hiddenStates *ts.Tensor, train bool) *ts.Tensor
+}
+
+// T5DenseActDense is the feed-forward network of original T5 checkpoints:
+// `wi`, activation (ReLU) and `wo`.
+type T5DenseActDense struct {
+	Wi         *util.LinearNoBias
+	Wo         *util.LinearNoBias
+	Activation util.ActivationFn
+	Dropout    *util.Dropout
+}
+
+// NewT5DenseActDense creates a new T5DenseActDense.
+func NewT5DenseActDense(p *nn.Path, config *T5Config, activation util.ActivationFn) (*T5DenseActDense, error) {
+	wi, err := util.NewLinearNoBias(p.Sub("wi"), config.DModel, config.DFf, util.DefaultLinearNoBiasConfig())
+	if err != nil {
+		return nil, err
+	}
+	wo, err := util.NewLinearNoBias(p.Sub("wo"), config.DFf, config.DModel, util.DefaultLinearNoBiasConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return &T5DenseActDense{wi, wo, activation, util.NewDropout(config.DropoutRate)}, nil
+}
+
+// ForwardT implements T5FeedForward interface.
+func (d *T5DenseActDense) ForwardT(hiddenStates *ts.Tensor, train bool) *ts.Tensor {
+	x1 := hiddenStates.Apply(d.Wi)
+	x2 := d.Activation.Fwd(x1)
+	x1.MustDrop()
+	x3 := x2.ApplyT(d.Dropout, train)
+	x2.MustDrop()
+	retVal := x3.Apply(d.Wo)
+	x3.MustDrop()
+
+	return retVal
+}
+
+// T5DenseGatedActDense is the gated feed-forward network of T5 v1.1 checkpoints:
+// activation of `wi_0` is multiplied by linear gate `wi_1` before `wo`.
+type T5DenseGatedActDense struct {
+	Wi0        *util.LinearNoBias
+	Wi1        *util.LinearNoBias
+	Wo         *util.LinearNoBias
+	Activation util.ActivationFn
+	Dropout    *util.Dropout
+}
+
+// NewT5DenseGatedActDense creates a new T5DenseGatedActDense.
+func NewT5DenseGatedActDense(p *nn.Path, config *T5Config, activation util.ActivationFn) (*T5DenseGatedActDense, error) {
+	wi0, err := util.NewLinearNoBias(p.Sub("wi_0"), config.DModel, config.DFf, util.DefaultLinearNoBiasConfig())
+	if err != nil {
+		return nil, err
+	}
+	wi1, err := util.NewLinearNoBias(p.Sub("wi_1"), config.DModel, config.DFf, util.DefaultLinearNoBiasConfig())
+	if err != nil {
+		return nil, err
+	}
+	wo, err := util.NewLinearNoBias(p.Sub("wo"), config.DFf, config.DModel, util.DefaultLinearNoBiasConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return &T5DenseGatedActDense{wi0, wi1, wo, activation, util.NewDropout(config.DropoutRate)}, nil
+}
+
+// ForwardT implements T5FeedForward interface.
+func (d *T5DenseGatedActDense) ForwardT(hiddenStates *ts.Tensor, train bool) *ts.Tensor {
+	x := hiddenStates.Apply(d.Wi0)
+	gelu := d.Activation.Fwd(x)
+	x.MustDrop()
+	linear := hiddenStates.Apply(d.Wi1)
+	gated := gelu.MustMul(linear, true)
+	linear.MustDrop()
+	dropped := gated.ApplyT(d.Dropout, train)
+	gated.MustDrop()
+	retVal := dropped.Apply(d.Wo)
+	dropped.MustDrop()
+
+	return retVal
+}
+
+// T5 sub-layers:
+// ==============
+
+// T5LayerFF is the feed-forward sub-layer: pre layer norm, feed-forward network
+// and residual connection.
+type T5LayerFF struct {
+	DenseReluDense T5FeedForward
+	LayerNorm      *T5LayerNorm
+	Dropout        *util.Dropout
+}
+
+// NewT5LayerFF creates a new T5LayerFF. Feed-forward network is gated if
+// `config.FeedForwardProj` is prefixed with "gated-" (e.g., "gated-gelu").
+func NewT5LayerFF(p *nn.Path, config *T5Config) (*T5LayerFF, error) {
+	actName, gated := config.feedForward()
+	activation, ok := util.ActivationFnMap[actName]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported feed forward projection - %v: %w", config.FeedForwardProj, util.ErrUnsupportedActivation)
+	}
+
+	var (
+		ff  T5FeedForward
+		err error
+	)
+	if gated {
+		ff, err = NewT5DenseGatedActDense(p.Sub("DenseReluDense"), config, activation)
+	} else {
+		ff, err = NewT5DenseActDense(p.Sub("DenseReluDense"), config, activation)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	layerNorm, err := NewT5LayerNorm(p.Sub("layer_norm"), config.DModel, config.LayerNormEpsilon)
+	if err != nil {
+		return nil, err
+	}
+
+	return &T5LayerFF{ff, layerNorm, util.NewDropout(config.DropoutRate)}, nil
+}
+
+// ForwardT forwards pass through the sub-layer.
+func (l *T5LayerFF) ForwardT(hiddenStates *ts.Tensor, train bool) *ts.Tensor {
+	x1 := hiddenStates.Apply(l.LayerNorm)
+	x2 := l.DenseReluDense.ForwardT(x1, train)
+	x1.MustDrop()
+	x3 := x2.ApplyT(l.Dropout, train)
+	x2.MustDrop()
+	retVal := hiddenStates.MustAdd(x3, false)
+	x3.MustDrop()
+
+	return retVal
+}
+
+// T5LayerAttention is the attention sub-layer: pre layer norm, attention and
+// residual connection. It is used for self-attention (`SelfAttention`) and
+// encoder-decoder attention (`EncDecAttention`).
+type T5LayerAttention struct {
+	Attention *T5Attention
+	LayerNorm *T5LayerNorm
+	Dropout   *util.Dropout
+}
+
+// NewT5LayerAttention creates a new T5LayerAttention. `name` is the attention
+// sub-path, i.e. "SelfAttention" or "EncDecAttention".
+func NewT5LayerAttention(p *nn.Path, name string, config *T5Config, isDecoder, hasRelativeAttentionBias bool) (*T5LayerAttention, error) {
+	attention, err := NewT5Attention(p.Sub(name), config, isDecoder, hasRelativeAttentionBias)
+	if err != nil {
+		return nil, err
+	}
+	layerNorm, err := NewT5LayerNorm(p.Sub("layer_norm"), config.DModel, config.LayerNormEpsilon)
+	if err != nil {
+		return nil, err
+	}
+
+	return &T5LayerAttention{attention, layerNorm, util.NewDropout(config.DropoutRate)}, nil
+}
+
+// ForwardT forwards pass through the sub-layer. See `T5Attention.ForwardT` for params and returns.
+func (l *T5LayerAttention) ForwardT(hiddenStates, keyValueStates, positionBias, mask *ts.Tensor, layerState *LayerState, train bool) (retVal, retValOpt1, retValOpt2 *ts.Tensor, retState *LayerState) {
+	normed := hiddenStates.Apply(l.LayerNorm)
+	attnOutput, positionBias, weights, retState := l.Attention.ForwardT(normed, keyValueStates, positionBias, mask, layerState, train)
+	normed.MustDrop()
+
+	dropped := attnOutput.ApplyT(l.Dropout, train)
+	attnOutput.MustDrop()
+	retVal = hiddenStates.MustAdd(dropped, false)
+	dropped.MustDrop()
+
+	return retVal, positionBias, weights, retState
+}
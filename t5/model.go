@@ -0,0 +1,216 @@
+package t5
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/util"
+)
+
+// T5ModelOutput holds outputs of T5 encoder-decoder forward pass.
+type T5ModelOutput struct {
+	DecoderOutput          *ts.Tensor   // (batch size, target length, d model)
+	EncoderHiddenState     *ts.Tensor   // (batch size, source length, d model)
+	Cache                  []BlockState // keys and values to pass to next decoding step
+	AllDecoderHiddenStates []ts.Tensor
+	AllDecoderAttentions   []ts.Tensor
+	AllEncoderHiddenStates []ts.Tensor
+	AllEncoderAttentions   []ts.Tensor
+}
+
+// T5Model:
+// ========
+
+// T5Model defines base architecture for T5 models: an encoder and a decoder
+// sharing token embeddings (`shared`).
+type T5Model struct {
+	Shared  *nn.Embedding
+	Encoder *T5Stack
+	Decoder *T5Stack
+}
+
+// NewT5Model builds a new `T5Model`.
+//
+// Params:
+//   - `p`: Variable store path for the root of the T5 model
+//   - `config`: T5Config configuration for model architecture
+func NewT5Model(p *nn.Path, config *T5Config) (*T5Model, error) {
+	shared := nn.NewEmbedding(p.Sub("shared"), config.VocabSize, config.DModel, nn.DefaultEmbeddingConfig())
+
+	encoder, err := NewT5Stack(p.Sub("encoder"), config, shared, false)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := NewT5Stack(p.Sub("decoder"), config, shared, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &T5Model{shared, encoder, decoder}, nil
+}
+
+// Encode forwards pass through the encoder. Its output can be passed to
+// `ForwardT` as `encoderOutput` to avoid re-encoding source at every decoding step.
+func (m *T5Model) Encode(inputIds, mask *ts.Tensor, train bool) (*ts.Tensor, error) {
+	hiddenState, _, _, _, err := m.Encoder.ForwardT(inputIds, mask, ts.None, ts.None, ts.None, nil, train)
+	return hiddenState, err
+}
+
+// ForwardT forwards pass through the model.
+//
+// Params:
+//   - `inputIds`: optional source tokens of shape (batch size, source length).
+//     Not used if `encoderOutput` is provided.
+//   - `mask`: optional source mask of shape (batch size, source length). If None set to 1.
+//     It also masks encoder output in the decoder, hence it is required with `encoderOutput` for padded inputs.
+//   - `encoderOutput`: optional pre-computed encoder output of shape (batch size, source length, d model)
+//   - `decoderInputIds`: optional target tokens of shape (batch size, target length).
+//     If None, pre-computed embeddings must be provided (see `decoderInputEmbeds`)
+//   - `decoderMask`: optional target mask of shape (batch size, cached length + target length)
+//   - `inputEmbeds`: optional pre-computed source embeddings of shape (batch size, source length, d model)
+//   - `decoderInputEmbeds`: optional pre-computed target embeddings of shape (batch size, target length, d model)
+//   - `cache`: optional keys and values cached at previous decoding step (`T5ModelOutput.Cache`).
+//     When set, `decoderInputIds` only holds new tokens.
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+func (m *T5Model) ForwardT(inputIds, mask, encoderOutput, decoderInputIds, decoderMask, inputEmbeds, decoderInputEmbeds *ts.Tensor, cache []BlockState, train bool) (*T5ModelOutput, error) {
+	out := new(T5ModelOutput)
+
+	if encoderOutput.MustDefined() {
+		out.EncoderHiddenState = encoderOutput.MustShallowClone()
+	} else {
+		hiddenState, _, hiddenStates, attentions, err := m.Encoder.ForwardT(inputIds, mask, ts.None, ts.None, inputEmbeds, nil, train)
+		if err != nil {
+			return nil, err
+		}
+		out.EncoderHiddenState = hiddenState
+		out.AllEncoderHiddenStates = hiddenStates
+		out.AllEncoderAttentions = attentions
+	}
+
+	decoderOutput, newCache, hiddenStates, attentions, err := m.Decoder.ForwardT(decoderInputIds, decoderMask, out.EncoderHiddenState, mask, decoderInputEmbeds, cache, train)
+	if err != nil {
+		return nil, err
+	}
+	out.DecoderOutput = decoderOutput
+	out.Cache = newCache
+	out.AllDecoderHiddenStates = hiddenStates
+	out.AllDecoderAttentions = attentions
+
+	return out, nil
+}
+
+// T5ForConditionalGeneration:
+// ===========================
+
+// T5ForConditionalGeneration is T5 with a language model head for text-to-text
+// tasks (e.g., summarization, translation).
+//
+// It is made of the following blocks:
+//   - `shared`, `encoder`, `decoder`: Base T5Model
+//   - `lm_head`: linear layer from d model to vocab size. When `TieWordEmbeddings` is set,
+//     the shared embeddings are used instead and decoder output is rescaled by d model^-0.5.
+type T5ForConditionalGeneration struct {
+	base   *T5Model
+	lmHead *util.LinearNoBias // nil for tied embeddings
+	config *T5Config
+	vs     *nn.VarStore
+}
+
+// NewT5ForConditionalGeneration creates T5ForConditionalGeneration.
+func NewT5ForConditionalGeneration(p *nn.Path, config *T5Config) (*T5ForConditionalGeneration, error) {
+	base, err := NewT5Model(p, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var lmHead *util.LinearNoBias
+	if !config.TieWordEmbeddings {
+		lmHead, err = util.NewLinearNoBias(p.Sub("lm_head"), config.DModel, config.VocabSize, util.DefaultLinearNoBiasConfig())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &T5ForConditionalGeneration{
+		base:   base,
+		lmHead: lmHead,
+		config: config,
+	}, nil
+}
+
+// Load loads model from file or model name. It also updates
+// default configuration parameters if provided.
+// This method implements `pretrained.Model` interface.
+func (g *T5ForConditionalGeneration) Load(modelNameOrPath string, config interface{ pretrained.Config }, params map[string]interface{}, device gotch.Device) (*pretrained.LoadReport, error) {
+	t5Config, ok := config.(*T5Config)
+	if !ok {
+		return nil, fmt.Errorf("T5ForConditionalGeneration.Load() failed: invalid config type %T, want *t5.T5Config", config)
+	}
+
+	vs := nn.NewVarStore(device)
+	model, err := NewT5ForConditionalGeneration(vs.Root(), t5Config)
+	if err != nil {
+		return nil, err
+	}
+	*g = *model
+	g.vs = vs
+
+	return util.LoadVarStore(vs, modelNameOrPath, util.LoadOptionsFromParams(params, "lm_head"), util.ProgressFromParams(params))
+}
+
+// SavePretrained saves model configuration and weights to directory `dir`.
+// Saved model can be loaded back with `Load` by passing `dir` as model name.
+// This method implements `pretrained.Model` interface.
+func (g *T5ForConditionalGeneration) SavePretrained(dir string) error {
+	return util.SaveModel(dir, g.config, g.vs)
+}
+
+// VarStore returns the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with `SetVarStore`.
+func (g *T5ForConditionalGeneration) VarStore() *nn.VarStore {
+	return g.vs
+}
+
+// SetVarStore sets the var store passed to the model constructor so that a model not created
+// with `Load`, e.g. trained from scratch, can be saved with `SavePretrained`.
+func (g *T5ForConditionalGeneration) SetVarStore(vs *nn.VarStore) {
+	g.vs = vs
+}
+
+// Config returns model configuration.
+func (g *T5ForConditionalGeneration) Config() *T5Config {
+	return g.config
+}
+
+// Encode forwards pass through the encoder. See `T5Model.Encode`.
+func (g *T5ForConditionalGeneration) Encode(inputIds, mask *ts.Tensor, train bool) (*ts.Tensor, error) {
+	return g.base.Encode(inputIds, mask, train)
+}
+
+// ForwardT forwards pass through the model. See `T5Model.ForwardT` for params.
+//
+// Returns:
+//   - `logits`: tensor of shape (batch size, target length, vocab size)
+//   - `output`: base model outputs, including encoder output and cache for next decoding step
+func (g *T5ForConditionalGeneration) ForwardT(inputIds, mask, encoderOutput, decoderInputIds, decoderMask, inputEmbeds, decoderInputEmbeds *ts.Tensor, cache []BlockState, train bool) (retVal *ts.Tensor, output *T5ModelOutput, err error) {
+	output, err = g.base.ForwardT(inputIds, mask, encoderOutput, decoderInputIds, decoderMask, inputEmbeds, decoderInputEmbeds, cache, train)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if g.lmHead != nil {
+		return output.DecoderOutput.Apply(g.lmHead), output, nil
+	}
+
+	scaled := output.DecoderOutput.MustMulScalar(ts.FloatScalar(math.Pow(float64(g.config.DModel), -0.5)), false)
+	wsT := g.base.Shared.Ws.MustT(false)
+	retVal = scaled.MustMatmul(wsT, true)
+	wsT.MustDrop()
+
+	return retVal, output, nil
+}
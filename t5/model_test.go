@@ -0,0 +1,91 @@
+package t5_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/t5"
+)
+
+func TestRelativePositionBucket(t *testing.T) {
+	var got []int64
+	for _, pos := range []int64{0, 1, -1, 8, -8, 20, -200, 200} {
+		got = append(got, t5.RelativePositionBucket(pos, true, 32, 128))
+	}
+	for _, pos := range []int64{5, -5, -20, -200} {
+		got = append(got, t5.RelativePositionBucket(pos, false, 32, 128))
+	}
+
+	want := []int64{0, 17, 1, 24, 8, 26, 15, 31, 0, 5, 17, 31}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestT5ForConditionalGeneration(t *testing.T) {
+	modelName := "t5-small"
+	config := new(t5.T5Config)
+	if err := transformer.LoadConfig(config, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+	model := new(t5.T5ForConditionalGeneration)
+	if _, err := transformer.LoadModel(model, modelName, config, nil, gotch.CPU); err != nil {
+		t.Fatal(err)
+	}
+	tk := t5.NewTokenizer()
+	if err := transformer.LoadTokenizer(tk, modelName, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	encoding, err := tk.EncodeSingle("translate English to German: The house is wonderful.", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, id := range encoding.Ids {
+		ids = append(ids, int64(id))
+	}
+	inputIds := ts.MustOfSlice(ids).MustView([]int64{1, -1}, true)
+
+	// Greedy decoding
+	var generated []int
+	ts.NoGrad(func() {
+		var encoderOutput *ts.Tensor
+		encoderOutput, err = model.Encode(inputIds, ts.None, false)
+		if err != nil {
+			return
+		}
+		var cache []t5.BlockState
+		next := config.DecoderStartTokenId
+		for i := 0; i < 20; i++ {
+			step := ts.MustOfSlice([]int64{next}).MustView([]int64{1, 1}, true)
+			var logits *ts.Tensor
+			var output *t5.T5ModelOutput
+			logits, output, err = model.ForwardT(ts.None, ts.None, encoderOutput, step, ts.None, ts.None, ts.None, cache, false)
+			if err != nil {
+				return
+			}
+			cache = output.Cache
+			next = logits.MustGet(0).MustGet(0).MustArgmax([]int64{0}, false, false).Int64Values()[0]
+			if next == config.EosTokenId {
+				break
+			}
+			generated = append(generated, int(next))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Das Haus ist wunderbar."
+	got := tk.Decode(generated, true)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
@@ -0,0 +1,14 @@
+package t5
+
+import (
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// Registers T5 configuration, tokenizer and model for auto-loading
+// (see `transformer.AutoModelFor`).
+func init() {
+	pretrained.RegisterConfig("t5", func() pretrained.Config { return new(T5Config) })
+	pretrained.RegisterTokenizer("t5", func() pretrained.Tokenizer { return NewTokenizer() })
+
+	pretrained.RegisterModel("t5", pretrained.Seq2SeqLM, "T5ForConditionalGeneration", func() pretrained.Model { return new(T5ForConditionalGeneration) })
+}
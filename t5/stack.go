@@ -0,0 +1,308 @@
+package t5
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// T5Block:
+// ========
+
+// BlockState holds cached keys and values of a decoder block: self-attention
+// keys and values grow with every decoding step, encoder-decoder attention ones
+// are computed once from the encoder output.
+type BlockState struct {
+	SelfAttention           *LayerState
+	EncoderDecoderAttention *LayerState
+}
+
+// T5Block is a T5 layer: self-attention, encoder-decoder attention (decoder only)
+// and feed-forward sub-layers.
+type T5Block struct {
+	SelfAttention  *T5LayerAttention
+	CrossAttention *T5LayerAttention // nil for encoder blocks
+	FF             *T5LayerFF
+}
+
+// NewT5Block creates a new T5Block.
+func NewT5Block(p *nn.Path, config *T5Config, isDecoder, hasRelativeAttentionBias bool) (*T5Block, error) {
+	path := p.Sub("layer")
+
+	selfAttention, err := NewT5LayerAttention(path.Sub("0"), "SelfAttention", config, isDecoder, hasRelativeAttentionBias)
+	if err != nil {
+		return nil, err
+	}
+
+	var crossAttention *T5LayerAttention
+	ffIdx := "1"
+	if isDecoder {
+		crossAttention, err = NewT5LayerAttention(path.Sub("1"), "EncDecAttention", config, isDecoder, false)
+		if err != nil {
+			return nil, err
+		}
+		ffIdx = "2"
+	}
+
+	ff, err := NewT5LayerFF(path.Sub(ffIdx), config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &T5Block{selfAttention, crossAttention, ff}, nil
+}
+
+// ForwardT forwards pass through the block.
+//
+// Returns:
+//   - block output of shape (batch size, sequence length, d model)
+//   - self-attention position bias to be reused by next blocks
+//   - encoder-decoder attention position bias to be reused by next blocks (`ts.None` for encoder blocks)
+//   - self-attention weights
+//   - keys and values to cache for next decoding step (nil for encoder blocks)
+func (b *T5Block) ForwardT(hiddenStates, mask, positionBias, encoderHiddenStates, encoderMask, encoderDecoderPositionBias *ts.Tensor, state *BlockState, train bool) (retVal, retValOpt1, retValOpt2, retValOpt3 *ts.Tensor, retState *BlockState) {
+	var selfState, crossState *LayerState
+	if state != nil {
+		selfState, crossState = state.SelfAttention, state.EncoderDecoderAttention
+	}
+
+	hiddenState, positionBias, attentionWeights, newSelfState := b.SelfAttention.ForwardT(hiddenStates, ts.None, positionBias, mask, selfState, train)
+
+	if b.CrossAttention != nil && encoderHiddenStates.MustDefined() {
+		var (
+			stateTmp      *ts.Tensor
+			crossWeights  *ts.Tensor
+			newCrossState *LayerState
+		)
+		stateTmp, encoderDecoderPositionBias, crossWeights, newCrossState = b.CrossAttention.ForwardT(hiddenState, encoderHiddenStates, encoderDecoderPositionBias, encoderMask, crossState, train)
+		crossWeights.MustDrop()
+		hiddenState.MustDrop()
+		hiddenState = stateTmp
+
+		retState = &BlockState{newSelfState, newCrossState}
+	} else if newSelfState != nil {
+		retState = &BlockState{SelfAttention: newSelfState}
+	}
+
+	retVal = b.FF.ForwardT(hiddenState, train)
+	hiddenState.MustDrop()
+
+	return retVal, positionBias, encoderDecoderPositionBias, attentionWeights, retState
+}
+
+// T5Stack:
+// ========
+
+// T5Stack is the T5 encoder or decoder: token embeddings shared with the other
+// stack, `block.N` layers and a final layer norm. Only the first block holds a
+// relative attention bias.
+type T5Stack struct {
+	EmbedTokens        *nn.Embedding
+	Blocks             []*T5Block
+	FinalLayerNorm     *T5LayerNorm
+	Dropout            *util.Dropout
+	IsDecoder          bool
+	OutputAttentions   bool
+	OutputHiddenStates bool
+}
+
+// NewT5Stack creates a new T5Stack using `embedTokens` as token embeddings.
+func NewT5Stack(p *nn.Path, config *T5Config, embedTokens *nn.Embedding, isDecoder bool) (*T5Stack, error) {
+	numLayers := config.NumLayers
+	if isDecoder {
+		numLayers = config.decoderLayers()
+	}
+
+	path := p.Sub("block")
+	var blocks []*T5Block
+	for i := 0; i < int(numLayers); i++ {
+		block, err := NewT5Block(path.Sub(fmt.Sprintf("%v", i)), config, isDecoder, i == 0)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	finalLayerNorm, err := NewT5LayerNorm(p.Sub("final_layer_norm"), config.DModel, config.LayerNormEpsilon)
+	if err != nil {
+		return nil, err
+	}
+
+	return &T5Stack{
+		EmbedTokens:        embedTokens,
+		Blocks:             blocks,
+		FinalLayerNorm:     finalLayerNorm,
+		Dropout:            util.NewDropout(config.DropoutRate),
+		IsDecoder:          isDecoder,
+		OutputAttentions:   config.OutputAttentions,
+		OutputHiddenStates: config.OutputHiddenStates,
+	}, nil
+}
+
+// ForwardT forwards pass through the stack.
+//
+// Params:
+//   - `inputIds`: optional input tensor of shape (batch size, sequence length).
+//     If None, pre-computed embeddings must be provided (see `inputEmbeds`)
+//   - `mask`: optional mask of shape (batch size, cached length + sequence length).
+//     Masked position have value 0, non-masked value 1. If None set to 1.
+//   - `encoderHiddenStates`: encoder output of shape (batch size, source length, d model) for the decoder,
+//     `ts.None` for the encoder
+//   - `encoderMask`: optional mask of shape (batch size, source length) for the decoder
+//   - `inputEmbeds`: optional pre-computed input embeddings of shape (batch size, sequence length, d model).
+//   - `cache`: cached keys and values of previous decoding steps for every block, or nil.
+//     Then `inputIds` only holds new tokens.
+//   - `train`: boolean flag to turn on/off the dropout layers in the model. Should be set to false for inference.
+//
+// Returns:
+//   - `output`: tensor of shape (batch size, sequence length, d model)
+//   - `cache`: updated keys and values for every block (nil for the encoder)
+//   - `hiddenStates`: input of every block and final output if `OutputHiddenStates` is set
+//   - `attentions`: self-attention weights of every block if `OutputAttentions` is set
+func (s *T5Stack) ForwardT(inputIds, mask, encoderHiddenStates, encoderMask, inputEmbeds *ts.Tensor, cache []BlockState, train bool) (retVal *ts.Tensor, retCache []BlockState, retValOpt1, retValOpt2 []ts.Tensor, err error) {
+	var embeddings *ts.Tensor
+	switch {
+	case inputIds.MustDefined() && inputEmbeds.MustDefined():
+		err = fmt.Errorf("Only one of input ids or input embeddings may be set: %w", util.ErrInvalidInput)
+		return
+	case inputIds.MustDefined():
+		embeddings = inputIds.ApplyT(s.EmbedTokens, train)
+	case inputEmbeds.MustDefined():
+		embeddings = inputEmbeds.MustShallowClone()
+	default:
+		err = fmt.Errorf("At least one of input ids or input embeddings must be set: %w", util.ErrInvalidInput)
+		return
+	}
+
+	if cache != nil && len(cache) != len(s.Blocks) {
+		embeddings.MustDrop()
+		err = fmt.Errorf("Invalid cache: got states for %v blocks, want %v: %w", len(cache), len(s.Blocks), util.ErrInvalidInput)
+		return
+	}
+
+	size := embeddings.MustSize()
+	bs, qLen := size[0], size[1]
+	device := embeddings.MustDevice()
+
+	var pastLen int64
+	if cache != nil && cache[0].SelfAttention != nil {
+		pastLen = cache[0].SelfAttention.PrevKey.MustSize()[2]
+	}
+
+	extendedMask := s.extendedMask(mask, bs, qLen, pastLen, device)
+
+	var encoderExtendedMask *ts.Tensor = ts.None
+	if s.IsDecoder && encoderHiddenStates.MustDefined() {
+		encoderExtendedMask = invertMask(encoderMask, bs, encoderHiddenStates.MustSize()[1], device)
+	}
+
+	var (
+		allHiddenStates []ts.Tensor
+		allAttentions   []ts.Tensor
+		positionBias    *ts.Tensor = ts.None
+		encDecPosBias   *ts.Tensor = ts.None
+	)
+
+	hiddenState := embeddings.ApplyT(s.Dropout, train)
+	embeddings.MustDrop()
+
+	for i, block := range s.Blocks {
+		if s.OutputHiddenStates {
+			allHiddenStates = append(allHiddenStates, *hiddenState.MustShallowClone())
+		}
+
+		var state *BlockState
+		if cache != nil {
+			state = &cache[i]
+		}
+
+		stateTmp, posBiasTmp, encDecPosBiasTmp, attnWeights, newState := block.ForwardT(hiddenState, extendedMask, positionBias, encoderHiddenStates, encoderExtendedMask, encDecPosBias, state, train)
+		hiddenState.MustDrop()
+		hiddenState = stateTmp
+		positionBias, encDecPosBias = posBiasTmp, encDecPosBiasTmp
+
+		if s.OutputAttentions {
+			allAttentions = append(allAttentions, *attnWeights)
+		} else {
+			attnWeights.MustDrop()
+		}
+
+		if newState != nil {
+			retCache = append(retCache, *newState)
+		}
+	}
+
+	if positionBias.MustDefined() {
+		positionBias.MustDrop()
+	}
+	if encDecPosBias.MustDefined() {
+		encDecPosBias.MustDrop()
+	}
+	extendedMask.MustDrop()
+	if encoderExtendedMask.MustDefined() {
+		encoderExtendedMask.MustDrop()
+	}
+
+	normed := hiddenState.Apply(s.FinalLayerNorm)
+	hiddenState.MustDrop()
+	retVal = normed.ApplyT(s.Dropout, train)
+	normed.MustDrop()
+
+	if s.OutputHiddenStates {
+		allHiddenStates = append(allHiddenStates, *retVal.MustShallowClone())
+	}
+
+	return retVal, retCache, allHiddenStates, allAttentions, nil
+}
+
+// extendedMask builds the additive self-attention mask of shape (batch size, 1, query length, key length).
+// Decoder queries are additionally prevented to attend to future positions.
+func (s *T5Stack) extendedMask(mask *ts.Tensor, bs, qLen, pastLen int64, device gotch.Device) *ts.Tensor {
+	kLen := pastLen + qLen
+	if !s.IsDecoder {
+		return invertMask(mask, bs, kLen, device)
+	}
+
+	causal := make([]float32, qLen*kLen)
+	for i := int64(0); i < qLen; i++ {
+		for j := int64(0); j <= i+pastLen; j++ {
+			causal[i*kLen+j] = 1
+		}
+	}
+	causalTs := ts.MustOfSlice(causal).MustView([]int64{1, 1, qLen, kLen}, true).MustTo(device, true)
+
+	var maskTs *ts.Tensor
+	if mask.MustDefined() {
+		maskTs = mask.MustTotype(gotch.Float, false)
+	} else {
+		maskTs = ts.MustOnes([]int64{bs, kLen}, gotch.Float, device)
+	}
+	extended := maskTs.MustUnsqueeze(1, true).MustUnsqueeze(2, true).MustMul(causalTs, true)
+	causalTs.MustDrop()
+
+	inverted := extended.MustOnesLike(false).MustSub(extended, true)
+	extended.MustDrop()
+
+	return inverted.MustMulScalar(ts.FloatScalar(-1e9), true)
+}
+
+// invertMask converts a mask of shape (batch size, length) with value 1 for positions
+// to attend to an additive mask of shape (batch size, 1, 1, length).
+func invertMask(mask *ts.Tensor, bs, length int64, device gotch.Device) *ts.Tensor {
+	var maskTs *ts.Tensor
+	if mask.MustDefined() {
+		maskTs = mask.MustTotype(gotch.Float, false)
+	} else {
+		maskTs = ts.MustOnes([]int64{bs, length}, gotch.Float, device)
+	}
+	extended := maskTs.MustUnsqueeze(1, true).MustUnsqueeze(2, true)
+
+	inverted := extended.MustOnesLike(false).MustSub(extended, true)
+	extended.MustDrop()
+
+	return inverted.MustMulScalar(ts.FloatScalar(-1e9), true)
+}
@@ -0,0 +1,85 @@
+package t5
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/processor"
+
+	"github.com/sugarme/transformer/sentencepiece"
+	"github.com/sugarme/transformer/util"
+)
+
+// DefaultExtraIds is the number of sentinel tokens (`<extra_id_0>`, `<extra_id_1>`...)
+// added to T5 vocabulary for span corruption.
+const DefaultExtraIds = 100
+
+// Tokenizer holds data for T5 tokenizer.
+type Tokenizer struct {
+	*tokenizer.Tokenizer
+}
+
+// NewTokenizer creates a new T5 tokenizer.
+func NewTokenizer() *Tokenizer {
+	tk := tokenizer.NewTokenizer(nil)
+	return &Tokenizer{tk}
+}
+
+// Load loads T5 tokenizer from pretrained SentencePiece model file `spiece.model`.
+// An end of sequence token `</s>` is appended to every encoded sequence.
+//
+// Optional params:
+//   - "ExtraIds" (int): number of sentinel tokens. Default=100
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) Load(modelNameOrPath string, params map[string]interface{}) error {
+	cachedFile, err := util.CachedPathWithProgress(modelNameOrPath, sentencepiece.DefaultModelFile, util.ProgressFromParams(params))
+	if err != nil {
+		return err
+	}
+
+	model, err := sentencepiece.NewUnigramFromFile(cachedFile)
+	if err != nil {
+		return err
+	}
+
+	t.WithModel(model)
+	t.WithNormalizer(sentencepiece.NewNormalizer(false, false, false))
+	t.WithPreTokenizer(sentencepiece.NewMetaspace())
+	t.WithDecoder(sentencepiece.NewDecoder())
+
+	extraIds := DefaultExtraIds
+	if v, ok := params["ExtraIds"].(int); ok {
+		extraIds = v
+	}
+
+	var specialTokens []tokenizer.AddedToken
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<pad>", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("</s>", true))
+	specialTokens = append(specialTokens, tokenizer.NewAddedToken("<unk>", true))
+	// Sentinel ids are counted down from the end of vocabulary, i.e. `<extra_id_0>` has the highest id.
+	for i := extraIds - 1; i >= 0; i-- {
+		specialTokens = append(specialTokens, tokenizer.NewAddedToken(fmt.Sprintf("<extra_id_%v>", i), true))
+	}
+	t.AddSpecialTokens(specialTokens)
+
+	eosId, ok := t.TokenToId("</s>")
+	if !ok {
+		return fmt.Errorf("Cannot find ID for </s> token: %w", util.ErrMissingToken)
+	}
+	t.WithPostProcessor(sentencepiece.NewEosProcessing(processor.PostToken{Id: eosId, Value: "</s>"}))
+
+	return nil
+}
+
+// SavePretrained saves SentencePiece model file `spiece.model` to directory `dir`.
+//
+// This method implements `pretrained.Tokenizer` interface.
+func (t *Tokenizer) SavePretrained(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return t.GetModel().Save(dir)
+}
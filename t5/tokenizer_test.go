@@ -0,0 +1,53 @@
+package t5_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/transformer/sentencepiece"
+	"github.com/sugarme/transformer/t5"
+)
+
+func TestTokenizer_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "t5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	proto := sentencepiece.NewModelProto([]sentencepiece.Piece{
+		{Piece: "<pad>", Type: sentencepiece.ControlPiece},
+		{Piece: "</s>", Type: sentencepiece.ControlPiece},
+		{Piece: "<unk>", Type: sentencepiece.UnknownPiece},
+		{Piece: "▁Hello", Score: -1, Type: sentencepiece.NormalPiece},
+		{Piece: "▁World", Score: -1, Type: sentencepiece.NormalPiece},
+	})
+	proto.UnkId, proto.EosId, proto.PadId, proto.BosId = 2, 1, 0, -1
+	if err := proto.WriteFile(filepath.Join(dir, sentencepiece.DefaultModelFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	tk := t5.NewTokenizer()
+	if err := tk.Load(dir, map[string]interface{}{"ExtraIds": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	en, err := tk.EncodeSingle("Hello <extra_id_0> World", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"▁Hello", "<extra_id_0>", "▁World", "</s>"}
+	if !reflect.DeepEqual(want, en.Tokens) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", en.Tokens)
+	}
+	wantIds := []int{3, 6, 4, 1}
+	if !reflect.DeepEqual(wantIds, en.Ids) {
+		t.Errorf("Want: %v\n", wantIds)
+		t.Errorf("Got: %v\n", en.Ids)
+	}
+}
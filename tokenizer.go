@@ -19,3 +19,9 @@ import (
 func LoadTokenizer(tk pretrained.Tokenizer, modelNameOrPath string, customParams map[string]interface{}) error {
 	return tk.Load(modelNameOrPath, customParams)
 }
+
+// SaveTokenizer saves tokenizer vocab files to local directory `dir`.
+// Saved tokenizer can be loaded back with `LoadTokenizer` by passing `dir` as `modelNameOrPath`.
+func SaveTokenizer(tk pretrained.Tokenizer, dir string) error {
+	return tk.SavePretrained(dir)
+}
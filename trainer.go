@@ -1 +1,629 @@
 package transformer
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/albert"
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/distilbert"
+	"github.com/sugarme/transformer/electra"
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/roberta"
+	"github.com/sugarme/transformer/util"
+)
+
+// TrainingArgs holds hyper-parameters and bookkeeping options for `Trainer`.
+type TrainingArgs struct {
+	NumEpochs    int     // number of passes over the training dataset
+	BatchSize    int     // number of examples per optimization step
+	LearningRate float64 // peak learning rate of the optimizer
+	WeightDecay  float64 // decoupled weight decay. Bias and LayerNorm weights are not decayed.
+	WarmupSteps  int     // number of steps to linearly increase the learning rate from 0
+	LRSchedule   string  // learning rate schedule. See `util.NewSchedule` for supported names.
+	MaxGradNorm  float64 // gradient norm clipping threshold. Zero or negative disables clipping.
+	EvalSteps    int     // run evaluation every `EvalSteps` steps. Zero evaluates at the end of each epoch only.
+	SaveSteps    int     // save a checkpoint every `SaveSteps` steps. Zero disables intermediate checkpoints.
+	LoggingSteps int     // report training loss to `Trainer.Logger` every `LoggingSteps` steps. Zero disables logging.
+	OutputDir    string  // directory checkpoints are written to. Empty disables checkpointing.
+	Shuffle      bool    // shuffle training examples at each epoch
+	Seed         int64   // seed for shuffling
+	PadTokenId   int64   // token id used to pad input ids in a batch
+}
+
+// DefaultTrainingArgs returns training arguments with sensible fine-tuning defaults.
+func DefaultTrainingArgs() *TrainingArgs {
+	return &TrainingArgs{
+		NumEpochs:    3,
+		BatchSize:    8,
+		LearningRate: 5e-5,
+		WeightDecay:  0.0,
+		WarmupSteps:  0,
+		LRSchedule:   "linear",
+		MaxGradNorm:  1.0,
+		EvalSteps:    0,
+		SaveSteps:    0,
+		LoggingSteps: 50,
+		OutputDir:    "",
+		Shuffle:      true,
+		Seed:         42,
+		PadTokenId:   0,
+	}
+}
+
+// Example is a single tokenized training example.
+//
+// Which label fields are used depends on the model head being trained:
+//   - sequence classification: `Label`
+//   - token classification and masked language modeling: `Labels` (one per token, -100 is ignored)
+//   - question answering: `StartPosition` and `EndPosition`
+type Example struct {
+	InputIds      []int64
+	Mask          []int64 // optional. If nil, all tokens are attended.
+	TokenTypeIds  []int64 // optional. If nil, set to 0.
+	Label         int64
+	Labels        []int64
+	StartPosition int64
+	EndPosition   int64
+}
+
+// Dataset is a random-access collection of examples.
+type Dataset interface {
+	Len() int
+	Get(idx int) Example
+}
+
+// Batch holds padded tensors collated from a slice of examples.
+type Batch struct {
+	InputIds       *ts.Tensor
+	Mask           *ts.Tensor
+	TokenTypeIds   *ts.Tensor
+	Labels         *ts.Tensor
+	StartPositions *ts.Tensor
+	EndPositions   *ts.Tensor
+}
+
+// NewBatch collates examples into a batch. Sequences are right-padded to the longest
+// example with `padId`; token-level labels are padded with -100 so they are ignored by the loss.
+//
+// It returns an error if `Mask` or `TokenTypeIds` of an example do not have the length of its `InputIds`.
+func NewBatch(examples []Example, padId int64, device gotch.Device) (*Batch, error) {
+	var maxLen int
+	for i, e := range examples {
+		if e.Mask != nil && len(e.Mask) != len(e.InputIds) {
+			err := fmt.Errorf("NewBatch() failed: example %v has %v mask values for %v input ids: %w", i, len(e.Mask), len(e.InputIds), util.ErrInvalidInput)
+			return nil, err
+		}
+		if e.TokenTypeIds != nil && len(e.TokenTypeIds) != len(e.InputIds) {
+			err := fmt.Errorf("NewBatch() failed: example %v has %v token type ids for %v input ids: %w", i, len(e.TokenTypeIds), len(e.InputIds), util.ErrInvalidInput)
+			return nil, err
+		}
+		if len(e.InputIds) > maxLen {
+			maxLen = len(e.InputIds)
+		}
+	}
+
+	n := len(examples)
+	inputIds := make([]int64, n*maxLen)
+	mask := make([]int64, n*maxLen)
+	typeIds := make([]int64, n*maxLen)
+	labels := make([]int64, n*maxLen)
+	seqLabels := make([]int64, n)
+	starts := make([]int64, n)
+	ends := make([]int64, n)
+	tokenLevel := false
+
+	for i, e := range examples {
+		offset := i * maxLen
+		for j := 0; j < maxLen; j++ {
+			labels[offset+j] = -100
+			if j >= len(e.InputIds) {
+				inputIds[offset+j] = padId
+				continue
+			}
+			inputIds[offset+j] = e.InputIds[j]
+			mask[offset+j] = 1
+			if e.Mask != nil {
+				mask[offset+j] = e.Mask[j]
+			}
+			if e.TokenTypeIds != nil {
+				typeIds[offset+j] = e.TokenTypeIds[j]
+			}
+			if j < len(e.Labels) {
+				labels[offset+j] = e.Labels[j]
+			}
+		}
+		if e.Labels != nil {
+			tokenLevel = true
+		}
+		seqLabels[i] = e.Label
+		starts[i] = e.StartPosition
+		ends[i] = e.EndPosition
+	}
+
+	shape := []int64{int64(n), int64(maxLen)}
+	b := &Batch{
+		InputIds:       ts.MustOfSlice(inputIds).MustView(shape, true).MustTo(device, true),
+		Mask:           ts.MustOfSlice(mask).MustView(shape, true).MustTo(device, true),
+		TokenTypeIds:   ts.MustOfSlice(typeIds).MustView(shape, true).MustTo(device, true),
+		StartPositions: ts.MustOfSlice(starts).MustTo(device, true),
+		EndPositions:   ts.MustOfSlice(ends).MustTo(device, true),
+	}
+	if tokenLevel {
+		b.Labels = ts.MustOfSlice(labels).MustView(shape, true).MustTo(device, true)
+	} else {
+		b.Labels = ts.MustOfSlice(seqLabels).MustTo(device, true)
+	}
+
+	return b, nil
+}
+
+// Drop frees all tensors held by the batch.
+func (b *Batch) Drop() {
+	for _, x := range []*ts.Tensor{b.InputIds, b.Mask, b.TokenTypeIds, b.Labels, b.StartPositions, b.EndPositions} {
+		if x != nil {
+			x.MustDrop()
+		}
+	}
+}
+
+// EvalOutput holds the result of an evaluation run.
+type EvalOutput struct {
+	Step int     // global step at which the evaluation was run
+	Loss float64 // average loss over the evaluation dataset
+}
+
+// TrainLog is the training state reported to `Trainer.Logger` every `TrainingArgs.LoggingSteps` steps.
+type TrainLog struct {
+	Epoch int
+	Step  int     // global step
+	Loss  float64 // training loss of the step
+	LR    float64 // learning rate after the step
+}
+
+// TrainOutput holds the result of a training run.
+type TrainOutput struct {
+	GlobalStep   int          // total number of optimization steps
+	TrainingLoss float64      // average training loss over all steps
+	EvalHistory  []EvalOutput // evaluations run during training
+}
+
+// Trainer fine-tunes a task model on a dataset.
+//
+// Supported models are the task heads of the `bert`, `roberta`, `distilbert`, `albert` and `electra` packages:
+// masked LM, sequence classification, token classification and question answering.
+// The loss is computed according to the model head.
+type Trainer struct {
+	Model        interface{}
+	VarStore     *nn.VarStore
+	Args         *TrainingArgs
+	TrainDataset Dataset
+	EvalDataset  Dataset // optional
+
+	// Logger receives training loss every `Args.LoggingSteps` steps, e.g. to print it
+	// or send it to a metrics system. Nil disables logging.
+	Logger func(TrainLog)
+
+	optimizer  *util.AdamW
+	scheduler  *util.Scheduler
+	rng        *rand.Rand
+	globalStep int
+}
+
+// NewTrainer creates a trainer for `model` whose weights live in `vs`.
+//
+// `evalDataset` can be nil in which case no evaluation is run.
+func NewTrainer(vs *nn.VarStore, model interface{}, args *TrainingArgs, trainDataset, evalDataset Dataset) (*Trainer, error) {
+	if vs == nil {
+		return nil, fmt.Errorf("NewTrainer: nil VarStore")
+	}
+	if args == nil {
+		args = DefaultTrainingArgs()
+	}
+	if args.BatchSize <= 0 {
+		return nil, fmt.Errorf("NewTrainer: invalid batch size %v", args.BatchSize)
+	}
+	if trainDataset == nil {
+		return nil, fmt.Errorf("NewTrainer: nil training dataset")
+	}
+	if !isSupportedModel(model) {
+		return nil, fmt.Errorf("NewTrainer: unsupported model type %T", model)
+	}
+
+	stepsPerEpoch := (trainDataset.Len() + args.BatchSize - 1) / args.BatchSize
+	schedule, err := util.NewSchedule(args.LRSchedule, args.WarmupSteps, stepsPerEpoch*args.NumEpochs)
+	if err != nil {
+		return nil, err
+	}
+
+	optConfig := util.DefaultAdamWConfig()
+	optConfig.WeightDecay = args.WeightDecay
+	optimizer := util.NewAdamW(vs, args.LearningRate, optConfig)
+
+	return &Trainer{
+		Model:        model,
+		VarStore:     vs,
+		Args:         args,
+		TrainDataset: trainDataset,
+		EvalDataset:  evalDataset,
+		optimizer:    optimizer,
+		scheduler:    util.NewScheduler(optimizer, args.LearningRate, schedule),
+		rng:          rand.New(rand.NewSource(args.Seed)),
+	}, nil
+}
+
+// GlobalStep returns the number of optimization steps run so far.
+func (t *Trainer) GlobalStep() int {
+	return t.globalStep
+}
+
+// LR returns the current learning rate.
+func (t *Trainer) LR() float64 {
+	return t.optimizer.LR()
+}
+
+// Train runs the training loop for `Args.NumEpochs` epochs.
+func (t *Trainer) Train() (*TrainOutput, error) {
+	out := new(TrainOutput)
+	var totalLoss float64
+	var numSteps int
+
+	for epoch := 0; epoch < t.Args.NumEpochs; epoch++ {
+		indices := t.epochIndices()
+		for start := 0; start < len(indices); start += t.Args.BatchSize {
+			end := start + t.Args.BatchSize
+			if end > len(indices) {
+				end = len(indices)
+			}
+
+			lossVal, err := t.trainStep(indices[start:end])
+			if err != nil {
+				return out, err
+			}
+			t.globalStep++
+			numSteps++
+			totalLoss += lossVal
+
+			if t.Logger != nil && t.Args.LoggingSteps > 0 && t.globalStep%t.Args.LoggingSteps == 0 {
+				t.Logger(TrainLog{Epoch: epoch, Step: t.globalStep, Loss: lossVal, LR: t.LR()})
+			}
+
+			if t.Args.EvalSteps > 0 && t.globalStep%t.Args.EvalSteps == 0 && t.EvalDataset != nil {
+				evalOut, err := t.Evaluate()
+				if err != nil {
+					return out, err
+				}
+				out.EvalHistory = append(out.EvalHistory, *evalOut)
+			}
+
+			if t.Args.SaveSteps > 0 && t.globalStep%t.Args.SaveSteps == 0 && t.Args.OutputDir != "" {
+				dir := filepath.Join(t.Args.OutputDir, fmt.Sprintf("checkpoint-%v", t.globalStep))
+				if err := t.SaveCheckpoint(dir); err != nil {
+					return out, err
+				}
+			}
+		}
+
+		if t.Args.EvalSteps <= 0 && t.EvalDataset != nil {
+			evalOut, err := t.Evaluate()
+			if err != nil {
+				return out, err
+			}
+			out.EvalHistory = append(out.EvalHistory, *evalOut)
+		}
+	}
+
+	out.GlobalStep = t.globalStep
+	if numSteps > 0 {
+		out.TrainingLoss = totalLoss / float64(numSteps)
+	}
+
+	return out, nil
+}
+
+// Evaluate computes the average loss over the evaluation dataset without updating weights.
+func (t *Trainer) Evaluate() (*EvalOutput, error) {
+	if t.EvalDataset == nil {
+		return nil, fmt.Errorf("Evaluate: no evaluation dataset")
+	}
+
+	var (
+		totalLoss float64
+		numSteps  int
+		err       error
+	)
+	n := t.EvalDataset.Len()
+	ts.NoGrad(func() {
+		for start := 0; start < n; start += t.Args.BatchSize {
+			end := start + t.Args.BatchSize
+			if end > n {
+				end = n
+			}
+			var batch *Batch
+			batch, err = t.collate(t.EvalDataset, rangeIndices(start, end))
+			if err != nil {
+				return
+			}
+			var loss *ts.Tensor
+			loss, err = t.computeLoss(batch, false)
+			batch.Drop()
+			if err != nil {
+				return
+			}
+			totalLoss += loss.Float64Values(true)[0]
+			numSteps++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &EvalOutput{Step: t.globalStep}
+	if numSteps > 0 {
+		out.Loss = totalLoss / float64(numSteps)
+	}
+
+	return out, nil
+}
+
+// SaveCheckpoint saves model weights and configuration to `dir`.
+// The checkpoint can be loaded back with the model `Load` method.
+func (t *Trainer) SaveCheckpoint(dir string) error {
+	var config pretrained.Config
+	switch m := t.Model.(type) {
+	case interface{ Config() *bert.BertConfig }:
+		config = m.Config()
+	case interface {
+		Config() *distilbert.DistilBertConfig
+	}:
+		config = m.Config()
+	case interface{ Config() *albert.AlbertConfig }:
+		config = m.Config()
+	case interface {
+		Config() *electra.ElectraConfig
+	}:
+		config = m.Config()
+	default:
+		return fmt.Errorf("SaveCheckpoint() failed: cannot save configuration of model type %T", t.Model)
+	}
+
+	if err := util.SaveVarStore(t.VarStore, dir); err != nil {
+		return err
+	}
+
+	return config.SavePretrained(dir)
+}
+
+func (t *Trainer) trainStep(indices []int) (float64, error) {
+	batch, err := t.collate(t.TrainDataset, indices)
+	if err != nil {
+		return 0, err
+	}
+	defer batch.Drop()
+
+	loss, err := t.computeLoss(batch, true)
+	if err != nil {
+		return 0, err
+	}
+
+	t.optimizer.ZeroGrad()
+	loss.MustBackward()
+	if t.Args.MaxGradNorm > 0 {
+		t.optimizer.ClipGradNorm(t.Args.MaxGradNorm)
+	}
+	t.optimizer.Step()
+	t.scheduler.Step()
+
+	return loss.Float64Values(true)[0], nil
+}
+
+func (t *Trainer) epochIndices() []int {
+	indices := rangeIndices(0, t.TrainDataset.Len())
+	if t.Args.Shuffle {
+		t.rng.Shuffle(len(indices), func(i, j int) {
+			indices[i], indices[j] = indices[j], indices[i]
+		})
+	}
+	return indices
+}
+
+func (t *Trainer) collate(dataset Dataset, indices []int) (*Batch, error) {
+	examples := make([]Example, len(indices))
+	for i, idx := range indices {
+		examples[i] = dataset.Get(idx)
+	}
+	return NewBatch(examples, t.Args.PadTokenId, t.VarStore.Device())
+}
+
+// computeLoss runs a forward pass and computes the loss matching the model head.
+func (t *Trainer) computeLoss(b *Batch, train bool) (*ts.Tensor, error) {
+	switch m := t.Model.(type) {
+	case *bert.BertForSequenceClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return sequenceLoss(logits, b.Labels), nil
+
+	case *roberta.RobertaForSequenceClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return sequenceLoss(logits, b.Labels), nil
+
+	case *distilbert.DistilBertForSequenceClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return sequenceLoss(logits, b.Labels), nil
+
+	case *albert.AlbertForSequenceClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return sequenceLoss(logits, b.Labels), nil
+
+	case *electra.ElectraForSequenceClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return sequenceLoss(logits, b.Labels), nil
+
+	case *bert.BertForTokenClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *roberta.RobertaForTokenClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *distilbert.DistilBertForTokenClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *albert.AlbertForTokenClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *electra.ElectraForTokenClassification:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *bert.BertForMaskedLM:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *roberta.RobertaForMaskedLM:
+		logits, _, _, err := m.Forward(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *distilbert.DistilBertForMaskedLM:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *albert.AlbertForMaskedLM:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *electra.ElectraForMaskedLM:
+		logits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return tokenLoss(logits, b.Labels), nil
+
+	case *bert.BertForQuestionAnswering:
+		startLogits, endLogits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return spanLoss(startLogits, endLogits, b.StartPositions, b.EndPositions), nil
+
+	case *roberta.RobertaForQuestionAnswering:
+		startLogits, endLogits, _, _, err := m.ForwardT(b.InputIds, b.Mask, b.TokenTypeIds, ts.None, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return spanLoss(startLogits, endLogits, b.StartPositions, b.EndPositions), nil
+
+	case *distilbert.DistilBertForQuestionAnswering:
+		startLogits, endLogits, _, _, err := m.ForwardT(b.InputIds, b.Mask, ts.None, train)
+		if err != nil {
+			return nil, err
+		}
+		return spanLoss(startLogits, endLogits, b.StartPositions, b.EndPositions), nil
+
+	default:
+		return nil, fmt.Errorf("Trainer: unsupported model type %T", t.Model)
+	}
+}
+
+func isSupportedModel(model interface{}) bool {
+	switch model.(type) {
+	case *bert.BertForSequenceClassification, *roberta.RobertaForSequenceClassification,
+		*bert.BertForTokenClassification, *roberta.RobertaForTokenClassification,
+		*bert.BertForMaskedLM, *roberta.RobertaForMaskedLM,
+		*bert.BertForQuestionAnswering, *roberta.RobertaForQuestionAnswering,
+		*distilbert.DistilBertForSequenceClassification, *distilbert.DistilBertForTokenClassification,
+		*distilbert.DistilBertForMaskedLM, *distilbert.DistilBertForQuestionAnswering,
+		*albert.AlbertForSequenceClassification, *albert.AlbertForTokenClassification,
+		*albert.AlbertForMaskedLM, *electra.ElectraForSequenceClassification,
+		*electra.ElectraForTokenClassification, *electra.ElectraForMaskedLM:
+		return true
+	default:
+		return false
+	}
+}
+
+// sequenceLoss computes cross-entropy between logits of shape (batch size, num labels)
+// and labels of shape (batch size).
+func sequenceLoss(logits, labels *ts.Tensor) *ts.Tensor {
+	loss := logits.CrossEntropyForLogits(labels)
+	logits.MustDrop()
+	return loss
+}
+
+// tokenLoss computes cross-entropy between logits of shape (batch size, sequence length, num labels)
+// and labels of shape (batch size, sequence length). Labels with value -100 are ignored.
+func tokenLoss(logits, labels *ts.Tensor) *ts.Tensor {
+	size := logits.MustSize()
+	flatLogits := logits.MustView([]int64{-1, size[len(size)-1]}, true)
+	flatLabels := labels.MustView([]int64{-1}, false)
+	loss := flatLogits.CrossEntropyForLogits(flatLabels)
+	flatLogits.MustDrop()
+	flatLabels.MustDrop()
+	return loss
+}
+
+// spanLoss averages start and end position cross-entropy losses.
+func spanLoss(startLogits, endLogits, startPositions, endPositions *ts.Tensor) *ts.Tensor {
+	startLoss := sequenceLoss(startLogits, startPositions)
+	endLoss := sequenceLoss(endLogits, endPositions)
+	loss := startLoss.MustAdd(endLoss, true).MustDivScalar(ts.FloatScalar(2.0), true)
+	endLoss.MustDrop()
+	return loss
+}
+
+func rangeIndices(start, end int) []int {
+	indices := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		indices = append(indices, i)
+	}
+	return indices
+}
@@ -0,0 +1,183 @@
+package transformer_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer"
+	"github.com/sugarme/transformer/bert"
+	"github.com/sugarme/transformer/util"
+)
+
+type toyDataset []transformer.Example
+
+func (d toyDataset) Len() int                        { return len(d) }
+func (d toyDataset) Get(idx int) transformer.Example { return d[idx] }
+
+func tinyBertConfig() *bert.BertConfig {
+	config := bert.NewConfig(map[string]interface{}{
+		"VocabSize":             int64(50),
+		"HiddenSize":            int64(16),
+		"NumHiddenLayers":       int64(2),
+		"NumAttentionHeads":     int64(2),
+		"IntermediateSize":      int64(32),
+		"MaxPositionEmbeddings": int64(32),
+		"HiddenDropoutProb":     float64(0.0),
+	})
+	config.Id2Label = map[int64]string{0: "NEGATIVE", 1: "POSITIVE"}
+	config.Label2Id = map[string]int64{"NEGATIVE": 0, "POSITIVE": 1}
+	config.NumLabels = 2
+
+	return config
+}
+
+func TestTrainer_SequenceClassification(t *testing.T) {
+	device := gotch.CPU
+	vs := nn.NewVarStore(device)
+	config := tinyBertConfig()
+	model, err := bert.NewBertForSequenceClassification(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataset := toyDataset{
+		{InputIds: []int64{1, 5, 6, 2}, Label: 0},
+		{InputIds: []int64{1, 7, 8, 9, 2}, Label: 1},
+		{InputIds: []int64{1, 5, 2}, Label: 0},
+		{InputIds: []int64{1, 8, 9, 2}, Label: 1},
+	}
+
+	outputDir, err := ioutil.TempDir("", "trainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	args := transformer.DefaultTrainingArgs()
+	args.NumEpochs = 5
+	args.BatchSize = 2
+	args.LearningRate = 1e-3
+	args.LoggingSteps = 5
+	args.EvalSteps = 5
+	args.SaveSteps = 10
+	args.OutputDir = outputDir
+
+	trainer, err := transformer.NewTrainer(vs, model, args, dataset, dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logSteps []int
+	trainer.Logger = func(l transformer.TrainLog) {
+		logSteps = append(logSteps, l.Step)
+	}
+
+	before, err := trainer.Evaluate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := trainer.Train()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantSteps := 10
+	if !reflect.DeepEqual(wantSteps, out.GlobalStep) {
+		t.Errorf("Want: %v\n", wantSteps)
+		t.Errorf("Got: %v\n", out.GlobalStep)
+	}
+
+	wantLogSteps := []int{5, 10}
+	if !reflect.DeepEqual(wantLogSteps, logSteps) {
+		t.Errorf("Want: %v\n", wantLogSteps)
+		t.Errorf("Got: %v\n", logSteps)
+	}
+
+	wantEvals := 2
+	if !reflect.DeepEqual(wantEvals, len(out.EvalHistory)) {
+		t.Errorf("Want: %v\n", wantEvals)
+		t.Errorf("Got: %v\n", len(out.EvalHistory))
+	}
+
+	after := out.EvalHistory[len(out.EvalHistory)-1]
+	if after.Loss >= before.Loss {
+		t.Errorf("Want eval loss below %v\n", before.Loss)
+		t.Errorf("Got: %v\n", after.Loss)
+	}
+
+	checkpoint := filepath.Join(outputDir, "checkpoint-10", util.SafetensorsName)
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Errorf("Want checkpoint at %v\n", checkpoint)
+		t.Errorf("Got: %v\n", err)
+	}
+}
+
+func TestTrainer_TokenClassification(t *testing.T) {
+	device := gotch.CPU
+	vs := nn.NewVarStore(device)
+	config := tinyBertConfig()
+	model, err := bert.NewBertForTokenClassification(vs.Root(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataset := toyDataset{
+		{InputIds: []int64{1, 5, 6, 2}, Labels: []int64{-100, 0, 1, -100}},
+		{InputIds: []int64{1, 7, 2}, Labels: []int64{-100, 1, -100}},
+	}
+
+	args := transformer.DefaultTrainingArgs()
+	args.NumEpochs = 1
+	args.BatchSize = 2
+	args.LoggingSteps = 0
+
+	trainer, err := transformer.NewTrainer(vs, model, args, dataset, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := trainer.Train()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantSteps := 1
+	if !reflect.DeepEqual(wantSteps, out.GlobalStep) {
+		t.Errorf("Want: %v\n", wantSteps)
+		t.Errorf("Got: %v\n", out.GlobalStep)
+	}
+}
+
+func TestNewTrainer_UnsupportedModel(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	_, err := transformer.NewTrainer(vs, struct{}{}, nil, toyDataset{}, nil)
+	if err == nil {
+		t.Errorf("Want error for unsupported model\n")
+	}
+}
+
+func TestNewBatch_InvalidLength(t *testing.T) {
+	examples := []transformer.Example{
+		{InputIds: []int64{1, 5, 6, 2}, Mask: []int64{1, 1, 1, 1}},
+		{InputIds: []int64{1, 7, 8, 9, 2}, Mask: []int64{1, 1}},
+	}
+	if _, err := transformer.NewBatch(examples, 0, gotch.CPU); !errors.Is(err, util.ErrInvalidInput) {
+		t.Errorf("Want: %v\n", util.ErrInvalidInput)
+		t.Errorf("Got: %v\n", err)
+	}
+
+	examples = []transformer.Example{
+		{InputIds: []int64{1, 5, 6, 2}, TokenTypeIds: []int64{0, 0, 0}},
+	}
+	if _, err := transformer.NewBatch(examples, 0, gotch.CPU); !errors.Is(err, util.ErrInvalidInput) {
+		t.Errorf("Want: %v\n", util.ErrInvalidInput)
+		t.Errorf("Got: %v\n", err)
+	}
+}
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"math"
+
 	"github.com/sugarme/gotch/ts"
 )
 
@@ -120,16 +122,43 @@ func (m MishActivation) Name() (retVal string) {
 	return m.name
 }
 
+// GeLUNew activation:
+// ==================
+
+// GeluNewActivation is the tanh approximation of GeLU used by
+// ALBERT, GPT-2 and T5 (`gelu_new` in model configurations).
+type GeluNewActivation struct {
+	name string
+}
+
+var GeluNew = GeluNewActivation{}
+
+func NewGeluNew() GeluNewActivation {
+	return GeluNewActivation{"gelu_new"}
+}
+
+// Fwd computes x * 0.5 * (1 + tanh(sqrt(2/pi) * (x + 0.044715 * x^3))).
+func (g GeluNewActivation) Fwd(x *ts.Tensor) (retVal *ts.Tensor) {
+	return geluNew(x)
+}
+
+func (g GeluNewActivation) Name() (retVal string) {
+	return g.name
+}
+
 func geluNew(xs *ts.Tensor) (retVal *ts.Tensor) {
-	// TODO: implement
-	// x * 0.5 * (((x.pow(3.0f64) * 0.044715 + x) * ((2f64 / PI).sqrt())).tanh() + 1)
+	cube := xs.MustPowTensorScalar(ts.FloatScalar(3.0), false)
+	inner := cube.MustMulScalar(ts.FloatScalar(0.044715), true).MustAdd(xs, true)
+	tanh := inner.MustMulScalar(ts.FloatScalar(math.Sqrt(2.0/math.Pi)), true).MustTanh(true)
+	retVal = tanh.MustAddScalar(ts.FloatScalar(1.0), true).MustMul(xs, true).MustMulScalar(ts.FloatScalar(0.5), true)
 	return retVal
 }
 
 var ActivationFnMap map[string]ActivationFn = map[string]ActivationFn{
-	"gelu":  NewGelu(),
-	"relu":  NewRelu(),
-	"tanh":  NewTanh(),
-	"swish": NewSwish(),
-	"mish":  NewMish(),
+	"gelu":     NewGelu(),
+	"gelu_new": NewGeluNew(),
+	"relu":     NewRelu(),
+	"tanh":     NewTanh(),
+	"swish":    NewSwish(),
+	"mish":     NewMish(),
 }
@@ -0,0 +1,577 @@
+package util
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file provides a content-addressed model cache with the same layout as Hugging Face hub cache:
+//
+//	{Dir}/models--{org}--{name}/
+//	    blobs/{etag}                     file contents, named by their ETag (SHA-256 for LFS files)
+//	    refs/{revision}                  commit hash a branch or tag resolved to
+//	    snapshots/{commit}/{file}        links to blobs
+//	{Dir}/.locks/models--{org}--{name}/  lock files of downloads in progress
+//
+// Files are shared between revisions, downloads are verified against their ETag and resumed
+// if interrupted, and concurrent processes downloading the same file wait for each other.
+
+// ErrChecksum is returned when a downloaded file does not match its size or ETag.
+var ErrChecksum = errors.New("checksum mismatch")
+
+const (
+	repoPrefix     = "models--"
+	lastAccessName = ".last_access"
+	incompleteExt  = ".incomplete"
+)
+
+// Cache is a content-addressed cache of model files.
+type Cache struct {
+	Dir string
+}
+
+// NewCache creates a Cache at `dir`.
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// repoDir returns cache directory of a model. E.g., "google/electra-small" is cached at
+// "{Dir}/models--google--electra-small".
+func (c *Cache) repoDir(modelName string) string {
+	return filepath.Join(c.Dir, repoPrefix+strings.Replace(modelName, "/", "--", -1))
+}
+
+// commit returns commit hash `revision` was last resolved to, or `revision` itself if
+// it is not a known branch or tag.
+func (c *Cache) commit(modelName, revision string) string {
+	buff, err := ioutil.ReadFile(filepath.Join(c.repoDir(modelName), "refs", filepath.FromSlash(revision)))
+	if err != nil {
+		return revision
+	}
+	commit := strings.TrimSpace(string(buff))
+	if !isCommitHash(commit) {
+		return revision
+	}
+
+	return commit
+}
+
+// touch records access time of a model. Errors are ignored so that read-only caches work.
+func (c *Cache) touch(modelName string) {
+	file := filepath.Join(c.repoDir(modelName), lastAccessName)
+	now := time.Now()
+	if err := os.Chtimes(file, now, now); os.IsNotExist(err) {
+		if f, err := os.Create(file); err == nil {
+			f.Close()
+		}
+	}
+}
+
+// Lookup returns path to cached `fileName` of model at `revision`.
+func (c *Cache) Lookup(modelName, revision, fileName string) (string, bool) {
+	if !isRevision(revision) {
+		return "", false
+	}
+	file := filepath.Join(c.repoDir(modelName), "snapshots", c.commit(modelName, revision), filepath.FromSlash(fileName))
+	// NOTE. Stat follows links so that links to removed blobs are not found.
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return "", false
+	}
+	c.touch(modelName)
+
+	return file, true
+}
+
+// fileMeta is metadata of a file to download.
+type fileMeta struct {
+	commit string
+	etag   string
+	size   int64 // -1 if unknown
+	hub    bool  // ETag is a hash of file content as served by Hugging Face hub
+}
+
+// Download downloads `fileName` of model at `revision` from `url` into cache and returns path to cached file.
+//
+// Commit hash and ETag are read from `X-Repo-Commit` and `ETag` (or `X-Linked-Etag`) headers of a HEAD request.
+// Files from a hub (responses with commit hash) are verified against their ETag, a SHA-256 for LFS files or
+// a git blob SHA-1 for others. Without commit hash, only their size is verified. Files are cached under
+// `revision` as is if the commit hash is missing or is not a full hexadecimal hash. `revision` may be nested
+// (e.g. "refs/pr/1") but an invalid revision (e.g. with ".." elements) returns an error wrapping
+// `ErrInvalidInput`. Download progress is reported to `progress`, or `DefaultProgress` if nil.
+func (c *Cache) Download(client *http.Client, url, modelName, revision, fileName string, progress ProgressReporter) (string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if !isRevision(revision) {
+		return "", fmt.Errorf("Download() invalid revision %q: %w", revision, ErrInvalidInput)
+	}
+
+	repoDir := c.repoDir(modelName)
+	lockDir := filepath.Join(c.Dir, ".locks", filepath.Base(repoDir))
+	for _, dir := range []string{filepath.Join(repoDir, "blobs"), filepath.Join(repoDir, "refs"), lockDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+
+	meta, err := headFile(client, url)
+	if err != nil {
+		return "", err
+	}
+	if meta.commit == "" {
+		meta.commit = revision
+	}
+
+	// Downloads of the same blob are serialized, whatever the revision or file name.
+	unlock, err := lockFile(filepath.Join(lockDir, downloadName(url, meta)+".lock"))
+	if err != nil {
+		return "", fmt.Errorf("Download() failed to lock: %w", err)
+	}
+	defer unlock()
+
+	// Another process may have downloaded the blob while waiting for the lock.
+	var blob string
+	if meta.etag != "" {
+		blob = filepath.Join(repoDir, "blobs", meta.etag)
+	}
+	if _, err := os.Stat(blob); blob == "" || err != nil {
+		if blob, err = c.downloadBlob(client, url, repoDir, fileName, meta, progressOrDefault(progress)); err != nil {
+			return "", err
+		}
+	}
+
+	if meta.commit != revision {
+		// Revisions may be nested, e.g. "refs/pr/1".
+		ref := filepath.Join(repoDir, "refs", filepath.FromSlash(revision))
+		if err := os.MkdirAll(filepath.Dir(ref), 0755); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(ref, []byte(meta.commit), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	file := filepath.Join(repoDir, "snapshots", meta.commit, filepath.FromSlash(fileName))
+	if err := linkBlob(blob, file); err != nil {
+		return "", err
+	}
+	c.touch(modelName)
+
+	return file, nil
+}
+
+// headFile reads metadata of file at `url` without following redirects, as LFS files
+// are redirected to a storage server that does not know their ETag or commit hash.
+func headFile(client *http.Client, url string) (*fileMeta, error) {
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	meta := &fileMeta{size: -1}
+	resp, err := noRedirect.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("download file not found: %q: %w", url, os.ErrNotExist)
+	case resp.StatusCode >= 400:
+		// Servers not supporting HEAD requests: metadata are unknown.
+		return meta, nil
+	}
+
+	commit := resp.Header.Get("X-Repo-Commit")
+	meta.hub = commit != ""
+	// Commit hash names a cache directory: anything else is ignored.
+	if isCommitHash(commit) {
+		meta.commit = commit
+	}
+	meta.etag = resp.Header.Get("X-Linked-Etag")
+	if meta.etag == "" {
+		meta.etag = resp.Header.Get("ETag")
+	}
+	meta.etag = strings.Trim(strings.TrimPrefix(meta.etag, "W/"), `"`)
+	if strings.ContainsAny(meta.etag, `/\`) || meta.etag == "." || meta.etag == ".." {
+		meta.etag = ""
+	}
+
+	size := resp.Header.Get("X-Linked-Size")
+	if size == "" && resp.StatusCode == http.StatusOK {
+		size = resp.Header.Get("Content-Length")
+	}
+	if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+		meta.size = n
+	}
+
+	return meta, nil
+}
+
+// downloadName names lock and incomplete files of a download: ETag of file, or SHA-256
+// of its URL if ETag is unknown.
+func downloadName(url string, meta *fileMeta) string {
+	if meta.etag != "" {
+		return meta.etag
+	}
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// downloadBlob downloads file at `url` into blobs of `repoDir`, resuming an incomplete
+// download with a HTTP Range request, and returns path to verified blob.
+func (c *Cache) downloadBlob(client *http.Client, url, repoDir, fileName string, meta *fileMeta, progress ProgressReporter) (string, error) {
+	blobsDir := filepath.Join(repoDir, "blobs")
+	tmpFile := filepath.Join(blobsDir, downloadName(url, meta)+incompleteExt)
+
+	out, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	// Hash already downloaded bytes of an interrupted download.
+	hasher := sha256.New()
+	offset, err := io.Copy(hasher, out)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	if offset > 0 && meta.etag != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK, http.StatusRequestedRangeNotSatisfiable:
+		// Range not supported or partial file is invalid: restart download.
+		if offset > 0 {
+			if err := out.Truncate(0); err != nil {
+				return "", err
+			}
+			if _, err := out.Seek(0, io.SeekStart); err != nil {
+				return "", err
+			}
+			hasher.Reset()
+			offset = 0
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			req.Header.Del("Range")
+			if resp, err = client.Do(req); err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return "", fmt.Errorf("download file failed: %q: bad status %s", url, resp.Status)
+			}
+		}
+	case http.StatusNotFound:
+		return "", fmt.Errorf("download file not found: %q: %w", url, os.ErrNotExist)
+	default:
+		return "", fmt.Errorf("download file failed: %q: bad status %s", url, resp.Status)
+	}
+
+	if meta.size < 0 && resp.ContentLength >= 0 {
+		meta.size = offset + resp.ContentLength
+	}
+
+	counter := &progressWriter{progress: progress, fileName: fileName, done: offset, total: meta.size}
+	n, err := io.Copy(io.MultiWriter(out, hasher), io.TeeReader(resp.Body, counter))
+	if err != nil {
+		// Keep incomplete file to resume download.
+		return "", err
+	}
+	if meta.size < 0 {
+		progress.Progress(fileName, counter.done, counter.done)
+	}
+
+	sha256Sum := hex.EncodeToString(hasher.Sum(nil))
+	if err := verifyBlob(out, offset+n, sha256Sum, meta); err != nil {
+		out.Close()
+		os.Remove(tmpFile)
+		return "", fmt.Errorf("download file %q failed: %w", url, err)
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+
+	blobName := meta.etag
+	if blobName == "" {
+		blobName = sha256Sum
+	}
+	blob := filepath.Join(blobsDir, blobName)
+	if err := os.Rename(tmpFile, blob); err != nil {
+		return "", err
+	}
+
+	return blob, nil
+}
+
+// verifyBlob checks downloaded file size, and its hash if hub ETag is a SHA-256 or git blob SHA-1 hash.
+func verifyBlob(f *os.File, size int64, sha256Sum string, meta *fileMeta) error {
+	if meta.size >= 0 && size != meta.size {
+		return fmt.Errorf("%w: want %d bytes, got %d bytes", ErrChecksum, meta.size, size)
+	}
+
+	etag := strings.ToLower(meta.etag)
+	if !meta.hub || !isHex(etag) {
+		return nil
+	}
+	switch len(etag) {
+	case sha256.Size * 2:
+		if sha256Sum != etag {
+			return fmt.Errorf("%w: want sha256 %s, got %s", ErrChecksum, etag, sha256Sum)
+		}
+	case sha1.Size * 2:
+		// Regular git files: ETag is SHA-1 of "blob {size}\x00{content}".
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		h := sha1.New()
+		fmt.Fprintf(h, "blob %d\x00", size)
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != etag {
+			return fmt.Errorf("%w: want git sha1 %s, got %s", ErrChecksum, etag, sum)
+		}
+	}
+
+	return nil
+}
+
+// isCommitHash reports whether `s` is a full git commit hash.
+func isCommitHash(s string) bool {
+	return len(s) == 40 && isHex(s)
+}
+
+// isRevision reports whether `revision` can name a cache directory: a branch, tag or commit
+// hash, possibly nested (e.g. "refs/pr/1"), without empty, "." or ".." elements.
+func isRevision(revision string) bool {
+	if revision == "" || strings.ContainsAny(revision, `\:`) {
+		return false
+	}
+	for _, elem := range strings.Split(revision, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// linkBlob links snapshot `file` to `blob`, with a relative symbolic link if supported,
+// a hard link or a copy otherwise.
+func linkBlob(blob, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if rel, err := filepath.Rel(filepath.Dir(file), blob); err == nil {
+		if err := os.Symlink(rel, file); err == nil {
+			return nil
+		}
+	}
+	if err := os.Link(blob, file); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// CachedModel is a model in cache.
+type CachedModel struct {
+	Name       string            // model name, e.g. "bert-base-uncased"
+	Dir        string            // cache directory of model
+	Size       int64             // total size of files in bytes
+	Refs       map[string]string // branches and tags to commit hashes
+	Revisions  []string          // cached commit hashes (or revisions without commit hash)
+	LastAccess time.Time
+}
+
+// Models lists models in cache, most recently accessed first.
+func (c *Cache) Models() ([]CachedModel, error) {
+	entries, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var models []CachedModel
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), repoPrefix) {
+			continue
+		}
+		model, err := c.model(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, *model)
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		return models[i].LastAccess.After(models[j].LastAccess)
+	})
+
+	return models, nil
+}
+
+func (c *Cache) model(dirName string) (*CachedModel, error) {
+	dir := filepath.Join(c.Dir, dirName)
+	model := &CachedModel{
+		Name: strings.Replace(strings.TrimPrefix(dirName, repoPrefix), "--", "/", -1),
+		Dir:  dir,
+		Refs: make(map[string]string),
+	}
+
+	blobs, err := ioutil.ReadDir(filepath.Join(dir, "blobs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		model.Size += blob.Size()
+		if blob.ModTime().After(model.LastAccess) {
+			model.LastAccess = blob.ModTime()
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, lastAccessName)); err == nil {
+		model.LastAccess = info.ModTime()
+	}
+
+	refsDir := filepath.Join(dir, "refs")
+	err = filepath.Walk(refsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		buff, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		ref, _ := filepath.Rel(refsDir, path)
+		model.Refs[filepath.ToSlash(ref)] = strings.TrimSpace(string(buff))
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	snapshots, err := ioutil.ReadDir(filepath.Join(dir, "snapshots"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		model.Revisions = append(model.Revisions, snapshot.Name())
+	}
+
+	return model, nil
+}
+
+// Remove removes a model from cache.
+func (c *Cache) Remove(modelName string) error {
+	if err := os.RemoveAll(c.repoDir(modelName)); err != nil {
+		return fmt.Errorf("Remove() failed: %w", err)
+	}
+	os.RemoveAll(filepath.Join(c.Dir, ".locks", filepath.Base(c.repoDir(modelName))))
+
+	return nil
+}
+
+// PruneOptions selects models removed by `Prune`.
+type PruneOptions struct {
+	// OlderThan removes models not accessed for longer than this duration. Zero keeps all models.
+	OlderThan time.Duration
+
+	// MaxSize removes least recently accessed models until cache size is at most this many bytes.
+	// Zero means no size limit.
+	MaxSize int64
+
+	// DryRun returns models that would be removed without removing them.
+	DryRun bool
+}
+
+// Prune removes models from cache according to `opts` and returns removed models.
+func (c *Cache) Prune(opts PruneOptions) ([]CachedModel, error) {
+	models, err := c.Models()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		removed []CachedModel
+		kept    []CachedModel
+		size    int64
+	)
+	for _, model := range models {
+		if opts.OlderThan > 0 && time.Since(model.LastAccess) > opts.OlderThan {
+			removed = append(removed, model)
+			continue
+		}
+		kept = append(kept, model)
+		size += model.Size
+	}
+	// Models are sorted most recently accessed first.
+	for i := len(kept) - 1; i >= 0 && opts.MaxSize > 0 && size > opts.MaxSize; i-- {
+		removed = append(removed, kept[i])
+		size -= kept[i].Size
+	}
+
+	if opts.DryRun {
+		return removed, nil
+	}
+	for _, model := range removed {
+		if err := c.Remove(model.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,349 @@
+package util_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sugarme/transformer/util"
+)
+
+type hubFile struct {
+	content string
+	commit  string
+	etag    string // default: SHA-256 of content
+}
+
+// newTestLFSHub serves files like Hugging Face hub LFS files, with commit hash and SHA-256 ETag
+// headers and Range requests support. It records Range headers of GET requests.
+func newTestLFSHub(files map[string]hubFile) (*httptest.Server, func() []string) {
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "GET" {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+
+		etag := file.etag
+		if etag == "" {
+			sum := sha256.Sum256([]byte(file.content))
+			etag = hex.EncodeToString(sum[:])
+		}
+		w.Header().Set("X-Repo-Commit", file.commit)
+		w.Header().Set("ETag", fmt.Sprintf("%q", etag))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader([]byte(file.content)))
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ranges...)
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCache_Download(t *testing.T) {
+	weights := "weights of model"
+	c1, c2 := strings.Repeat("c1", 20), strings.Repeat("c2", 20)
+	server, _ := newTestLFSHub(map[string]hubFile{
+		"/org/model/resolve/main/model.bin":   {content: weights, commit: c2},
+		"/org/model/resolve/v1.0/model.bin":   {content: weights, commit: c1},
+		"/org/model/resolve/main/config.json": {content: `{}`, commit: c2, etag: sha256Hex("corrupted")},
+	})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache := util.NewCache(dir)
+
+	for _, rev := range []string{"main", "v1.0"} {
+		url := fmt.Sprintf("%s/org/model/resolve/%s/model.bin", server.URL, rev)
+		file, err := cache.Download(server.Client(), url, "org/model", rev, "model.bin", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, file); got != weights {
+			t.Errorf("Want: %v\n", weights)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+
+	// Revisions are resolved to their commits and share the same blob.
+	repoDir := filepath.Join(dir, "models--org--model")
+	for _, file := range []string{
+		filepath.Join("blobs", sha256Hex(weights)),
+		filepath.Join("refs", "main"),
+		filepath.Join("refs", "v1.0"),
+		filepath.Join("snapshots", c1, "model.bin"),
+		filepath.Join("snapshots", c2, "model.bin"),
+	} {
+		if _, err := os.Stat(filepath.Join(repoDir, file)); err != nil {
+			t.Errorf("Want cached file %v: %v\n", file, err)
+		}
+	}
+	blobs, err := ioutil.ReadDir(filepath.Join(repoDir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 {
+		t.Errorf("Want: %v\n", 1)
+		t.Errorf("Got: %v\n", len(blobs))
+	}
+
+	if file, ok := cache.Lookup("org/model", "v1.0", "model.bin"); !ok || file != filepath.Join(repoDir, "snapshots", c1, "model.bin") {
+		t.Errorf("Want lookup of revision v1.0, got %q, %v\n", file, ok)
+	}
+	if _, ok := cache.Lookup("org/model", c2, "model.bin"); !ok {
+		t.Errorf("Want lookup of commit %v\n", c2)
+	}
+
+	// Corrupted download is detected and not cached.
+	url := fmt.Sprintf("%s/org/model/resolve/main/config.json", server.URL)
+	if _, err := cache.Download(server.Client(), url, "org/model", "main", "config.json", nil); !errors.Is(err, util.ErrChecksum) {
+		t.Errorf("Want: %v\n", util.ErrChecksum)
+		t.Errorf("Got: %v\n", err)
+	}
+	if _, ok := cache.Lookup("org/model", "main", "config.json"); ok {
+		t.Errorf("Want corrupted file not cached\n")
+	}
+}
+
+func TestCache_Download_Revisions(t *testing.T) {
+	commit := strings.Repeat("ab", 20)
+	server, _ := newTestLFSHub(map[string]hubFile{
+		"/org/model/resolve/refs/pr/1/model.bin": {content: "pr", commit: commit},
+		"/org/model/resolve/main/model.bin":      {content: "main", commit: "../../../escaped"},
+	})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache := util.NewCache(dir)
+	repoDir := filepath.Join(dir, "models--org--model")
+
+	// Nested revision.
+	url := server.URL + "/org/model/resolve/refs/pr/1/model.bin"
+	file, err := cache.Download(server.Client(), url, "org/model", "refs/pr/1", "model.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(repoDir, "snapshots", commit, "model.bin")
+	if file != want {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", file)
+	}
+	if got, ok := cache.Lookup("org/model", "refs/pr/1", "model.bin"); !ok || got != want {
+		t.Errorf("Want lookup of revision refs/pr/1, got %q, %v\n", got, ok)
+	}
+
+	// Invalid commit hash is ignored: file is cached under its revision.
+	url = server.URL + "/org/model/resolve/main/model.bin"
+	file, err = cache.Download(server.Client(), url, "org/model", "main", "model.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = filepath.Join(repoDir, "snapshots", "main", "model.bin")
+	if file != want {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", file)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("Want no file outside of cache, got %v\n", err)
+	}
+
+	// Invalid revision.
+	for _, rev := range []string{"", "..", "../main", "refs//1", `refs\pr`} {
+		if _, err := cache.Download(server.Client(), url, "org/model", rev, "model.bin", nil); !errors.Is(err, util.ErrInvalidInput) {
+			t.Errorf("Want: %v\n", util.ErrInvalidInput)
+			t.Errorf("Got: %v\n", err)
+		}
+	}
+}
+
+func TestCache_Download_Resume(t *testing.T) {
+	content := "0123456789abcdef"
+	server, ranges := newTestLFSHub(map[string]hubFile{
+		"/org/model/resolve/main/model.bin": {content: content, commit: "c1"},
+	})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Interrupted download.
+	blobsDir := filepath.Join(dir, "models--org--model", "blobs")
+	if err := os.MkdirAll(blobsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(blobsDir, sha256Hex(content)+".incomplete"), []byte(content[:6]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	url := server.URL + "/org/model/resolve/main/model.bin"
+	file, err := util.NewCache(dir).Download(server.Client(), url, "org/model", "main", "model.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, file); got != content {
+		t.Errorf("Want: %v\n", content)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	want := []string{"bytes=6-"}
+	got := ranges()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestCache_Download_Concurrent(t *testing.T) {
+	content := "concurrent"
+	server, ranges := newTestLFSHub(map[string]hubFile{
+		"/org/model/resolve/main/model.bin": {content: content, commit: "c1"},
+	})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	url := server.URL + "/org/model/resolve/main/model.bin"
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = util.NewCache(dir).Download(server.Client(), url, "org/model", "main", "model.bin", nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(ranges()); n != 1 {
+		t.Errorf("Want: %v\n", 1)
+		t.Errorf("Got: %v\n", n)
+	}
+}
+
+func TestCache_Prune(t *testing.T) {
+	server, _ := newTestLFSHub(map[string]hubFile{
+		"/a/resolve/main/model.bin": {content: "aaaa", commit: "c1"},
+		"/b/resolve/main/model.bin": {content: "bbbbbbbb", commit: "c1"},
+		"/c/resolve/main/model.bin": {content: "cc", commit: "c1"},
+	})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache := util.NewCache(dir)
+
+	// Models accessed 3, 2 and 1 hours ago.
+	for i, name := range []string{"a", "b", "c"} {
+		url := fmt.Sprintf("%s/%s/resolve/main/model.bin", server.URL, name)
+		if _, err := cache.Download(server.Client(), url, name, "main", "model.bin", nil); err != nil {
+			t.Fatal(err)
+		}
+		accessed := time.Now().Add(-time.Duration(3-i) * time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, "models--"+name, ".last_access"), accessed, accessed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	models, err := cache.Models()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var sizes []int64
+	for _, m := range models {
+		names = append(names, m.Name)
+		sizes = append(sizes, m.Size)
+	}
+	wantNames := []string{"c", "b", "a"}
+	if !reflect.DeepEqual(wantNames, names) {
+		t.Errorf("Want: %v\n", wantNames)
+		t.Errorf("Got: %v\n", names)
+	}
+	wantSizes := []int64{2, 8, 4}
+	if !reflect.DeepEqual(wantSizes, sizes) {
+		t.Errorf("Want: %v\n", wantSizes)
+		t.Errorf("Got: %v\n", sizes)
+	}
+
+	// Dry run removes nothing.
+	removed, err := cache.Prune(util.PruneOptions{OlderThan: 150 * time.Minute, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models, _ := cache.Models(); len(removed) != 1 || len(models) != 3 {
+		t.Errorf("Want 1 model to remove and 3 models kept, got %v and %v\n", len(removed), len(models))
+	}
+
+	// "a" is too old, then "b" is least recently used over max size.
+	removed, err = cache.Prune(util.PruneOptions{OlderThan: 150 * time.Minute, MaxSize: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, m := range removed {
+		names = append(names, m.Name)
+	}
+	sort.Strings(names)
+	wantNames = []string{"a", "b"}
+	if !reflect.DeepEqual(wantNames, names) {
+		t.Errorf("Want: %v\n", wantNames)
+		t.Errorf("Got: %v\n", names)
+	}
+	if _, ok := cache.Lookup("c", "main", "model.bin"); !ok {
+		t.Errorf("Want model c kept\n")
+	}
+	if _, ok := cache.Lookup("a", "main", "model.bin"); ok {
+		t.Errorf("Want model a removed\n")
+	}
+}
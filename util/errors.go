@@ -0,0 +1,31 @@
+package util
+
+import "errors"
+
+// This file provides errors returned by model constructors, loaders and forward passes.
+// They are wrapped with details, so check them with `errors.Is`. E.g.:
+//
+//	if errors.Is(err, util.ErrMissingWeight) { ... }
+
+var (
+	// ErrInvalidConfig is returned when a configuration cannot be parsed or has invalid values.
+	ErrInvalidConfig = errors.New("invalid configuration")
+
+	// ErrUnsupportedActivation is returned for unknown activation function names in configuration.
+	ErrUnsupportedActivation = errors.New("Unsupported activation function")
+
+	// ErrShapeMismatch is returned when dimensions of configuration, weights or inputs do not match.
+	ErrShapeMismatch = errors.New("shape mismatch")
+
+	// ErrMissingWeight is returned when weights of a model variable are not found.
+	ErrMissingWeight = errors.New("missing weight")
+
+	// ErrInvalidInput is returned when inputs of a forward pass are invalid.
+	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrMissingToken is returned when a special token is not found in tokenizer vocab.
+	ErrMissingToken = errors.New("missing token")
+
+	// ErrNotImplemented is returned by features not implemented yet.
+	ErrNotImplemented = errors.New("not implemented")
+)
@@ -2,7 +2,6 @@ package util
 
 import (
 	"fmt"
-	"log"
 	"os"
 )
 
@@ -17,8 +16,6 @@ func init() {
 	CachedDir = fmt.Sprintf("%s/.cache/transformer", homeDir)
 
 	initEnv()
-
-	log.Printf("INFO: CachedDir=%q\n", CachedDir)
 }
 
 func initEnv() {
@@ -26,10 +23,17 @@ func initEnv() {
 	if val != "" {
 		CachedDir = val
 	}
+}
 
-	if _, err := os.Stat(CachedDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(CachedDir, 0755); err != nil {
-			log.Fatal(err)
-		}
+// SetCachedDir sets cache directory `CachedDir` of downloaded files, creating it if needed.
+// It overrides the default "{$HOME}/.cache/transformer" and environment `GO_TRANSFORMER`.
+//
+// NOTE. it should be called before loading models, as `CachedDir` is not safe for concurrent use.
+func SetCachedDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("SetCachedDir() failed: %w", err)
 	}
+	CachedDir = dir
+
+	return nil
 }
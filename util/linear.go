@@ -40,3 +40,33 @@ func (lnb *LinearNoBias) Forward(xs *ts.Tensor) (retVal *ts.Tensor) {
 
 	return retVal
 }
+
+// Conv1D is a linear layer with transposed weight of shape (inDim, outDim),
+// as used by GPT-style checkpoints.
+type Conv1D struct {
+	Ws *ts.Tensor
+	Bs *ts.Tensor
+}
+
+// NewConv1D creates a Conv1D layer. Weight is initialized from normal distribution
+// with standard deviation `std` and bias to zero.
+func NewConv1D(vs *nn.Path, inDim, outDim int64, std float64) (*Conv1D, error) {
+	ws, err := vs.NewVar("weight", []int64{inDim, outDim}, nn.NewRandnInit(0.0, std))
+	if err != nil {
+		return nil, err
+	}
+	bs, err := vs.NewVar("bias", []int64{outDim}, nn.NewConstInit(0.0))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conv1D{
+		Ws: ws,
+		Bs: bs,
+	}, nil
+}
+
+// Forward implements Module interface for Conv1D
+func (c *Conv1D) Forward(xs *ts.Tensor) (retVal *ts.Tensor) {
+	return xs.MustMatmul(c.Ws, false).MustAdd(c.Bs, true)
+}
@@ -0,0 +1,50 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package util
+
+import (
+	"os"
+	"time"
+)
+
+// lockFile takes an exclusive lock by creating a lock file, polling until it is available.
+// Lock files older than `staleLockAge` are left by dead processes and are removed. The
+// modification time of a held lock is refreshed so that long downloads keep their lock.
+func lockFile(name string) (unlock func() error, err error) {
+	const staleLockAge = time.Hour
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			done := make(chan struct{})
+			go refreshLock(name, staleLockAge/4, done)
+			return func() error {
+				close(done)
+				return os.Remove(name)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(name)
+			continue
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// refreshLock updates modification time of lock file every `interval` until `done` is closed.
+func refreshLock(name string, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			os.Chtimes(name, now, now)
+		}
+	}
+}
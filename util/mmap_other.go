@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package util
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile is not supported on this platform. Callers fall back to reading from file.
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("mmapFile: not supported")
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -1 +1,365 @@
 package util
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+// AdamW optimizer:
+// ================
+
+// AdamWConfig holds hyper-parameters for `AdamW`.
+type AdamWConfig struct {
+	Beta1       float64
+	Beta2       float64
+	Eps         float64
+	WeightDecay float64
+	CorrectBias bool     // whether to apply bias correction to moment estimates
+	NoDecay     []string // variables whose name ends with any of these patterns (case-insensitive) are excluded from weight decay
+}
+
+// DefaultAdamWConfig returns the configuration used to fine-tune BERT models.
+//
+// Bias and layer normalization parameters (`weight`/`bias` or `gamma`/`beta` naming) are
+// excluded from weight decay. Layer norms are matched by name suffix whatever their module
+// name (e.g. `LayerNorm.weight`, `sa_layer_norm.weight`), GPT-2 layer norms (`ln_*`) by name.
+func DefaultAdamWConfig() *AdamWConfig {
+	return &AdamWConfig{
+		Beta1:       0.9,
+		Beta2:       0.999,
+		Eps:         1e-6,
+		WeightDecay: 0.01,
+		CorrectBias: true,
+		NoDecay: []string{
+			"bias",
+			"norm.weight", "norm.gamma", "norm.beta",
+			"ln_1.weight", "ln_2.weight", "ln_f.weight",
+		},
+	}
+}
+
+type adamWParam struct {
+	name   string
+	tensor *ts.Tensor
+	expAvg *ts.Tensor // first moment estimate
+	expSq  *ts.Tensor // second moment estimate
+	decay  bool
+}
+
+// AdamW implements Adam with decoupled weight decay as described in
+// "Decoupled Weight Decay Regularization" (https://arxiv.org/abs/1711.05101).
+//
+// Unlike L2 regularization, weight decay is applied directly to the weights
+// and is not scaled by the adaptive learning rate.
+type AdamW struct {
+	config    *AdamWConfig
+	lr        float64
+	params    []adamWParam
+	stepCount int
+}
+
+// NewAdamW creates an AdamW optimizer over all trainable variables of `vs`.
+func NewAdamW(vs *nn.VarStore, lr float64, config *AdamWConfig) *AdamW {
+	if config == nil {
+		config = DefaultAdamWConfig()
+	}
+
+	vars := vs.Variables()
+	names := make([]string, 0, len(vars))
+	for name, x := range vars {
+		if x.MustRequiresGrad() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	params := make([]adamWParam, 0, len(names))
+	for _, name := range names {
+		x := vars[name]
+		params = append(params, adamWParam{
+			name:   name,
+			tensor: &x,
+			decay:  !matchAny(name, config.NoDecay),
+		})
+	}
+
+	return &AdamW{
+		config: config,
+		lr:     lr,
+		params: params,
+	}
+}
+
+// LR returns the current learning rate.
+func (opt *AdamW) LR() float64 {
+	return opt.lr
+}
+
+// SetLR sets the learning rate.
+func (opt *AdamW) SetLR(lr float64) {
+	opt.lr = lr
+}
+
+// StepCount returns the number of steps taken so far.
+func (opt *AdamW) StepCount() int {
+	return opt.stepCount
+}
+
+// DecayNames returns names of variables weight decay is applied to.
+func (opt *AdamW) DecayNames() []string {
+	var names []string
+	for _, p := range opt.params {
+		if p.decay {
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
+
+// ZeroGrad zeroes gradients of all variables.
+func (opt *AdamW) ZeroGrad() {
+	for _, p := range opt.params {
+		grad := p.tensor.MustGrad(false)
+		if grad.MustDefined() {
+			grad.Detach_()
+			grad.Zero_()
+		}
+		grad.MustDrop()
+	}
+}
+
+// ClipGradNorm rescales gradients so that their global L2 norm does not exceed `maxNorm`.
+// It returns the total norm before clipping.
+func (opt *AdamW) ClipGradNorm(maxNorm float64) float64 {
+	var (
+		grads      []*ts.Tensor
+		sumSquares float64
+	)
+	for _, p := range opt.params {
+		grad := p.tensor.MustGrad(false)
+		if !grad.MustDefined() {
+			grad.MustDrop()
+			continue
+		}
+		norm := grad.MustNorm(false).Float64Values(true)[0]
+		sumSquares += norm * norm
+		grads = append(grads, grad)
+	}
+
+	totalNorm := math.Sqrt(sumSquares)
+	scale := maxNorm / (totalNorm + 1e-6)
+	ts.NoGrad(func() {
+		for _, grad := range grads {
+			if scale < 1 {
+				grad.MustMulScalar_(ts.FloatScalar(scale))
+			}
+			grad.MustDrop()
+		}
+	})
+
+	return totalNorm
+}
+
+// Step performs a single optimization step.
+func (opt *AdamW) Step() {
+	opt.stepCount++
+	c := opt.config
+
+	stepSize := opt.lr
+	if c.CorrectBias {
+		t := float64(opt.stepCount)
+		stepSize = stepSize * math.Sqrt(1-math.Pow(c.Beta2, t)) / (1 - math.Pow(c.Beta1, t))
+	}
+
+	ts.NoGrad(func() {
+		for i := range opt.params {
+			p := &opt.params[i]
+			grad := p.tensor.MustGrad(false)
+			if !grad.MustDefined() {
+				grad.MustDrop()
+				continue
+			}
+
+			if p.expAvg == nil {
+				p.expAvg = p.tensor.MustZerosLike(false)
+				p.expSq = p.tensor.MustZerosLike(false)
+			}
+
+			// m = beta1 * m + (1 - beta1) * g
+			p.expAvg.MustMulScalar_(ts.FloatScalar(c.Beta1))
+			scaledGrad := grad.MustMulScalar(ts.FloatScalar(1-c.Beta1), false)
+			p.expAvg.MustAdd_(scaledGrad)
+			scaledGrad.MustDrop()
+
+			// v = beta2 * v + (1 - beta2) * g * g
+			p.expSq.MustMulScalar_(ts.FloatScalar(c.Beta2))
+			scaledGrad = grad.MustMulScalar(ts.FloatScalar(1-c.Beta2), false)
+			p.expSq.MustAddcmul_(grad, scaledGrad)
+			scaledGrad.MustDrop()
+
+			// p = p - stepSize * m / (sqrt(v) + eps)
+			denom := p.expSq.MustSqrt(false).MustAddScalar(ts.FloatScalar(c.Eps), true)
+			update := p.expAvg.MustDiv(denom, false).MustMulScalar(ts.FloatScalar(-stepSize), true)
+			p.tensor.MustAdd_(update)
+			update.MustDrop()
+			denom.MustDrop()
+
+			// Decoupled weight decay: p = p - lr * wd * p
+			if p.decay && c.WeightDecay > 0 {
+				p.tensor.MustMulScalar_(ts.FloatScalar(1 - opt.lr*c.WeightDecay))
+			}
+
+			grad.MustDrop()
+		}
+	})
+}
+
+// BackwardStep zeroes gradients, runs backward pass on `loss` and performs an optimization step.
+func (opt *AdamW) BackwardStep(loss *ts.Tensor) error {
+	opt.ZeroGrad()
+	if err := loss.Backward(); err != nil {
+		return err
+	}
+	opt.Step()
+	return nil
+}
+
+func matchAny(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if strings.HasSuffix(name, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Learning rate schedules:
+// ========================
+
+// LRLambda computes a learning rate multiplier for a given step.
+type LRLambda func(step int) float64
+
+// ConstantSchedule keeps the learning rate constant.
+func ConstantSchedule() LRLambda {
+	return func(step int) float64 {
+		return 1.0
+	}
+}
+
+// ConstantWarmupSchedule increases the learning rate linearly from 0 during
+// `warmupSteps` steps then keeps it constant.
+func ConstantWarmupSchedule(warmupSteps int) LRLambda {
+	return func(step int) float64 {
+		if step < warmupSteps {
+			return float64(step) / math.Max(1, float64(warmupSteps))
+		}
+		return 1.0
+	}
+}
+
+// LinearWarmupSchedule increases the learning rate linearly from 0 during
+// `warmupSteps` steps then decreases it linearly to 0 at `totalSteps`.
+func LinearWarmupSchedule(warmupSteps, totalSteps int) LRLambda {
+	return func(step int) float64 {
+		if step < warmupSteps {
+			return float64(step) / math.Max(1, float64(warmupSteps))
+		}
+		return math.Max(0, float64(totalSteps-step)/math.Max(1, float64(totalSteps-warmupSteps)))
+	}
+}
+
+// CosineWarmupSchedule increases the learning rate linearly from 0 during
+// `warmupSteps` steps then decreases it following a cosine curve with `numCycles`
+// half-waves down to 0 at `totalSteps`. A `numCycles` of 0.5 decays once from max to 0.
+func CosineWarmupSchedule(warmupSteps, totalSteps int, numCycles float64) LRLambda {
+	return func(step int) float64 {
+		if step < warmupSteps {
+			return float64(step) / math.Max(1, float64(warmupSteps))
+		}
+		progress := float64(step-warmupSteps) / math.Max(1, float64(totalSteps-warmupSteps))
+		return math.Max(0, 0.5*(1+math.Cos(math.Pi*numCycles*2*progress)))
+	}
+}
+
+// PolynomialWarmupSchedule increases the learning rate linearly from 0 during
+// `warmupSteps` steps then decreases it from `lrInit` to `lrEnd` at `totalSteps`
+// following a polynomial of degree `power`.
+func PolynomialWarmupSchedule(warmupSteps, totalSteps int, lrInit, lrEnd, power float64) LRLambda {
+	return func(step int) float64 {
+		if step < warmupSteps {
+			return float64(step) / math.Max(1, float64(warmupSteps))
+		}
+		if step > totalSteps {
+			return lrEnd / lrInit
+		}
+		lrRange := lrInit - lrEnd
+		remaining := 1 - float64(step-warmupSteps)/math.Max(1, float64(totalSteps-warmupSteps))
+		decay := lrRange*math.Pow(remaining, power) + lrEnd
+		return decay / lrInit
+	}
+}
+
+// NewSchedule returns a learning rate schedule by name. Supported names are
+// "constant", "constant_with_warmup", "linear", "cosine" and "polynomial".
+//
+// The polynomial schedule decays linearly (power 1) to 0.
+func NewSchedule(name string, warmupSteps, totalSteps int) (LRLambda, error) {
+	switch name {
+	case "constant":
+		return ConstantSchedule(), nil
+	case "constant_with_warmup":
+		return ConstantWarmupSchedule(warmupSteps), nil
+	case "linear":
+		return LinearWarmupSchedule(warmupSteps, totalSteps), nil
+	case "cosine":
+		return CosineWarmupSchedule(warmupSteps, totalSteps, 0.5), nil
+	case "polynomial":
+		return PolynomialWarmupSchedule(warmupSteps, totalSteps, 1.0, 0.0, 1.0), nil
+	default:
+		return nil, fmt.Errorf("Unsupported learning rate schedule: %q", name)
+	}
+}
+
+// LRSetter is implemented by optimizers whose learning rate can be updated,
+// such as `AdamW` and gotch `nn.Optimizer`.
+type LRSetter interface {
+	SetLR(lr float64)
+}
+
+// Scheduler updates the learning rate of an optimizer at each step
+// according to a schedule.
+type Scheduler struct {
+	optimizer LRSetter
+	baseLR    float64
+	lambda    LRLambda
+	step      int
+}
+
+// NewScheduler creates a scheduler for `optimizer` and sets its learning rate
+// to the value at step 0.
+func NewScheduler(optimizer LRSetter, baseLR float64, lambda LRLambda) *Scheduler {
+	s := &Scheduler{
+		optimizer: optimizer,
+		baseLR:    baseLR,
+		lambda:    lambda,
+	}
+	optimizer.SetLR(s.LR())
+	return s
+}
+
+// Step advances the schedule by one step. It should be called after each optimizer step.
+func (s *Scheduler) Step() {
+	s.step++
+	s.optimizer.SetLR(s.LR())
+}
+
+// LR returns the learning rate at the current step.
+func (s *Scheduler) LR() float64 {
+	return s.baseLR * s.lambda(s.step)
+}
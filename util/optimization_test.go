@@ -0,0 +1,84 @@
+package util_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer/util"
+)
+
+func TestLinearWarmupSchedule(t *testing.T) {
+	schedule := util.LinearWarmupSchedule(2, 10)
+
+	want := []float64{0, 0.5, 1, 0.875, 0.5, 0, 0}
+	var got []float64
+	for _, step := range []int{0, 1, 2, 3, 6, 10, 12} {
+		got = append(got, schedule(step))
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestCosineWarmupSchedule(t *testing.T) {
+	schedule := util.CosineWarmupSchedule(0, 10, 0.5)
+
+	want := []float64{1, 0.5, 0}
+	got := []float64{schedule(0), schedule(5), schedule(10)}
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-9 {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			break
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	vs.Root().MustZeros("weight", []int64{2})
+	opt := util.NewAdamW(vs, 1.0, nil)
+
+	scheduler := util.NewScheduler(opt, 1.0, util.LinearWarmupSchedule(4, 8))
+	for i := 0; i < 2; i++ {
+		scheduler.Step()
+	}
+
+	want := 0.5
+	got := opt.LR()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestAdamW_NoDecay(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	p := vs.Root()
+	p.Sub("dense").MustZeros("weight", []int64{2, 2})
+	p.Sub("dense").MustZeros("bias", []int64{2})
+	p.Sub("LayerNorm").MustOnes("gamma", []int64{2})
+	p.Sub("LayerNorm").MustZeros("beta", []int64{2})
+	p.Sub("output").Sub("LayerNorm").MustOnes("weight", []int64{2})
+	p.Sub("sa_layer_norm").MustOnes("weight", []int64{2})
+	p.Sub("full_layer_layer_norm").MustOnes("weight", []int64{2})
+	p.Sub("final_layer_norm").MustOnes("weight", []int64{2})
+	p.Sub("h").Sub("0").Sub("ln_1").MustOnes("weight", []int64{2})
+	p.Sub("relative_attention_bias").MustZeros("weight", []int64{2, 2})
+	p.Sub("h").Sub("0").Sub("mlp").Sub("c_fc").MustZeros("weight", []int64{2, 2})
+
+	opt := util.NewAdamW(vs, 1e-3, nil)
+
+	want := []string{"dense.weight", "h.0.mlp.c_fc.weight", "relative_attention_bias.weight"}
+	got := opt.DecayNames()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
@@ -0,0 +1,187 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file provides reporters of file download progress.
+
+// ProgressReporter receives progress of file downloads.
+type ProgressReporter interface {
+	// Progress reports `done` bytes of `total` bytes of `fileName` downloaded. `total` is -1 if unknown.
+	// It is called a last time with `done` equal to `total` when download completes.
+	Progress(fileName string, done, total int64)
+}
+
+// ProgressFunc is a function reporting download progress, e.g. to a structured logger.
+type ProgressFunc func(fileName string, done, total int64)
+
+// Progress implements ProgressReporter interface.
+func (f ProgressFunc) Progress(fileName string, done, total int64) {
+	f(fileName, done, total)
+}
+
+// SilentProgress discards download progress.
+var SilentProgress ProgressReporter = ProgressFunc(func(fileName string, done, total int64) {})
+
+// DefaultProgress reports progress of downloads when no reporter is given. It is silent by default.
+var DefaultProgress ProgressReporter = SilentProgress
+
+// ProgressParam is the key of a ProgressReporter in `params` of `Load` methods and loaders
+// (e.g. `transformer.LoadModel`).
+const ProgressParam = "Progress"
+
+// ProgressFromParams returns ProgressReporter at `ProgressParam` key of `params`, or `DefaultProgress`.
+func ProgressFromParams(params map[string]interface{}) ProgressReporter {
+	if progress, ok := params[ProgressParam].(ProgressReporter); ok && progress != nil {
+		return progress
+	}
+
+	return DefaultProgress
+}
+
+func progressOrDefault(progress ProgressReporter) ProgressReporter {
+	if progress == nil {
+		return DefaultProgress
+	}
+	return progress
+}
+
+// TerminalProgress draws a progress bar of downloads on a terminal, e.g.
+//
+//	model.safetensors [=========>          ] 210.3 MiB/420.6 MiB
+type TerminalProgress struct {
+	w        io.Writer
+	width    int
+	interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewTerminalProgress creates a TerminalProgress writing to `w` (e.g. `os.Stderr`).
+func NewTerminalProgress(w io.Writer) *TerminalProgress {
+	return &TerminalProgress{
+		w:        w,
+		width:    30,
+		interval: 100 * time.Millisecond,
+	}
+}
+
+// Progress implements ProgressReporter interface.
+func (p *TerminalProgress) Progress(fileName string, done, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	completed := total >= 0 && done >= total
+	if !completed && time.Since(p.last) < p.interval {
+		return
+	}
+	p.last = time.Now()
+
+	if total > 0 {
+		filled := int(float64(p.width) * float64(done) / float64(total))
+		if filled > p.width {
+			filled = p.width
+		}
+		bar := strings.Repeat("=", filled)
+		if filled < p.width {
+			bar += ">" + strings.Repeat(" ", p.width-filled-1)
+		}
+		fmt.Fprintf(p.w, "\r%s [%s] %s/%s  ", fileName, bar, byteCountIEC(uint64(done)), byteCountIEC(uint64(total)))
+	} else {
+		fmt.Fprintf(p.w, "\r%s %s  ", fileName, byteCountIEC(uint64(done)))
+	}
+
+	// The progress use the same line so print a new line once it's finished downloading
+	if completed {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// LogProgress logs download progress as key-value pairs when downloads start, complete and
+// at most once per interval in between, e.g.
+//
+//	msg="download" file="model.safetensors" done=220528640 total=441024512 percent=50.0
+type LogProgress struct {
+	logger   *log.Logger
+	interval time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// NewLogProgress creates a LogProgress logging to `logger` (standard logger if nil) every `interval`.
+func NewLogProgress(logger *log.Logger, interval time.Duration) *LogProgress {
+	return &LogProgress{
+		logger:   logger,
+		interval: interval,
+		last:     make(map[string]time.Time),
+	}
+}
+
+// Progress implements ProgressReporter interface.
+func (p *LogProgress) Progress(fileName string, done, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	completed := total >= 0 && done >= total
+	last, started := p.last[fileName]
+	if started && !completed && time.Since(last) < p.interval {
+		return
+	}
+	if completed {
+		delete(p.last, fileName)
+	} else {
+		p.last[fileName] = time.Now()
+	}
+
+	msg := fmt.Sprintf("msg=%q file=%q done=%d total=%d", "download", fileName, done, total)
+	if total > 0 {
+		msg += fmt.Sprintf(" percent=%.1f", 100*float64(done)/float64(total))
+	}
+	if completed {
+		msg += " completed=true"
+	}
+
+	if p.logger == nil {
+		log.Print(msg)
+		return
+	}
+	p.logger.Print(msg)
+}
+
+// progressWriter reports bytes written to it as download progress. It is used alongside
+// the downloaded file writer with `io.TeeReader`.
+type progressWriter struct {
+	progress ProgressReporter
+	fileName string
+	done     int64
+	total    int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	w.progress.Progress(w.fileName, w.done, w.total)
+	return len(p), nil
+}
+
+// byteCountIEC converts bytes to human-readable string in binary (IEC) format.
+func byteCountIEC(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB",
+		float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,85 @@
+package util_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sugarme/transformer/util"
+)
+
+func TestCache_Download_Progress(t *testing.T) {
+	content := strings.Repeat("x", 100000)
+	server, _ := newTestLFSHub(map[string]hubFile{
+		"/org/model/resolve/main/model.bin": {content: content, commit: "c1"},
+	})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		calls int
+		last  []int64
+	)
+	progress := util.ProgressFunc(func(fileName string, done, total int64) {
+		if fileName != "model.bin" {
+			t.Errorf("Want: %v\n", "model.bin")
+			t.Errorf("Got: %v\n", fileName)
+		}
+		calls++
+		last = []int64{done, total}
+	})
+	url := server.URL + "/org/model/resolve/main/model.bin"
+	if _, err := util.NewCache(dir).Download(server.Client(), url, "org/model", "main", "model.bin", progress); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int64{100000, 100000}
+	if calls == 0 || !reflect.DeepEqual(want, last) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v (%v calls)\n", last, calls)
+	}
+}
+
+func TestTerminalProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := util.NewTerminalProgress(&buf)
+	p.Progress("model.bin", 512, 2048)
+	p.Progress("model.bin", 1024, 2048) // throttled
+	p.Progress("model.bin", 2048, 2048)
+
+	want := "\rmodel.bin [=======>                      ] 512 B/2.0 KiB  " +
+		"\rmodel.bin [==============================] 2.0 KiB/2.0 KiB  \n"
+	got := buf.String()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %q\n", want)
+		t.Errorf("Got: %q\n", got)
+	}
+}
+
+func TestLogProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := util.NewLogProgress(log.New(&buf, "", 0), time.Hour)
+	p.Progress("model.bin", 512, 2048)
+	p.Progress("model.bin", 1024, 2048) // throttled
+	p.Progress("model.bin", 2048, 2048)
+
+	want := []string{
+		`msg="download" file="model.bin" done=512 total=2048 percent=25.0`,
+		`msg="download" file="model.bin" done=2048 total=2048 percent=100.0 completed=true`,
+	}
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
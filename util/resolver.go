@@ -0,0 +1,347 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// This file provides resolvers that find model files (config, vocab, weights, ...)
+// by model name or path and return their local file paths.
+
+// ErrOffline is returned when a file is not available locally and a resolver is not allowed
+// to download it.
+var ErrOffline = errors.New("file not available offline")
+
+var (
+	offlineEnvKey  string = "GO_TRANSFORMER_OFFLINE"
+	endpointEnvKey string = "GO_TRANSFORMER_ENDPOINT"
+)
+
+// Resolver resolves a file of a model name or path to a local file path.
+type Resolver interface {
+	// Resolve returns local path to `fileName` of model, downloading it if needed. Download
+	// progress is reported to `progress`, or `DefaultProgress` if nil.
+	Resolve(modelNameOrPath, fileName string, progress ProgressReporter) (string, error)
+
+	// Cached returns local path to `fileName` of model if it is available without network access.
+	Cached(modelNameOrPath, fileName string) (string, bool)
+}
+
+// DefaultResolver is the resolver used by `CachedPath`. It looks up local directories first,
+// then the Hugging Face hub with files cached at `CachedDir`.
+//
+// NOTE. Setting environment `GO_TRANSFORMER_OFFLINE=1` makes it resolve cached files only.
+var DefaultResolver Resolver = ChainResolver{
+	new(LocalResolver),
+	NewHubResolver(),
+}
+
+// LocalResolver resolves files in local model directories.
+type LocalResolver struct {
+	// Dir is the directory containing model directories. If empty, model name or path is used as
+	// path to the model directory.
+	Dir string
+}
+
+// NewLocalResolver creates a LocalResolver looking up models in `dir`.
+func NewLocalResolver(dir string) *LocalResolver {
+	return &LocalResolver{Dir: dir}
+}
+
+// Cached implements Resolver interface.
+func (r *LocalResolver) Cached(modelNameOrPath, fileName string) (string, bool) {
+	file := filepath.Join(modelNameOrPath, fileName)
+	if r.Dir != "" {
+		file = filepath.Join(r.Dir, file)
+	}
+	if info, err := os.Stat(file); err == nil && !info.IsDir() {
+		return file, true
+	}
+
+	return "", false
+}
+
+// Resolve implements Resolver interface.
+func (r *LocalResolver) Resolve(modelNameOrPath, fileName string, progress ProgressReporter) (string, error) {
+	if file, ok := r.Cached(modelNameOrPath, fileName); ok {
+		return file, nil
+	}
+
+	return "", fmt.Errorf("file %q of model %q not found in local directory: %w", fileName, modelNameOrPath, os.ErrNotExist)
+}
+
+// HubResolver resolves files from a Hugging Face compatible hub and caches them in a `Cache`.
+//
+// Files are downloaded from `{BaseURL}/{model}/resolve/{Revision}/{file}`. Revision can be a branch,
+// a tag or a commit hash; pinning a commit hash makes resolution deterministic.
+type HubResolver struct {
+	BaseURL  string // default `HFpath`
+	Revision string // default "main"
+	CacheDir string // default `CachedDir`
+	Client   *http.Client
+
+	// Offline makes resolver return cached files only and never touch the network.
+	Offline bool
+}
+
+// NewHubResolver creates a HubResolver with default settings, overridden by environment
+// `GO_TRANSFORMER_ENDPOINT` (hub base URL) and `GO_TRANSFORMER_OFFLINE`.
+func NewHubResolver() *HubResolver {
+	r := &HubResolver{
+		BaseURL:  HFpath,
+		Revision: "main",
+	}
+	if val := os.Getenv(endpointEnvKey); val != "" {
+		r.BaseURL = strings.TrimSuffix(val, "/")
+	}
+	switch strings.ToLower(os.Getenv(offlineEnvKey)) {
+	case "1", "true", "yes", "on":
+		r.Offline = true
+	}
+
+	return r
+}
+
+func (r *HubResolver) revision() string {
+	if r.Revision == "" {
+		return "main"
+	}
+	return r.Revision
+}
+
+// Cache returns model cache of resolver.
+func (r *HubResolver) Cache() *Cache {
+	if r.CacheDir == "" {
+		return NewCache(CachedDir)
+	}
+	return NewCache(r.CacheDir)
+}
+
+// URL returns download URL of `fileName` of model.
+func (r *HubResolver) URL(modelName, fileName string) string {
+	baseURL := r.BaseURL
+	if baseURL == "" {
+		baseURL = HFpath
+	}
+
+	return fmt.Sprintf("%s/%s/resolve/%s/%s", baseURL, modelName, r.revision(), fileName)
+}
+
+// Cached implements Resolver interface.
+//
+// NOTE. files cached by previous versions at `{CacheDir}/{model}/{file}` are still used for revision "main".
+func (r *HubResolver) Cached(modelName, fileName string) (string, bool) {
+	cache := r.Cache()
+	if file, ok := cache.Lookup(modelName, r.revision(), fileName); ok {
+		return file, true
+	}
+	if r.revision() == "main" {
+		file := filepath.Join(cache.Dir, modelName, fileName)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file, true
+		}
+	}
+
+	return "", false
+}
+
+// Resolve implements Resolver interface.
+func (r *HubResolver) Resolve(modelName, fileName string, progress ProgressReporter) (string, error) {
+	if file, ok := r.Cached(modelName, fileName); ok {
+		return file, nil
+	}
+	if r.Offline {
+		return "", fmt.Errorf("file %q of model %q (revision %q) not in cache: %w", fileName, modelName, r.revision(), ErrOffline)
+	}
+	// Local model directories are not hub models: files missing there are not downloaded.
+	if info, err := os.Stat(modelName); err == nil && info.IsDir() {
+		return "", fmt.Errorf("file %q of model %q not found in local directory: %w", fileName, modelName, os.ErrNotExist)
+	}
+
+	return r.Cache().Download(r.Client, r.URL(modelName, fileName), modelName, r.revision(), fileName, progress)
+}
+
+// ChainResolver resolves files with a list of resolvers. Files available without network access
+// from any resolver are preferred, then resolvers are tried in order.
+type ChainResolver []Resolver
+
+// Cached implements Resolver interface.
+func (c ChainResolver) Cached(modelNameOrPath, fileName string) (string, bool) {
+	for _, r := range c {
+		if file, ok := r.Cached(modelNameOrPath, fileName); ok {
+			return file, true
+		}
+	}
+
+	return "", false
+}
+
+// Resolve implements Resolver interface.
+func (c ChainResolver) Resolve(modelNameOrPath, fileName string, progress ProgressReporter) (string, error) {
+	if file, ok := c.Cached(modelNameOrPath, fileName); ok {
+		return file, nil
+	}
+
+	var errs []string
+	for _, r := range c {
+		file, err := r.Resolve(modelNameOrPath, fileName, progress)
+		if err == nil {
+			return file, nil
+		}
+		// Wrap the last error so that callers can check it (e.g. `errors.Is(err, ErrOffline)`).
+		if len(errs) == len(c)-1 {
+			if len(errs) == 0 {
+				return "", err
+			}
+			return "", fmt.Errorf("%w (%s)", err, strings.Join(errs, "; "))
+		}
+		errs = append(errs, err.Error())
+	}
+
+	return "", fmt.Errorf("cannot resolve file %q of model %q: no resolvers", fileName, modelNameOrPath)
+}
+
+// ManifestEntry describes where to find files of a model.
+type ManifestEntry struct {
+	// Path is a local directory containing model files.
+	Path string `json:"path,omitempty"`
+
+	// Revision pins hub revision (branch, tag or commit hash) of model.
+	Revision string `json:"revision,omitempty"`
+
+	// Files maps file names to local paths or URLs. It takes precedence over `Path` and `Revision`.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// Manifest maps model names to their entries.
+type Manifest map[string]ManifestEntry
+
+// LoadManifest loads a JSON manifest file. Relative local paths are resolved against
+// the directory of manifest file.
+//
+// Example:
+//
+//	{
+//	  "bert-base-uncased": {"revision": "86b5e0934494bd15c9632b12f734a8a67f723594"},
+//	  "my-ner": {"path": "models/my-ner"},
+//	  "roberta-base": {"files": {"config.json": "https://example.com/roberta-base/config.json"}}
+//	}
+func LoadManifest(file string) (Manifest, error) {
+	buff, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(buff, &manifest); err != nil {
+		return nil, fmt.Errorf("LoadManifest() failed: %w", err)
+	}
+
+	dir := filepath.Dir(file)
+	for name, entry := range manifest {
+		if entry.Path != "" && !filepath.IsAbs(entry.Path) {
+			entry.Path = filepath.Join(dir, entry.Path)
+		}
+		for fileName, location := range entry.Files {
+			if !isURL(location) && !filepath.IsAbs(location) {
+				entry.Files[fileName] = filepath.Join(dir, location)
+			}
+		}
+		manifest[name] = entry
+	}
+
+	return manifest, nil
+}
+
+// ManifestResolver resolves files of models listed in a manifest. Models not in manifest
+// are resolved with `Hub` unless `Strict` is set.
+type ManifestResolver struct {
+	Manifest Manifest
+	Hub      *HubResolver // resolves and caches hub files and URLs; default `NewHubResolver()`
+	Strict   bool         // fail on models not in manifest
+}
+
+// NewManifestResolver creates a ManifestResolver with default hub settings.
+func NewManifestResolver(manifest Manifest) *ManifestResolver {
+	return &ManifestResolver{
+		Manifest: manifest,
+		Hub:      NewHubResolver(),
+	}
+}
+
+// hub returns hub resolver pinned to revision of manifest entry.
+func (r *ManifestResolver) hub(entry ManifestEntry) *HubResolver {
+	hub := r.Hub
+	if hub == nil {
+		hub = NewHubResolver()
+	}
+	if entry.Revision != "" {
+		pinned := *hub
+		pinned.Revision = entry.Revision
+		hub = &pinned
+	}
+
+	return hub
+}
+
+// Cached implements Resolver interface.
+func (r *ManifestResolver) Cached(modelName, fileName string) (string, bool) {
+	entry, ok := r.Manifest[modelName]
+	if !ok {
+		if r.Strict {
+			return "", false
+		}
+		return r.hub(entry).Cached(modelName, fileName)
+	}
+
+	if location, ok := entry.Files[fileName]; ok {
+		if isURL(location) {
+			return r.hub(entry).Cached(modelName, fileName)
+		}
+		if _, err := os.Stat(location); err == nil {
+			return location, true
+		}
+		return "", false
+	}
+	if entry.Path != "" {
+		return NewLocalResolver(entry.Path).Cached("", fileName)
+	}
+
+	return r.hub(entry).Cached(modelName, fileName)
+}
+
+// Resolve implements Resolver interface.
+func (r *ManifestResolver) Resolve(modelName, fileName string, progress ProgressReporter) (string, error) {
+	entry, ok := r.Manifest[modelName]
+	if !ok && r.Strict {
+		return "", fmt.Errorf("model %q not in manifest", modelName)
+	}
+	if file, ok := r.Cached(modelName, fileName); ok {
+		return file, nil
+	}
+
+	hub := r.hub(entry)
+	if location, ok := entry.Files[fileName]; ok {
+		if !isURL(location) {
+			return "", fmt.Errorf("file %q of model %q not found at %q: %w", fileName, modelName, location, os.ErrNotExist)
+		}
+		if hub.Offline {
+			return "", fmt.Errorf("file %q of model %q not in cache: %w", fileName, modelName, ErrOffline)
+		}
+		return hub.Cache().Download(hub.Client, location, modelName, hub.revision(), fileName, progress)
+	}
+	if entry.Path != "" {
+		return NewLocalResolver(entry.Path).Resolve("", fileName, progress)
+	}
+
+	return hub.Resolve(modelName, fileName, progress)
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
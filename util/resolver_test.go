@@ -0,0 +1,228 @@
+package util_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// newTestHub serves `{model}/resolve/{revision}/{file}` from `files` and records paths of GET requests.
+func newTestHub(files map[string]string) (*httptest.Server, func() []string) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			mu.Lock()
+			requests = append(requests, r.URL.Path)
+			mu.Unlock()
+		}
+
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests...)
+	}
+}
+
+func readFile(t *testing.T, file string) string {
+	buff, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buff)
+}
+
+func TestHubResolver(t *testing.T) {
+	server, requests := newTestHub(map[string]string{
+		"/org/model/resolve/main/config.json":   `{"rev": "main"}`,
+		"/org/model/resolve/abc123/config.json": `{"rev": "abc123"}`,
+	})
+	defer server.Close()
+
+	cacheDir, err := ioutil.TempDir("", "resolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	hub := &util.HubResolver{BaseURL: server.URL, CacheDir: cacheDir, Client: server.Client()}
+	pinned := &util.HubResolver{BaseURL: server.URL, Revision: "abc123", CacheDir: cacheDir, Client: server.Client()}
+
+	for _, r := range []*util.HubResolver{hub, pinned, hub, pinned} {
+		file, err := r.Resolve("org/model", "config.json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rev := r.Revision
+		if rev == "" {
+			rev = "main"
+		}
+		got := readFile(t, file)
+		want := fmt.Sprintf(`{"rev": %q}`, rev)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+
+	// Second resolutions are served from cache.
+	want := []string{"/org/model/resolve/main/config.json", "/org/model/resolve/abc123/config.json"}
+	got := requests()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	if _, err := hub.Resolve("org/model", "missing.json", nil); err == nil {
+		t.Errorf("Want error on missing file\n")
+	}
+	if _, ok := hub.Cached("org/model", "missing.json"); ok {
+		t.Errorf("Want failed download not cached\n")
+	}
+}
+
+func TestHubResolver_Offline(t *testing.T) {
+	server, requests := newTestHub(map[string]string{
+		"/org/model/resolve/main/config.json": `{}`,
+		"/org/model/resolve/main/vocab.txt":   "[PAD]",
+	})
+	defer server.Close()
+
+	cacheDir, err := ioutil.TempDir("", "resolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	online := &util.HubResolver{BaseURL: server.URL, CacheDir: cacheDir, Client: server.Client()}
+	if _, err := online.Resolve("org/model", "config.json", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	offline := &util.HubResolver{BaseURL: server.URL, CacheDir: cacheDir, Client: server.Client(), Offline: true}
+	if _, err := offline.Resolve("org/model", "config.json", nil); err != nil {
+		t.Errorf("Want cached file resolved offline, got error: %v\n", err)
+	}
+	chain := util.ChainResolver{new(util.LocalResolver), offline}
+	_, err = chain.Resolve("org/model", "vocab.txt", nil)
+	if !errors.Is(err, util.ErrOffline) {
+		t.Errorf("Want: %v\n", util.ErrOffline)
+		t.Errorf("Got: %v\n", err)
+	}
+
+	want := []string{"/org/model/resolve/main/config.json"}
+	got := requests()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestManifestResolver(t *testing.T) {
+	server, requests := newTestHub(map[string]string{
+		"/org/pinned/resolve/abc123/config.json": `{"pinned": true}`,
+		"/files/remote.json":                     `{"remote": true}`,
+	})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "resolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "models", "local"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "models", "local", "config.json"), []byte(`{"local": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifestFile := filepath.Join(dir, "manifest.json")
+	manifest := fmt.Sprintf(`{
+		"local": {"path": "models/local"},
+		"org/pinned": {"revision": "abc123"},
+		"remote": {"files": {"config.json": "%s/files/remote.json"}}
+	}`, server.URL)
+	if err := ioutil.WriteFile(manifestFile, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := util.LoadManifest(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &util.ManifestResolver{
+		Manifest: m,
+		Hub:      &util.HubResolver{BaseURL: server.URL, CacheDir: filepath.Join(dir, "cache"), Client: server.Client()},
+		Strict:   true,
+	}
+
+	for model, want := range map[string]string{
+		"local":      `{"local": true}`,
+		"org/pinned": `{"pinned": true}`,
+		"remote":     `{"remote": true}`,
+	} {
+		file, err := r.Resolve(model, "config.json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := readFile(t, file)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+
+	if _, err := r.Resolve("unknown", "config.json", nil); err == nil {
+		t.Errorf("Want error on model not in strict manifest\n")
+	}
+	if n := len(requests()); n != 2 {
+		t.Errorf("Want: %v\n", 2)
+		t.Errorf("Got: %v\n", n)
+	}
+}
+
+func TestCachedPath_Safetensors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, fileName := range []string{util.PytorchWeightName, util.SafetensorsName} {
+		if err := ioutil.WriteFile(filepath.Join(dir, fileName), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Safetensors weights are preferred.
+	want := filepath.Join(dir, util.SafetensorsName)
+	for _, fileName := range []string{util.PytorchWeightName, util.WeightName} {
+		got, err := util.CachedPath(dir, fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+}
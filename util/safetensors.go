@@ -0,0 +1,445 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+// This file provides a pure Go reader and writer for safetensors format.
+//
+// File layout (https://github.com/huggingface/safetensors):
+//   - 8 bytes: little-endian unsigned integer N, size of the header
+//   - N bytes: JSON header mapping tensor names to dtype, shape and data offsets.
+//     Optional key "__metadata__" maps to string-string metadata.
+//   - rest of file: tensor data. Offsets in header are relative to this section.
+
+const safetensorsMetadataKey = "__metadata__"
+
+// SafetensorsInfo describes a tensor stored in a safetensors file.
+type SafetensorsInfo struct {
+	DType       string   `json:"dtype"`
+	Shape       []int64  `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+// Safetensors is a safetensors file opened for reading.
+//
+// Only the header is read when opening. Tensor data is read on demand from
+// a memory mapped file if supported by the platform, otherwise from file.
+type Safetensors struct {
+	file      *os.File
+	mmap      []byte // nil if file is not memory mapped
+	dataStart int64
+	tensors   map[string]SafetensorsInfo
+	metadata  map[string]string
+}
+
+// OpenSafetensors opens a safetensors file and parses its header.
+func OpenSafetensors(path string) (*Safetensors, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := newSafetensors(f)
+	if err != nil {
+		f.Close()
+		err = fmt.Errorf("OpenSafetensors() failed for %q: %w", path, err)
+		return nil, err
+	}
+
+	return st, nil
+}
+
+func newSafetensors(f *os.File) (*Safetensors, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := stat.Size()
+
+	var sizeBuf [8]byte
+	if _, err := io.ReadFull(f, sizeBuf[:]); err != nil {
+		return nil, fmt.Errorf("reading header size: %w", err)
+	}
+	headerSize := int64(binary.LittleEndian.Uint64(sizeBuf[:]))
+	if headerSize <= 0 || 8+headerSize > fileSize {
+		return nil, fmt.Errorf("invalid header size %v for file size %v", headerSize, fileSize)
+	}
+
+	headerBuf := make([]byte, headerSize)
+	if _, err := io.ReadFull(f, headerBuf); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(headerBuf, &raw); err != nil {
+		return nil, fmt.Errorf("parsing header: %w", err)
+	}
+
+	st := &Safetensors{
+		file:      f,
+		dataStart: 8 + headerSize,
+		tensors:   make(map[string]SafetensorsInfo, len(raw)),
+	}
+	dataSize := fileSize - st.dataStart
+
+	for name, msg := range raw {
+		if name == safetensorsMetadataKey {
+			if err := json.Unmarshal(msg, &st.metadata); err != nil {
+				return nil, fmt.Errorf("parsing metadata: %w", err)
+			}
+			continue
+		}
+
+		var info SafetensorsInfo
+		if err := json.Unmarshal(msg, &info); err != nil {
+			return nil, fmt.Errorf("parsing tensor %q: %w", name, err)
+		}
+		eltSize, ok := safetensorsDTypeSize[info.DType]
+		if !ok {
+			return nil, fmt.Errorf("tensor %q has unsupported dtype %q", name, info.DType)
+		}
+		start, end := info.DataOffsets[0], info.DataOffsets[1]
+		if start < 0 || end < start || end > dataSize {
+			return nil, fmt.Errorf("tensor %q has invalid data offsets %v", name, info.DataOffsets)
+		}
+		if want := elementCount(info.Shape) * eltSize; end-start != want {
+			return nil, fmt.Errorf("tensor %q has %v bytes, want %v for shape %v", name, end-start, want, info.Shape)
+		}
+		st.tensors[name] = info
+	}
+
+	// Memory mapping is an optimization. Fall back to reading from file if it fails.
+	if data, err := mmapFile(f, fileSize); err == nil {
+		st.mmap = data
+	}
+
+	return st, nil
+}
+
+// Close releases resources held by the reader. Tensors created from it remain valid.
+func (st *Safetensors) Close() error {
+	if st.mmap != nil {
+		if err := munmapFile(st.mmap); err != nil {
+			return err
+		}
+		st.mmap = nil
+	}
+	return st.file.Close()
+}
+
+// Names returns sorted names of all tensors in the file.
+func (st *Safetensors) Names() []string {
+	names := make([]string, 0, len(st.tensors))
+	for name := range st.tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Info returns dtype, shape and data offsets of tensor `name`.
+func (st *Safetensors) Info(name string) (SafetensorsInfo, bool) {
+	info, ok := st.tensors[name]
+	return info, ok
+}
+
+// Metadata returns the optional string metadata stored in the file header.
+func (st *Safetensors) Metadata() map[string]string {
+	return st.metadata
+}
+
+// Bytes returns raw little-endian data of tensor `name`.
+//
+// If the file is memory mapped, the returned slice references the mapping
+// and must not be modified or used after `Close`.
+func (st *Safetensors) Bytes(name string) ([]byte, error) {
+	info, ok := st.tensors[name]
+	if !ok {
+		return nil, fmt.Errorf("tensor %q not found", name)
+	}
+
+	start := st.dataStart + info.DataOffsets[0]
+	end := st.dataStart + info.DataOffsets[1]
+	if st.mmap != nil {
+		return st.mmap[start:end], nil
+	}
+
+	buf := make([]byte, end-start)
+	if _, err := st.file.ReadAt(buf, start); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Tensor reads tensor `name` to `device`.
+//
+// Half precision (F16, BF16) tensors are converted to float32.
+func (st *Safetensors) Tensor(name string, device gotch.Device) (*ts.Tensor, error) {
+	info, ok := st.tensors[name]
+	if !ok {
+		return nil, fmt.Errorf("tensor %q not found", name)
+	}
+
+	data, err := st.Bytes(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var dtype gotch.DType
+	switch info.DType {
+	case "F16":
+		data, dtype = halfToFloat32(data, float16ToFloat32), gotch.Float
+	case "BF16":
+		data, dtype = halfToFloat32(data, bfloat16ToFloat32), gotch.Float
+	default:
+		dtype = safetensorsDTypes[info.DType]
+	}
+
+	x, err := ts.OfDataSize(data, info.Shape, dtype)
+	if err != nil {
+		return nil, err
+	}
+	if device == gotch.CPU {
+		return x, nil
+	}
+
+	return x.MustTo(device, true), nil
+}
+
+// LoadSafetensors loads weights from a safetensors file to `vs`.
+//
+// Variables are matched by name. LayerNorm parameters named `gamma`/`beta`
+// also match `weight`/`bias` and vice versa. It returns an error if a variable
+// is not found in the file or has a different shape. Use `LoadWeightFile`
+// to load with other options and get a report of loaded weights.
+func LoadSafetensors(vs *nn.VarStore, path string) error {
+	st, err := OpenSafetensors(path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	if _, err := loadWeights(vs, st, LoadOptions{}); err != nil {
+		return fmt.Errorf("LoadSafetensors() failed for %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// shapes implements weightSource interface.
+func (st *Safetensors) shapes() map[string][]int64 {
+	shapes := make(map[string][]int64, len(st.tensors))
+	for name, info := range st.tensors {
+		shapes[name] = info.Shape
+	}
+	return shapes
+}
+
+// tensor implements weightSource interface.
+func (st *Safetensors) tensor(name string) (*ts.Tensor, error) {
+	return st.Tensor(name, gotch.CPU)
+}
+
+// SaveSafetensors saves all variables of `vs` to a safetensors file.
+// `metadata` is optional.
+func SaveSafetensors(vs *nn.VarStore, path string, metadata map[string]string) error {
+	vars := vs.Variables()
+	named := make(map[string]*ts.Tensor, len(vars))
+	for name := range vars {
+		x := vars[name]
+		named[name] = &x
+	}
+
+	return WriteSafetensors(path, named, metadata)
+}
+
+// WriteSafetensors writes named tensors to a safetensors file.
+// `metadata` is optional.
+func WriteSafetensors(path string, tensors map[string]*ts.Tensor, metadata map[string]string) error {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header := make(map[string]interface{}, len(names)+1)
+	if len(metadata) > 0 {
+		header[safetensorsMetadataKey] = metadata
+	}
+
+	var data bytes.Buffer
+	for _, name := range names {
+		dtypeName, buf, err := tensorBytes(tensors[name])
+		if err != nil {
+			return fmt.Errorf("WriteSafetensors() failed at tensor %q: %w", name, err)
+		}
+		start := int64(data.Len())
+		data.Write(buf)
+		header[name] = SafetensorsInfo{
+			DType:       dtypeName,
+			Shape:       tensors[name].MustSize(),
+			DataOffsets: [2]int64{start, int64(data.Len())},
+		}
+	}
+
+	headerBuf, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// Pad header with spaces so that data section is 8-byte aligned.
+	if rem := len(headerBuf) % 8; rem != 0 {
+		headerBuf = append(headerBuf, bytes.Repeat([]byte(" "), 8-rem)...)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sizeBuf [8]byte
+	binary.LittleEndian.PutUint64(sizeBuf[:], uint64(len(headerBuf)))
+	for _, b := range [][]byte{sizeBuf[:], headerBuf, data.Bytes()} {
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// tensorBytes returns safetensors dtype name and little-endian data of a tensor.
+func tensorBytes(x *ts.Tensor) (string, []byte, error) {
+	cpu := x.MustTo(gotch.CPU, false).MustContiguous(true)
+	defer cpu.MustDrop()
+
+	numel := cpu.Numel()
+	var dst interface{}
+	var dtypeName string
+	switch cpu.DType() {
+	case gotch.Float:
+		dst, dtypeName = make([]float32, numel), "F32"
+	case gotch.Double:
+		dst, dtypeName = make([]float64, numel), "F64"
+	case gotch.Int64:
+		dst, dtypeName = make([]int64, numel), "I64"
+	case gotch.Int:
+		dst, dtypeName = make([]int32, numel), "I32"
+	case gotch.Int16:
+		dst, dtypeName = make([]int16, numel), "I16"
+	case gotch.Int8:
+		dst, dtypeName = make([]int8, numel), "I8"
+	case gotch.Uint8:
+		dst, dtypeName = make([]uint8, numel), "U8"
+	case gotch.Bool:
+		dst, dtypeName = make([]bool, numel), "BOOL"
+	default:
+		return "", nil, fmt.Errorf("unsupported dtype %v", cpu.DType())
+	}
+
+	if numel > 0 {
+		if err := cpu.CopyData(dst, numel); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, dst); err != nil {
+		return "", nil, err
+	}
+
+	return dtypeName, buf.Bytes(), nil
+}
+
+var safetensorsDTypes = map[string]gotch.DType{
+	"F64":  gotch.Double,
+	"F32":  gotch.Float,
+	"I64":  gotch.Int64,
+	"I32":  gotch.Int,
+	"I16":  gotch.Int16,
+	"I8":   gotch.Int8,
+	"U8":   gotch.Uint8,
+	"BOOL": gotch.Bool,
+}
+
+var safetensorsDTypeSize = map[string]int64{
+	"F64":  8,
+	"F32":  4,
+	"F16":  2,
+	"BF16": 2,
+	"I64":  8,
+	"I32":  4,
+	"I16":  2,
+	"I8":   1,
+	"U8":   1,
+	"BOOL": 1,
+}
+
+func elementCount(shape []int64) int64 {
+	n := int64(1)
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
+
+func shapeEqual(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// halfToFloat32 converts little-endian 16-bit floats to little-endian float32.
+func halfToFloat32(data []byte, convert func(uint16) float32) []byte {
+	out := make([]byte, len(data)*2)
+	for i := 0; i < len(data)/2; i++ {
+		h := binary.LittleEndian.Uint16(data[2*i:])
+		binary.LittleEndian.PutUint32(out[4*i:], math.Float32bits(convert(h)))
+	}
+	return out
+}
+
+func bfloat16ToFloat32(h uint16) float32 {
+	return math.Float32frombits(uint32(h) << 16)
+}
+
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+
+	switch {
+	case exp == 0 && frac == 0: // zero
+		return math.Float32frombits(sign)
+	case exp == 0: // subnormal: normalize
+		e := uint32(127 - 15 + 1)
+		for frac&0x400 == 0 {
+			frac <<= 1
+			e--
+		}
+		frac &= 0x3ff
+		return math.Float32frombits(sign | e<<23 | frac<<13)
+	case exp == 0x1f: // inf or NaN
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+	}
+}
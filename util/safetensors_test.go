@@ -0,0 +1,165 @@
+package util_test
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/util"
+)
+
+// writeRawSafetensors writes a safetensors file from a raw JSON header and data.
+func writeRawSafetensors(t *testing.T, path, header string, data []byte) {
+	var sizeBuf [8]byte
+	binary.LittleEndian.PutUint64(sizeBuf[:], uint64(len(header)))
+	buf := append(sizeBuf[:], []byte(header)...)
+	buf = append(buf, data...)
+	if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenSafetensors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 12)
+	for i, v := range []float32{1, 2, 3} {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	path := filepath.Join(dir, util.SafetensorsName)
+	header := `{"__metadata__":{"format":"pt"},"a.weight":{"dtype":"F32","shape":[3],"data_offsets":[0,12]}}`
+	writeRawSafetensors(t, path, header, data)
+
+	st, err := util.OpenSafetensors(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	wantNames := []string{"a.weight"}
+	if !reflect.DeepEqual(wantNames, st.Names()) {
+		t.Errorf("Want: %v\n", wantNames)
+		t.Errorf("Got: %v\n", st.Names())
+	}
+
+	wantMeta := map[string]string{"format": "pt"}
+	if !reflect.DeepEqual(wantMeta, st.Metadata()) {
+		t.Errorf("Want: %v\n", wantMeta)
+		t.Errorf("Got: %v\n", st.Metadata())
+	}
+
+	got, err := st.Bytes("a.weight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, got) {
+		t.Errorf("Want: %v\n", data)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestOpenSafetensors_Invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, util.SafetensorsName)
+	// data offsets do not match shape
+	header := `{"a":{"dtype":"F32","shape":[3],"data_offsets":[0,8]}}`
+	writeRawSafetensors(t, path, header, make([]byte, 8))
+
+	if _, err := util.OpenSafetensors(path); err == nil {
+		t.Errorf("Want error for invalid data offsets\n")
+	}
+}
+
+func TestSafetensors_RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vs := nn.NewVarStore(gotch.CPU)
+	p := vs.Root()
+	nn.NewLinear(p.Sub("dense"), 4, 3, nn.DefaultLinearConfig())
+	nn.NewLayerNorm(p.Sub("LayerNorm"), []int64{3}, nn.DefaultLayerNormConfig())
+
+	if err := util.SaveVarStore(vs, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Target names LayerNorm parameters `gamma`/`beta`.
+	vs2 := nn.NewVarStore(gotch.CPU)
+	p2 := vs2.Root()
+	nn.NewLinear(p2.Sub("dense"), 4, 3, nn.DefaultLinearConfig())
+	lnConfig := nn.DefaultLayerNormConfig()
+	lnConfig.WsName = "gamma"
+	lnConfig.BsName = "beta"
+	nn.NewLayerNorm(p2.Sub("LayerNorm"), []int64{3}, lnConfig)
+
+	if _, err := util.LoadVarStore(vs2, dir, util.LoadOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := vs.Variables()
+	got := vs2.Variables()
+	for name, alias := range map[string]string{
+		"dense.weight":     "dense.weight",
+		"dense.bias":       "dense.bias",
+		"LayerNorm.weight": "LayerNorm.gamma",
+		"LayerNorm.bias":   "LayerNorm.beta",
+	} {
+		x, y := want[name], got[alias]
+		if !util.Equal(&x, &y) {
+			t.Errorf("Want %q equal to %q\n", alias, name)
+		}
+	}
+}
+
+func TestSafetensors_Float16(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 1.0, -2.0, 0.5 in IEEE half precision
+	data := make([]byte, 6)
+	for i, h := range []uint16{0x3c00, 0xc000, 0x3800} {
+		binary.LittleEndian.PutUint16(data[2*i:], h)
+	}
+	path := filepath.Join(dir, util.SafetensorsName)
+	writeRawSafetensors(t, path, `{"x":{"dtype":"F16","shape":[3],"data_offsets":[0,6]}}`, data)
+
+	st, err := util.OpenSafetensors(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	x, err := st.Tensor("x", gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ts.MustOfSlice([]float32{1, -2, 0.5})
+	if !util.Equal(want, x) {
+		t.Errorf("Want: %v\n", want.Float64Values())
+		t.Errorf("Got: %v\n", x.Float64Values())
+	}
+}
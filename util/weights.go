@@ -0,0 +1,391 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/pickle"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/transformer/pretrained"
+)
+
+// This file provides functions to load and save model weights.
+
+// LoadMode sets which weight loading issues are errors. See `LoadOptions`.
+type LoadMode int
+
+const (
+	// LoadStrict fails if a model variable is missing from the weight file or has
+	// a different shape. Unexpected tensors of the weight file are only reported.
+	LoadStrict LoadMode = iota
+
+	// LoadIgnoreHeads is LoadStrict except that task head variables (see `LoadOptions.Heads`)
+	// may be missing or mismatched, e.g. to fine-tune a task model from a base model checkpoint.
+	LoadIgnoreHeads
+
+	// LoadLenient loads all matching variables and only reports issues.
+	LoadLenient
+)
+
+// LoadOptions sets how tensors of a weight file are matched and loaded to model variables.
+//
+// Variables are matched by name after renaming weight file tensors with `Rename` rules.
+// LayerNorm parameters named `gamma`/`beta` also match `weight`/`bias` and vice versa.
+// Variables not loaded are left at their initial (random) values and listed in the returned
+// `pretrained.LoadReport`.
+type LoadOptions struct {
+	Mode LoadMode
+
+	// Heads are name prefixes of task head variables (e.g. "classifier") used by LoadIgnoreHeads mode.
+	// Task models set their own heads if empty.
+	Heads []string
+
+	// Rename rules are applied in order to names of weight file tensors.
+	Rename []RenameRule
+
+	// Tied maps variables sharing weights with another tensor (e.g. a masked LM decoder tied to
+	// word embeddings) to the name of that tensor. Weight files may store shared tensors once,
+	// e.g. safetensors files, in which case tied variables are loaded from that tensor. Task models set it.
+	Tied map[string]string
+}
+
+// RenameRule replaces matches of `Pattern` in weight file tensor names with `Replacement`.
+// `Replacement` can refer to submatches as in `regexp.Regexp.ReplaceAllString`.
+type RenameRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// NewRenameRule creates a RenameRule. E.g. to load a checkpoint saved without `bert.` prefix:
+//
+//	rule, err := util.NewRenameRule(`^`, "bert.")
+func NewRenameRule(pattern, replacement string) (RenameRule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return RenameRule{}, fmt.Errorf("NewRenameRule() failed: %w", err)
+	}
+
+	return RenameRule{re, replacement}, nil
+}
+
+// LoadOptionsParam is the key of LoadOptions in `params` of `Load` methods and loaders
+// (e.g. `transformer.LoadModel`).
+const LoadOptionsParam = "LoadOptions"
+
+// LoadOptionsFromParams returns LoadOptions at `LoadOptionsParam` key of `params`, or
+// default options (LoadStrict) if not set. `heads` are used if options have no heads.
+func LoadOptionsFromParams(params map[string]interface{}, heads ...string) LoadOptions {
+	opts, _ := params[LoadOptionsParam].(LoadOptions)
+	if len(opts.Heads) == 0 {
+		opts.Heads = heads
+	}
+
+	return opts
+}
+
+// isHead returns whether variable `name` belongs to a task head.
+func (opts LoadOptions) isHead(name string) bool {
+	for _, head := range opts.Heads {
+		if name == head || strings.HasPrefix(name, head+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns an error for issues of `report` not allowed by load mode.
+func (opts LoadOptions) check(report *pretrained.LoadReport) error {
+	if opts.Mode == LoadLenient {
+		return nil
+	}
+
+	var missing, mismatched []string
+	for _, name := range report.MissingKeys {
+		if opts.Mode == LoadIgnoreHeads && opts.isHead(name) {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	for _, m := range report.MismatchedKeys {
+		if opts.Mode == LoadIgnoreHeads && opts.isHead(m.Key) {
+			continue
+		}
+		mismatched = append(mismatched, fmt.Sprintf("%v (model: %v, file: %v)", m.Key, m.ModelShape, m.FileShape))
+	}
+
+	switch {
+	case len(missing) > 0:
+		return fmt.Errorf("%v model variables not found in weight file: %v: %w", len(missing), truncateList(missing, 10), ErrMissingWeight)
+	case len(mismatched) > 0:
+		return fmt.Errorf("%v model variables have different shapes in weight file: %v: %w", len(mismatched), truncateList(mismatched, 10), ErrShapeMismatch)
+	default:
+		return nil
+	}
+}
+
+func truncateList(items []string, n int) string {
+	if len(items) <= n {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%v, ... (%v more)", strings.Join(items[:n], ", "), len(items)-n)
+}
+
+// LoadVarStore loads weights to `vs` from model name or directory `modelNameOrPath`.
+//
+// Weight files are looked up in order: `SafetensorsName` (saved by `SaveVarStore`),
+// `WeightName` (gotch format) then `PytorchWeightName` (Python Pytorch checkpoint). The next
+// file is tried only if a file does not exist (or is not cached in offline mode), other
+// errors (e.g. network errors) are returned.
+// Download progress is reported to `progress`, or `DefaultProgress` if nil.
+//
+// It returns a report of missing, unexpected and mismatched weights, also on load errors
+// due to `opts.Mode`.
+func LoadVarStore(vs *nn.VarStore, modelNameOrPath string, opts LoadOptions, progress ProgressReporter) (*pretrained.LoadReport, error) {
+	var errs []string
+	for _, fileName := range []string{SafetensorsName, WeightName, PytorchWeightName} {
+		weightFile, err := CachedPathWithProgress(modelNameOrPath, fileName, progress)
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrOffline) {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("LoadVarStore() failed: %w", err)
+		}
+
+		return LoadWeightFile(vs, weightFile, opts)
+	}
+
+	err := fmt.Errorf("LoadVarStore() failed: no weight file found for %q: %w:\n%s", modelNameOrPath, ErrMissingWeight, strings.Join(errs, "\n"))
+	return nil, err
+}
+
+// LoadWeightFile loads weights to `vs` from a weight file. File format is inferred from file extension.
+// See `LoadVarStore`.
+func LoadWeightFile(vs *nn.VarStore, weightFile string, opts LoadOptions) (*pretrained.LoadReport, error) {
+	src, err := openWeightFile(vs, weightFile)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	report, err := loadWeights(vs, src, opts)
+	if err != nil {
+		err = fmt.Errorf("LoadWeightFile() failed for %q: %w", weightFile, err)
+		return report, err
+	}
+
+	return report, nil
+}
+
+// weightSource is a weight file opened for loading.
+type weightSource interface {
+	// shapes returns shapes of all tensors by name.
+	shapes() map[string][]int64
+	// tensor reads tensor `name`. The caller drops it.
+	tensor(name string) (*ts.Tensor, error)
+	Close() error
+}
+
+func openWeightFile(vs *nn.VarStore, weightFile string) (weightSource, error) {
+	switch filepath.Ext(weightFile) {
+	case ".safetensors":
+		return OpenSafetensors(weightFile)
+	case ".bin", ".pt", ".pth":
+		weights, err := pickle.Decode(weightFile)
+		if err != nil {
+			return nil, err
+		}
+		return namedTensors(weights), nil
+	default:
+		weights, err := ts.LoadMultiWithDevice(weightFile, vs.Device())
+		if err != nil {
+			return nil, err
+		}
+		tensors := make(namedTensors, len(weights))
+		for _, x := range weights {
+			tensors[x.Name] = x.Tensor
+		}
+		return tensors, nil
+	}
+}
+
+// namedTensors are weights fully read in memory. It implements weightSource interface.
+type namedTensors map[string]*ts.Tensor
+
+func (t namedTensors) shapes() map[string][]int64 {
+	shapes := make(map[string][]int64, len(t))
+	for name, x := range t {
+		shapes[name] = x.MustSize()
+	}
+	return shapes
+}
+
+func (t namedTensors) tensor(name string) (*ts.Tensor, error) {
+	x, ok := t[name]
+	if !ok {
+		return nil, fmt.Errorf("tensor %q not found", name)
+	}
+	return x.ShallowClone()
+}
+
+func (t namedTensors) Close() error {
+	for _, x := range t {
+		x.MustDrop()
+	}
+	return nil
+}
+
+// loadWeights copies tensors of `src` to matching variables of `vs`. Nothing is loaded
+// if issues are not allowed by `opts.Mode`.
+func loadWeights(vs *nn.VarStore, src weightSource, opts LoadOptions) (*pretrained.LoadReport, error) {
+	vars := vs.Variables()
+	varShapes := make(map[string][]int64, len(vars))
+	for name, x := range vars {
+		varShapes[name] = x.MustSize()
+	}
+
+	report, keys := matchWeights(varShapes, src.shapes(), opts)
+	if err := opts.check(report); err != nil {
+		return report, err
+	}
+
+	for name, key := range keys {
+		x := vars[name]
+		y, err := src.tensor(key)
+		if err != nil {
+			return report, err
+		}
+		ts.NoGrad(func() {
+			x.Copy_(y)
+		})
+		y.MustDrop()
+	}
+
+	return report, nil
+}
+
+// matchWeights matches model variables to weight file tensors by name. It returns
+// a report of issues and weight file tensor names of variables to load.
+func matchWeights(varShapes, fileShapes map[string][]int64, opts LoadOptions) (*pretrained.LoadReport, map[string]string) {
+	renamed := make(map[string]string, len(fileShapes))
+	for key := range fileShapes {
+		name := key
+		for _, rule := range opts.Rename {
+			name = rule.Pattern.ReplaceAllString(name, rule.Replacement)
+		}
+		renamed[name] = key
+	}
+
+	report := new(pretrained.LoadReport)
+	keys := make(map[string]string, len(varShapes))
+	used := make(map[string]bool, len(varShapes))
+	for name, shape := range varShapes {
+		key, ok := lookupWeight(renamed, name)
+		if tied, isTied := opts.Tied[name]; !ok && isTied {
+			key, ok = lookupWeight(renamed, tied)
+		}
+		if !ok {
+			report.MissingKeys = append(report.MissingKeys, name)
+			continue
+		}
+		used[key] = true
+
+		if !shapeEqual(shape, fileShapes[key]) {
+			report.MismatchedKeys = append(report.MismatchedKeys, pretrained.ShapeMismatch{
+				Key:        name,
+				ModelShape: shape,
+				FileShape:  fileShapes[key],
+			})
+			continue
+		}
+		keys[name] = key
+	}
+
+	for key := range fileShapes {
+		if !used[key] {
+			report.UnexpectedKeys = append(report.UnexpectedKeys, key)
+		}
+	}
+
+	sort.Strings(report.MissingKeys)
+	sort.Strings(report.UnexpectedKeys)
+	sort.Slice(report.MismatchedKeys, func(i, j int) bool {
+		return report.MismatchedKeys[i].Key < report.MismatchedKeys[j].Key
+	})
+
+	return report, keys
+}
+
+// lookupWeight finds weight file tensor name matching variable `name` in `renamed`
+// mapping renamed tensor names to their names in file.
+func lookupWeight(renamed map[string]string, name string) (string, bool) {
+	if key, ok := renamed[name]; ok {
+		return key, true
+	}
+	for _, alias := range layerNormAliases(name) {
+		if key, ok := renamed[alias]; ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// layerNormAliases returns alternative names for LayerNorm parameters as
+// checkpoints use either `gamma`/`beta` or `weight`/`bias` naming.
+func layerNormAliases(name string) []string {
+	idx := strings.LastIndex(name, ".")
+	if idx < 0 {
+		return nil
+	}
+	prefix, param := name[:idx], name[idx+1:]
+	if !strings.HasSuffix(prefix, "LayerNorm") && !strings.HasSuffix(prefix, "layer_norm") {
+		return nil
+	}
+
+	switch param {
+	case "gamma":
+		return []string{prefix + ".weight"}
+	case "beta":
+		return []string{prefix + ".bias"}
+	case "weight":
+		return []string{prefix + ".gamma"}
+	case "bias":
+		return []string{prefix + ".beta"}
+	default:
+		return nil
+	}
+}
+
+// SaveVarStore saves weights of `vs` to directory `dir` as `SafetensorsName` file.
+func SaveVarStore(vs *nn.VarStore, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return SaveSafetensors(vs, filepath.Join(dir, SafetensorsName), map[string]string{"format": "pt"})
+}
+
+// SaveModel saves model configuration and weights to directory `dir` so that
+// it can be loaded back with `Load` methods by passing `dir` as model name.
+//
+// `vs` is the var store holding model weights. It is nil if model was not created
+// with `Load` nor set with the model `SetVarStore` method, in which case an error is returned.
+func SaveModel(dir string, config pretrained.Config, vs *nn.VarStore) error {
+	if vs == nil {
+		err := fmt.Errorf("SaveModel() failed: model has no var store. Set the var store passed to the model constructor with the model `SetVarStore` method")
+		return err
+	}
+
+	if err := config.SavePretrained(dir); err != nil {
+		return err
+	}
+
+	return SaveVarStore(vs, dir)
+}
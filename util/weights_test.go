@@ -0,0 +1,213 @@
+package util_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+
+	"github.com/sugarme/transformer/pretrained"
+	"github.com/sugarme/transformer/util"
+)
+
+// saveBaseModel saves a model with an encoder layer, a pooler and a 2-label classifier.
+func saveBaseModel(t *testing.T, dir string) {
+	vs := nn.NewVarStore(gotch.CPU)
+	p := vs.Root()
+	nn.NewLinear(p.Sub("encoder"), 4, 4, nn.DefaultLinearConfig())
+	nn.NewLinear(p.Sub("pooler"), 4, 4, nn.DefaultLinearConfig())
+	nn.NewLinear(p.Sub("classifier"), 4, 2, nn.DefaultLinearConfig())
+
+	if err := util.SaveVarStore(vs, dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTaskModel creates a model with an encoder layer and a 3-label classifier.
+func newTaskModel() *nn.VarStore {
+	vs := nn.NewVarStore(gotch.CPU)
+	p := vs.Root()
+	nn.NewLinear(p.Sub("encoder"), 4, 4, nn.DefaultLinearConfig())
+	nn.NewLinear(p.Sub("classifier"), 4, 3, nn.DefaultLinearConfig())
+	return vs
+}
+
+func TestLoadVarStore_Modes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	saveBaseModel(t, dir)
+
+	wantReport := &pretrained.LoadReport{
+		UnexpectedKeys: []string{"pooler.bias", "pooler.weight"},
+		MismatchedKeys: []pretrained.ShapeMismatch{
+			{Key: "classifier.bias", ModelShape: []int64{3}, FileShape: []int64{2}},
+			{Key: "classifier.weight", ModelShape: []int64{3, 4}, FileShape: []int64{2, 4}},
+		},
+	}
+
+	// Strict
+	report, err := util.LoadVarStore(newTaskModel(), dir, util.LoadOptions{}, nil)
+	if !errors.Is(err, util.ErrShapeMismatch) {
+		t.Errorf("Want: %v\n", util.ErrShapeMismatch)
+		t.Errorf("Got: %v\n", err)
+	}
+	if !reflect.DeepEqual(wantReport, report) {
+		t.Errorf("Want: %v\n", wantReport)
+		t.Errorf("Got: %v\n", report)
+	}
+
+	// Ignore heads
+	vs := newTaskModel()
+	opts := util.LoadOptions{Mode: util.LoadIgnoreHeads, Heads: []string{"classifier"}}
+	report, err = util.LoadVarStore(vs, dir, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(wantReport, report) {
+		t.Errorf("Want: %v\n", wantReport)
+		t.Errorf("Got: %v\n", report)
+	}
+	if report.Complete() {
+		t.Errorf("Want incomplete report with mismatched classifier\n")
+	}
+
+	// Ignore heads does not cover other variables.
+	opts.Heads = []string{"class"}
+	if _, err := util.LoadVarStore(newTaskModel(), dir, opts, nil); !errors.Is(err, util.ErrShapeMismatch) {
+		t.Errorf("Want: %v\n", util.ErrShapeMismatch)
+		t.Errorf("Got: %v\n", err)
+	}
+}
+
+func TestLoadVarStore_Missing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	saveBaseModel(t, dir)
+
+	newModel := func() *nn.VarStore {
+		vs := nn.NewVarStore(gotch.CPU)
+		p := vs.Root()
+		nn.NewLinear(p.Sub("encoder"), 4, 4, nn.DefaultLinearConfig())
+		nn.NewLinear(p.Sub("decoder"), 4, 4, nn.DefaultLinearConfig())
+		return vs
+	}
+
+	if _, err := util.LoadVarStore(newModel(), dir, util.LoadOptions{}, nil); !errors.Is(err, util.ErrMissingWeight) {
+		t.Errorf("Want: %v\n", util.ErrMissingWeight)
+		t.Errorf("Got: %v\n", err)
+	}
+
+	report, err := util.LoadVarStore(newModel(), dir, util.LoadOptions{Mode: util.LoadLenient}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantMissing := []string{"decoder.bias", "decoder.weight"}
+	if !reflect.DeepEqual(wantMissing, report.MissingKeys) {
+		t.Errorf("Want: %v\n", wantMissing)
+		t.Errorf("Got: %v\n", report.MissingKeys)
+	}
+	wantUnexpected := []string{"classifier.bias", "classifier.weight", "pooler.bias", "pooler.weight"}
+	if !reflect.DeepEqual(wantUnexpected, report.UnexpectedKeys) {
+		t.Errorf("Want: %v\n", wantUnexpected)
+		t.Errorf("Got: %v\n", report.UnexpectedKeys)
+	}
+}
+
+func TestLoadVarStore_Rename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := nn.NewVarStore(gotch.CPU)
+	nn.NewLinear(src.Root().Sub("dense"), 4, 3, nn.DefaultLinearConfig())
+	if err := util.SaveVarStore(src, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	vs := nn.NewVarStore(gotch.CPU)
+	nn.NewLinear(vs.Root().Sub("bert").Sub("dense"), 4, 3, nn.DefaultLinearConfig())
+
+	rule, err := util.NewRenameRule(`^`, "bert.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := util.LoadVarStore(vs, dir, util.LoadOptions{Rename: []util.RenameRule{rule}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Complete() || len(report.UnexpectedKeys) > 0 {
+		t.Errorf("Want: %v\n", "all weights loaded")
+		t.Errorf("Got: %v\n", report)
+	}
+
+	want := src.Variables()["dense.weight"]
+	got := vs.Variables()["bert.dense.weight"]
+	if !util.Equal(&want, &got) {
+		t.Errorf("Want %q equal to %q\n", "bert.dense.weight", "dense.weight")
+	}
+
+	if _, err := util.NewRenameRule(`(`, ""); err == nil {
+		t.Errorf("Want error for invalid pattern\n")
+	}
+}
+
+func TestLoadVarStore_Fallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Local directory without safetensors file.
+	src := nn.NewVarStore(gotch.CPU)
+	nn.NewLinear(src.Root().Sub("dense"), 4, 3, nn.DefaultLinearConfig())
+	if err := src.Save(filepath.Join(dir, util.WeightName)); err != nil {
+		t.Fatal(err)
+	}
+	vs := nn.NewVarStore(gotch.CPU)
+	nn.NewLinear(vs.Root().Sub("dense"), 4, 3, nn.DefaultLinearConfig())
+	if _, err := util.LoadVarStore(vs, dir, util.LoadOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors other than missing files are returned.
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			requests = append(requests, r.URL.Path)
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	defaultResolver := util.DefaultResolver
+	defer func() { util.DefaultResolver = defaultResolver }()
+	util.DefaultResolver = &util.HubResolver{BaseURL: server.URL, CacheDir: dir, Client: server.Client()}
+
+	_, err = util.LoadVarStore(vs, "org/model", util.LoadOptions{}, nil)
+	if err == nil || errors.Is(err, util.ErrMissingWeight) {
+		t.Errorf("Want: %v\n", "download error")
+		t.Errorf("Got: %v\n", err)
+	}
+	want := []string{"/org/model/resolve/main/" + util.SafetensorsName}
+	if !reflect.DeepEqual(want, requests) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", requests)
+	}
+}